@@ -0,0 +1,225 @@
+package alerts
+
+import (
+	"encoding/json"
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/notify"
+)
+
+// Alert states
+const (
+	StateOK       = "ok"
+	StateActive   = "active"
+	StateResolved = "resolved"
+)
+
+// Probe returns the current value of the measured quantity
+type Probe func() float64
+
+// Rule fires while its probe value exceeds the threshold. A threshold of
+// zero or less disables the rule.
+type Rule struct {
+	Name        string
+	Description string
+	Unit        string
+	Threshold   float64
+	Probe       Probe
+}
+
+// Alert is the evaluated state of one rule
+type Alert struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	Value       float64    `json:"value"`
+	Threshold   float64    `json:"threshold"`
+	Unit        string     `json:"unit"`
+	ActiveSince *time.Time `json:"active_since,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	EvaluatedAt time.Time  `json:"evaluated_at"`
+}
+
+// Evaluator periodically checks rules and tracks active/resolved alerts,
+// optionally posting state transitions to a webhook
+type Evaluator struct {
+	mu         sync.Mutex
+	rules      []Rule
+	alerts     map[string]*Alert
+	webhookURL string
+	webhooks   *notify.Dispatcher
+}
+
+// NewEvaluator creates an evaluator; webhookURL may be empty. Transitions
+// are only posted once a dispatcher is set with SetDispatcher.
+func NewEvaluator(webhookURL string) *Evaluator {
+	return &Evaluator{
+		rules:      make([]Rule, 0),
+		alerts:     make(map[string]*Alert),
+		webhookURL: webhookURL,
+	}
+}
+
+// SetDispatcher delivers webhook posts through d, keyed by alert name so a
+// flapping alert queues only its latest state
+func (e *Evaluator) SetDispatcher(d *notify.Dispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.webhooks = d
+}
+
+// AddRule registers a rule; disabled rules (threshold <= 0) are ignored
+func (e *Evaluator) AddRule(rule Rule) {
+	if rule.Threshold <= 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = append(e.rules, rule)
+	e.alerts[rule.Name] = &Alert{
+		Name:        rule.Name,
+		Description: rule.Description,
+		State:       StateOK,
+		Threshold:   rule.Threshold,
+		Unit:        rule.Unit,
+	}
+}
+
+// Evaluate runs every probe once and updates alert states
+func (e *Evaluator) Evaluate() {
+	e.mu.Lock()
+	rules := make([]Rule, len(e.rules))
+	copy(rules, e.rules)
+	e.mu.Unlock()
+
+	transitions := make([]Alert, 0)
+	now := time.Now()
+
+	for _, rule := range rules {
+		value := rule.Probe()
+
+		e.mu.Lock()
+		alert := e.alerts[rule.Name]
+		alert.Value = value
+		alert.EvaluatedAt = now
+
+		firing := value > rule.Threshold
+		switch {
+		case firing && alert.State != StateActive:
+			alert.State = StateActive
+			since := now
+			alert.ActiveSince = &since
+			alert.ResolvedAt = nil
+			transitions = append(transitions, *alert)
+		case !firing && alert.State == StateActive:
+			alert.State = StateResolved
+			resolved := now
+			alert.ResolvedAt = &resolved
+			transitions = append(transitions, *alert)
+		}
+		e.mu.Unlock()
+	}
+
+	e.mu.Lock()
+	webhooks := e.webhooks
+	e.mu.Unlock()
+
+	for _, alert := range transitions {
+		log.Printf("Alert %s is %s (value %.3f %s, threshold %.3f)\n", alert.Name, alert.State, alert.Value, alert.Unit, alert.Threshold)
+		if e.webhookURL == "" || webhooks == nil {
+			continue
+		}
+		body, err := json.Marshal(alert)
+		if err != nil {
+			continue
+		}
+		if !webhooks.Send(notify.Message{URL: e.webhookURL, Key: "alert:" + alert.Name, Body: body}) {
+			log.Printf("Alert webhook for %s dropped: delivery queue full\n", alert.Name)
+		}
+	}
+}
+
+// Alerts returns every alert, active ones first
+func (e *Evaluator) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		list = append(list, *alert)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if (list[i].State == StateActive) != (list[j].State == StateActive) {
+			return list[i].State == StateActive
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// ActiveCount returns the number of alerts currently firing
+func (e *Evaluator) ActiveCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	count := 0
+	for _, alert := range e.alerts {
+		if alert.State == StateActive {
+			count++
+		}
+	}
+	return count
+}
+
+// MaxP99Probe reports the highest p99 latency, in milliseconds, across every
+// histogram in the registry
+func MaxP99Probe(registry *metrics.Registry) Probe {
+	return func() float64 {
+		max := 0.0
+		for _, h := range registry.Histograms() {
+			if p99 := h.Quantile(0.99) * 1000; p99 > max {
+				max = p99
+			}
+		}
+		return max
+	}
+}
+
+// HeapProbe reports heap memory in use, in megabytes
+func HeapProbe() Probe {
+	return func() float64 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return float64(m.HeapInuse) / (1024 * 1024)
+	}
+}
+
+// ErrorRateProbe reports the fraction of requests that failed since the
+// previous evaluation
+func ErrorRateProbe(total, failed *metrics.Counter) Probe {
+	var mu sync.Mutex
+	lastTotal, lastFailed := total.Value(), failed.Value()
+
+	return func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+
+		currentTotal, currentFailed := total.Value(), failed.Value()
+		requests := currentTotal - lastTotal
+		errors := currentFailed - lastFailed
+		lastTotal, lastFailed = currentTotal, currentFailed
+
+		if requests == 0 {
+			return 0
+		}
+		return float64(errors) / float64(requests)
+	}
+}
@@ -0,0 +1,48 @@
+// Command fixtures writes a generated user set as a snapshot the server
+// loads on startup, for local development against realistic data:
+//
+//	go run ./cmd/fixtures -count 50000 -distribution skewed
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"leaderboard-backend/fixtures"
+	"leaderboard-backend/store"
+)
+
+func main() {
+	count := flag.Int("count", 10000, "number of users to generate")
+	distribution := flag.String("distribution", string(fixtures.Skewed), "rating distribution: uniform, normal, skewed, tied or flat")
+	seed := flag.Int64("seed", 1, "seed for the generated ratings")
+	out := flag.String("out", "data/leaderboard.json", "snapshot file to write")
+	force := flag.Bool("force", false, "overwrite an existing snapshot")
+	flag.Parse()
+
+	dist, err := fixtures.ParseDistribution(*distribution)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	persistence := store.NewPersistence(*out)
+	if persistence.Exists() && !*force {
+		log.Fatalf("%s already exists; pass -force to replace it", *out)
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	ms := store.NewMemoryStore(store.NewRatingBucketIndex())
+	if _, err := fixtures.Load(ms, fixtures.Set{Count: *count, Distribution: dist, Seed: *seed}); err != nil {
+		log.Fatal(err)
+	}
+	if err := persistence.Save(ms); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Wrote %d %s users to %s\n", *count, dist, *out)
+}
@@ -2,15 +2,155 @@ package config
 
 import (
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
-	Port           string
-	InitialUsers   int
-	MinRating      int
-	MaxRating      int
-	UpdateInterval int // milliseconds between simulated updates
+	Port               string
+	InitialUsers       int
+	MinRating          int
+	MaxRating          int
+	UpdateInterval     int    // milliseconds between simulated updates
+	AutoSeed           bool   // seed InitialUsers and start the simulator when booting with no saved data
+	AutosaveInterval   int    // seconds between timed snapshots (0 disables)
+	AutosaveWrites     int    // mutations that trigger an early snapshot (0 disables)
+	PersistenceShards  int    // files a save is split across, written and read in parallel
+	PersistenceWorkers int    // shard files encoded or decoded at once
+	SyncPolicy         string // when saves fsync: "never", "on-save" or "wal-batch"
+	WALEnabled         bool   // journal mutations for crash recovery
+	WALFlushInterval   int    // milliseconds between write-ahead log batches
+	DatabaseURL        string // PostgreSQL DSN; set to save there instead of JSON files
+	OnlineWindow       int    // seconds since last heartbeat a user still counts as online
+	Environment        string
+	SeedCooldown       int    // minimum seconds between calls to /api/seed
+	JobLockDir         string // shared directory for cross-replica job leases ("" = single replica)
+	ReplicaID          string
+	Alerts             AlertConfig
+	SnapshotDir        string // where named snapshots for /api/snapshots are kept
+	ArchiveDir         string // where final standings of closed seasons are kept
+	ArchiveCache       int    // archived seasons kept loaded in memory (0 = always read from disk)
+	SeasonBase         int    // rating season resets move towards unless the request names one
+	AchievementsFile   string // where unlocked achievements and win streaks are kept
+	SkipListImpl       string // ranking structure: "locked", "concurrent" (lock-free reads) or "btree"
+	RequestTimeout     int    // milliseconds before a request's work is abandoned (0 disables)
+	StreamDebounce     int    // milliseconds of changes gathered into one leaderboard stream update
+	TopPageInterval    int    // milliseconds between first-page broadcasts to /api/ws clients (0 disables)
+	SkipList           SkipListConfig
+	Chaos              ChaosConfig
+	FinalSigningKey    string // HMAC key for /api/leaderboard/final signatures ("" = digest only)
+	ResponseSigning    string // "ed25519", "hmac-sha256" or "" to leave responses unsigned
+	ResponseSigningKey string // base64 Ed25519 seed or HMAC secret
+	SearchCandidates   int    // candidate IDs one search may examine (0 = unlimited)
+	SearchBudget       int    // milliseconds one search may scan for (0 = unlimited)
+	MergeStrategy      string // default rating strategy for account merges
+	RatingRangeMode    string // "clamp" or "strict" for ratings outside 100-5000
+	RankingMode        string // "competition" (1, 2, 2, 4) or "dense" (1, 2, 2, 3)
+	EloK               int    // Elo K-factor: the most one match moves a rating
+	RatingSystem       string // how match results are rated: "elo" or "glicko2"
+	GlickoPeriod       int    // seconds in a Glicko-2 rating period; RD widens for users who sat one out (0 disables)
+	RatingHistory      int    // rating changes kept per user for /api/users/{id}/history (0 disables)
+	RankSnapshots      int    // seconds between the rank snapshots rank_change is measured from (0 disables)
+	BoardsDir          string // where named leaderboards from /api/boards are kept
+	MaxBoards          int    // named leaderboards besides the default one (0 = unlimited)
+	Usernames          UsernameConfig
+	Statsd             StatsdConfig
+	Backpressure       BackpressureConfig
+	Webhooks           WebhookConfig
+	IPFilter           IPFilterConfig
+	Auth               AuthConfig
+}
+
+// SkipListConfig shapes the skip lists that rank users
+type SkipListConfig struct {
+	MaxLevel      int     // tower height limit (0 = picked from ExpectedUsers)
+	Probability   float64 // chance a tower grows another level
+	ExpectedUsers int     // users the skip list should stay fast up to
+}
+
+// IPFilterConfig lists client IPs and CIDR ranges let in or turned away
+// before rate limiting
+type IPFilterConfig struct {
+	Allow []string // only these may make requests (empty = everyone not denied)
+	Deny  []string // refused even when allowed
+}
+
+// AuthConfig holds the API keys that guard mutating endpoints and the key
+// bearer tokens are verified with
+type AuthConfig struct {
+	APIKeys      []string // "role:key" entries; once any is set, writes need a key
+	APIKeyReads  bool     // reads need at least a reader key too
+	JWTAlgorithm string   // "HS256" or "RS256"
+	JWTKey       string   // HMAC secret, or RSA public key PEM or file ("" ignores tokens)
+	JWTNoExpiry  bool     // accept tokens without an "exp" claim
+}
+
+// WebhookConfig sizes the worker pool that delivers alert and change
+// webhooks off the request path
+type WebhookConfig struct {
+	EventURL    string // receives every store change as JSON ("" = disabled)
+	Workers     int    // concurrent deliveries
+	QueueSize   int    // messages waiting for a worker before new ones are dropped
+	Policy      string // "aggregate" (latest message per key) or "drop"
+	MaxAttempts int    // tries before a message is dead-lettered
+	Timeout     int    // milliseconds per attempt
+
+	DeadLetterFile  string // where undelivered messages are kept until replayed
+	DeadLetterLimit int    // dead letters kept before the oldest are discarded
+}
+
+// BackpressureConfig sets when write requests are refused because internal
+// queues are backing up; a zero fraction disables that level
+type BackpressureConfig struct {
+	Soft       float64 // queue fill fraction answered with 429
+	Hard       float64 // queue fill fraction answered with 503
+	WALEntries int     // unflushed write-ahead log entries counted as full
+}
+
+// StatsdConfig enables pushing metrics to a StatsD or DogStatsD agent, for
+// servers that sit behind NAT and can't be scraped
+type StatsdConfig struct {
+	Addr     string   // agent "host:port" over UDP ("" = disabled)
+	Prefix   string   // prepended to every metric name
+	Interval int      // seconds between pushes
+	Tags     []string // DogStatsD tags such as "env:prod" (empty = plain StatsD)
+}
+
+// UsernameConfig is the policy new and changed usernames must follow
+type UsernameConfig struct {
+	MinLength     int
+	MaxLength     int
+	Pattern       string   // regular expression the whole name must match
+	Blocklist     []string // words that may not appear anywhere in a name
+	BlocklistFile string   // more blocked words, one per line ("" = none)
+	Reserved      []string // names nobody may take (nil = built-in list)
+}
+
+// ChaosConfig holds the fault injection settings applied at startup; it is
+// off unless CHAOS_ENABLED=true and can't be enabled in production
+type ChaosConfig struct {
+	Enabled     bool
+	LatencyMs   int
+	LatencyRate float64  // fraction of requests delayed by LatencyMs
+	ErrorRate   float64  // fraction of requests answered with ErrorStatus
+	ErrorStatus int      // 5xx status for injected errors
+	DropRate    float64  // fraction of requests whose connection is closed unanswered
+	Paths       []string // path prefixes to target (empty = all)
+}
+
+// AlertConfig holds alert thresholds; a zero threshold disables that alert
+type AlertConfig struct {
+	P99LatencyMs   float64 // p99 of store operation latency
+	MemoryMB       float64 // heap in use
+	ErrorRate      float64 // fraction of HTTP requests answered with 5xx
+	SimulatorStall int     // seconds a running simulator may go without an update
+	WebhookURL     string  // receives alert state changes as JSON ("" = disabled)
+}
+
+// IsProduction reports whether the server runs with APP_ENV=production
+func (c *Config) IsProduction() bool {
+	return c.Environment == "production"
 }
 
 func Load() *Config {
@@ -33,11 +173,390 @@ func Load() *Config {
 		}
 	}
 
+	autosaveInterval := 60
+	if val := os.Getenv("AUTOSAVE_INTERVAL"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			autosaveInterval = parsed
+		}
+	}
+
+	autosaveWrites := 10000
+	if val := os.Getenv("AUTOSAVE_WRITES"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			autosaveWrites = parsed
+		}
+	}
+
+	persistenceShards := 1
+	if val := os.Getenv("PERSISTENCE_SHARDS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			persistenceShards = parsed
+		}
+	}
+
+	persistenceWorkers := runtime.NumCPU()
+	if val := os.Getenv("PERSISTENCE_WORKERS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			persistenceWorkers = parsed
+		}
+	}
+
+	syncPolicy := os.Getenv("DURABILITY")
+	if syncPolicy == "" {
+		syncPolicy = "on-save"
+	}
+
+	walFlushInterval := 100
+	if val := os.Getenv("WAL_FLUSH_MS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			walFlushInterval = parsed
+		}
+	}
+
+	onlineWindow := 60
+	if val := os.Getenv("ONLINE_WINDOW"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			onlineWindow = parsed
+		}
+	}
+
+	environment := os.Getenv("APP_ENV")
+	if environment == "" {
+		environment = "development"
+	}
+
+	seedCooldown := 300
+	if val := os.Getenv("SEED_COOLDOWN"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			seedCooldown = parsed
+		}
+	}
+
+	alerts := AlertConfig{
+		P99LatencyMs:   floatEnv("ALERT_P99_MS", 50),
+		MemoryMB:       floatEnv("ALERT_MEMORY_MB", 512),
+		ErrorRate:      floatEnv("ALERT_ERROR_RATE", 0.05),
+		SimulatorStall: int(floatEnv("ALERT_SIMULATOR_STALL", 10)),
+		WebhookURL:     os.Getenv("ALERT_WEBHOOK_URL"),
+	}
+
+	snapshotDir := os.Getenv("SNAPSHOT_DIR")
+	if snapshotDir == "" {
+		snapshotDir = "data/snapshots"
+	}
+
+	archiveDir := os.Getenv("ARCHIVE_DIR")
+	if archiveDir == "" {
+		archiveDir = "data/seasons"
+	}
+
+	achievementsFile := os.Getenv("ACHIEVEMENTS_FILE")
+	if achievementsFile == "" {
+		achievementsFile = "data/achievements.json"
+	}
+
+	archiveCache := 4
+	if val := os.Getenv("ARCHIVE_CACHE_SEASONS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			archiveCache = parsed
+		}
+	}
+
+	seasonBase := 1500
+	if val := os.Getenv("SEASON_BASE_RATING"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			seasonBase = parsed
+		}
+	}
+
+	replicaID := os.Getenv("REPLICA_ID")
+	if replicaID == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			replicaID = hostname
+		} else {
+			replicaID = "replica-" + strconv.Itoa(os.Getpid())
+		}
+	}
+
+	requestTimeout := 10000
+	if val := os.Getenv("REQUEST_TIMEOUT_MS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			requestTimeout = parsed
+		}
+	}
+
+	streamDebounce := 500
+	if val := os.Getenv("LEADERBOARD_STREAM_DEBOUNCE_MS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			streamDebounce = parsed
+		}
+	}
+
+	topPageInterval := 1000
+	if val := os.Getenv("LIVE_TOP_PAGE_INTERVAL_MS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			topPageInterval = parsed
+		}
+	}
+
+	chaos := ChaosConfig{
+		Enabled:     os.Getenv("CHAOS_ENABLED") == "true",
+		LatencyMs:   int(floatEnv("CHAOS_LATENCY_MS", 0)),
+		LatencyRate: floatEnv("CHAOS_LATENCY_RATE", 0),
+		ErrorRate:   floatEnv("CHAOS_ERROR_RATE", 0),
+		ErrorStatus: int(floatEnv("CHAOS_ERROR_STATUS", 503)),
+		DropRate:    floatEnv("CHAOS_DROP_RATE", 0),
+	}
+	chaos.Paths = listEnv("CHAOS_PATHS")
+
+	usernames := UsernameConfig{
+		MinLength:     3,
+		MaxLength:     24,
+		Pattern:       `^[A-Za-z0-9_.-]+$`,
+		Blocklist:     listEnv("USERNAME_BLOCKLIST"),
+		BlocklistFile: os.Getenv("USERNAME_BLOCKLIST_FILE"),
+		Reserved:      listEnv("USERNAME_RESERVED"),
+	}
+	if val := os.Getenv("USERNAME_MIN_LENGTH"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			usernames.MinLength = parsed
+		}
+	}
+	if val := os.Getenv("USERNAME_MAX_LENGTH"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			usernames.MaxLength = parsed
+		}
+	}
+	if val := os.Getenv("USERNAME_PATTERN"); val != "" {
+		usernames.Pattern = val
+	}
+
+	searchMaxCandidates := 50000
+	if val := os.Getenv("SEARCH_MAX_CANDIDATES"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			searchMaxCandidates = parsed
+		}
+	}
+
+	searchBudget := 25
+	if val := os.Getenv("SEARCH_BUDGET_MS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			searchBudget = parsed
+		}
+	}
+
+	ratingHistory := 100
+	if val := os.Getenv("RATING_HISTORY_SIZE"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			ratingHistory = parsed
+		}
+	}
+
+	eloK := 32
+	if val := os.Getenv("ELO_K_FACTOR"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			eloK = parsed
+		}
+	}
+
+	glickoPeriod := 86400
+	if val := os.Getenv("GLICKO_RATING_PERIOD"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			glickoPeriod = parsed
+		}
+	}
+
+	rankSnapshotInterval := 300
+	if val := os.Getenv("RANK_SNAPSHOT_INTERVAL"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			rankSnapshotInterval = parsed
+		}
+	}
+
+	boardsDir := os.Getenv("BOARDS_DIR")
+	if boardsDir == "" {
+		boardsDir = "data/boards"
+	}
+
+	maxBoards := 32
+	if val := os.Getenv("MAX_BOARDS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			maxBoards = parsed
+		}
+	}
+
+	statsd := StatsdConfig{
+		Addr:     os.Getenv("STATSD_ADDR"),
+		Prefix:   "leaderboard.",
+		Interval: 10,
+		Tags:     listEnv("STATSD_TAGS"),
+	}
+	if val, ok := os.LookupEnv("STATSD_PREFIX"); ok {
+		statsd.Prefix = val
+	}
+	if val := os.Getenv("STATSD_INTERVAL"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			statsd.Interval = parsed
+		}
+	}
+
+	backpressure := BackpressureConfig{
+		Soft:       floatEnv("BACKPRESSURE_SOFT", 0.75),
+		Hard:       floatEnv("BACKPRESSURE_HARD", 0.95),
+		WALEntries: 50000,
+	}
+	if val := os.Getenv("BACKPRESSURE_WAL_ENTRIES"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			backpressure.WALEntries = parsed
+		}
+	}
+
+	webhooks := WebhookConfig{
+		EventURL:    os.Getenv("EVENT_WEBHOOK_URL"),
+		Workers:     4,
+		QueueSize:   1024,
+		Policy:      "aggregate",
+		MaxAttempts: 5,
+		Timeout:     5000,
+
+		DeadLetterFile:  os.Getenv("DEADLETTER_FILE"),
+		DeadLetterLimit: 10000,
+	}
+	if webhooks.DeadLetterFile == "" {
+		webhooks.DeadLetterFile = "data/deadletters.jsonl"
+	}
+	if val := os.Getenv("DEADLETTER_LIMIT"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			webhooks.DeadLetterLimit = parsed
+		}
+	}
+	if val := os.Getenv("WEBHOOK_WORKERS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			webhooks.Workers = parsed
+		}
+	}
+	if val := os.Getenv("WEBHOOK_QUEUE"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			webhooks.QueueSize = parsed
+		}
+	}
+	if val := os.Getenv("WEBHOOK_POLICY"); val != "" {
+		webhooks.Policy = val
+	}
+	if val := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			webhooks.MaxAttempts = parsed
+		}
+	}
+	if val := os.Getenv("WEBHOOK_TIMEOUT_MS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			webhooks.Timeout = parsed
+		}
+	}
+
+	skipListImpl := os.Getenv("SKIPLIST_IMPL")
+	if skipListImpl == "" {
+		skipListImpl = "locked"
+	}
+
+	skipList := SkipListConfig{
+		Probability:   floatEnv("SKIPLIST_PROBABILITY", 0.25),
+		ExpectedUsers: 10_000_000,
+	}
+	if val := os.Getenv("SKIPLIST_MAX_LEVEL"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed >= 0 {
+			skipList.MaxLevel = parsed
+		}
+	}
+	if val := os.Getenv("SKIPLIST_EXPECTED_USERS"); val != "" {
+		if parsed, err := strconv.Atoi(val); err == nil && parsed > 0 {
+			skipList.ExpectedUsers = parsed
+		}
+	}
+
 	return &Config{
-		Port:           port,
-		InitialUsers:   initialUsers,
-		MinRating:      100,
-		MaxRating:      5000,
-		UpdateInterval: updateInterval,
+		Port:               port,
+		InitialUsers:       initialUsers,
+		AutoSeed:           os.Getenv("AUTO_SEED") == "true",
+		MinRating:          100,
+		MaxRating:          5000,
+		UpdateInterval:     updateInterval,
+		AutosaveInterval:   autosaveInterval,
+		AutosaveWrites:     autosaveWrites,
+		PersistenceShards:  persistenceShards,
+		PersistenceWorkers: persistenceWorkers,
+		SyncPolicy:         syncPolicy,
+		WALEnabled:         os.Getenv("WAL_ENABLED") == "true",
+		WALFlushInterval:   walFlushInterval,
+		DatabaseURL:        os.Getenv("DATABASE_URL"),
+		OnlineWindow:       onlineWindow,
+		Environment:        environment,
+		SeedCooldown:       seedCooldown,
+		JobLockDir:         os.Getenv("JOB_LOCK_DIR"),
+		ReplicaID:          replicaID,
+		Alerts:             alerts,
+		SnapshotDir:        snapshotDir,
+		ArchiveDir:         archiveDir,
+		ArchiveCache:       archiveCache,
+		SeasonBase:         seasonBase,
+		AchievementsFile:   achievementsFile,
+		SkipListImpl:       skipListImpl,
+		SkipList:           skipList,
+		RequestTimeout:     requestTimeout,
+		StreamDebounce:     streamDebounce,
+		TopPageInterval:    topPageInterval,
+		Chaos:              chaos,
+		FinalSigningKey:    os.Getenv("FINAL_SIGNING_KEY"),
+		ResponseSigning:    os.Getenv("RESPONSE_SIGNING"),
+		ResponseSigningKey: os.Getenv("RESPONSE_SIGNING_KEY"),
+		SearchCandidates:   searchMaxCandidates,
+		SearchBudget:       searchBudget,
+		MergeStrategy:      os.Getenv("MERGE_RATING_STRATEGY"),
+		RatingRangeMode:    os.Getenv("RATING_RANGE_MODE"),
+		RankingMode:        os.Getenv("RANKING_MODE"),
+		EloK:               eloK,
+		RatingSystem:       os.Getenv("RATING_SYSTEM"),
+		GlickoPeriod:       glickoPeriod,
+		RatingHistory:      ratingHistory,
+		RankSnapshots:      rankSnapshotInterval,
+		BoardsDir:          boardsDir,
+		MaxBoards:          maxBoards,
+		Usernames:          usernames,
+		Statsd:             statsd,
+		Backpressure:       backpressure,
+		Webhooks:           webhooks,
+		IPFilter: IPFilterConfig{
+			Allow: listEnv("IP_ALLOWLIST"),
+			Deny:  listEnv("IP_DENYLIST"),
+		},
+		Auth: AuthConfig{
+			APIKeys:      listEnv("API_KEYS"),
+			APIKeyReads:  os.Getenv("API_KEY_READS") == "true",
+			JWTAlgorithm: os.Getenv("JWT_ALGORITHM"),
+			JWTKey:       os.Getenv("JWT_KEY"),
+			JWTNoExpiry:  os.Getenv("JWT_ALLOW_NO_EXPIRY") == "true",
+		},
+	}
+}
+
+// floatEnv reads a non-negative float from the environment, falling back to def
+func floatEnv(name string, def float64) float64 {
+	if val := os.Getenv(name); val != "" {
+		if parsed, err := strconv.ParseFloat(val, 64); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return def
+}
+
+// listEnv splits a comma-separated variable, dropping blank items; unset
+// gives nil
+func listEnv(name string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
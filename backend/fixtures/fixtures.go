@@ -0,0 +1,166 @@
+// Package fixtures builds deterministic, realistic user sets for tests and
+// local development: readable IDs, real-looking usernames and rating
+// distributions shaped like an actual player base.
+package fixtures
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+var (
+	//go:embed testdata/first_names.txt
+	firstNamesFile string
+	//go:embed testdata/last_names.txt
+	lastNamesFile string
+
+	firstNames = strings.Fields(firstNamesFile)
+	lastNames  = strings.Fields(lastNamesFile)
+)
+
+// Rating bounds used when a Set leaves them unset; they match the server's
+// default configuration
+const (
+	DefaultMinRating = 100
+	DefaultMaxRating = 5000
+)
+
+// Distribution shapes the ratings of a generated set
+type Distribution string
+
+const (
+	// Uniform spreads ratings evenly over the range
+	Uniform Distribution = "uniform"
+	// Normal clusters ratings around the middle of the range
+	Normal Distribution = "normal"
+	// Skewed puts most players near the bottom with a long tail of strong
+	// players, the shape of a real ladder
+	Skewed Distribution = "skewed"
+	// Tied draws ratings from a handful of values so most users share a rank
+	Tied Distribution = "tied"
+	// Flat gives every user the same rating
+	Flat Distribution = "flat"
+)
+
+// ParseDistribution validates a distribution name
+func ParseDistribution(name string) (Distribution, error) {
+	switch d := Distribution(name); d {
+	case Uniform, Normal, Skewed, Tied, Flat:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown distribution %q (want %q, %q, %q, %q or %q)", name, Uniform, Normal, Skewed, Tied, Flat)
+}
+
+// Set describes a generated user set. The same Set always produces the same
+// users.
+type Set struct {
+	Prefix       string       // ID prefix; defaults to "user"
+	Count        int          // number of users
+	Distribution Distribution // defaults to Uniform
+	MinRating    int          // defaults to DefaultMinRating
+	MaxRating    int          // defaults to DefaultMaxRating
+	Rating       int          // the shared rating for Flat; defaults to the middle of the range
+	Seed         int64        // varies the ratings drawn; usernames depend only on the index
+}
+
+// ID returns the ID of the i-th fixture user under prefix, e.g. "user-00042"
+func ID(prefix string, i int) string {
+	return fmt.Sprintf("%s-%05d", prefix, i)
+}
+
+// Username returns a realistic username that is unique for every index
+func Username(i int) string {
+	first := firstNames[i%len(firstNames)]
+	last := lastNames[(i/len(firstNames))%len(lastNames)]
+	if round := i / (len(firstNames) * len(lastNames)); round > 0 {
+		return fmt.Sprintf("%s_%s%d", first, last, round)
+	}
+	return first + "_" + last
+}
+
+// Users generates the users of set
+func Users(set Set) []*models.User {
+	set = withDefaults(set)
+	r := rand.New(rand.NewSource(set.Seed))
+
+	users := make([]*models.User, set.Count)
+	for i := range users {
+		users[i] = &models.User{
+			ID:       ID(set.Prefix, i),
+			Username: Username(i),
+			Rating:   rating(set, r),
+		}
+	}
+	return users
+}
+
+// Load generates the users of set and adds them to s, returning them in
+// generation order
+func Load(s *store.MemoryStore, set Set) ([]*models.User, error) {
+	users := Users(set)
+	for _, user := range users {
+		// The store keeps the pointer, so callers get their own copies
+		stored := *user
+		if err := s.AddUserFrom(&stored, store.SourceImport); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func withDefaults(set Set) Set {
+	if set.Prefix == "" {
+		set.Prefix = "user"
+	}
+	if set.Distribution == "" {
+		set.Distribution = Uniform
+	}
+	if set.MinRating == 0 {
+		set.MinRating = DefaultMinRating
+	}
+	if set.MaxRating == 0 {
+		set.MaxRating = DefaultMaxRating
+	}
+	if set.Rating == 0 {
+		set.Rating = (set.MinRating + set.MaxRating) / 2
+	}
+	return set
+}
+
+// tiedRatings is how many distinct values the Tied distribution uses
+const tiedRatings = 8
+
+func rating(set Set, r *rand.Rand) int {
+	span := float64(set.MaxRating - set.MinRating)
+
+	var value float64
+	switch set.Distribution {
+	case Normal:
+		value = float64(set.MinRating) + span/2 + r.NormFloat64()*span/6
+	case Skewed:
+		// Exponential with a mean at a sixth of the range
+		value = float64(set.MinRating) + r.ExpFloat64()*span/6
+	case Tied:
+		step := span / (tiedRatings - 1)
+		value = float64(set.MinRating) + float64(r.Intn(tiedRatings))*step
+	case Flat:
+		return set.Rating
+	default:
+		value = float64(set.MinRating) + r.Float64()*(span+1)
+	}
+
+	rating := int(math.Floor(value))
+	if rating < set.MinRating {
+		rating = set.MinRating
+	}
+	if rating > set.MaxRating {
+		rating = set.MaxRating
+	}
+	return rating
+}
@@ -5,6 +5,8 @@ go 1.21
 require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.3
+	github.com/lib/pq v1.10.9
 	github.com/rs/cors v1.10.1
 	golang.org/x/time v0.5.0
 )
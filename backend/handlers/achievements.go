@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+
+	"github.com/gorilla/mux"
+)
+
+type AchievementsHandler struct {
+	service *services.AchievementService
+}
+
+func NewAchievementsHandler(service *services.AchievementService) *AchievementsHandler {
+	return &AchievementsHandler{service: service}
+}
+
+// GetAchievements lists what a user has unlocked and their win streak
+func (h *AchievementsHandler) GetAchievements(w http.ResponseWriter, r *http.Request) {
+	response, err := h.service.GetAchievements(r.Context(), mux.Vars(r)["id"])
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+	"leaderboard-backend/notify"
+	"leaderboard-backend/scheduler"
+	"leaderboard-backend/services"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBulkDeleteIDs caps explicit ID lists for bulk delete
+const maxBulkDeleteIDs = 10000
+
+type AdminHandler struct {
+	userService *services.UserService
+	resetGuard  *services.ResetGuard
+	jobs        *scheduler.Scheduler
+	chaos       *middleware.Chaos // optional
+	deadLetters *notify.DeadLetterQueue
+	webhooks    *notify.Dispatcher
+	ipFilter    *middleware.IPFilter // optional
+}
+
+func NewAdminHandler(userService *services.UserService, jobs *scheduler.Scheduler) *AdminHandler {
+	return &AdminHandler{
+		userService: userService,
+		resetGuard:  services.NewResetGuard(services.ResetTokenTTL),
+		jobs:        jobs,
+	}
+}
+
+// SetChaos enables the fault injection endpoints
+func (h *AdminHandler) SetChaos(chaos *middleware.Chaos) {
+	h.chaos = chaos
+}
+
+// SetDeadLetters enables the dead letter endpoints; replays are delivered
+// through d
+func (h *AdminHandler) SetDeadLetters(q *notify.DeadLetterQueue, d *notify.Dispatcher) {
+	h.deadLetters = q
+	h.webhooks = d
+}
+
+// SetIPFilter enables the IP filter endpoints
+func (h *AdminHandler) SetIPFilter(f *middleware.IPFilter) {
+	h.ipFilter = f
+}
+
+// BulkDeleteUsers removes users by explicit ID list or by filter
+func (h *AdminHandler) BulkDeleteUsers(w http.ResponseWriter, r *http.Request) {
+	var req models.BulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	ids := req.IDs
+	if req.Filter != nil {
+		if len(ids) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Specify either ids or filter, not both",
+			})
+			return
+		}
+		if req.Filter.RatingBelow <= 0 && req.Filter.InactiveDays <= 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Filter must set rating_below and/or inactive_days",
+			})
+			return
+		}
+		matching, err := h.userService.FindUsersMatching(r.Context(), *req.Filter)
+		if writeContextError(w, err) {
+			return
+		}
+		ids = matching
+	} else if len(ids) == 0 || len(ids) > maxBulkDeleteIDs {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Provide between 1 and 10000 ids, or a filter",
+		})
+		return
+	}
+
+	response, err := h.userService.DeleteUsers(r.Context(), ids)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// MergeUsers folds the account {dupe} into {keep}. An optional JSON body
+// picks the rating strategy; see models.MergeUsersRequest.
+func (h *AdminHandler) MergeUsers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req models.MergeUsersRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Invalid JSON body",
+			})
+			return
+		}
+	}
+
+	strategy := services.MergeStrategy("")
+	if req.RatingStrategy != "" {
+		parsed, err := services.ParseMergeStrategy(req.RatingStrategy)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_strategy",
+				Message: err.Error(),
+			})
+			return
+		}
+		strategy = parsed
+	}
+	if vars["keep"] == vars["dupe"] {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Cannot merge a user into itself",
+		})
+		return
+	}
+
+	response, err := h.userService.MergeUsers(r.Context(), vars["keep"], vars["dupe"], strategy)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetBot flags or unflags the user {id} as a bot or simulator account, so
+// ?exclude_bots=true can hide them. The body is {"bot": true} or false.
+func (h *AdminHandler) SetBot(w http.ResponseWriter, r *http.Request) {
+	var req models.SetBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Bot == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Body must be {\"bot\": true} or {\"bot\": false}",
+		})
+		return
+	}
+
+	user, err := h.userService.SetBot(r.Context(), mux.Vars(r)["id"], *req.Bot)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// Reset wipes all data in two steps: a call without a token returns a
+// confirmation token, and a second call echoing that token within its TTL
+// performs the reset
+func (h *AdminHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: "Invalid JSON body",
+			})
+			return
+		}
+	}
+
+	if req.ConfirmToken == "" {
+		token, expiresAt, err := h.resetGuard.Issue()
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "token_failed",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.ResetChallengeResponse{
+			Message:      "Repeat this request with confirm_token to erase all data",
+			ConfirmToken: token,
+			ExpiresAt:    expiresAt,
+		})
+		return
+	}
+
+	if err := h.resetGuard.Consume(req.ConfirmToken); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_token",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	removed := h.userService.GetUserCount()
+	h.userService.Clear()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ResetResponse{
+		Message:      "All data cleared",
+		UsersRemoved: removed,
+	})
+}
+
+// ListJobs returns the status of every scheduled background job
+func (h *AdminHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	statuses := h.jobs.Statuses()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":  statuses,
+		"count": len(statuses),
+	})
+}
+
+// RunJob triggers an immediate run of a scheduled job
+func (h *AdminHandler) RunJob(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if err := h.jobs.RunNow(name); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "job_not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Job triggered",
+		"job":     name,
+	})
+}
+
+// GetChaos returns the fault injection settings and counts
+func (h *AdminHandler) GetChaos(w http.ResponseWriter, r *http.Request) {
+	if !h.requireChaos(w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ChaosStatusResponse{
+		ChaosSettings: h.chaos.Settings(),
+		Injected:      h.chaos.Injected(),
+	})
+}
+
+// UpdateChaos replaces the fault injection settings
+func (h *AdminHandler) UpdateChaos(w http.ResponseWriter, r *http.Request) {
+	if !h.requireChaos(w) {
+		return
+	}
+
+	var req models.ChaosSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	if err := h.chaos.Update(req); err != nil {
+		status, code := http.StatusBadRequest, "invalid_settings"
+		if errors.Is(err, middleware.ErrChaosForbidden) {
+			status, code = http.StatusForbidden, "chaos_forbidden"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ChaosStatusResponse{
+		ChaosSettings: h.chaos.Settings(),
+		Injected:      h.chaos.Injected(),
+	})
+}
+
+func (h *AdminHandler) requireChaos(w http.ResponseWriter) bool {
+	if h.chaos != nil {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   "chaos_unavailable",
+		Message: "Fault injection is not configured on this server",
+	})
+	return false
+}
+
+// ListDeadLetters returns undelivered webhook messages, oldest first:
+// ?limit=100 (max 1000) from ?offset=0
+func (h *AdminHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDeadLetters(w) {
+		return
+	}
+
+	limit, offset := 100, 0
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsed > 0 && parsed <= 1000 {
+		limit = parsed
+	}
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && parsed >= 0 {
+		offset = parsed
+	}
+
+	letters, total := h.deadLetters.List(offset, limit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DeadLettersResponse{
+		DeadLetters: letters,
+		Count:       len(letters),
+		Total:       total,
+		Offset:      offset,
+	})
+}
+
+// ReplayDeadLetters queues dead letters for delivery again: those listed in
+// the body's ids, or all of them when the body is empty
+func (h *AdminHandler) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !h.requireDeadLetters(w) {
+		return
+	}
+
+	var req models.ReplayDeadLettersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	replayed, err := h.deadLetters.Replay(h.webhooks, req.IDs)
+	if err != nil {
+		// The letters were still handed to the dispatcher
+		log.Printf("Failed to save dead letters after replay: %v\n", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ReplayDeadLettersResponse{
+		Replayed:  replayed,
+		Remaining: h.deadLetters.Len(),
+	})
+}
+
+func (h *AdminHandler) requireDeadLetters(w http.ResponseWriter) bool {
+	if h.deadLetters != nil {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   "deadletters_unavailable",
+		Message: "The dead letter queue is not configured on this server",
+	})
+	return false
+}
+
+// GetIPFilter returns the allow and deny lists and the temporary blocks in
+// force
+func (h *AdminHandler) GetIPFilter(w http.ResponseWriter, r *http.Request) {
+	if !h.requireIPFilter(w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.ipFilter.Status())
+}
+
+// BlockIP refuses requests from an IP or CIDR range for a while
+func (h *AdminHandler) BlockIP(w http.ResponseWriter, r *http.Request) {
+	if !h.requireIPFilter(w) {
+		return
+	}
+
+	var req models.CreateIPBlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	block, err := h.ipFilter.Block(req.CIDR, time.Duration(req.DurationSeconds)*time.Second, req.Reason)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_block",
+			Message: err.Error(),
+		})
+		return
+	}
+	log.Printf("Blocked %s until %s: %s\n", block.CIDR, block.ExpiresAt.Format(time.RFC3339), block.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(block)
+}
+
+// UnblockIP lifts the temporary block on ?cidr= early
+func (h *AdminHandler) UnblockIP(w http.ResponseWriter, r *http.Request) {
+	if !h.requireIPFilter(w) {
+		return
+	}
+
+	removed, err := h.ipFilter.Unblock(r.URL.Query().Get("cidr"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_cidr",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !removed {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "block_not_found",
+			Message: "No temporary block on " + r.URL.Query().Get("cidr"),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) requireIPFilter(w http.ResponseWriter) bool {
+	if h.ipFilter != nil {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   "ipfilter_unavailable",
+		Message: "IP filtering is not configured on this server",
+	})
+	return false
+}
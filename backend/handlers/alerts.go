@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"leaderboard-backend/alerts"
+)
+
+type AlertsHandler struct {
+	evaluator *alerts.Evaluator
+}
+
+func NewAlertsHandler(evaluator *alerts.Evaluator) *AlertsHandler {
+	return &AlertsHandler{evaluator: evaluator}
+}
+
+// ListAlerts returns every configured alert with its current state
+func (h *AlertsHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	list := h.evaluator.Alerts()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alerts": list,
+		"active": h.evaluator.ActiveCount(),
+		"count":  len(list),
+	})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+
+	"github.com/gorilla/mux"
+)
+
+type ArchiveHandler struct {
+	service *services.ArchiveService
+}
+
+func NewArchiveHandler(service *services.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{service: service}
+}
+
+// ArchiveSeason saves the live leaderboard as the final standings of {season}
+func (h *ArchiveHandler) ArchiveSeason(w http.ResponseWriter, r *http.Request) {
+	info, err := h.service.ArchiveCurrent(mux.Vars(r)["season"])
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+// CloseSeason archives the live leaderboard as the final standings of
+// {season} and resets ratings for the next season as the body says. An
+// empty body archives without a reset.
+func (h *ArchiveHandler) CloseSeason(w http.ResponseWriter, r *http.Request) {
+	var req models.CloseSeasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	response, err := h.service.CloseSeason(r.Context(), mux.Vars(r)["season"], req)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListSeasons returns every archived season
+func (h *ArchiveHandler) ListSeasons(w http.ResponseWriter, r *http.Request) {
+	response, err := h.service.ListSeasons()
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSeasonLeaderboard serves a page of final standings: ?limit=50&offset=0
+func (h *ArchiveHandler) GetSeasonLeaderboard(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	offset := 0
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	response, err := h.service.GetLeaderboard(mux.Vars(r)["season"], limit, offset)
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSeasonStanding returns a user's final rank and percentile in a season
+func (h *ArchiveHandler) GetSeasonStanding(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	response, err := h.service.GetUserStanding(vars["season"], vars["id"])
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeArchiveError(w http.ResponseWriter, err error) {
+	status, code := http.StatusInternalServerError, "archive_failed"
+	switch {
+	case errors.Is(err, store.ErrInvalidSeasonID), errors.Is(err, services.ErrInvalidSeasonReset):
+		status, code = http.StatusBadRequest, "invalid_season"
+	case errors.Is(err, store.ErrSeasonArchived):
+		status, code = http.StatusConflict, "season_archived"
+	case errors.Is(err, store.ErrSeasonNotFound), errors.Is(err, store.ErrNoStanding):
+		status, code = http.StatusNotFound, "not_found"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   code,
+		Message: err.Error(),
+	})
+}
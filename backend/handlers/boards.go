@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+
+	"github.com/gorilla/mux"
+)
+
+// BoardsHandler serves named leaderboards under /api/boards/{board}. Each
+// board route behaves like its unprefixed counterpart on that board's users.
+type BoardsHandler struct {
+	manager      *services.LeaderboardManager
+	ratingTokens bool // rating updates need the user's bearer token or an admin
+}
+
+func NewBoardsHandler(manager *services.LeaderboardManager) *BoardsHandler {
+	return &BoardsHandler{manager: manager}
+}
+
+// RequireRatingTokens applies UserHandler.RequireRatingTokens to the rating
+// route of every board
+func (h *BoardsHandler) RequireRatingTokens() {
+	h.ratingTokens = true
+}
+
+// boardHandlers are the handlers of the unprefixed routes, bound to one board
+type boardHandlers struct {
+	leaderboard *LeaderboardHandler
+	users       *UserHandler
+}
+
+// withBoard runs fn with the handlers of the board named in the path, or
+// answers 404 if there is no such board
+func (h *BoardsHandler) withBoard(w http.ResponseWriter, r *http.Request, fn func(b boardHandlers)) {
+	board, err := h.manager.Get(mux.Vars(r)["board"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "board_not_found",
+			Message: "Board " + mux.Vars(r)["board"] + " does not exist",
+		})
+		return
+	}
+	users := NewUserHandler(board.Users, board.Leaderboard, nil, 0, board.RatingIndex, board.Store, nil)
+	users.ratingTokens = h.ratingTokens
+	fn(boardHandlers{
+		leaderboard: NewLeaderboardHandler(board.Leaderboard),
+		users:       users,
+	})
+}
+
+// ListBoards returns every board with its user count
+func (h *BoardsHandler) ListBoards(w http.ResponseWriter, r *http.Request) {
+	boards := h.manager.List()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.BoardListResponse{
+		Boards: boards,
+		Count:  len(boards),
+	})
+}
+
+// CreateBoard adds an empty board named by the body
+func (h *BoardsHandler) CreateBoard(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateBoardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	board, err := h.manager.Create(req.Name)
+	if err != nil {
+		status, code := http.StatusInternalServerError, "create_failed"
+		switch {
+		case errors.Is(err, services.ErrBoardExists):
+			status, code = http.StatusConflict, "board_exists"
+		case errors.Is(err, services.ErrTooManyBoards):
+			status, code = http.StatusConflict, "too_many_boards"
+		case services.ValidateBoardName(req.Name) != nil:
+			status, code = http.StatusBadRequest, "invalid_board_name"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.BoardInfo{Name: board.Name})
+}
+
+// DeleteBoard removes a board with all its users
+func (h *BoardsHandler) DeleteBoard(w http.ResponseWriter, r *http.Request) {
+	if err := h.manager.Delete(mux.Vars(r)["board"]); err != nil {
+		status, code := http.StatusInternalServerError, "delete_failed"
+		switch {
+		case errors.Is(err, services.ErrBoardNotFound):
+			status, code = http.StatusNotFound, "board_not_found"
+		case errors.Is(err, services.ErrDefaultBoard):
+			status, code = http.StatusForbidden, "default_board"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLeaderboard is GET /api/leaderboard on the board
+func (h *BoardsHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.leaderboard.GetLeaderboard(w, r) })
+}
+
+// SearchUsers is GET /api/search on the board
+func (h *BoardsHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.leaderboard.SearchUsers(w, r) })
+}
+
+// CreateUser is POST /api/users on the board
+func (h *BoardsHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.users.CreateUser(w, r) })
+}
+
+// GetUser is GET /api/users/{id} on the board
+func (h *BoardsHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.users.GetUser(w, r) })
+}
+
+// DeleteUser is DELETE /api/users/{id} on the board
+func (h *BoardsHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.users.DeleteUser(w, r) })
+}
+
+// UpdateRating is PATCH /api/users/{id}/rating on the board
+func (h *BoardsHandler) UpdateRating(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.users.UpdateRating(w, r) })
+}
+
+// RecordMatch is POST /api/matches on the board
+func (h *BoardsHandler) RecordMatch(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.users.RecordMatch(w, r) })
+}
+
+// GetAroundUser is GET /api/users/{id}/context on the board
+func (h *BoardsHandler) GetAroundUser(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.users.GetAroundUser(w, r) })
+}
+
+// GetRatingHistory is GET /api/users/{id}/history on the board
+func (h *BoardsHandler) GetRatingHistory(w http.ResponseWriter, r *http.Request) {
+	h.withBoard(w, r, func(b boardHandlers) { b.users.GetRatingHistory(w, r) })
+}
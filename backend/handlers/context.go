@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"leaderboard-backend/models"
+)
+
+// statusClientClosedRequest is the non-standard status logged for requests
+// the client abandoned before an answer was ready
+const statusClientClosedRequest = 499
+
+// writeContextError answers a request whose context ended before the service
+// finished, and reports whether err was such an error. Other errors are left
+// for the caller to map.
+func writeContextError(w http.ResponseWriter, err error) bool {
+	var status int
+	var response models.ErrorResponse
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+		response = models.ErrorResponse{Error: "timeout", Message: "The request took too long and was abandoned"}
+	case errors.Is(err, context.Canceled):
+		status = statusClientClosedRequest
+		response = models.ErrorResponse{Error: "canceled", Message: "The request was canceled"}
+	default:
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+	return true
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"leaderboard-backend/services"
+)
+
+// ExportUsers streams every user with rank, rating, tier and bot flag as
+// gzip-compressed CSV for the data warehouse. X-Export-Schema names the
+// column layout and X-Export-Version the store version it was read at.
+func (h *LeaderboardHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	export, err := h.service.ExportUsers(r.Context())
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users-%s.csv.gz"`, export.TakenAt().Format("20060102T150405Z")))
+	w.Header().Set("X-Export-Schema", strconv.Itoa(services.ExportSchemaVersion))
+	w.Header().Set("X-Export-Version", strconv.FormatUint(export.Version(), 10))
+	w.Header().Set("X-Export-Count", strconv.Itoa(export.Count()))
+	// The status is already sent, so a failure can only cut the file short
+	if err := export.WriteCSV(w); err != nil {
+		log.Printf("User export to %s failed: %v", r.RemoteAddr, err)
+	}
+}
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"leaderboard-backend/models"
 	"leaderboard-backend/services"
+	"leaderboard-backend/store"
 )
 
 type LeaderboardHandler struct {
@@ -16,6 +19,42 @@ func NewLeaderboardHandler(service *services.LeaderboardService) *LeaderboardHan
 	return &LeaderboardHandler{service: service}
 }
 
+// parseFilter reads the optional result filters and ranking mode shared by
+// leaderboard and search. An unknown ?ranking= is answered with 400 and
+// reported as false.
+func parseFilter(w http.ResponseWriter, r *http.Request) (services.LeaderboardFilter, bool) {
+	ranking, err := services.ParseRankingMode(r.URL.Query().Get("ranking"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_ranking",
+			Message: err.Error(),
+		})
+		return services.LeaderboardFilter{}, false
+	}
+
+	tier := r.URL.Query().Get("tier")
+	if tier != "" {
+		if _, _, err := services.FindTier(tier); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_tier",
+				Message: err.Error(),
+			})
+			return services.LeaderboardFilter{}, false
+		}
+	}
+
+	return services.LeaderboardFilter{
+		OnlineOnly:  r.URL.Query().Get("online") == "true",
+		ExcludeBots: r.URL.Query().Get("exclude_bots") == "true",
+		Ranking:     ranking,
+		Tier:        tier,
+	}, true
+}
+
 func (h *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
 	offsetStr := r.URL.Query().Get("offset")
@@ -35,9 +74,118 @@ func (h *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	response := h.service.GetLeaderboard(limit, offset)
+	filter, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	// view=compact trades the full rows for speed on plain offset pages
+	if view := r.URL.Query().Get("view"); view != "" {
+		if view != "compact" || r.URL.Query().Has("cursor") || r.URL.Query().Has("since_version") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_view",
+				Message: "view must be compact, and compact pages take no cursor or since_version",
+			})
+			return
+		}
+
+		response, err := h.service.GetCompactLeaderboard(r.Context(), limit, offset, filter)
+		if writeContextError(w, err) {
+			return
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_view",
+				Message: err.Error(),
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// A cursor replaces offset and since_version
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		cursor, err := services.ParseCursor(cursorStr)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_cursor",
+				Message: err.Error(),
+			})
+			return
+		}
+
+		response, err := h.service.GetLeaderboardAfter(r.Context(), limit, cursor, filter)
+		if writeContextError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if sinceStr := r.URL.Query().Get("since_version"); sinceStr != "" {
+		since, err := strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_since_version",
+				Message: "since_version must be a non-negative integer",
+			})
+			return
+		}
+
+		response, err := h.service.GetLeaderboardDelta(r.Context(), limit, offset, filter, since)
+		if writeContextError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response, err := h.service.GetLeaderboard(r.Context(), limit, offset, filter)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetFinalStandings returns a consistent, digested top N for prize payouts:
+// ?top=100 (max 1000)
+func (h *LeaderboardHandler) GetFinalStandings(w http.ResponseWriter, r *http.Request) {
+	top := 100
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		parsed, err := strconv.Atoi(topStr)
+		if err != nil || parsed <= 0 || parsed > services.MaxFinalTop {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_top",
+				Message: fmt.Sprintf("top must be between 1 and %d", services.MaxFinalTop),
+			})
+			return
+		}
+		top = parsed
+	}
+
+	response, err := h.service.GetFinalStandings(r.Context(), top)
+	if writeContextError(w, err) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	json.NewEncoder(w).Encode(response)
 }
 
@@ -47,14 +195,142 @@ func (h *LeaderboardHandler) SearchUsers(w http.ResponseWriter, r *http.Request)
 	if query == "" {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"users": []interface{}{},
-			"query": "",
-			"count": 0,
+			"users":     []interface{}{},
+			"query":     "",
+			"count":     0,
+			"truncated": false,
+		})
+		return
+	}
+
+	filter, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := h.service.SearchUsers(r.Context(), query, filter)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SuggestUsernames serves typeahead suggestions: ?q=prefix&limit=10 (max 50)
+func (h *LeaderboardHandler) SuggestUsernames(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	limitStr := r.URL.Query().Get("limit")
+
+	limit := 10
+	if limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 50 {
+			limit = parsed
+		}
+	}
+
+	response, err := h.service.SuggestUsernames(r.Context(), query, limit)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRatingDistribution returns the rating histogram for charting.
+// Optional ?step=N merges adjacent ratings into bands N points wide.
+func (h *LeaderboardHandler) GetRatingDistribution(w http.ResponseWriter, r *http.Request) {
+	step := 1
+	if stepStr := r.URL.Query().Get("step"); stepStr != "" {
+		parsed, err := strconv.Atoi(stepStr)
+		if err != nil || parsed < 1 || parsed > store.RatingRange {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_request",
+				Message: fmt.Sprintf("step must be between 1 and %d", store.RatingRange),
+			})
+			return
+		}
+		step = parsed
+	}
+
+	response := h.service.GetRatingDistribution(step)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// maxRankLookupItems caps the combined number of IDs and ratings per batch
+const maxRankLookupItems = 1000
+
+// LookupRanks returns ranks for a batch of user IDs and/or ratings
+func (h *LeaderboardHandler) LookupRanks(w http.ResponseWriter, r *http.Request) {
+	var req models.RankLookupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	if len(req.UserIDs)+len(req.Ratings) > maxRankLookupItems {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("at most %d user IDs and ratings per request", maxRankLookupItems),
+		})
+		return
+	}
+
+	response, err := h.service.LookupRanks(r.Context(), req.UserIDs, req.Ratings)
+	if writeContextError(w, err) || writeRatingRangeError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetSubsetLeaderboard ranks the users named in the body against each other,
+// e.g. a friends-only board, in one request. The leaderboard's online,
+// exclude_bots, tier and ranking parameters apply.
+func (h *LeaderboardHandler) GetSubsetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseFilter(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.SubsetLeaderboardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
 		})
 		return
 	}
 
-	response := h.service.SearchUsers(query)
+	if len(req.UserIDs) > maxRankLookupItems {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("at most %d user IDs per request", maxRankLookupItems),
+		})
+		return
+	}
+
+	response, err := h.service.GetSubsetLeaderboard(r.Context(), req.UserIDs, filter)
+	if writeContextError(w, err) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	liveWriteWait  = 10 * time.Second      // time allowed to write one message
+	livePongWait   = 60 * time.Second      // time allowed between pongs
+	livePingPeriod = livePongWait * 9 / 10 // must be shorter than livePongWait
+)
+
+type LiveHandler struct {
+	hub      *services.LiveHub
+	topPage  *services.TopPageBroadcaster
+	upgrader websocket.Upgrader
+}
+
+func NewLiveHandler(hub *services.LiveHub) *LiveHandler {
+	return &LiveHandler{
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			// The API is open to any origin, as with CORS
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// SetTopPage sends new clients the last first page broadcast, so they have
+// the top of the board before the next one
+func (h *LiveHandler) SetTopPage(topPage *services.TopPageBroadcaster) {
+	h.topPage = topPage
+}
+
+// Stream upgrades to a websocket and pushes a models.LiveEvent as JSON for
+// every change until the client goes away. Clients too slow to keep up are
+// closed with "try again later" and should reload the board before
+// reconnecting.
+func (h *LiveHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.hub.Subscribe()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "shutting_down",
+			Message: err.Error(),
+		})
+		return
+	}
+	defer h.hub.Unsubscribe(sub)
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already answered the request
+		return
+	}
+	defer conn.Close()
+
+	if h.topPage != nil {
+		if event, ok := h.topPage.Latest(); ok {
+			conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+
+	// Clients send nothing but control frames; reading notices pongs and
+	// the connection closing
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(livePongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(livePongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(livePingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				code, reason := websocket.CloseGoingAway, "server shutting down"
+				if sub.Slow() {
+					code, reason = websocket.CloseTryAgainLater, "client too slow"
+				}
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(liveWriteWait))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := conn.WriteJSON(event); err != nil {
+				log.Printf("Live update to %s failed: %v", r.RemoteAddr, err)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
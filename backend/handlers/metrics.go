@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"leaderboard-backend/metrics"
+)
+
+type MetricsHandler struct {
+	registry *metrics.Registry
+}
+
+func NewMetricsHandler(registry *metrics.Registry) *MetricsHandler {
+	return &MetricsHandler{registry: registry}
+}
+
+// Prometheus serves all registered metrics in the Prometheus text format
+func (h *MetricsHandler) Prometheus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	h.registry.WritePrometheus(w)
+}
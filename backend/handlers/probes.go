@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+)
+
+// ProbeHandler serves the liveness and readiness probes and holds other
+// requests back until the store's indexes are built
+type ProbeHandler struct {
+	warmup *services.Warmup
+}
+
+func NewProbeHandler(warmup *services.Warmup) *ProbeHandler {
+	return &ProbeHandler{warmup: warmup}
+}
+
+// Healthz answers as soon as the server is listening
+func (h *ProbeHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readyz is 200 once the indexes are built and 503 with their progress
+// until then
+func (h *ProbeHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	status := h.warmup.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// Gate answers everything but the probes with 503 until the indexes are
+// built, rather than leaving requests waiting on the build
+func (h *ProbeHandler) Gate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.warmup.Ready() || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		status := h.warmup.Status()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "warming_up",
+			Message: fmt.Sprintf("building indexes: %d of %d users (%.0f%%)", status.Indexed, status.Total, status.Percent),
+		})
+	})
+}
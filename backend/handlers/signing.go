@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+)
+
+type SigningHandler struct {
+	signer *middleware.Signer
+}
+
+func NewSigningHandler(signer *middleware.Signer) *SigningHandler {
+	return &SigningHandler{signer: signer}
+}
+
+// GetSigningKey describes how responses are signed and, for Ed25519, the
+// public key that verifies them
+func (h *SigningHandler) GetSigningKey(w http.ResponseWriter, r *http.Request) {
+	if !h.signer.Enabled() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "signing_disabled",
+			Message: "Responses are not signed on this server",
+		})
+		return
+	}
+
+	response := models.SigningKeyResponse{
+		Algorithm: h.signer.Algorithm(),
+		KeyID:     h.signer.KeyID(),
+		Ephemeral: h.signer.Ephemeral(),
+		Headers: []string{
+			middleware.SignatureHeader,
+			middleware.SignatureTimestampHeader,
+			middleware.SignatureKeyIDHeader,
+			middleware.SignatureAlgorithmHeader,
+		},
+	}
+	if key := h.signer.PublicKey(); key != nil {
+		response.PublicKey = base64.StdEncoding.EncodeToString(key)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDiffTop is how much of each snapshot a diff compares by default
+const defaultDiffTop = 100
+
+type SnapshotHandler struct {
+	service *services.SnapshotService
+}
+
+func NewSnapshotHandler(service *services.SnapshotService) *SnapshotHandler {
+	return &SnapshotHandler{service: service}
+}
+
+// CreateSnapshot saves the live leaderboard under the name in the body
+func (h *SnapshotHandler) CreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	info, err := h.service.Create(req.Name)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+// ListSnapshots returns the stored snapshots
+func (h *SnapshotHandler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	response, err := h.service.List()
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DiffSnapshots compares snapshot {a} to {b}: ?top=N limits the comparison to
+// the top N ranks of each (default 100, 0 compares everyone)
+func (h *SnapshotHandler) DiffSnapshots(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	top := defaultDiffTop
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		parsed, err := strconv.Atoi(topStr)
+		if err != nil || parsed < 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_top",
+				Message: "top must be a non-negative integer",
+			})
+			return
+		}
+		top = parsed
+	}
+
+	response, err := h.service.Diff(vars["a"], vars["b"], top)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	status, code := http.StatusInternalServerError, "snapshot_failed"
+	switch {
+	case errors.Is(err, store.ErrInvalidSnapshotName):
+		status, code = http.StatusBadRequest, "invalid_name"
+	case errors.Is(err, store.ErrSnapshotExists):
+		status, code = http.StatusConflict, "snapshot_exists"
+	case errors.Is(err, store.ErrSnapshotNotFound):
+		status, code = http.StatusNotFound, "not_found"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   code,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+)
+
+const (
+	streamWriteWait = 10 * time.Second // time allowed to write one message
+	streamKeepAlive = 15 * time.Second // comment sent on a quiet stream so proxies keep it open
+)
+
+// StreamHandler serves leaderboard pages as Server-Sent Events
+type StreamHandler struct {
+	service  *services.LeaderboardService
+	hub      *services.LiveHub
+	debounce time.Duration
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+func NewStreamHandler(service *services.LeaderboardService, hub *services.LiveHub, debounce time.Duration) *StreamHandler {
+	return &StreamHandler{
+		service:  service,
+		hub:      hub,
+		debounce: debounce,
+		closing:  make(chan struct{}),
+	}
+}
+
+// Close ends every open stream. The HTTP server waits for streams to finish
+// before shutting down, so call it when shutdown begins.
+func (h *StreamHandler) Close() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// StreamLeaderboard sends the page ?limit= (default 50, max 100) at
+// ?offset= as a "leaderboard" event, then again whenever it changes. Changes
+// are gathered for the debounce interval so a busy board is sent at most
+// once per interval, and only when the page actually differs.
+func (h *StreamHandler) StreamLeaderboard(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsed > 0 && parsed <= 100 {
+		limit = parsed
+	}
+	offset := 0
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && parsed >= 0 {
+		offset = parsed
+	}
+	lastRank := offset + limit
+
+	sub, err := h.hub.Subscribe()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "shutting_down",
+			Message: err.Error(),
+		})
+		return
+	}
+	defer h.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+
+	var sent []byte
+	sendPage := func() error {
+		page, err := h.service.GetLeaderboard(r.Context(), limit, offset, services.LeaderboardFilter{})
+		if err != nil {
+			return err
+		}
+		users, _ := json.Marshal(page.Users)
+		if sent != nil && bytes.Equal(users, sent) {
+			return nil
+		}
+		data, err := json.Marshal(page)
+		if err != nil {
+			return err
+		}
+		sent = users
+		return writeEvent(rc, w, fmt.Sprintf("event: leaderboard\nid: %d\ndata: %s\n\n", page.Version, data))
+	}
+
+	if err := sendPage(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	var pending <-chan time.Time // fires when the debounce interval ends
+
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if pending == nil && affectsPage(event, lastRank) {
+				pending = time.After(h.debounce)
+			}
+		case <-pending:
+			pending = nil
+			if err := sendPage(); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if err := writeEvent(rc, w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-h.closing:
+			return
+		}
+	}
+}
+
+// affectsPage reports whether event may have changed the places down to
+// lastRank. Removals carry no rank, so they always count.
+func affectsPage(event models.LiveEvent, lastRank int) bool {
+	switch event.Type {
+	case "cleared", "removed":
+		return true
+	case "added", "rating":
+		return event.User.Rank <= lastRank || (event.OldRank > 0 && event.OldRank <= lastRank)
+	}
+	return false
+}
+
+// writeEvent writes and flushes one event. The server's write timeout is
+// pushed back first, as it would otherwise end the stream.
+func writeEvent(rc *http.ResponseController, w http.ResponseWriter, event string) error {
+	rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+	if _, err := w.Write([]byte(event)); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
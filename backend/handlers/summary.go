@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+
+	"github.com/gorilla/mux"
+)
+
+type SummaryHandler struct {
+	service *services.SummaryService
+}
+
+func NewSummaryHandler(service *services.SummaryService) *SummaryHandler {
+	return &SummaryHandler{service: service}
+}
+
+// GetUserSummary returns a user's standing on the live leaderboard and in
+// every archived season they played
+func (h *SummaryHandler) GetUserSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.service.GetUserSummary(r.Context(), mux.Vars(r)["id"])
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		status, code := http.StatusInternalServerError, "summary_failed"
+		if errors.Is(err, services.ErrNoStandings) {
+			status, code = http.StatusNotFound, "not_found"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"leaderboard-backend/services"
+)
+
+type TierHandler struct {
+	tierService *services.TierService
+}
+
+func NewTierHandler(tierService *services.TierService) *TierHandler {
+	return &TierHandler{tierService: tierService}
+}
+
+// ListTiers returns every tier's rating band and how many users are in it
+func (h *TierHandler) ListTiers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.tierService.GetTiers())
+}
@@ -2,12 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/middleware"
 	"leaderboard-backend/models"
+	"leaderboard-backend/notify"
 	"leaderboard-backend/services"
 	"leaderboard-backend/store"
 
@@ -21,6 +28,13 @@ type UserHandler struct {
 	initialUsers       int
 	ratingIndex        *store.RatingBucketIndex
 	memoryStore        *store.MemoryStore
+	seedGuard          *services.SeedGuard
+	persistence        *store.Persistence       // optional, reported in health
+	autoSaver          *store.AutoSaver         // optional, reported in health
+	backpressure       *middleware.Backpressure // optional, reported in health
+	webhooks           *notify.Dispatcher       // optional, reported in health
+	rateLimiter        *middleware.RateLimiter  // optional, reported in health
+	ratingTokens       bool                     // rating updates need the user's bearer token or an admin
 }
 
 func NewUserHandler(
@@ -30,6 +44,7 @@ func NewUserHandler(
 	initialUsers int,
 	ratingIndex *store.RatingBucketIndex,
 	memoryStore *store.MemoryStore,
+	seedGuard *services.SeedGuard,
 ) *UserHandler {
 	return &UserHandler{
 		userService:        userService,
@@ -38,9 +53,45 @@ func NewUserHandler(
 		initialUsers:       initialUsers,
 		ratingIndex:        ratingIndex,
 		memoryStore:        memoryStore,
+		seedGuard:          seedGuard,
 	}
 }
 
+// SetPersistence adds save/load progress and timing to the health report
+func (h *UserHandler) SetPersistence(p *store.Persistence) {
+	h.persistence = p
+}
+
+// SetAutoSaver adds the autosave schedule, pending writes and last result
+// to the health report
+func (h *UserHandler) SetAutoSaver(a *store.AutoSaver) {
+	h.autoSaver = a
+}
+
+// SetBackpressure adds internal queue backlogs and the write pressure
+// level to the health report
+func (h *UserHandler) SetBackpressure(b *middleware.Backpressure) {
+	h.backpressure = b
+}
+
+// SetWebhooks adds the webhook queue and endpoint health to the health
+// report
+func (h *UserHandler) SetWebhooks(d *notify.Dispatcher) {
+	h.webhooks = d
+}
+
+// RequireRatingTokens limits PATCH /api/users/{id}/rating to bearer tokens
+// whose subject is that user, and to admins
+func (h *UserHandler) RequireRatingTokens() {
+	h.ratingTokens = true
+}
+
+// SetRateLimiter adds the rate limiter's tracked visitors and evictions to
+// the health report
+func (h *UserHandler) SetRateLimiter(rl *middleware.RateLimiter) {
+	h.rateLimiter = rl
+}
+
 func (h *UserHandler) SeedUsers(w http.ResponseWriter, r *http.Request) {
 	countStr := r.URL.Query().Get("count")
 	count := h.initialUsers
@@ -51,9 +102,29 @@ func (h *UserHandler) SeedUsers(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h.userService.Clear()
+	force := r.URL.Query().Get("force") == "true"
+	if err := h.seedGuard.Acquire(force); err != nil {
+		status := http.StatusForbidden
+		var cooldownErr *services.SeedCooldownError
+		if errors.As(err, &cooldownErr) {
+			status = http.StatusTooManyRequests
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(cooldownErr.RetryAfter.Seconds()))))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "seed_blocked",
+			Message: err.Error(),
+		})
+		return
+	}
 
-	added, err := h.userService.SeedUsers(count)
+	// Seeding adds to the existing board; wiping data is done explicitly
+	// through the confirmed POST /api/admin/reset
+	added, err := h.userService.SeedUsers(r.Context(), count)
+	if writeContextError(w, err) {
+		return
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -77,7 +148,76 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	userWithRank, err := h.leaderboardService.GetUserWithRank(id)
+	userWithRank, err := h.leaderboardService.GetUserWithRank(r.Context(), id)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		// Merged accounts redirect to the one that absorbed them
+		if into, merged := h.memoryStore.MergedInto(id); merged {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Location", "/api/users/"+into)
+			w.WriteHeader(http.StatusMovedPermanently)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "user_merged",
+				Message: "User " + id + " was merged into " + into,
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userWithRank)
+}
+
+// maxBatchUserIDs caps the IDs in one GET /api/users?ids= request, keeping
+// the URL within what proxies accept
+const maxBatchUserIDs = 100
+
+// GetUsers returns the ranked view of every user in the comma-separated ids
+// parameter, in one request instead of one per user
+func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	ids := make([]string, 0)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxBatchUserIDs {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: fmt.Sprintf("ids must list 1 to %d comma-separated user IDs", maxBatchUserIDs),
+		})
+		return
+	}
+
+	response, err := h.leaderboardService.GetUsersWithRank(r.Context(), ids)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetUserByUsername looks a user up by exact (case-insensitive) username
+func (h *UserHandler) GetUserByUsername(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	userWithRank, err := h.leaderboardService.GetUserByUsername(r.Context(), username)
+	if writeContextError(w, err) {
+		return
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
@@ -92,10 +232,159 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(userWithRank)
 }
 
+// CheckUsername reports whether ?name= passes the username policy and
+// whether someone already uses it
+func (h *UserHandler) CheckUsername(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Query parameter 'name' is required",
+		})
+		return
+	}
+
+	response, err := h.userService.CheckUsername(r.Context(), name)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRecentUsers returns the most recently changed users: ?limit=50 (max 200),
+// optionally only counting changes from ?source=
+func (h *UserHandler) GetRecentUsers(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+
+	var source store.Source
+	if name := r.URL.Query().Get("source"); name != "" {
+		parsed, err := store.ParseSource(name)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_source",
+				Message: err.Error(),
+			})
+			return
+		}
+		source = parsed
+	}
+
+	limit := 50
+	if limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+
+	response, err := h.leaderboardService.GetRecentlyUpdated(r.Context(), limit, source)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetRatingHistory returns the user's latest ?limit= (default 100, max
+// 1000) rating changes, newest first
+func (h *UserHandler) GetRatingHistory(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+
+	response, err := h.userService.GetRatingHistory(r.Context(), mux.Vars(r)["id"], limit)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetAroundUser returns the user with the ?window= (default 5, max 50)
+// players ranked directly above and below them
+func (h *UserHandler) GetAroundUser(w http.ResponseWriter, r *http.Request) {
+	window := 5
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		if parsed, err := strconv.Atoi(windowStr); err == nil && parsed >= 0 && parsed <= services.MaxAroundWindow {
+			window = parsed
+		}
+	}
+
+	response, err := h.leaderboardService.GetAroundUser(r.Context(), mux.Vars(r)["id"], window)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SampleUsers returns ?n= random users (default 10), optionally weighted by
+// ?weight=rating or recency
+func (h *UserHandler) SampleUsers(w http.ResponseWriter, r *http.Request) {
+	weight, err := store.ParseSampleWeight(r.URL.Query().Get("weight"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_weight",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	n := 10
+	if nStr := r.URL.Query().Get("n"); nStr != "" {
+		if parsed, err := strconv.Atoi(nStr); err == nil && parsed > 0 && parsed <= services.MaxSampleSize {
+			n = parsed
+		}
+	}
+
+	response, err := h.leaderboardService.SampleUsers(r.Context(), n, weight)
+	if writeContextError(w, err) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 func (h *UserHandler) UpdateRating(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	if h.ratingTokens && !mayUpdateRating(w, r, id) {
+		return
+	}
+
 	var req models.UpdateRatingRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -107,7 +396,35 @@ func (h *UserHandler) UpdateRating(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.userService.UpdateRating(id, req.Rating); err != nil {
+	var source store.Source
+	if req.Source != "" {
+		parsed, err := store.ParseSource(req.Source)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "invalid_source",
+				Message: err.Error(),
+			})
+			return
+		}
+		source = parsed
+	}
+
+	err := h.userService.UpdateRating(r.Context(), id, req.Rating, source, isAdmin(r))
+	if writeContextError(w, err) || writeRatingRangeError(w, err) {
+		return
+	}
+	if errors.Is(err, services.ErrSourceNotAllowed) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "source_not_allowed",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.ErrorResponse{
@@ -117,7 +434,140 @@ func (h *UserHandler) UpdateRating(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userWithRank, err := h.leaderboardService.GetUserWithRank(id)
+	userWithRank, err := h.leaderboardService.GetUserWithRank(r.Context(), id)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "fetch_failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userWithRank)
+}
+
+// isAdmin reports whether the request has the admin role, from its API key
+// or its bearer token
+func isAdmin(r *http.Request) bool {
+	if role, ok := middleware.RoleFromContext(r.Context()); ok && role == middleware.RoleAdmin {
+		return true
+	}
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	return ok && claims.Role == middleware.RoleAdmin
+}
+
+// mayUpdateRating lets admins, by API key or token role, and the token
+// subject change a rating, and writes 401 or 403 for anyone else
+func mayUpdateRating(w http.ResponseWriter, r *http.Request, id string) bool {
+	claims, hasToken := middleware.ClaimsFromContext(r.Context())
+	if isAdmin(r) || hasToken && claims.Subject == id {
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !hasToken {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "Changing a rating needs a bearer token for that user or an admin role",
+		})
+		return false
+	}
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   "forbidden",
+		Message: "A token may only change its own user's rating",
+	})
+	return false
+}
+
+// RecordMatch rates a played match server-side from {"winner_id": ...,
+// "loser_id": ..., "draw": false} and returns both players ranked after it
+func (h *UserHandler) RecordMatch(w http.ResponseWriter, r *http.Request) {
+	var req models.MatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	response, err := h.userService.RecordMatch(r.Context(), req)
+	if writeContextError(w, err) || writeRatingRangeError(w, err) {
+		return
+	}
+	if err != nil {
+		status, code := http.StatusNotFound, "not_found"
+		if errors.Is(err, services.ErrInvalidMatch) {
+			status, code = http.StatusBadRequest, "invalid_match"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Ranked after both ratings changed; a player deleted since keeps rank 0
+	for _, player := range []*models.MatchPlayer{&response.Winner, &response.Loser} {
+		if ranked, err := h.leaderboardService.GetUserWithRank(r.Context(), player.ID); err == nil {
+			player.UserWithRank = *ranked
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateUser adds one user from {"username": ..., "rating": ...} and
+// returns them with their rank
+func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "invalid_request",
+			Message: "Invalid JSON body",
+		})
+		return
+	}
+
+	user, err := h.userService.CreateUser(r.Context(), req.Username, req.Rating)
+	if writeContextError(w, err) || writeRatingRangeError(w, err) {
+		return
+	}
+	if err != nil {
+		code := "create_failed"
+		var usernameErr *services.UsernameError
+		if errors.As(err, &usernameErr) {
+			code = "invalid_username"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   code,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	userWithRank, err := h.leaderboardService.GetUserWithRank(r.Context(), user.ID)
+	if writeContextError(w, err) {
+		return
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -129,9 +579,60 @@ func (h *UserHandler) UpdateRating(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/users/"+user.ID)
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(userWithRank)
 }
 
+// DeleteUser permanently removes a user from the leaderboard
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := h.userService.DeleteUser(r.Context(), id)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DeleteResult{ID: id, Deleted: true})
+}
+
+// Heartbeat marks a user as online
+func (h *UserHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	lastSeen, err := h.userService.Heartbeat(r.Context(), id)
+	if writeContextError(w, err) {
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:   "not_found",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.HeartbeatResponse{
+		ID:       id,
+		Online:   true,
+		LastSeen: lastSeen,
+	})
+}
+
 // Health returns comprehensive health check with system stats
 func (h *UserHandler) Health(w http.ResponseWriter, r *http.Request) {
 	var m runtime.MemStats
@@ -145,11 +646,13 @@ func (h *UserHandler) Health(w http.ResponseWriter, r *http.Request) {
 		"status":    "healthy",
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 		"users": map[string]interface{}{
-			"total": h.userService.GetUserCount(),
+			"total":  h.userService.GetUserCount(),
+			"online": h.userService.GetOnlineCount(),
 		},
 		"rating_index": ratingStats,
 		"memory_store": storeStats,
 		"simulator":    simulatorStats,
+		"latency":      metrics.Default.Summaries(),
 		"memory": map[string]interface{}{
 			"alloc_mb":       m.Alloc / 1024 / 1024,
 			"total_alloc_mb": m.TotalAlloc / 1024 / 1024,
@@ -157,6 +660,21 @@ func (h *UserHandler) Health(w http.ResponseWriter, r *http.Request) {
 			"num_gc":         m.NumGC,
 		},
 	}
+	if h.persistence != nil {
+		response["persistence"] = h.persistence.GetStats()
+	}
+	if h.autoSaver != nil {
+		response["autosave"] = h.autoSaver.GetStats()
+	}
+	if h.backpressure != nil {
+		response["backpressure"] = h.backpressure.GetStats()
+	}
+	if h.webhooks != nil {
+		response["webhooks"] = h.webhooks.GetStats()
+	}
+	if h.rateLimiter != nil {
+		response["rate_limiter"] = h.rateLimiter.GetStats()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -180,7 +698,75 @@ func (h *UserHandler) StopSimulator(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// PauseSimulator freezes a running simulator without ending its run, so a
+// demo can stop for explanation and carry on with POST /api/simulator/resume
+func (h *UserHandler) PauseSimulator(w http.ResponseWriter, r *http.Request) {
+	if !writeSimulatorError(w, h.simulator.Pause()) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Simulator paused",
+		"running": h.simulator.IsRunning(),
+		"paused":  h.simulator.IsPaused(),
+	})
+}
+
+// ResumeSimulator continues a paused simulator
+func (h *UserHandler) ResumeSimulator(w http.ResponseWriter, r *http.Request) {
+	if !writeSimulatorError(w, h.simulator.Resume()) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Simulator resumed",
+		"running": h.simulator.IsRunning(),
+		"paused":  h.simulator.IsPaused(),
+	})
+}
+
+// RefreshSimulatorCache rebuilds the simulator's user ID cache at once,
+// rather than within a second of users joining or leaving
+func (h *UserHandler) RefreshSimulatorCache(w http.ResponseWriter, r *http.Request) {
+	cached := h.simulator.RefreshCache()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Simulator cache refreshed",
+		"cache_size": cached,
+	})
+}
+
+// writeSimulatorError answers 409 when the simulator is not running, and
+// reports whether the request may go on
+func writeSimulatorError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   "simulator_not_running",
+		Message: "Start the simulator before pausing or resuming it",
+	})
+	return false
+}
+
 func (h *UserHandler) SimulatorStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(h.simulator.GetStats())
 }
+
+// writeRatingRangeError answers 422 when err is a rating rejected as out of
+// range, and reports whether it was
+func writeRatingRangeError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, store.ErrRatingOutOfRange) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Error:   "rating_out_of_range",
+		Message: err.Error(),
+	})
+	return true
+}
@@ -0,0 +1,150 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Shutdown order groups. Hooks run in ascending order, so producers of new
+// work are stopped before the server drains and state is flushed last.
+const (
+	OrderProducers   = 10 // simulator and other background writers
+	OrderServer      = 20 // stop accepting and drain HTTP requests
+	OrderPublishers  = 30 // websocket hub, event publishers
+	OrderPersistence = 40 // final save to disk
+)
+
+const defaultHookTimeout = 10 * time.Second
+
+// Hook is a named cleanup step run during shutdown
+type Hook struct {
+	Name    string
+	Order   int
+	Timeout time.Duration
+	Fn      func(ctx context.Context) error
+}
+
+// Manager collects shutdown hooks from components and runs them in order
+type Manager struct {
+	mu       sync.Mutex
+	hooks    []Hook
+	stopping bool
+	done     chan struct{}
+}
+
+// NewManager creates an empty lifecycle manager
+func NewManager() *Manager {
+	return &Manager{
+		hooks: make([]Hook, 0),
+		done:  make(chan struct{}),
+	}
+}
+
+// Register adds a shutdown hook. Hooks with the same order run in
+// registration order. A zero timeout uses the default of 10 seconds.
+func (m *Manager) Register(name string, order int, timeout time.Duration, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	m.hooks = append(m.hooks, Hook{
+		Name:    name,
+		Order:   order,
+		Timeout: timeout,
+		Fn:      fn,
+	})
+}
+
+// Shutdown runs every registered hook once, in order, each bounded by its own
+// timeout. Errors are logged and do not stop later hooks from running.
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		<-m.done
+		return
+	}
+	m.stopping = true
+	hooks := make([]Hook, len(m.hooks))
+	copy(hooks, m.hooks)
+	m.mu.Unlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Order < hooks[j].Order
+	})
+
+	for _, hook := range hooks {
+		start := time.Now()
+		if err := runHook(hook); err != nil {
+			log.Printf("Shutdown hook %q failed after %v: %v\n", hook.Name, time.Since(start), err)
+			continue
+		}
+		log.Printf("Shutdown hook %q completed in %v\n", hook.Name, time.Since(start))
+	}
+
+	close(m.done)
+}
+
+// runHook executes a single hook, giving up once its timeout expires
+func runHook(hook Hook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- hook.Fn(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %v", hook.Timeout)
+	}
+}
+
+// WaitForSignal blocks until one of the given signals arrives, then runs Shutdown
+func (m *Manager) WaitForSignal(signals ...os.Signal) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, signals...)
+	defer signal.Stop(quit)
+
+	select {
+	case <-quit:
+		fmt.Println("\nShutting down server...")
+		m.Shutdown()
+	case <-m.done:
+	}
+}
+
+// Done is closed once all shutdown hooks have run
+func (m *Manager) Done() <-chan struct{} {
+	return m.done
+}
+
+// Hooks returns the registered hooks in execution order
+func (m *Manager) Hooks() []Hook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hooks := make([]Hook, len(m.hooks))
+	copy(hooks, m.hooks)
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Order < hooks[j].Order
+	})
+	return hooks
+}
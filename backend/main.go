@@ -5,16 +5,21 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
 	"syscall"
 	"time"
 
+	"leaderboard-backend/alerts"
 	"leaderboard-backend/config"
 	"leaderboard-backend/handlers"
+	"leaderboard-backend/lifecycle"
+	"leaderboard-backend/metrics"
 	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+	"leaderboard-backend/notify"
+	"leaderboard-backend/scheduler"
 	"leaderboard-backend/services"
 	"leaderboard-backend/store"
+	"leaderboard-backend/tracing"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -26,57 +31,545 @@ func main() {
 	cfg := config.Load()
 
 	ratingIndex := store.NewRatingBucketIndex()
-	memoryStore := store.NewMemoryStore(ratingIndex)
+	skipListImpl, err := store.ParseSkipListImpl(cfg.SkipListImpl)
+	if err != nil {
+		log.Fatalf("Invalid SKIPLIST_IMPL setting: %v", err)
+	}
+	skipListParams, err := store.NewSkipListParams(cfg.SkipList.MaxLevel, cfg.SkipList.Probability, cfg.SkipList.ExpectedUsers)
+	if err != nil {
+		log.Fatalf("Invalid SKIPLIST_* settings: %v", err)
+	}
+	memoryStore := store.NewMemoryStoreWithSkipListParams(ratingIndex, skipListImpl, skipListParams)
+	memoryStore.SetSearchLimits(store.SearchLimits{
+		MaxCandidates: cfg.SearchCandidates,
+		TimeBudget:    time.Duration(cfg.SearchBudget) * time.Millisecond,
+	})
+	memoryStore.SetRatingHistorySize(cfg.RatingHistory)
+	// The skip list and username index are built after the server starts
+	// listening; see warmup below
+	memoryStore.DeferIndexes()
 	persistence := store.NewPersistence(persistenceFile)
+	persistence.SetShards(cfg.PersistenceShards)
+	persistence.SetWorkers(cfg.PersistenceWorkers)
+	syncPolicy, err := store.ParseSyncPolicy(cfg.SyncPolicy)
+	if err != nil {
+		log.Fatalf("Invalid DURABILITY setting: %v", err)
+	}
+	persistence.SetSyncPolicy(syncPolicy)
 
-	// Load existing data if available
-	if persistence.Exists() {
-		fmt.Println("Loading existing data from disk...")
-		if err := persistence.Load(memoryStore, ratingIndex); err != nil {
+	// With a database the JSON files and write-ahead log are not used
+	var saver store.Saver = persistence
+	var postgres *store.PostgresStore
+	if cfg.DatabaseURL != "" {
+		postgres, err = store.OpenPostgres(cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("Invalid DATABASE_URL: %v", err)
+		}
+		applied, err := postgres.Migrate(context.Background())
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		if applied > 0 {
+			fmt.Printf("Applied %d database migrations\n", applied)
+		}
+		saver = postgres
+		if cfg.WALEnabled {
+			log.Println("Warning: WAL_ENABLED is ignored when DATABASE_URL is set")
+			cfg.WALEnabled = false
+		}
+	}
+
+	walPath := ""
+	if cfg.WALEnabled {
+		walPath = persistenceFile + ".wal"
+	}
+
+	// firstBoot is set when there is no saved board at all, never when
+	// loading failed, so AUTO_SEED cannot add to real data
+	firstBoot := false
+	if postgres != nil {
+		saved, existsErr := postgres.Exists()
+		if err := postgres.Load(memoryStore); err != nil {
+			log.Printf("Warning: failed to load data: %v\n", err)
+		} else {
+			fmt.Printf("Loaded %d users from the database\n", memoryStore.GetUserCount())
+			if existsErr != nil {
+				log.Printf("Warning: %v\n", existsErr)
+			} else {
+				firstBoot = !saved && memoryStore.GetUserCount() == 0
+			}
+		}
+	} else {
+		// Load the last snapshot, replaying the write-ahead log over it
+		saved := persistence.Exists()
+		if saved {
+			fmt.Println("Loading existing data from disk...")
+		}
+		if report, err := persistence.Recover(memoryStore, ratingIndex, walPath); err != nil {
 			log.Printf("Warning: failed to load data: %v\n", err)
 		} else {
 			fmt.Printf("Loaded %d users from disk\n", memoryStore.GetUserCount())
+			firstBoot = !saved && memoryStore.GetUserCount() == 0
+			if report.Unclean {
+				log.Printf("Recovered from unclean shutdown: %d users from snapshot, %d WAL entries replayed (report in %s)\n",
+					report.SnapshotUsers, report.EntriesReplayed, persistence.ReportPath())
+			}
+		}
+	}
+
+	warmup := services.NewWarmup(memoryStore)
+
+	// Strict mode only applies from here on: persisted ratings are clamped
+	// rather than dropped
+	ratingRangeMode, err := store.ParseRatingRangeMode(cfg.RatingRangeMode)
+	if err != nil {
+		log.Fatalf("Invalid RATING_RANGE_MODE: %v", err)
+	}
+	memoryStore.SetRatingRangeMode(ratingRangeMode)
+
+	var wal *store.WAL
+	if cfg.WALEnabled {
+		wal, err = store.OpenWAL(walPath, syncPolicy)
+		if err != nil {
+			log.Fatalf("Failed to open write-ahead log: %v", err)
+		}
+		persistence.SetJournal(wal)
+		memoryStore.SetJournal(wal)
+	}
+
+	jobs := scheduler.New()
+	if cfg.JobLockDir != "" {
+		locker, err := scheduler.NewFileLocker(cfg.JobLockDir, cfg.ReplicaID)
+		if err != nil {
+			log.Fatalf("Failed to set up job locking: %v", err)
+		}
+		jobs.SetLocker(locker)
+	}
+
+	autoSaver := store.NewAutoSaver(saver, memoryStore, time.Duration(cfg.AutosaveInterval)*time.Second, cfg.AutosaveWrites)
+	if err := jobs.Register("autosave", store.AutosaveCheckInterval, func(ctx context.Context) error {
+		return autoSaver.Check()
+	}); err != nil {
+		log.Fatalf("Failed to schedule job: %v", err)
+	}
+
+	if wal != nil {
+		if err := jobs.Register("wal-flush", time.Duration(cfg.WALFlushInterval)*time.Millisecond, func(ctx context.Context) error {
+			return wal.Flush()
+		}); err != nil {
+			log.Fatalf("Failed to schedule job: %v", err)
 		}
 	}
 
-	userService := services.NewUserService(memoryStore, ratingIndex, cfg.MinRating, cfg.MaxRating)
-	leaderboardService := services.NewLeaderboardService(memoryStore, ratingIndex)
+	presence := store.NewPresenceTracker(time.Duration(cfg.OnlineWindow) * time.Second)
+
+	userService := services.NewUserService(memoryStore, ratingIndex, presence, cfg.MinRating, cfg.MaxRating)
+	leaderboardService := services.NewLeaderboardService(memoryStore, ratingIndex, presence)
 	simulator := services.NewScoreSimulator(memoryStore, ratingIndex, cfg.MinRating, cfg.MaxRating, cfg.UpdateInterval)
+	// New users are simulated, and deleted ones skipped, within a second
+	memoryStore.OnMembershipChange(simulator.InvalidateCache)
 
+	if cfg.FinalSigningKey != "" {
+		leaderboardService.SetSigningKey([]byte(cfg.FinalSigningKey))
+	}
+	rankingMode, err := services.ParseRankingMode(cfg.RankingMode)
+	if err != nil {
+		log.Fatalf("Invalid RANKING_MODE: %v", err)
+	}
+	leaderboardService.SetRankingMode(rankingMode)
 	leaderboardHandler := handlers.NewLeaderboardHandler(leaderboardService)
-	userHandler := handlers.NewUserHandler(userService, leaderboardService, simulator, cfg.InitialUsers, ratingIndex, memoryStore)
+	tierHandler := handlers.NewTierHandler(services.NewTierService(ratingIndex))
+	signer, err := middleware.NewSigner(cfg.ResponseSigning, cfg.ResponseSigningKey)
+	if err != nil {
+		log.Fatalf("Invalid RESPONSE_SIGNING settings: %v", err)
+	}
+	signingHandler := handlers.NewSigningHandler(signer)
+	seedGuard := services.NewSeedGuard(time.Duration(cfg.SeedCooldown)*time.Second, cfg.IsProduction())
+	userHandler := handlers.NewUserHandler(userService, leaderboardService, simulator, cfg.InitialUsers, ratingIndex, memoryStore, seedGuard)
+	if postgres == nil {
+		userHandler.SetPersistence(persistence)
+	}
+	userHandler.SetAutoSaver(autoSaver)
+	mergeStrategy, err := services.ParseMergeStrategy(cfg.MergeStrategy)
+	if err != nil {
+		log.Fatalf("Invalid MERGE_RATING_STRATEGY: %v", err)
+	}
+	userService.SetMergeStrategy(mergeStrategy)
+	usernamePolicy, err := services.NewUsernamePolicy(services.UsernamePolicyConfig(cfg.Usernames))
+	if err != nil {
+		log.Fatalf("Invalid USERNAME_* settings: %v", err)
+	}
+	userService.SetUsernamePolicy(usernamePolicy)
+	ratingEngine, err := services.NewRatingEngine(cfg.RatingSystem, float64(cfg.EloK))
+	if err != nil {
+		log.Fatalf("Invalid RATING_SYSTEM: %v", err)
+	}
+	userService.SetRatingEngine(ratingEngine)
+
+	// Named leaderboards under /api/boards; the unprefixed routes serve the
+	// default one
+	boards := services.NewLeaderboardManager(cfg.BoardsDir, &services.Board{
+		Store:       memoryStore,
+		RatingIndex: ratingIndex,
+		Users:       userService,
+		Leaderboard: leaderboardService,
+	}, services.BoardOptions{
+		SkipListImpl:    skipListImpl,
+		SkipListParams:  skipListParams,
+		RatingRangeMode: ratingRangeMode,
+		MinRating:       cfg.MinRating,
+		MaxRating:       cfg.MaxRating,
+		Ranking:         rankingMode,
+		UsernamePolicy:  usernamePolicy,
+		RatingEngine:    ratingEngine,
+		Presence:        presence,
+		MaxBoards:       cfg.MaxBoards,
+
+		PersistenceShards:  cfg.PersistenceShards,
+		PersistenceWorkers: cfg.PersistenceWorkers,
+		SyncPolicy:         syncPolicy,
+		AutosaveInterval:   time.Duration(cfg.AutosaveInterval) * time.Second,
+		AutosaveWrites:     cfg.AutosaveWrites,
+	})
+	if err := boards.Load(); err != nil {
+		log.Fatalf("Failed to load boards: %v", err)
+	}
+	if err := jobs.Register("boards-save", store.AutosaveCheckInterval, func(ctx context.Context) error {
+		return boards.Autosave()
+	}); err != nil {
+		log.Fatalf("Failed to schedule job: %v", err)
+	}
+	boardsHandler := handlers.NewBoardsHandler(boards)
+
+	// rank_change in responses counts places moved since the last snapshot
+	if cfg.RankSnapshots > 0 {
+		snapshotRanks := func(ctx context.Context) error {
+			if err := leaderboardService.SnapshotRanks(ctx); err != nil {
+				return err
+			}
+			return boards.SnapshotRanks(ctx)
+		}
+		warmup.OnReady(func() { snapshotRanks(context.Background()) })
+		if err := jobs.RegisterExclusive("rank-snapshot", time.Duration(cfg.RankSnapshots)*time.Second, snapshotRanks); err != nil {
+			log.Fatalf("Failed to schedule job: %v", err)
+		}
+	}
+	// Glicko-2 ratings grow less certain for every period a user sits out.
+	// Like rank snapshots, this runs on one replica per period when replicas
+	// share JOB_LOCK_DIR, so a shared database is not inflated once each.
+	if ratingEngine.Name() == services.RatingSystemGlicko2 && cfg.GlickoPeriod > 0 {
+		if err := jobs.RegisterExclusive("glicko-rd-inflation", time.Duration(cfg.GlickoPeriod)*time.Second, func(ctx context.Context) error {
+			userService.InflateDeviations()
+			boards.InflateDeviations()
+			return nil
+		}); err != nil {
+			log.Fatalf("Failed to schedule job: %v", err)
+		}
+	}
+	adminHandler := handlers.NewAdminHandler(userService, jobs)
+	chaos, err := middleware.NewChaos(models.ChaosSettings(cfg.Chaos), cfg.IsProduction())
+	if err != nil {
+		log.Fatalf("Invalid CHAOS_* settings: %v", err)
+	}
+	adminHandler.SetChaos(chaos)
+	snapshotService := services.NewSnapshotService(store.NewNamedSnapshots(cfg.SnapshotDir), memoryStore)
+	snapshotHandler := handlers.NewSnapshotHandler(snapshotService)
+	seasonArchive := store.NewSeasonArchive(cfg.ArchiveDir, cfg.ArchiveCache)
+	archiveService := services.NewArchiveService(seasonArchive, memoryStore)
+	archiveService.SetBaseRating(cfg.SeasonBase)
+	archiveHandler := handlers.NewArchiveHandler(archiveService)
+	summaryHandler := handlers.NewSummaryHandler(services.NewSummaryService(memoryStore, ratingIndex, seasonArchive))
+
+	// Every change from here on is pushed to /api/ws clients
+	liveHub := services.NewLiveHub()
+	memoryStore.AddChangeFeed(liveHub.Feed())
+	liveHub.Start()
+	liveHandler := handlers.NewLiveHandler(liveHub)
+	streamHandler := handlers.NewStreamHandler(leaderboardService, liveHub, time.Duration(cfg.StreamDebounce)*time.Millisecond)
+
+	// The first page is computed once per tick and pushed to every client
+	var topPage *services.TopPageBroadcaster
+	if cfg.TopPageInterval > 0 {
+		topPage = services.NewTopPageBroadcaster(liveHub, leaderboardService, memoryStore, time.Duration(cfg.TopPageInterval)*time.Millisecond)
+		topPage.Start()
+		liveHandler.SetTopPage(topPage)
+	}
+
+	achievementLedger := store.NewAchievementLedger(cfg.AchievementsFile)
+	if err := achievementLedger.Load(); err != nil {
+		log.Printf("Warning: failed to load achievements: %v\n", err)
+	}
+	achievementService := services.NewAchievementService(memoryStore, achievementLedger)
+	achievementService.SetLiveHub(liveHub)
+	memoryStore.AddChangeFeed(achievementService.Feed())
+	achievementService.Start()
+	if err := jobs.Register("achievements-save", 30*time.Second, func(ctx context.Context) error {
+		return achievementService.Save()
+	}); err != nil {
+		log.Fatalf("Failed to schedule job: %v", err)
+	}
+	achievementsHandler := handlers.NewAchievementsHandler(achievementService)
+
+	// Webhooks are delivered by a bounded worker pool, never by the caller
+	webhookPolicy, err := notify.ParsePolicy(cfg.Webhooks.Policy)
+	if err != nil {
+		log.Fatalf("Invalid WEBHOOK_POLICY: %v", err)
+	}
+	webhooks := notify.NewDispatcher(notify.Options{
+		Workers:     cfg.Webhooks.Workers,
+		QueueSize:   cfg.Webhooks.QueueSize,
+		Policy:      webhookPolicy,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Timeout:     time.Duration(cfg.Webhooks.Timeout) * time.Millisecond,
+	})
+	// Undeliverable messages are kept for replay rather than only logged
+	deadLetters, err := notify.OpenDeadLetterQueue(cfg.Webhooks.DeadLetterFile, cfg.Webhooks.DeadLetterLimit)
+	if err != nil {
+		log.Fatalf("Failed to open dead letter queue: %v", err)
+	}
+	webhooks.SetDeadLetterHandler(func(letter notify.DeadLetter) {
+		if err := deadLetters.Add(letter); err != nil {
+			log.Printf("Failed to save dead letter: %v\n", err)
+		}
+	})
+	if err := jobs.Register("deadletters-sync", time.Second, func(ctx context.Context) error {
+		return deadLetters.Sync()
+	}); err != nil {
+		log.Fatalf("Failed to schedule job: %v", err)
+	}
+	webhooks.Start()
+	userHandler.SetWebhooks(webhooks)
+	adminHandler.SetDeadLetters(deadLetters, webhooks)
+
+	var changeWebhook *services.ChangeWebhook
+	if cfg.Webhooks.EventURL != "" {
+		changeWebhook = services.NewChangeWebhook(cfg.Webhooks.EventURL, webhooks)
+		memoryStore.AddChangeFeed(changeWebhook.Feed())
+		changeWebhook.Start()
+	}
+
+	requestsTotal, serverErrors := middleware.RequestCounters()
+	alertEvaluator := alerts.NewEvaluator(cfg.Alerts.WebhookURL)
+	alertEvaluator.SetDispatcher(webhooks)
+	alertEvaluator.AddRule(alerts.Rule{
+		Name:        "p99_latency",
+		Description: "p99 latency of store operations",
+		Unit:        "ms",
+		Threshold:   cfg.Alerts.P99LatencyMs,
+		Probe:       alerts.MaxP99Probe(metrics.Default),
+	})
+	alertEvaluator.AddRule(alerts.Rule{
+		Name:        "memory",
+		Description: "Heap memory in use",
+		Unit:        "MB",
+		Threshold:   cfg.Alerts.MemoryMB,
+		Probe:       alerts.HeapProbe(),
+	})
+	alertEvaluator.AddRule(alerts.Rule{
+		Name:        "error_rate",
+		Description: "Fraction of HTTP requests answered with 5xx",
+		Unit:        "ratio",
+		Threshold:   cfg.Alerts.ErrorRate,
+		Probe:       alerts.ErrorRateProbe(requestsTotal, serverErrors),
+	})
+	alertEvaluator.AddRule(alerts.Rule{
+		Name:        "simulator_stall",
+		Description: "Seconds the running simulator has gone without an update",
+		Unit:        "s",
+		Threshold:   float64(cfg.Alerts.SimulatorStall),
+		Probe: func() float64 {
+			return simulator.StalledFor().Seconds()
+		},
+	})
+	if err := jobs.Register("alerts", 10*time.Second, func(ctx context.Context) error {
+		alertEvaluator.Evaluate()
+		return nil
+	}); err != nil {
+		log.Fatalf("Failed to schedule job: %v", err)
+	}
+	alertsHandler := handlers.NewAlertsHandler(alertEvaluator)
+
+	// Push metrics as well when the server can't be scraped
+	var statsd *metrics.StatsdEmitter
+	if cfg.Statsd.Addr != "" {
+		statsd, err = metrics.NewStatsdEmitter(metrics.Default, cfg.Statsd.Addr, cfg.Statsd.Prefix, cfg.Statsd.Tags)
+		if err != nil {
+			log.Fatalf("Failed to set up StatsD: %v", err)
+		}
+		if err := jobs.Register("statsd-push", time.Duration(cfg.Statsd.Interval)*time.Second, func(ctx context.Context) error {
+			return statsd.Flush()
+		}); err != nil {
+			log.Fatalf("Failed to schedule job: %v", err)
+		}
+	}
 
 	router := mux.NewRouter()
+	probes := handlers.NewProbeHandler(warmup)
+	router.HandleFunc("/healthz", probes.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readyz).Methods("GET")
+	router.HandleFunc("/metrics", handlers.NewMetricsHandler(metrics.Default).Prometheus).Methods("GET")
 
 	api := router.PathPrefix("/api").Subrouter()
 
-	api.HandleFunc("/leaderboard", leaderboardHandler.GetLeaderboard).Methods("GET")
+	// Standings and ranks are signed when RESPONSE_SIGNING is set
+	api.Handle("/leaderboard", signer.SignFunc(leaderboardHandler.GetLeaderboard)).Methods("GET")
+	api.HandleFunc("/leaderboard/stream", streamHandler.StreamLeaderboard).Methods("GET")
+	api.Handle("/leaderboard/final", signer.SignFunc(leaderboardHandler.GetFinalStandings)).Methods("GET")
+	api.HandleFunc("/signing-key", signingHandler.GetSigningKey).Methods("GET")
 	api.HandleFunc("/search", leaderboardHandler.SearchUsers).Methods("GET")
+	api.HandleFunc("/search/suggest", leaderboardHandler.SuggestUsernames).Methods("GET")
+	api.HandleFunc("/stats/buckets", leaderboardHandler.GetRatingDistribution).Methods("GET")
+	api.HandleFunc("/tiers", tierHandler.ListTiers).Methods("GET")
+	api.HandleFunc("/ws", liveHandler.Stream).Methods("GET")
+	api.Handle("/ranks", signer.SignFunc(leaderboardHandler.LookupRanks)).Methods("POST")
+	api.Handle("/leaderboard/subset", signer.SignFunc(leaderboardHandler.GetSubsetLeaderboard)).Methods("POST")
+
+	api.HandleFunc("/snapshots", snapshotHandler.ListSnapshots).Methods("GET")
+	api.HandleFunc("/snapshots", snapshotHandler.CreateSnapshot).Methods("POST")
+	api.HandleFunc("/snapshots/{a}/diff/{b}", snapshotHandler.DiffSnapshots).Methods("GET")
+
+	api.HandleFunc("/seasons", archiveHandler.ListSeasons).Methods("GET")
+	api.HandleFunc("/seasons/{season}/leaderboard", archiveHandler.GetSeasonLeaderboard).Methods("GET")
+	api.HandleFunc("/seasons/{season}/users/{id}", archiveHandler.GetSeasonStanding).Methods("GET")
 
 	api.HandleFunc("/seed", userHandler.SeedUsers).Methods("POST")
-	api.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
+	api.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
+	api.Handle("/users", signer.SignFunc(userHandler.GetUsers)).Methods("GET")
+	api.HandleFunc("/users/recent", userHandler.GetRecentUsers).Methods("GET")
+	api.HandleFunc("/users/sample", userHandler.SampleUsers).Methods("GET")
+	api.HandleFunc("/usernames/check", userHandler.CheckUsername).Methods("GET")
+	api.Handle("/users/by-username/{username}", signer.SignFunc(userHandler.GetUserByUsername)).Methods("GET")
+	api.Handle("/users/{id}", signer.SignFunc(userHandler.GetUser)).Methods("GET")
+	api.HandleFunc("/users/{id}", userHandler.DeleteUser).Methods("DELETE")
+	api.HandleFunc("/users/{id}/summary", summaryHandler.GetUserSummary).Methods("GET")
+	api.HandleFunc("/users/{id}/context", userHandler.GetAroundUser).Methods("GET")
+	api.HandleFunc("/users/{id}/achievements", achievementsHandler.GetAchievements).Methods("GET")
+	api.HandleFunc("/users/{id}/history", userHandler.GetRatingHistory).Methods("GET")
 	api.HandleFunc("/users/{id}/rating", userHandler.UpdateRating).Methods("PATCH")
+	api.HandleFunc("/users/{id}/heartbeat", userHandler.Heartbeat).Methods("POST")
+	api.HandleFunc("/matches", userHandler.RecordMatch).Methods("POST")
 
 	api.HandleFunc("/health", userHandler.Health).Methods("GET")
+	api.HandleFunc("/alerts", alertsHandler.ListAlerts).Methods("GET")
 	api.HandleFunc("/simulator/start", userHandler.StartSimulator).Methods("POST")
 	api.HandleFunc("/simulator/stop", userHandler.StopSimulator).Methods("POST")
+	api.HandleFunc("/simulator/pause", userHandler.PauseSimulator).Methods("POST")
+	api.HandleFunc("/simulator/resume", userHandler.ResumeSimulator).Methods("POST")
+	api.HandleFunc("/simulator/refresh", userHandler.RefreshSimulatorCache).Methods("POST")
 	api.HandleFunc("/simulator/status", userHandler.SimulatorStatus).Methods("GET")
 
+	api.HandleFunc("/admin/users/delete", adminHandler.BulkDeleteUsers).Methods("POST")
+	api.HandleFunc("/admin/users/{keep}/merge/{dupe}", adminHandler.MergeUsers).Methods("POST")
+	api.HandleFunc("/admin/users/{id}/bot", adminHandler.SetBot).Methods("PUT")
+	api.HandleFunc("/admin/export/users.csv.gz", leaderboardHandler.ExportUsers).Methods("GET")
+	api.HandleFunc("/admin/reset", adminHandler.Reset).Methods("POST")
+	api.HandleFunc("/admin/seasons/{season}/archive", archiveHandler.ArchiveSeason).Methods("POST")
+	api.HandleFunc("/admin/seasons/{season}/close", archiveHandler.CloseSeason).Methods("POST")
+	api.HandleFunc("/admin/jobs", adminHandler.ListJobs).Methods("GET")
+	api.HandleFunc("/admin/jobs/{name}/run", adminHandler.RunJob).Methods("POST")
+	api.HandleFunc("/admin/chaos", adminHandler.GetChaos).Methods("GET")
+	api.HandleFunc("/admin/chaos", adminHandler.UpdateChaos).Methods("PUT")
+	api.HandleFunc("/admin/deadletters", adminHandler.ListDeadLetters).Methods("GET")
+	api.HandleFunc("/admin/deadletters/replay", adminHandler.ReplayDeadLetters).Methods("POST")
+	api.HandleFunc("/admin/ipfilter", adminHandler.GetIPFilter).Methods("GET")
+	api.HandleFunc("/admin/ipfilter/blocks", adminHandler.BlockIP).Methods("POST")
+	api.HandleFunc("/admin/ipfilter/blocks", adminHandler.UnblockIP).Methods("DELETE")
+
+	api.HandleFunc("/boards", boardsHandler.ListBoards).Methods("GET")
+	api.HandleFunc("/boards", boardsHandler.CreateBoard).Methods("POST")
+	api.HandleFunc("/boards/{board}", boardsHandler.DeleteBoard).Methods("DELETE")
+	api.HandleFunc("/boards/{board}/leaderboard", boardsHandler.GetLeaderboard).Methods("GET")
+	api.HandleFunc("/boards/{board}/search", boardsHandler.SearchUsers).Methods("GET")
+	api.HandleFunc("/boards/{board}/users", boardsHandler.CreateUser).Methods("POST")
+	api.HandleFunc("/boards/{board}/users/{id}", boardsHandler.GetUser).Methods("GET")
+	api.HandleFunc("/boards/{board}/users/{id}", boardsHandler.DeleteUser).Methods("DELETE")
+	api.HandleFunc("/boards/{board}/users/{id}/context", boardsHandler.GetAroundUser).Methods("GET")
+	api.HandleFunc("/boards/{board}/users/{id}/history", boardsHandler.GetRatingHistory).Methods("GET")
+	api.HandleFunc("/boards/{board}/users/{id}/rating", boardsHandler.UpdateRating).Methods("PATCH")
+	api.HandleFunc("/boards/{board}/matches", boardsHandler.RecordMatch).Methods("POST")
+
 	// Initialize middleware
 	rateLimiter := middleware.NewRateLimiter(100, 200) // 100 req/sec, burst of 200
-	rateLimiter.CleanupOldVisitors(time.Minute * 10)
+	// Only visitors idle for a while are forgotten, so nobody's burst resets
+	if err := jobs.Register("rate-limiter-cleanup", time.Minute, func(ctx context.Context) error {
+		rateLimiter.CleanupVisitors()
+		return nil
+	}); err != nil {
+		log.Fatalf("Failed to schedule job: %v", err)
+	}
+	userHandler.SetRateLimiter(rateLimiter)
+
+	// Refused clients are turned away before they count against rate limits
+	ipFilter, err := middleware.NewIPFilter(cfg.IPFilter.Allow, cfg.IPFilter.Deny)
+	if err != nil {
+		log.Fatalf("Invalid IP_ALLOWLIST or IP_DENYLIST: %v", err)
+	}
+	adminHandler.SetIPFilter(ipFilter)
+	if err := jobs.Register("ip-block-expiry", time.Minute, func(ctx context.Context) error {
+		ipFilter.Expire()
+		return nil
+	}); err != nil {
+		log.Fatalf("Failed to schedule job: %v", err)
+	}
+
+	jwtAuth, err := middleware.NewJWTAuth(cfg.Auth.JWTAlgorithm, cfg.Auth.JWTKey)
+	if err != nil {
+		log.Fatalf("Invalid JWT_* settings: %v", err)
+	}
+	if cfg.Auth.JWTNoExpiry {
+		jwtAuth.AllowNoExpiry()
+	}
+	if jwtAuth.Enabled() {
+		userHandler.RequireRatingTokens()
+		boardsHandler.RequireRatingTokens()
+	}
+	apiKeys, err := middleware.NewAPIKeyAuth(cfg.Auth.APIKeys, cfg.Auth.APIKeyReads)
+	if err != nil {
+		log.Fatalf("Invalid API_KEYS setting: %v", err)
+	}
+	if !apiKeys.Enabled() {
+		log.Println("WARNING: API_KEYS is not set; anyone can seed, reset or change ratings")
+	}
 
 	logger := middleware.NewLogger()
 
+	// Refuse writes while the queues behind them back up
+	queues := []middleware.Queue{
+		{Name: "live-hub", Backlog: liveHub.Backlog},
+		{Name: "achievements", Backlog: achievementService.Backlog},
+	}
+	if wal != nil {
+		queues = append(queues, middleware.Queue{Name: "wal", Backlog: func() (int, int) {
+			return wal.Pending(), cfg.Backpressure.WALEntries
+		}})
+	}
+	backpressure := middleware.NewBackpressure(cfg.Backpressure.Soft, cfg.Backpressure.Hard, queues...)
+	userHandler.SetBackpressure(backpressure)
+
+	// Let browser clients read fault and signature markers
+	exposedHeaders := []string{
+		middleware.ChaosHeader,
+		middleware.SignatureHeader,
+		middleware.SignatureTimestampHeader,
+		middleware.SignatureKeyIDHeader,
+		middleware.SignatureAlgorithmHeader,
+		tracing.TraceparentHeader,
+		tracing.CloudTraceHeader,
+	}
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "ngrok-skip-browser-warning"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", middleware.APIKeyHeader, "ngrok-skip-browser-warning", tracing.TraceparentHeader, tracing.CloudTraceHeader},
+		ExposedHeaders:   exposedHeaders,
 		AllowCredentials: true,
 	})
 
-	// Chain middleware: CORS -> RateLimiter -> Logger -> Router
-	handler := c.Handler(rateLimiter.Limit(logger.LogRequest(router)))
+	// Chain middleware: CORS -> Trace -> Chaos -> IPFilter -> RateLimiter -> Logger -> JWT -> APIKeys -> Backpressure -> Timeout -> Router
+	// Chaos sits inside CORS so browsers can read injected errors, and inside
+	// Trace so they can be traced too
+	// Streams stay open for as long as the client listens
+	timeout := middleware.RequestTimeout(time.Duration(cfg.RequestTimeout)*time.Millisecond, "/api/leaderboard/stream", "/api/ws")
+	handler := c.Handler(middleware.Trace(chaos.Inject(ipFilter.Filter(rateLimiter.Limit(logger.LogRequest(middleware.Authenticate(jwtAuth, apiKeys, backpressure.Limit(probes.Gate(timeout(router))))))))))
 
 	// Create server with proper shutdown handling
 	server := &http.Server{
@@ -87,59 +580,180 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// Graceful shutdown channel
-	done := make(chan bool)
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	// Open streams would otherwise hold up the server's shutdown
+	server.RegisterOnShutdown(streamHandler.Close)
 
-	go func() {
-		<-quit
-		fmt.Println("\nShutting down server...")
+	// Components register ordered shutdown hooks instead of being torn down ad hoc
+	lc := lifecycle.NewManager()
 
-		// Stop simulator
+	lc.Register("simulator", lifecycle.OrderProducers, 5*time.Second, func(ctx context.Context) error {
 		simulator.Stop()
+		return nil
+	})
 
-		// Save data to disk
-		fmt.Println("Saving data to disk...")
-		if err := persistence.Save(memoryStore); err != nil {
-			log.Printf("Warning: failed to save data: %v\n", err)
-		} else {
-			fmt.Printf("Saved %d users to disk\n", memoryStore.GetUserCount())
+	lc.Register("scheduler", lifecycle.OrderProducers, 30*time.Second, jobs.Stop)
+
+	lc.Register("http-server", lifecycle.OrderServer, 30*time.Second, server.Shutdown)
+
+	if topPage != nil {
+		lc.Register("top-page", lifecycle.OrderProducers, 5*time.Second, topPage.Stop)
+	}
+	lc.Register("live-hub", lifecycle.OrderPublishers, 5*time.Second, liveHub.Stop)
+
+	// Hand queued changes to the dispatcher before it drains
+	if changeWebhook != nil {
+		lc.Register("change-webhook", lifecycle.OrderPublishers, 5*time.Second, changeWebhook.Stop)
+	}
+	lc.Register("webhooks", lifecycle.OrderPublishers, 10*time.Second, webhooks.Stop)
+
+	if statsd != nil {
+		lc.Register("statsd", lifecycle.OrderPublishers, 5*time.Second, func(ctx context.Context) error {
+			return statsd.Close()
+		})
+	}
+
+	lc.Register("dead-letters", lifecycle.OrderPersistence, 5*time.Second, func(ctx context.Context) error {
+		return deadLetters.Close()
+	})
+
+	lc.Register("boards", lifecycle.OrderPersistence, 30*time.Second, func(ctx context.Context) error {
+		return boards.Save()
+	})
+
+	lc.Register("achievements", lifecycle.OrderPersistence, 10*time.Second, func(ctx context.Context) error {
+		if err := achievementService.Stop(ctx); err != nil {
+			return err
 		}
+		return achievementService.Save()
+	})
 
-		// Graceful shutdown with timeout
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	lc.Register("persistence", lifecycle.OrderPersistence, 30*time.Second, func(ctx context.Context) error {
+		fmt.Println("Saving data...")
+		saveErr := saver.Save(memoryStore)
+		// Flush the log even if the save failed so recovery can replay it
+		if wal != nil {
+			if err := wal.Close(); err != nil {
+				return fmt.Errorf("failed to close write-ahead log: %w", err)
+			}
+		}
+		if saveErr != nil {
+			return fmt.Errorf("failed to save data: %w", saveErr)
+		}
+		fmt.Printf("Saved %d users\n", memoryStore.GetUserCount())
+		if postgres != nil {
+			return postgres.Close()
+		}
+		return persistence.MarkClean()
+	})
 
-		if err := server.Shutdown(ctx); err != nil {
-			log.Fatalf("Server forced to shutdown: %v", err)
+	// Demo environments come up populated; the autosaver then writes the
+	// seeded board, so later boots load it instead
+	if cfg.AutoSeed && firstBoot {
+		added, err := userService.SeedUsers(context.Background(), cfg.InitialUsers)
+		if err != nil {
+			log.Printf("Warning: auto-seed stopped early: %v\n", err)
 		}
+		simulator.Start()
+		fmt.Printf("Auto-seeded %d users and started the simulator\n", added)
+	}
 
-		close(done)
-	}()
+	jobs.Start()
+	warmup.Start()
+	go lc.WaitForSignal(syscall.SIGINT, syscall.SIGTERM)
 
 	fmt.Printf("Leaderboard Server starting on port %s\n", cfg.Port)
+	fmt.Printf("Environment: %s\n", cfg.Environment)
 	fmt.Printf("Rating range: %d - %d\n", cfg.MinRating, cfg.MaxRating)
 	fmt.Printf("Initial users: %d\n", cfg.InitialUsers)
 	fmt.Printf("Update interval: %dms\n", cfg.UpdateInterval)
 	fmt.Printf("Rate limiting: 100 req/sec, burst 200\n")
-	fmt.Printf("Persistence: %s\n", persistenceFile)
+	if postgres != nil {
+		fmt.Println("Persistence: PostgreSQL (DATABASE_URL)")
+	} else {
+		fmt.Printf("Persistence: %s (%d shards, %d workers, fsync %s)\n", persistenceFile, cfg.PersistenceShards, cfg.PersistenceWorkers, syncPolicy)
+	}
+	fmt.Printf("Autosave: every %ds or %d writes\n", cfg.AutosaveInterval, cfg.AutosaveWrites)
+	if skipListImpl == store.SkipListBTree {
+		fmt.Println("Ranking structure: btree")
+	} else {
+		fmt.Printf("Skip list: %s, %d levels, promotion probability %.2f\n", skipListImpl, skipListParams.MaxLevel, skipListParams.Probability)
+	}
+	if signer.Enabled() {
+		fmt.Printf("Response signing: %s (key %s)\n", signer.Algorithm(), signer.KeyID())
+		if signer.Ephemeral() {
+			log.Printf("Warning: no RESPONSE_SIGNING_KEY set; signing with a key that changes on every restart")
+		}
+	}
+	if cfg.Chaos.Enabled {
+		fmt.Printf("WARNING: fault injection enabled (latency %dms on %.0f%%, errors %.0f%%, drops %.0f%%)\n",
+			cfg.Chaos.LatencyMs, cfg.Chaos.LatencyRate*100, cfg.Chaos.ErrorRate*100, cfg.Chaos.DropRate*100)
+	}
 	fmt.Println("\nAPI Endpoints:")
 	fmt.Println("  GET  /api/leaderboard     - Get paginated leaderboard")
+	fmt.Println("  GET  /api/leaderboard/stream - Server-Sent Events: the page whenever it changes")
+	fmt.Println("  GET  /api/leaderboard/final?top=100 - Consistent, signed top N for prize payouts")
+	fmt.Println("  GET  /api/signing-key     - How signed responses are verified")
 	fmt.Println("  GET  /api/search?q=query  - Search users by username")
-	fmt.Println("  POST /api/seed            - Seed initial users")
+	fmt.Println("  GET  /api/search/suggest?q=prefix - Username typeahead suggestions")
+	fmt.Println("  GET  /api/stats/buckets   - Rating distribution (?step=N to down-sample)")
+	fmt.Println("  GET  /api/tiers           - Tier rating bands and how many users are in each")
+	fmt.Println("  POST /api/ranks           - Batch rank lookup by user IDs or ratings")
+	fmt.Println("  POST /api/leaderboard/subset - Friends-only board: given users sorted, with global ranks")
+	fmt.Println("  GET  /api/ws              - WebSocket stream of rating and rank changes")
+	fmt.Println("  POST /api/snapshots       - Save a named leaderboard snapshot")
+	fmt.Println("  GET  /api/snapshots/{a}/diff/{b} - Rank movements between two snapshots")
+	fmt.Println("  GET  /api/seasons/{season}/leaderboard - Archived final standings")
+	fmt.Println("  GET  /api/seasons/{season}/users/{id} - Final rank and percentile")
+	fmt.Println("  POST /api/admin/seasons/{season}/close - Archive the season and reset ratings")
+	fmt.Println("  POST /api/seed            - Seed additional users")
+	fmt.Println("  POST /api/users           - Create a user")
 	fmt.Println("  GET  /api/users/{id}      - Get user by ID")
+	fmt.Println("  GET  /api/users?ids=a,b,c - Get up to 100 users by ID in one request")
+	fmt.Println("  DELETE /api/users/{id}    - Remove a user")
+	fmt.Println("  GET  /api/users/{id}/summary - Standing on every leaderboard")
+	fmt.Println("  GET  /api/users/{id}/context?window=5 - Players ranked around a user")
+	fmt.Println("  GET  /api/users/{id}/achievements - Unlocked achievements and win streak")
+	fmt.Println("  GET  /api/users/{id}/history?limit=100 - Latest rating changes")
+	fmt.Println("  GET  /api/users/by-username/{username} - Get user by username")
+	fmt.Println("  GET  /api/users/recent    - Most recently updated users")
+	fmt.Println("  GET  /api/users/sample?n=10&weight=rating - Random users to feature")
+	fmt.Println("  GET  /api/usernames/check?name= - Check a username for signup")
 	fmt.Println("  PATCH /api/users/{id}/rating - Update user rating")
+	fmt.Println("  POST /api/users/{id}/heartbeat - Mark user online")
+	fmt.Println("  POST /api/matches         - Record a match result; ratings are computed server-side")
 	fmt.Println("  GET  /api/health          - Health check with stats")
+	fmt.Println("  GET  /healthz             - Liveness: up as soon as the server listens")
+	fmt.Println("  GET  /readyz              - Readiness: 503 with progress until indexes are built")
+	fmt.Println("  GET  /api/alerts          - Alert states (active/resolved)")
+	fmt.Println("  GET  /metrics             - Prometheus metrics (store latency histograms)")
 	fmt.Println("  POST /api/simulator/start - Start score simulator")
 	fmt.Println("  POST /api/simulator/stop  - Stop score simulator")
+	fmt.Println("  POST /api/simulator/pause - Pause the simulator, keeping its state")
+	fmt.Println("  POST /api/simulator/resume - Resume a paused simulator")
+	fmt.Println("  POST /api/simulator/refresh - Rebuild the simulator's user ID cache")
 	fmt.Println("  GET  /api/simulator/status - Get simulator status")
+	fmt.Println("  POST /api/admin/users/delete - Bulk delete users by IDs or filter")
+	fmt.Println("  POST /api/admin/users/{keep}/merge/{dupe} - Merge a duplicate account")
+	fmt.Println("  PUT  /api/admin/users/{id}/bot - Flag a bot or simulator account")
+	fmt.Println("  GET  /api/admin/export/users.csv.gz - Export users as gzip CSV")
+	fmt.Println("  POST /api/admin/reset     - Clear all data (two-step confirmation)")
+	fmt.Println("  GET  /api/admin/jobs      - Background job status")
+	fmt.Println("  PUT  /api/admin/chaos     - Configure fault injection (off by default)")
+	fmt.Println("  GET  /api/admin/deadletters - Webhook messages that could not be delivered")
+	fmt.Println("  POST /api/admin/deadletters/replay - Deliver dead letters again")
+	fmt.Println("  GET  /api/admin/ipfilter  - IP allow/deny lists and temporary blocks")
+	fmt.Println("  POST /api/admin/ipfilter/blocks - Temporarily block an IP or CIDR range")
+	fmt.Println("  DELETE /api/admin/ipfilter/blocks?cidr= - Lift a temporary block")
+	fmt.Println("  GET  /api/boards          - Named leaderboards")
+	fmt.Println("  POST /api/boards          - Create a named leaderboard")
+	fmt.Println("  DELETE /api/boards/{board} - Remove a named leaderboard")
+	fmt.Println("  *    /api/boards/{board}/... - leaderboard, search, users and matches routes on one board")
 	fmt.Println("\nPress Ctrl+C to save and exit gracefully")
 
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed: %v", err)
 	}
 
-	<-done
+	<-lc.Done()
 	fmt.Println("Server stopped gracefully")
 }
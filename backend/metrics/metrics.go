@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are upper bounds in seconds, from 1µs to 2.5s
+var DefaultLatencyBuckets = []float64{
+	0.000001, 0.0000025, 0.000005,
+	0.00001, 0.000025, 0.00005,
+	0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005,
+	0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5,
+	1, 2.5,
+}
+
+// Histogram records observations into fixed buckets using atomic counters,
+// so Observe is cheap enough to call on every store operation
+type Histogram struct {
+	name    string
+	help    string
+	bounds  []float64
+	counts  []uint64 // one per bound plus +Inf
+	count   uint64
+	sumNano int64
+}
+
+// NewHistogram creates a latency histogram and registers it with Default
+func NewHistogram(name, help string) *Histogram {
+	h := &Histogram{
+		name:   name,
+		help:   help,
+		bounds: DefaultLatencyBuckets,
+		counts: make([]uint64, len(DefaultLatencyBuckets)+1),
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe records one duration
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	idx := sort.SearchFloat64s(h.bounds, seconds)
+	atomic.AddUint64(&h.counts[idx], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddInt64(&h.sumNano, int64(d))
+}
+
+// ObserveSince records the time elapsed since start
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+// Name returns the metric name
+func (h *Histogram) Name() string {
+	return h.name
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+// Quantile estimates the q-th quantile (0..1) in seconds by linear
+// interpolation within the bucket containing it
+func (h *Histogram) Quantile(q float64) float64 {
+	counts := make([]uint64, len(h.counts))
+	var total uint64
+	for i := range h.counts {
+		counts[i] = atomic.LoadUint64(&h.counts[i])
+		total += counts[i]
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+	var cumulative uint64
+	for i, c := range counts {
+		if float64(cumulative+c) >= rank && c > 0 {
+			if i == len(h.bounds) {
+				return h.bounds[len(h.bounds)-1]
+			}
+			lower := 0.0
+			if i > 0 {
+				lower = h.bounds[i-1]
+			}
+			fraction := (rank - float64(cumulative)) / float64(c)
+			return lower + (h.bounds[i]-lower)*fraction
+		}
+		cumulative += c
+	}
+	return h.bounds[len(h.bounds)-1]
+}
+
+// Summary returns count, mean and common quantiles in milliseconds
+func (h *Histogram) Summary() map[string]interface{} {
+	count := h.Count()
+	mean := 0.0
+	if count > 0 {
+		mean = float64(atomic.LoadInt64(&h.sumNano)) / float64(count) / 1e6
+	}
+	return map[string]interface{}{
+		"count":   count,
+		"mean_ms": round3(mean),
+		"p50_ms":  round3(h.Quantile(0.50) * 1000),
+		"p95_ms":  round3(h.Quantile(0.95) * 1000),
+		"p99_ms":  round3(h.Quantile(0.99) * 1000),
+	}
+}
+
+func (h *Histogram) writePrometheus(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	cumulative += atomic.LoadUint64(&h.counts[len(h.bounds)])
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, cumulative)
+	fmt.Fprintf(w, "%s_sum %g\n", h.name, float64(atomic.LoadInt64(&h.sumNano))/1e9)
+	fmt.Fprintf(w, "%s_count %d\n", h.name, cumulative)
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	name  string
+	help  string
+	value uint64
+}
+
+// NewCounter creates a counter and registers it with Default
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	Default.registerCounter(c)
+	return c
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// Name returns the metric name
+func (c *Counter) Name() string {
+	return c.name
+}
+
+func (c *Counter) writePrometheus(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+}
+
+// Registry holds named metrics for exposition
+type Registry struct {
+	mu         sync.RWMutex
+	histograms map[string]*Histogram
+	counters   map[string]*Counter
+}
+
+// Default is the process-wide registry served at /metrics
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		histograms: make(map[string]*Histogram),
+		counters:   make(map[string]*Counter),
+	}
+}
+
+func (r *Registry) register(h *Histogram) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.histograms[h.name] = h
+}
+
+func (r *Registry) registerCounter(c *Counter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters[c.name] = c
+}
+
+// Counters returns all registered counters sorted by name
+func (r *Registry) Counters() []*Counter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Counter, 0, len(r.counters))
+	for _, c := range r.counters {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return list
+}
+
+// Histograms returns all registered histograms sorted by name
+func (r *Registry) Histograms() []*Histogram {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Histogram, 0, len(r.histograms))
+	for _, h := range r.histograms {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return list
+}
+
+// WritePrometheus writes every metric in the Prometheus text format
+func (r *Registry) WritePrometheus(w io.Writer) {
+	for _, c := range r.Counters() {
+		c.writePrometheus(w)
+	}
+	for _, h := range r.Histograms() {
+		h.writePrometheus(w)
+	}
+}
+
+// Summaries returns a JSON-friendly latency summary per histogram
+func (r *Registry) Summaries() map[string]interface{} {
+	summaries := make(map[string]interface{})
+	for _, h := range r.Histograms() {
+		summaries[h.name] = h.Summary()
+	}
+	return summaries
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// statsdMaxPacket keeps each datagram under a typical 1500 byte MTU
+const statsdMaxPacket = 1432
+
+// StatsdEmitter pushes a registry to a StatsD or DogStatsD agent over UDP,
+// for servers that can't be scraped. Counters are sent as the increase
+// since the last push; each histogram as its new observation count plus
+// p50/p95/p99 gauges in milliseconds.
+type StatsdEmitter struct {
+	registry *Registry
+	conn     net.Conn
+	prefix   string
+	tags     string // DogStatsD suffix, e.g. "|#env:prod" ("" = plain StatsD)
+
+	mu       sync.Mutex
+	counters map[string]uint64
+	counts   map[string]uint64
+}
+
+// NewStatsdEmitter dials addr ("host:port"). Every metric name is prefixed
+// with prefix and, when tags are given, sent with them in DogStatsD form.
+func NewStatsdEmitter(registry *Registry, addr, prefix string, tags []string) (*StatsdEmitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial statsd at %s: %w", addr, err)
+	}
+
+	e := &StatsdEmitter{
+		registry: registry,
+		conn:     conn,
+		prefix:   prefix,
+		counters: make(map[string]uint64),
+		counts:   make(map[string]uint64),
+	}
+	if len(tags) > 0 {
+		e.tags = "|#" + strings.Join(tags, ",")
+	}
+	return e, nil
+}
+
+// Flush sends one round of metrics. Unchanged counters are skipped.
+func (e *StatsdEmitter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var lines []string
+	for _, c := range e.registry.Counters() {
+		value := c.Value()
+		if delta := value - e.counters[c.name]; delta > 0 {
+			lines = append(lines, e.line(c.name, strconv.FormatUint(delta, 10), "c"))
+		}
+		e.counters[c.name] = value
+	}
+	for _, h := range e.registry.Histograms() {
+		count := h.Count()
+		delta := count - e.counts[h.name]
+		e.counts[h.name] = count
+		if delta == 0 {
+			continue
+		}
+		lines = append(lines, e.line(h.name+".count", strconv.FormatUint(delta, 10), "c"))
+		for _, q := range []struct {
+			suffix string
+			q      float64
+		}{{".p50", 0.50}, {".p95", 0.95}, {".p99", 0.99}} {
+			ms := round3(h.Quantile(q.q) * 1000)
+			lines = append(lines, e.line(h.name+q.suffix, strconv.FormatFloat(ms, 'f', -1, 64), "g"))
+		}
+	}
+
+	return e.send(lines)
+}
+
+// Close sends any changes since the last push and closes the socket
+func (e *StatsdEmitter) Close() error {
+	flushErr := e.Flush()
+	if err := e.conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+func (e *StatsdEmitter) line(name, value, kind string) string {
+	return e.prefix + name + ":" + value + "|" + kind + e.tags
+}
+
+// send packs lines into as few datagrams as fit, newline separated
+func (e *StatsdEmitter) send(lines []string) error {
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := e.conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if err := flush(); err != nil {
+				return fmt.Errorf("failed to send metrics: %w", err)
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	return nil
+}
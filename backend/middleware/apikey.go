@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"leaderboard-backend/metrics"
+)
+
+// APIKeyHeader carries the API key of a request
+const APIKeyHeader = "X-API-Key"
+
+var apiKeyRejectedTotal = metrics.NewCounter("api_key_rejected_total", "Requests refused for a missing, unknown or underprivileged API key")
+
+// Role is what an API key may do. Each role may do everything the ones below
+// it may.
+type Role string
+
+const (
+	// RoleReader may read; it only matters when reads need a key
+	RoleReader Role = "reader"
+	// RoleWriter may also create users, change ratings and record matches
+	RoleWriter Role = "writer"
+	// RoleAdmin may also seed, control the simulator, create boards, delete
+	// anything and use the /api/admin endpoints
+	RoleAdmin Role = "admin"
+)
+
+var roleLevels = map[Role]int{RoleReader: 1, RoleWriter: 2, RoleAdmin: 3}
+
+// readOnlyPosts are lookups sent as POST for their request bodies; they
+// change nothing and are treated as reads
+var readOnlyPosts = map[string]bool{
+	"/api/ranks":              true,
+	"/api/leaderboard/subset": true,
+}
+
+// probePaths answer orchestrators, which hold no key, even when reads need one
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// ParseRole validates a role name
+func ParseRole(name string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(name)))
+	if _, ok := roleLevels[role]; !ok {
+		return "", fmt.Errorf("unknown role %q (want %q, %q or %q)", name, RoleReader, RoleWriter, RoleAdmin)
+	}
+	return role, nil
+}
+
+// Allows reports whether r may do what need may
+func (r Role) Allows(need Role) bool {
+	return roleLevels[r] >= roleLevels[need]
+}
+
+type roleContextKey struct{}
+
+// RoleFromContext returns the role of the API key a request was made with,
+// if any
+func RoleFromContext(ctx context.Context) (Role, bool) {
+	role, ok := ctx.Value(roleContextKey{}).(Role)
+	return role, ok
+}
+
+// APIKeyAuth requires an API key in X-API-Key for requests that change
+// data, with the role the change needs, and leaves reads public unless told
+// otherwise. Keys are held as SHA-256 digests, so a lookup never compares
+// key bytes.
+type APIKeyAuth struct {
+	keys         map[[sha256.Size]byte]Role
+	protectReads bool
+}
+
+// NewAPIKeyAuth creates the middleware from "role:key" entries. With no
+// entries every request is let through, as before keys existed. With
+// protectReads, reads need at least a reader key.
+func NewAPIKeyAuth(entries []string, protectReads bool) (*APIKeyAuth, error) {
+	a := &APIKeyAuth{keys: make(map[[sha256.Size]byte]Role), protectReads: protectReads}
+	for _, entry := range entries {
+		name, key, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(key) == "" {
+			// The entry may be a bare key, so it is not echoed
+			return nil, errors.New("API key entries must be role:key")
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256([]byte(strings.TrimSpace(key)))
+		if _, exists := a.keys[digest]; exists {
+			return nil, fmt.Errorf("API key for role %s is listed twice", role)
+		}
+		a.keys[digest] = role
+	}
+	return a, nil
+}
+
+// Enabled reports whether any key is configured
+func (a *APIKeyAuth) Enabled() bool {
+	return len(a.keys) > 0
+}
+
+// Protect refuses requests without a key good for what they do: 401 when
+// the key is missing or unknown, 403 when its role is too low. The key's
+// role is attached to the request for RoleFromContext. A verified bearer
+// token's role counts when no key is given.
+func (a *APIKeyAuth) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		role, known := a.keys[sha256.Sum256([]byte(r.Header.Get(APIKeyHeader)))]
+		if claims, ok := ClaimsFromContext(r.Context()); !known && ok && claims.Role != "" {
+			// A verified bearer token with a role stands in for a key
+			role, known = claims.Role, true
+		}
+		if known {
+			r = r.WithContext(context.WithValue(r.Context(), roleContextKey{}, role))
+		}
+
+		need := a.RequiredRole(r)
+		switch {
+		case need == "":
+		case !known:
+			apiKeyRejectedTotal.Inc()
+			writeAuthError(w, http.StatusUnauthorized, "unauthorized", fmt.Sprintf("This request needs an API key with the %s role in %s.", need, APIKeyHeader))
+			return
+		case !role.Allows(need):
+			apiKeyRejectedTotal.Inc()
+			writeAuthError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("This request needs the %s role; the API key has %s.", need, role))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequiredRole returns the role a request needs, or "" when it is public
+func (a *APIKeyAuth) RequiredRole(r *http.Request) Role {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead ||
+		r.Method == http.MethodPost && readOnlyPosts[path]
+
+	switch {
+	case r.Method == http.MethodOptions, probePaths[path]:
+		// CORS preflights carry no credentials; probes are always public
+		return ""
+	case r.Method == http.MethodPatch && isOwnRating(r, path):
+		// Users may set their own rating with a bearer token; UpdateRating
+		// checks the subject again
+		return ""
+	case strings.HasPrefix(path, "/api/admin/"),
+		path == "/api/seed",
+		strings.HasPrefix(path, "/api/simulator/") && !read,
+		path == "/api/boards" && !read,
+		r.Method == http.MethodDelete:
+		return RoleAdmin
+	case !read:
+		return RoleWriter
+	case a.protectReads:
+		return RoleReader
+	}
+	return ""
+}
+
+// isOwnRating reports whether path is /api/users/{id}/rating, or the same
+// route on a named board, for the subject of the request's bearer token
+func isOwnRating(r *http.Request, path string) bool {
+	claims, ok := ClaimsFromContext(r.Context())
+	if !ok || claims.Subject == "" {
+		return false
+	}
+	own := "users/" + claims.Subject + "/rating"
+	if path == "/api/"+own {
+		return true
+	}
+	board, rest, ok := strings.Cut(strings.TrimPrefix(path, "/api/boards/"), "/")
+	return ok && board != "" && strings.HasPrefix(path, "/api/boards/") && rest == own
+}
+
+func writeAuthError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `APIKey header="`+APIKeyHeader+`"`)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   code,
+		"message": message,
+	})
+}
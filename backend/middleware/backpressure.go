@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"leaderboard-backend/metrics"
+)
+
+// Queue is an internal queue watched for backpressure. Backlog returns how
+// many items are waiting and how many the queue holds.
+type Queue struct {
+	Name    string
+	Backlog func() (depth, capacity int)
+}
+
+// Pressure levels, from least to most loaded
+const (
+	PressureNone = "none"
+	PressureSoft = "soft" // writes answered with 429
+	PressureHard = "hard" // writes answered with 503
+)
+
+var (
+	backpressureSoftTotal = metrics.NewCounter("http_backpressure_soft_total", "Writes refused with 429 because an internal queue was backing up")
+	backpressureHardTotal = metrics.NewCounter("http_backpressure_hard_total", "Writes refused with 503 because an internal queue was nearly full")
+)
+
+// Backpressure refuses write requests while an internal queue is backing
+// up, so a burst of writes can't grow the queues without bound or have
+// their events dropped. Reads are always let through.
+type Backpressure struct {
+	soft   float64 // fill fraction at which writes get 429
+	hard   float64 // fill fraction at which writes get 503
+	queues []Queue
+}
+
+// NewBackpressure watches queues, refusing writes with 429 once any is
+// filled to the soft fraction of its capacity and with 503 from the hard
+// fraction. A zero fraction disables that level.
+func NewBackpressure(soft, hard float64, queues ...Queue) *Backpressure {
+	return &Backpressure{soft: soft, hard: hard, queues: queues}
+}
+
+// Level returns the current pressure and the fullest queue's name
+func (b *Backpressure) Level() (string, string) {
+	level, worst, worstFill := PressureNone, "", 0.0
+	for _, q := range b.queues {
+		fill := fillOf(q)
+		if fill > worstFill {
+			worst, worstFill = q.Name, fill
+		}
+	}
+	switch {
+	case b.hard > 0 && worstFill >= b.hard:
+		level = PressureHard
+	case b.soft > 0 && worstFill >= b.soft:
+		level = PressureSoft
+	}
+	return level, worst
+}
+
+// Limit is the middleware handler
+func (b *Backpressure) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		level, queue := b.Level()
+		status, retryAfter := 0, 0
+		switch level {
+		case PressureSoft:
+			backpressureSoftTotal.Inc()
+			status, retryAfter = http.StatusTooManyRequests, 1
+		case PressureHard:
+			backpressureHardTotal.Inc()
+			status, retryAfter = http.StatusServiceUnavailable, 5
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "backpressure",
+			"message": "The server is catching up on " + queue + ". Retry later.",
+		})
+	})
+}
+
+// GetStats returns the pressure level and each queue's backlog for the
+// health report
+func (b *Backpressure) GetStats() map[string]interface{} {
+	level, worst := b.Level()
+	queues := make(map[string]interface{}, len(b.queues))
+	for _, q := range b.queues {
+		depth, capacity := q.Backlog()
+		queues[q.Name] = map[string]interface{}{
+			"depth":    depth,
+			"capacity": capacity,
+		}
+	}
+	return map[string]interface{}{
+		"level":          level,
+		"fullest_queue":  worst,
+		"soft_threshold": b.soft,
+		"hard_threshold": b.hard,
+		"queues":         queues,
+		"refused_soft":   backpressureSoftTotal.Value(),
+		"refused_hard":   backpressureHardTotal.Value(),
+	}
+}
+
+func fillOf(q Queue) float64 {
+	depth, capacity := q.Backlog()
+	if capacity <= 0 {
+		return 0
+	}
+	return float64(depth) / float64(capacity)
+}
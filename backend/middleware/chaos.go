@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/models"
+)
+
+// MaxChaosLatency caps injected latency so a misconfigured delay can't hold
+// connections open indefinitely
+const MaxChaosLatency = 30 * time.Second
+
+// ChaosHeader marks responses that carry an injected fault, so clients under
+// test can tell them from genuine failures
+const ChaosHeader = "X-Chaos-Injected"
+
+// ErrChaosForbidden is returned when enabling fault injection in production
+var ErrChaosForbidden = errors.New("fault injection cannot be enabled in production")
+
+// Faults injected so far, by kind
+var (
+	chaosLatencyTotal = metrics.NewCounter("chaos_latency_injected_total", "Requests delayed by fault injection")
+	chaosErrorsTotal  = metrics.NewCounter("chaos_errors_injected_total", "Requests failed with a 5xx by fault injection")
+	chaosDropsTotal   = metrics.NewCounter("chaos_drops_injected_total", "Connections dropped by fault injection")
+)
+
+// chaosExempt lists paths never targeted, so chaos can always be switched
+// off and scraped
+var chaosExempt = []string{"/api/admin/chaos", "/metrics"}
+
+// Chaos injects latency, 5xx errors and dropped connections into a share of
+// requests, for exercising client retry logic. It does nothing until
+// enabled.
+type Chaos struct {
+	mu         sync.RWMutex
+	settings   models.ChaosSettings
+	production bool
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+}
+
+// NewChaos creates a fault injector with the given settings. In production
+// it refuses to be enabled.
+func NewChaos(settings models.ChaosSettings, production bool) (*Chaos, error) {
+	c := &Chaos{
+		production: production,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if err := c.Update(settings); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Settings returns the current settings
+func (c *Chaos) Settings() models.ChaosSettings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings
+}
+
+// Update validates and applies new settings
+func (c *Chaos) Update(settings models.ChaosSettings) error {
+	if settings.Enabled && c.production {
+		return ErrChaosForbidden
+	}
+	rates := []struct {
+		name  string
+		value float64
+	}{
+		{"latency_rate", settings.LatencyRate},
+		{"error_rate", settings.ErrorRate},
+		{"drop_rate", settings.DropRate},
+	}
+	for _, rate := range rates {
+		if rate.value < 0 || rate.value > 1 {
+			return fmt.Errorf("%s must be between 0 and 1", rate.name)
+		}
+	}
+	if settings.LatencyMs < 0 || time.Duration(settings.LatencyMs)*time.Millisecond > MaxChaosLatency {
+		return fmt.Errorf("latency_ms must be between 0 and %d", MaxChaosLatency.Milliseconds())
+	}
+	if settings.ErrorStatus == 0 {
+		settings.ErrorStatus = http.StatusServiceUnavailable
+	}
+	if settings.ErrorStatus < 500 || settings.ErrorStatus > 599 {
+		return fmt.Errorf("error_status must be a 5xx code")
+	}
+
+	c.mu.Lock()
+	c.settings = settings
+	c.mu.Unlock()
+	return nil
+}
+
+// Injected returns how many faults of each kind have been injected
+func (c *Chaos) Injected() map[string]uint64 {
+	return map[string]uint64{
+		"latency": chaosLatencyTotal.Value(),
+		"error":   chaosErrorsTotal.Value(),
+		"drop":    chaosDropsTotal.Value(),
+	}
+}
+
+// roll reports whether an event with the given probability happens
+func (c *Chaos) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	c.randMu.Lock()
+	defer c.randMu.Unlock()
+	return c.rand.Float64() < rate
+}
+
+func (c *Chaos) targets(settings models.ChaosSettings, path string) bool {
+	for _, prefix := range chaosExempt {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	if len(settings.Paths) == 0 {
+		return true
+	}
+	for _, prefix := range settings.Paths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject is the middleware handler. A targeted request may first be
+// delayed, then either have its connection dropped or be answered with an
+// error instead of reaching next.
+func (c *Chaos) Inject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := c.Settings()
+		if !settings.Enabled || !c.targets(settings, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if settings.LatencyMs > 0 && c.roll(settings.LatencyRate) {
+			chaosLatencyTotal.Inc()
+			w.Header().Add(ChaosHeader, "latency")
+			select {
+			case <-time.After(time.Duration(settings.LatencyMs) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if c.roll(settings.DropRate) {
+			chaosDropsTotal.Inc()
+			log.Printf("[chaos] dropped connection for %s %s", r.Method, r.URL.Path)
+			// The server closes the connection without writing a response
+			panic(http.ErrAbortHandler)
+		}
+
+		if c.roll(settings.ErrorRate) {
+			chaosErrorsTotal.Inc()
+			log.Printf("[chaos] injected %d for %s %s", settings.ErrorStatus, r.Method, r.URL.Path)
+			w.Header().Add(ChaosHeader, "error")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(settings.ErrorStatus)
+			json.NewEncoder(w).Encode(models.ErrorResponse{
+				Error:   "chaos_injected",
+				Message: "Fault injected for resilience testing",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/models"
+)
+
+// Bounds on temporary blocks; a block without a duration lasts
+// DefaultIPBlockDuration
+const (
+	DefaultIPBlockDuration = time.Hour
+	MaxIPBlockDuration     = 7 * 24 * time.Hour
+)
+
+var ipFilterRejectedTotal = metrics.NewCounter("ip_filter_rejected_total", "Requests refused by the IP allow/deny lists or a temporary block")
+
+// ipBlock is a temporary block and the range it covers
+type ipBlock struct {
+	network *net.IPNet
+	block   models.IPBlock
+}
+
+// IPFilter refuses requests from clients outside the allowlist, on the
+// denylist or under a temporary block. The lists are fixed at startup;
+// temporary blocks are added at runtime to deal with abusive clients and
+// lapse on their own.
+type IPFilter struct {
+	allow []*net.IPNet // empty allows every client not denied
+	deny  []*net.IPNet
+
+	mu     sync.RWMutex
+	blocks map[string]ipBlock // by canonical CIDR
+}
+
+// NewIPFilter creates a filter from allow and deny lists of CIDR ranges or
+// single IPs
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	f := &IPFilter{blocks: make(map[string]ipBlock)}
+	for _, entry := range allow {
+		network, err := ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		f.allow = append(f.allow, network)
+	}
+	for _, entry := range deny {
+		network, err := ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		f.deny = append(f.deny, network)
+	}
+	return f, nil
+}
+
+// ParseCIDR parses a CIDR range, or a single IPv4 or IPv6 address as a range
+// of one
+func ParseCIDR(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP or CIDR %q", entry)
+	}
+	return network, nil
+}
+
+// clientIP returns the address the request came from
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether a client at ip may make requests, and if not,
+// the error code to refuse it with
+func (f *IPFilter) Allowed(ip net.IP) (bool, string) {
+	if ip == nil {
+		// Not a TCP peer address, e.g. a test recorder or a unix socket
+		return true, ""
+	}
+	if len(f.allow) > 0 && !containsIP(f.allow, ip) {
+		return false, "ip_not_allowed"
+	}
+	if containsIP(f.deny, ip) {
+		return false, "ip_denied"
+	}
+
+	now := time.Now()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, b := range f.blocks {
+		if now.Before(b.block.ExpiresAt) && b.network.Contains(ip) {
+			return false, "ip_blocked"
+		}
+	}
+	return true, ""
+}
+
+// Filter is the middleware handler
+func (f *IPFilter) Filter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, code := f.Allowed(clientIP(r)); !ok {
+			ipFilterRejectedTotal.Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   code,
+				"message": "Requests from your address are not accepted.",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Block refuses requests from cidr for d (0 = DefaultIPBlockDuration, at
+// most MaxIPBlockDuration). Blocking a range again replaces its block.
+func (f *IPFilter) Block(cidr string, d time.Duration, reason string) (models.IPBlock, error) {
+	network, err := ParseCIDR(cidr)
+	if err != nil {
+		return models.IPBlock{}, err
+	}
+	if d < 0 || d > MaxIPBlockDuration {
+		return models.IPBlock{}, fmt.Errorf("block duration must be between 0 and %s", MaxIPBlockDuration)
+	}
+	if d == 0 {
+		d = DefaultIPBlockDuration
+	}
+
+	now := time.Now().UTC()
+	block := models.IPBlock{
+		CIDR:      network.String(),
+		Reason:    reason,
+		CreatedAt: now,
+		ExpiresAt: now.Add(d),
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.blocks[block.CIDR] = ipBlock{network: network, block: block}
+	return block, nil
+}
+
+// Unblock lifts the temporary block on cidr, reporting whether there was one
+func (f *IPFilter) Unblock(cidr string) (bool, error) {
+	network, err := ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.blocks[network.String()]; !ok {
+		return false, nil
+	}
+	delete(f.blocks, network.String())
+	return true, nil
+}
+
+// Expire forgets lapsed blocks and returns how many; it is run periodically
+// by the job scheduler
+func (f *IPFilter) Expire() int {
+	now := time.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	expired := 0
+	for cidr, b := range f.blocks {
+		if !now.Before(b.block.ExpiresAt) {
+			delete(f.blocks, cidr)
+			expired++
+		}
+	}
+	return expired
+}
+
+// Status returns the lists and the blocks still in force, soonest to lapse
+// first
+func (f *IPFilter) Status() models.IPFilterResponse {
+	status := models.IPFilterResponse{
+		Allow:    make([]string, 0, len(f.allow)),
+		Deny:     make([]string, 0, len(f.deny)),
+		Blocks:   []models.IPBlock{},
+		Rejected: ipFilterRejectedTotal.Value(),
+	}
+	for _, network := range f.allow {
+		status.Allow = append(status.Allow, network.String())
+	}
+	for _, network := range f.deny {
+		status.Deny = append(status.Deny, network.String())
+	}
+
+	now := time.Now()
+	f.mu.RLock()
+	for _, b := range f.blocks {
+		if now.Before(b.block.ExpiresAt) {
+			status.Blocks = append(status.Blocks, b.block)
+		}
+	}
+	f.mu.RUnlock()
+	sort.Slice(status.Blocks, func(i, j int) bool {
+		return status.Blocks[i].ExpiresAt.Before(status.Blocks[j].ExpiresAt)
+	})
+	return status
+}
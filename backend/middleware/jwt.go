@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"leaderboard-backend/metrics"
+)
+
+// JWT signing algorithms
+const (
+	JWTHS256 = "HS256"
+	JWTRS256 = "RS256"
+)
+
+// jwtLeeway absorbs clock skew between the token issuer and this server
+const jwtLeeway = 30 * time.Second
+
+var jwtRejectedTotal = metrics.NewCounter("jwt_rejected_total", "Requests refused for a malformed, forged or expired bearer token")
+
+// Claims are the parts of a verified JWT the server acts on
+type Claims struct {
+	Subject   string // "sub": the user the token speaks for
+	Role      Role   // "role", if it names a known role
+	ExpiresAt time.Time
+}
+
+type claimsContextKey struct{}
+
+// ClaimsFromContext returns the claims of the bearer token a request was
+// made with, if it carried a valid one
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	return claims, ok
+}
+
+// JWTAuth verifies "Authorization: Bearer" tokens signed with HS256 or
+// RS256 and attaches their claims to the request. It never requires a
+// token; handlers and APIKeyAuth decide what the claims allow.
+type JWTAuth struct {
+	algorithm     string
+	secret        []byte
+	publicKey     *rsa.PublicKey
+	allowNoExpiry bool // accept tokens without an "exp" claim
+}
+
+// NewJWTAuth creates the middleware for algorithm ("" means HS256). An
+// HS256 key is the shared secret; an RS256 key is a PEM public key, or the
+// path of a file holding one. With no key, tokens are ignored.
+func NewJWTAuth(algorithm, key string) (*JWTAuth, error) {
+	if key == "" {
+		return &JWTAuth{}, nil
+	}
+	switch strings.ToUpper(algorithm) {
+	case "", JWTHS256:
+		return &JWTAuth{algorithm: JWTHS256, secret: []byte(key)}, nil
+	case JWTRS256:
+		publicKey, err := parseRSAPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return &JWTAuth{algorithm: JWTRS256, publicKey: publicKey}, nil
+	}
+	return nil, fmt.Errorf("unknown JWT algorithm %q (want %q or %q)", algorithm, JWTHS256, JWTRS256)
+}
+
+func parseRSAPublicKey(key string) (*rsa.PublicKey, error) {
+	data := []byte(key)
+	if !strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(key); err != nil {
+			return nil, fmt.Errorf("failed to read RS256 key: %w", err)
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("RS256 key must be a PEM public key")
+	}
+	if publicKey, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return publicKey, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RS256 public key: %w", err)
+	}
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("RS256 key is not an RSA public key")
+	}
+	return publicKey, nil
+}
+
+// AllowNoExpiry accepts tokens without an "exp" claim. By default they are
+// refused, so a leaked token can't be used forever.
+func (a *JWTAuth) AllowNoExpiry() {
+	a.allowNoExpiry = true
+}
+
+// Enabled reports whether a key is configured
+func (a *JWTAuth) Enabled() bool {
+	return a.algorithm != ""
+}
+
+// Authenticate attaches the claims of a valid bearer token to the request.
+// Requests without one pass untouched; a token that fails verification
+// gets 401 invalid_token rather than being quietly ignored.
+func (a *JWTAuth) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !a.Enabled() || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := a.Verify(token, time.Now())
+		if err != nil {
+			jwtRejectedTotal.Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":   "invalid_token",
+				"message": err.Error(),
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims)))
+	})
+}
+
+// Authenticate is the authentication stage of the server's handler chain:
+// bearer tokens are verified first, so their claims reach the API key check
+// and the handlers behind it
+func Authenticate(tokens *JWTAuth, keys *APIKeyAuth, next http.Handler) http.Handler {
+	return tokens.Authenticate(keys.Protect(next))
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// Verify checks a token's signature, algorithm and validity window at now
+// and returns its claims
+func (a *JWTAuth) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token must have three parts")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	// The configured algorithm is the only one accepted, so a token cannot
+	// pick "none" or have an RSA public key used as an HMAC secret
+	if header.Alg != a.algorithm {
+		return nil, fmt.Errorf("token algorithm %q is not %s", header.Alg, a.algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid token signature encoding")
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	switch a.algorithm {
+	case JWTHS256:
+		mac := hmac.New(sha256.New, a.secret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, errors.New("token signature does not match")
+		}
+	case JWTRS256:
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(a.publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("token signature does not match")
+		}
+	}
+
+	var payload struct {
+		Sub  string  `json:"sub"`
+		Role string  `json:"role"`
+		Exp  float64 `json:"exp"`
+		Nbf  float64 `json:"nbf"`
+	}
+	if err := decodeJWTPart(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	claims := &Claims{Subject: payload.Sub}
+	if payload.Exp == 0 && !a.allowNoExpiry {
+		return nil, errors.New("token has no expiry")
+	}
+	if payload.Exp != 0 {
+		claims.ExpiresAt = time.Unix(int64(payload.Exp), 0)
+		if now.After(claims.ExpiresAt.Add(jwtLeeway)) {
+			return nil, errors.New("token has expired")
+		}
+	}
+	if payload.Nbf != 0 && now.Add(jwtLeeway).Before(time.Unix(int64(payload.Nbf), 0)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	// Roles from other systems grant nothing here rather than failing the token
+	if role, err := ParseRole(payload.Role); err == nil {
+		claims.Role = role
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -1,43 +1,83 @@
 package middleware
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/tracing"
+
 	"golang.org/x/time/rate"
 )
 
+// DefaultVisitorIdle is how long a visitor goes without a request before
+// CleanupVisitors forgets them
+const DefaultVisitorIdle = 3 * time.Minute
+
+var rateLimiterEvictionsTotal = metrics.NewCounter("rate_limiter_evictions_total", "Idle visitors dropped by the rate limiter")
+
+// visitor is one client's token bucket and when it was last used
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64 // unix nanoseconds
+}
+
 // RateLimiter middleware limits requests per IP/client
 type RateLimiter struct {
-	visitors map[string]*rate.Limiter
+	visitors map[string]*visitor
 	mu       sync.RWMutex
 	r        rate.Limit
 	b        int
+	idle     time.Duration
 }
 
 // NewRateLimiter creates a rate limiter with r requests per second and burst of b
 func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
 	return &RateLimiter{
-		visitors: make(map[string]*rate.Limiter),
+		visitors: make(map[string]*visitor),
 		r:        rate.Limit(requestsPerSecond),
 		b:        burst,
+		idle:     DefaultVisitorIdle,
 	}
 }
 
-func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
+// SetIdleTimeout sets how long a visitor goes without a request before
+// CleanupVisitors forgets them
+func (rl *RateLimiter) SetIdleTimeout(d time.Duration) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	limiter, exists := rl.visitors[ip]
+	rl.idle = d
+}
+
+func (rl *RateLimiter) getLimiter(ip string) *rate.Limiter {
+	now := time.Now().UnixNano()
+
+	rl.mu.RLock()
+	v, exists := rl.visitors[ip]
+	rl.mu.RUnlock()
+
 	if !exists {
-		limiter = rate.NewLimiter(rl.r, rl.b)
-		rl.visitors[ip] = limiter
+		rl.mu.Lock()
+		v, exists = rl.visitors[ip]
+		if !exists {
+			v = &visitor{limiter: rate.NewLimiter(rl.r, rl.b)}
+			rl.visitors[ip] = v
+		}
+		rl.mu.Unlock()
 	}
+	v.lastSeen.Store(now)
 
-	return limiter
+	return v.limiter
 }
 
 // Limit is the middleware handler
@@ -60,17 +100,79 @@ func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
 	})
 }
 
-// CleanupOldVisitors removes stale rate limiters periodically
-func (rl *RateLimiter) CleanupOldVisitors(interval time.Duration) {
-	go func() {
-		for {
-			time.Sleep(interval)
-			rl.mu.Lock()
-			// Clear all visitors periodically (simple approach)
-			rl.visitors = make(map[string]*rate.Limiter)
-			rl.mu.Unlock()
+// CleanupVisitors forgets visitors idle for longer than the idle timeout and
+// returns how many; it is run periodically by the job scheduler. A visitor
+// is kept at least until their bucket has refilled, so forgetting them never
+// hands out a burst they hadn't earned back.
+func (rl *RateLimiter) CleanupVisitors() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	idle := rl.idle
+	if rl.r > 0 {
+		if refill := time.Duration(float64(rl.b) / float64(rl.r) * float64(time.Second)); refill > idle {
+			idle = refill
+		}
+	}
+	cutoff := time.Now().Add(-idle).UnixNano()
+
+	evicted := 0
+	for ip, v := range rl.visitors {
+		if v.lastSeen.Load() < cutoff {
+			delete(rl.visitors, ip)
+			evicted++
+		}
+	}
+	rateLimiterEvictionsTotal.Add(uint64(evicted))
+	return evicted
+}
+
+// GetStats returns the tracked visitors and how many idle ones were dropped
+func (rl *RateLimiter) GetStats() map[string]interface{} {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	return map[string]interface{}{
+		"visitors":          len(rl.visitors),
+		"evictions":         rateLimiterEvictionsTotal.Value(),
+		"idle_timeout_secs": rl.idle.Seconds(),
+		"requests_per_sec":  float64(rl.r),
+		"burst":             rl.b,
+	}
+}
+
+// Request counters used for error-rate alerting
+var (
+	httpRequestsTotal     = metrics.NewCounter("http_requests_total", "Total HTTP requests served")
+	httpServerErrorsTotal = metrics.NewCounter("http_server_errors_total", "HTTP responses with a 5xx status")
+)
+
+// RequestCounters returns the total and 5xx request counters
+func RequestCounters() (total, serverErrors *metrics.Counter) {
+	return httpRequestsTotal, httpServerErrorsTotal
+}
+
+// RequestTimeout gives every request a deadline of d, so services stop
+// working on requests that overrun it. Zero leaves requests without one.
+// Paths starting with one of exempt, such as long-lived streams, get no
+// deadline.
+func RequestTimeout(d time.Duration, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
 		}
-	}()
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range exempt {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 // Logger is a middleware that logs all requests
@@ -93,12 +195,22 @@ func (l *Logger) LogRequest(next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
-		log.Printf("[%s] %s %s %d %v",
+		httpRequestsTotal.Inc()
+		if wrapper.statusCode >= 500 {
+			httpServerErrorsTotal.Inc()
+		}
+
+		traceID := "-"
+		if trace, ok := tracing.FromContext(r.Context()); ok {
+			traceID = trace.TraceID + "/" + trace.SpanID
+		}
+		log.Printf("[%s] %s %s %d %v trace=%s",
 			r.Method,
 			r.RequestURI,
 			r.RemoteAddr,
 			wrapper.statusCode,
 			duration,
+			traceID,
 		)
 	})
 }
@@ -112,3 +224,19 @@ func (rw *responseWrapper) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap exposes the underlying writer to http.ResponseController, which
+// streaming handlers use to flush and extend write deadlines
+func (rw *responseWrapper) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack hands the connection over for websocket upgrades
+func (rw *responseWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	rw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
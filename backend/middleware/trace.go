@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"leaderboard-backend/tracing"
+)
+
+// Trace continues the caller's trace (traceparent or X-Cloud-Trace-Context)
+// or starts one, puts it in the request context for logs and published
+// events, and echoes it on the response so clients can quote it
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace := tracing.FromHeaders(r.Header)
+
+		w.Header().Set(tracing.TraceparentHeader, trace.Traceparent())
+		if r.Header.Get(tracing.CloudTraceHeader) != "" {
+			w.Header().Set(tracing.CloudTraceHeader, trace.CloudTrace())
+		}
+		next.ServeHTTP(w, r.WithContext(tracing.NewContext(r.Context(), trace)))
+	})
+}
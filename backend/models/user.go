@@ -1,34 +1,393 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type User struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
 	Rating   int    `json:"rating"`
+	Bot      bool   `json:"bot,omitempty"` // bot or simulator account, hidden by ?exclude_bots=true
+
+	// Glicko-2 rating deviation and volatility; 0 until the user has played
+	// a match rated with Glicko-2
+	RD         float64 `json:"rd,omitempty"`
+	Volatility float64 `json:"volatility,omitempty"`
 }
 
 type UserWithRank struct {
+	ID             string  `json:"id"`
+	Username       string  `json:"username"`
+	Rating         int     `json:"rating"`
+	Rank           int     `json:"rank"`
+	RankChange     *int    `json:"rank_change,omitempty"` // places climbed (+) or fallen (-) since the last rank snapshot; absent for users not in it
+	Percentile     float64 `json:"percentile"`            // share of players ranked below, as on season standings
+	TopPercent     float64 `json:"top_percent"`           // share ranked at or above: "top X%"
+	Tier           string  `json:"tier"`
+	NextTierRating int     `json:"next_tier_rating,omitempty"` // 0 when already in the top tier
+	Bot            bool    `json:"bot,omitempty"`
+}
+
+type RecentUser struct {
+	UserWithRank
+	UpdatedAt time.Time `json:"updated_at"`
+	Source    string    `json:"source"` // what made the change: api, simulator, match, decay, import or admin
+}
+
+type RecentUsersResponse struct {
+	Users []RecentUser `json:"users"`
+	Count int          `json:"count"`
+}
+
+// RatingHistoryEntry is one rating change; Change is Rating minus OldRating
+type RatingHistoryEntry struct {
+	Rating    int       `json:"rating"`
+	OldRating int       `json:"old_rating"`
+	Change    int       `json:"change"`
+	Source    string    `json:"source"`
+	At        time.Time `json:"at"`
+}
+
+// RatingHistoryResponse lists a user's latest rating changes, newest first.
+// Capacity is how many changes the server keeps per user; older ones, and
+// any made before the server started, are not available.
+type RatingHistoryResponse struct {
+	ID       string               `json:"id"`
+	Username string               `json:"username"`
+	Rating   int                  `json:"rating"`
+	History  []RatingHistoryEntry `json:"history"`
+	Count    int                  `json:"count"`
+	Capacity int                  `json:"capacity"`
+}
+
+// DeadLetter is a webhook message that could not be delivered, kept until
+// it is replayed. Attempts is 0 for one dropped because the delivery queue
+// was full.
+type DeadLetter struct {
+	ID       uint64          `json:"id"`
+	URL      string          `json:"url"`
+	Key      string          `json:"key,omitempty"`
+	Body     json.RawMessage `json:"body"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failed_at"`
+}
+
+// DeadLettersResponse is one page of dead letters, oldest first
+type DeadLettersResponse struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+	Count       int          `json:"count"`
+	Total       int          `json:"total"`
+	Offset      int          `json:"offset"`
+}
+
+// ReplayDeadLettersRequest names the dead letters to replay; no IDs replays
+// all of them
+type ReplayDeadLettersRequest struct {
+	IDs []uint64 `json:"ids,omitempty"`
+}
+
+// ReplayDeadLettersResponse reports a replay. Replayed letters that fail
+// again return with new IDs.
+type ReplayDeadLettersResponse struct {
+	Replayed  int `json:"replayed"`
+	Remaining int `json:"remaining"`
+}
+
+// BoardInfo describes one named leaderboard
+type BoardInfo struct {
+	Name       string `json:"name"`
+	TotalUsers int    `json:"total_users"`
+	Default    bool   `json:"default,omitempty"` // the board the unprefixed /api routes serve
+}
+
+// BoardListResponse lists every board, the default one first
+type BoardListResponse struct {
+	Boards []BoardInfo `json:"boards"`
+	Count  int         `json:"count"`
+}
+
+// CreateBoardRequest names a new board
+type CreateBoardRequest struct {
+	Name string `json:"name"`
+}
+
+// AroundUserResponse shows a user among the players ranked next to them.
+// Above is in leaderboard order, ending with the player just ahead.
+type AroundUserResponse struct {
+	User       UserWithRank   `json:"user"`
+	Above      []UserWithRank `json:"above"`
+	Below      []UserWithRank `json:"below"`
+	Window     int            `json:"window"`
+	TotalUsers int            `json:"total_users"`
+}
+
+// SampleResponse is a random selection of users, in no particular order
+type SampleResponse struct {
+	Users  []UserWithRank `json:"users"`
+	Count  int            `json:"count"`
+	Weight string         `json:"weight"` // uniform, rating or recency
+}
+
+// LiveEvent is a change pushed to /api/ws clients. Type is added, rating,
+// removed, cleared, achievement or top_page; User is absent for cleared and
+// top_page and has rank 0 once removed.
+type LiveEvent struct {
+	Type        string               `json:"type"`
+	User        *UserWithRank        `json:"user,omitempty"`
+	OldRating   int                  `json:"old_rating,omitempty"` // rating changes only
+	OldRank     int                  `json:"old_rank,omitempty"`
+	Achievement *Achievement         `json:"achievement,omitempty"` // achievement events only
+	Page        *LeaderboardResponse `json:"page,omitempty"`        // top_page events only
+	Source      string               `json:"source,omitempty"`      // what made the change, as in RecentUser
+	Trace       string               `json:"trace,omitempty"`       // traceparent of the request that made the change
+	At          time.Time            `json:"at"`
+}
+
+// Achievement is a milestone a user has unlocked
+type Achievement struct {
+	ID          string    `json:"id"` // top_100, rating_4000 or win_streak_10
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UnlockedAt  time.Time `json:"unlocked_at"`
+}
+
+// AchievementsResponse lists a user's achievements in the order earned
+type AchievementsResponse struct {
+	UserID       string        `json:"user_id"`
+	Achievements []Achievement `json:"achievements"`
+	WinStreak    int           `json:"win_streak"` // match wins in a row so far
+}
+
+type LeaderboardResponse struct {
+	Users           []UserWithRank `json:"users"`
+	TotalUsers      int            `json:"total_users"`
+	Page            int            `json:"page"`
+	PageSize        int            `json:"page_size"`
+	HasMore         bool           `json:"has_more"`
+	Version         uint64         `json:"version"`                     // pass back as ?since_version= to get a delta
+	NextCursor      string         `json:"next_cursor,omitempty"`       // pass back as ?cursor= for the following page
+	RankChangeSince *time.Time     `json:"rank_change_since,omitempty"` // when the snapshot behind rank_change was taken
+}
+
+// CompactUser is a leaderboard row with only what a list shows
+type CompactUser struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
 	Rating   int    `json:"rating"`
 	Rank     int    `json:"rank"`
 }
 
-type LeaderboardResponse struct {
-	Users      []UserWithRank `json:"users"`
-	TotalUsers int            `json:"total_users"`
-	Page       int            `json:"page"`
-	PageSize   int            `json:"page_size"`
-	HasMore    bool           `json:"has_more"`
+// CompactLeaderboardResponse is a leaderboard page of CompactUser rows
+type CompactLeaderboardResponse struct {
+	Users      []CompactUser `json:"users"`
+	TotalUsers int           `json:"total_users"`
+	Page       int           `json:"page"`
+	PageSize   int           `json:"page_size"`
+	HasMore    bool          `json:"has_more"`
+	Version    uint64        `json:"version"`
+}
+
+// TierInfo is one tier's rating band and how many users are in it
+type TierInfo struct {
+	Name      string  `json:"name"`
+	MinRating int     `json:"min_rating"`
+	MaxRating int     `json:"max_rating,omitempty"` // absent for the top tier
+	Users     int     `json:"users"`
+	Percent   float64 `json:"percent"` // share of all users
+}
+
+// TierListResponse lists the tiers from the lowest up
+type TierListResponse struct {
+	Tiers      []TierInfo `json:"tiers"`
+	TotalUsers int        `json:"total_users"`
+}
+
+// LeaderboardDeltaResponse lists what changed on a leaderboard page since an
+// earlier version. When Full is set the earlier version was unknown and
+// Changed holds the whole page.
+type LeaderboardDeltaResponse struct {
+	Version      uint64         `json:"version"`
+	SinceVersion uint64         `json:"since_version"`
+	Full         bool           `json:"full"`
+	Changed      []UserWithRank `json:"changed"`
+	Removed      []string       `json:"removed"`
+	TotalUsers   int            `json:"total_users"`
+	Page         int            `json:"page"`
+	PageSize     int            `json:"page_size"`
+	HasMore      bool           `json:"has_more"`
 }
 
 type SearchResponse struct {
-	Users []UserWithRank `json:"users"`
-	Query string         `json:"query"`
-	Count int            `json:"count"`
+	Users     []UserWithRank `json:"users"`
+	Query     string         `json:"query"`
+	Count     int            `json:"count"`
+	Truncated bool           `json:"truncated"` // the scan hit its work limit; better matches may exist
 }
 
-type UpdateRatingRequest struct {
+type Suggestion struct {
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+}
+
+type SuggestResponse struct {
+	Suggestions []Suggestion `json:"suggestions"`
+	Query       string       `json:"query"`
+	Truncated   bool         `json:"truncated"` // the scan hit its work limit
+}
+
+type RatingBucket struct {
+	Rating int `json:"rating"` // lowest rating in the bucket (or band)
+	Count  int `json:"count"`
+}
+
+type RatingDistributionResponse struct {
+	Buckets    []RatingBucket `json:"buckets"`
+	Step       int            `json:"step"`
+	TotalUsers int            `json:"total_users"`
+}
+
+type RankLookupRequest struct {
+	UserIDs []string `json:"user_ids"`
+	Ratings []int    `json:"ratings"`
+}
+
+type RatingRank struct {
 	Rating int `json:"rating"`
+	Rank   int `json:"rank"`
+}
+
+type RankLookupResponse struct {
+	Users    []UserWithRank `json:"users"`
+	Ratings  []RatingRank   `json:"ratings"`
+	NotFound []string       `json:"not_found"`
+}
+
+// BatchUsersResponse answers a lookup of many users by ID, in request order
+type BatchUsersResponse struct {
+	Users    []UserWithRank    `json:"users"`
+	NotFound []string          `json:"not_found"`
+	Merged   map[string]string `json:"merged,omitempty"` // requested ID -> the account it was merged into
+}
+
+// SubsetLeaderboardRequest names the users of a friends-only board
+type SubsetLeaderboardRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+// SubsetLeaderboardResponse lists the named users in leaderboard order with
+// their global ranks
+type SubsetLeaderboardResponse struct {
+	Users    []UserWithRank `json:"users"`
+	NotFound []string       `json:"not_found"`
+}
+
+// CreateUserRequest adds one user; rating defaults to the minimum rating
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Rating   *int   `json:"rating,omitempty"`
+}
+
+type UpdateRatingRequest struct {
+	Rating int    `json:"rating"`
+	Source string `json:"source,omitempty"` // api (default), match, decay or admin
+}
+
+type HeartbeatResponse struct {
+	ID       string    `json:"id"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type BulkDeleteFilter struct {
+	RatingBelow  int `json:"rating_below,omitempty"`  // only users rated strictly below this
+	InactiveDays int `json:"inactive_days,omitempty"` // only users not seen for this many days
+}
+
+type BulkDeleteRequest struct {
+	IDs    []string          `json:"ids,omitempty"`
+	Filter *BulkDeleteFilter `json:"filter,omitempty"`
+}
+
+type DeleteResult struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BulkDeleteResponse struct {
+	Results []DeleteResult `json:"results"`
+	Deleted int            `json:"deleted"`
+	Failed  int            `json:"failed"`
+}
+
+// MergeUsersRequest optionally overrides the configured rating strategy
+type MergeUsersRequest struct {
+	RatingStrategy string `json:"rating_strategy,omitempty"` // max, keep, dupe or average
+}
+
+// SetBotRequest flags or unflags a user as a bot or simulator account
+type SetBotRequest struct {
+	Bot *bool `json:"bot"`
+}
+
+// MatchRequest reports a played match; with Draw set neither player won
+type MatchRequest struct {
+	WinnerID string `json:"winner_id"`
+	LoserID  string `json:"loser_id"`
+	Draw     bool   `json:"draw,omitempty"`
+}
+
+// MatchPlayer is one side of a rated match, ranked after it
+type MatchPlayer struct {
+	UserWithRank
+	OldRating  int     `json:"old_rating"`
+	Change     int     `json:"change"`
+	RD         float64 `json:"rd,omitempty"` // Glicko-2 only
+	Volatility float64 `json:"volatility,omitempty"`
+}
+
+// MatchResponse is both players' standing after a rated match
+type MatchResponse struct {
+	Winner       MatchPlayer `json:"winner"`
+	Loser        MatchPlayer `json:"loser"`
+	Draw         bool        `json:"draw"`
+	RatingSystem string      `json:"rating_system"`
+}
+
+// MergeUsersResponse describes the surviving account after a merge
+type MergeUsersResponse struct {
+	User           User   `json:"user"`
+	MergedID       string `json:"merged_id"`
+	RatingStrategy string `json:"rating_strategy"`
+	KeepRating     int    `json:"keep_rating"` // ratings before the merge
+	DupeRating     int    `json:"dupe_rating"`
+}
+
+// UsernameCheckResponse tells a signup form whether a username can be used
+type UsernameCheckResponse struct {
+	Username string `json:"username"`
+	Valid    bool   `json:"valid"`
+	Reason   string `json:"reason,omitempty"` // policy violation code when not valid
+	Message  string `json:"message,omitempty"`
+	Taken    bool   `json:"taken"` // another user already goes by this name
+}
+
+type ResetRequest struct {
+	ConfirmToken string `json:"confirm_token"`
+}
+
+type ResetChallengeResponse struct {
+	Message      string    `json:"message"`
+	ConfirmToken string    `json:"confirm_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type ResetResponse struct {
+	Message      string `json:"message"`
+	UsersRemoved int    `json:"users_removed"`
 }
 
 type SeedResponse struct {
@@ -41,7 +400,205 @@ type HealthResponse struct {
 	TotalUsers int    `json:"total_users"`
 }
 
+// ReadinessResponse is /readyz: whether the indexes built after startup
+// are done and how far along they are
+type ReadinessResponse struct {
+	Status    string  `json:"status"` // ready or warming_up
+	Indexed   int     `json:"indexed"`
+	Total     int     `json:"total"`
+	Percent   float64 `json:"percent"`
+	ElapsedMs int64   `json:"elapsed_ms"`
+}
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
 }
+
+// SnapshotInfo describes a named leaderboard snapshot
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UserCount int       `json:"user_count,omitempty"`
+}
+
+type SnapshotListResponse struct {
+	Snapshots []SnapshotInfo `json:"snapshots"`
+	Count     int            `json:"count"`
+}
+
+type CreateSnapshotRequest struct {
+	Name string `json:"name"`
+}
+
+// SnapshotEntry is a user's standing within one snapshot
+type SnapshotEntry struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Rank     int    `json:"rank"`
+	Rating   int    `json:"rating"`
+}
+
+// RankMovement is a user's change in standing between two snapshots.
+// Change is positive when the user climbed.
+type RankMovement struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	FromRank   int    `json:"from_rank"`
+	ToRank     int    `json:"to_rank"`
+	Change     int    `json:"change"`
+	FromRating int    `json:"from_rating"`
+	ToRating   int    `json:"to_rating"`
+}
+
+// SnapshotDiffResponse compares the top of two snapshots (all users when Top is 0)
+type SnapshotDiffResponse struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	Top         int             `json:"top"`
+	Movements   []RankMovement  `json:"movements"`
+	NewEntrants []SnapshotEntry `json:"new_entrants"`
+	Dropouts    []SnapshotEntry `json:"dropouts"`
+}
+
+// SeasonInfo describes an archived season
+type SeasonInfo struct {
+	ID         string    `json:"id"`
+	ArchivedAt time.Time `json:"archived_at"`
+	UserCount  int       `json:"user_count"`
+}
+
+type SeasonListResponse struct {
+	Seasons []SeasonInfo           `json:"seasons"`
+	Count   int                    `json:"count"`
+	Cache   map[string]interface{} `json:"cache"`
+}
+
+// CloseSeasonRequest says how ratings carry over into the next season when
+// one is closed
+type CloseSeasonRequest struct {
+	Reset      string   `json:"reset"`                 // "none" (default), "soft" or "hard"
+	BaseRating int      `json:"base_rating,omitempty"` // rating resets move towards (0 = the server default)
+	Carry      *float64 `json:"carry,omitempty"`       // share of the distance from base_rating a soft reset keeps (default 0.5)
+}
+
+// CloseSeasonResponse is the archived season and how many ratings were reset
+type CloseSeasonResponse struct {
+	Season     SeasonInfo `json:"season"`
+	Reset      string     `json:"reset"`
+	BaseRating int        `json:"base_rating,omitempty"`
+	UsersReset int        `json:"users_reset"`
+}
+
+// ArchivedStanding is a user's final position in an archived season
+type ArchivedStanding struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Rank     int    `json:"rank"`
+	Rating   int    `json:"rating"`
+}
+
+type SeasonLeaderboardResponse struct {
+	Season     string             `json:"season"`
+	Users      []ArchivedStanding `json:"users"`
+	TotalUsers int                `json:"total_users"`
+	Page       int                `json:"page"`
+	PageSize   int                `json:"page_size"`
+	HasMore    bool               `json:"has_more"`
+}
+
+// SeasonStandingResponse is a user's final standing with the share of
+// players that finished below them
+type SeasonStandingResponse struct {
+	Season string `json:"season"`
+	ArchivedStanding
+	Percentile float64 `json:"percentile"`
+	TotalUsers int     `json:"total_users"`
+}
+
+// BoardStanding is a user's position on one leaderboard
+type BoardStanding struct {
+	Board          string  `json:"board"` // "live" or an archived season ID
+	Final          bool    `json:"final"` // archived standings no longer change
+	Rating         int     `json:"rating"`
+	Rank           int     `json:"rank"`
+	Percentile     float64 `json:"percentile"`
+	TotalUsers     int     `json:"total_users"`
+	Tier           string  `json:"tier"`
+	NextTierRating int     `json:"next_tier_rating,omitempty"`
+}
+
+// UserSummaryResponse gathers a user's standing on every leaderboard they
+// appear in: the live board first, then archived seasons newest first
+type UserSummaryResponse struct {
+	ID       string          `json:"id"`
+	Username string          `json:"username"`
+	Boards   []BoardStanding `json:"boards"`
+}
+
+// ChaosSettings controls fault injection. Each rate is the fraction of
+// requests, from 0 to 1, that receive that fault.
+type ChaosSettings struct {
+	Enabled     bool     `json:"enabled"`
+	LatencyMs   int      `json:"latency_ms"`
+	LatencyRate float64  `json:"latency_rate"`
+	ErrorRate   float64  `json:"error_rate"`
+	ErrorStatus int      `json:"error_status"`
+	DropRate    float64  `json:"drop_rate"`
+	Paths       []string `json:"paths,omitempty"` // path prefixes to target; empty targets every path
+}
+
+// ChaosStatusResponse reports the fault injection settings and how many
+// faults of each kind have been injected
+type ChaosStatusResponse struct {
+	ChaosSettings
+	Injected map[string]uint64 `json:"injected"`
+}
+
+// IPBlock is a temporary block on a client IP or CIDR range
+type IPBlock struct {
+	CIDR      string    `json:"cidr"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateIPBlockRequest blocks a client IP or CIDR range for DurationSeconds
+type CreateIPBlockRequest struct {
+	CIDR            string `json:"cidr"`
+	DurationSeconds int    `json:"duration_seconds"`
+	Reason          string `json:"reason,omitempty"`
+}
+
+// IPFilterResponse lists the configured allow and deny lists and the
+// temporary blocks still in force
+type IPFilterResponse struct {
+	Allow    []string  `json:"allow"` // empty allows every client not denied
+	Deny     []string  `json:"deny"`
+	Blocks   []IPBlock `json:"blocks"`
+	Rejected uint64    `json:"rejected"`
+}
+
+// FinalStandingsResponse is a consistent top N for prize payouts. Digest is
+// the hex SHA-256 of services.CanonicalStandings; Signature, when the server
+// has a signing key, is the hex HMAC-SHA256 of that digest.
+type FinalStandingsResponse struct {
+	Users              []UserWithRank `json:"users"`
+	Top                int            `json:"top"`
+	TotalUsers         int            `json:"total_users"`
+	Version            uint64         `json:"version"`
+	GeneratedAt        time.Time      `json:"generated_at"`
+	Digest             string         `json:"digest"`
+	Signature          string         `json:"signature,omitempty"`
+	SignatureAlgorithm string         `json:"signature_algorithm,omitempty"`
+}
+
+// SigningKeyResponse tells consumers how to verify signed responses. For
+// HMAC there is no public key; the secret is shared out of band.
+type SigningKeyResponse struct {
+	Algorithm string   `json:"algorithm"`
+	KeyID     string   `json:"key_id"`
+	PublicKey string   `json:"public_key,omitempty"` // base64 Ed25519 public key
+	Ephemeral bool     `json:"ephemeral"`            // key regenerated on every restart
+	Headers   []string `json:"headers"`
+}
@@ -0,0 +1,263 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/models"
+)
+
+// DefaultDeadLetterLimit is how many dead letters are kept before the oldest
+// are discarded
+const DefaultDeadLetterLimit = 10000
+
+var deadLettersDiscardedTotal = metrics.NewCounter("webhook_dead_letters_discarded_total", "Dead letters discarded because the dead letter queue was full")
+
+// DeadLetterQueue keeps dead letters in an append-only JSON lines file until
+// they are replayed. Each letter is written as it arrives, so it survives a
+// crash of the process; Sync makes it survive power loss as well. Replaying
+// rewrites the file without the letters handed back to the dispatcher.
+type DeadLetterQueue struct {
+	mu        sync.Mutex
+	path      string
+	limit     int
+	file      *os.File
+	writer    *bufio.Writer
+	letters   []models.DeadLetter // oldest first
+	nextID    uint64
+	fileLines int // lines in the file, including letters since discarded
+}
+
+// OpenDeadLetterQueue loads the letters saved at path, creating the file if
+// needed. At most limit letters are kept (0 = DefaultDeadLetterLimit).
+func OpenDeadLetterQueue(path string, limit int) (*DeadLetterQueue, error) {
+	if limit <= 0 {
+		limit = DefaultDeadLetterLimit
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	q := &DeadLetterQueue{path: path, limit: limit, nextID: 1}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.rewrite(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load reads the saved letters. A torn last line from a crash mid-write is
+// skipped.
+func (q *DeadLetterQueue) load() error {
+	file, err := os.Open(q.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open dead letters: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var letter models.DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			continue
+		}
+		q.letters = append(q.letters, letter)
+		if letter.ID >= q.nextID {
+			q.nextID = letter.ID + 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read dead letters: %w", err)
+	}
+	q.trim()
+	return nil
+}
+
+// Add saves a dead letter; it is the dispatcher's dead letter handler
+func (q *DeadLetterQueue) Add(letter DeadLetter) error {
+	body := json.RawMessage(letter.Body)
+	if !json.Valid(body) {
+		// Keep what can't be embedded as JSON as a string rather than lose it
+		body, _ = json.Marshal(string(letter.Body))
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stored := models.DeadLetter{
+		ID:       q.nextID,
+		URL:      letter.URL,
+		Key:      letter.Key,
+		Body:     body,
+		Attempts: letter.Attempts,
+		Error:    letter.Error,
+		FailedAt: letter.FailedAt,
+	}
+	q.nextID++
+	q.letters = append(q.letters, stored)
+	q.trim()
+
+	if err := q.append(stored); err != nil {
+		return err
+	}
+	// Don't let discarded letters grow the file without bound
+	if q.fileLines > 2*q.limit {
+		return q.rewrite()
+	}
+	return nil
+}
+
+// trim discards the oldest letters beyond the limit; the caller holds q.mu
+// or owns q
+func (q *DeadLetterQueue) trim() {
+	if excess := len(q.letters) - q.limit; excess > 0 {
+		deadLettersDiscardedTotal.Add(uint64(excess))
+		q.letters = append([]models.DeadLetter(nil), q.letters[excess:]...)
+	}
+}
+
+// append writes one letter to the end of the file; the caller holds q.mu
+func (q *DeadLetterQueue) append(letter models.DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	q.writer.Write(line)
+	q.writer.WriteByte('\n')
+	if err := q.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	q.fileLines++
+	return nil
+}
+
+// rewrite replaces the file with the current letters, atomically; the
+// caller holds q.mu or owns q
+func (q *DeadLetterQueue) rewrite() error {
+	tempPath := q.path + ".tmp"
+	temp, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create dead letter file: %w", err)
+	}
+	writer := bufio.NewWriter(temp)
+	encoder := json.NewEncoder(writer)
+	for _, letter := range q.letters {
+		if err := encoder.Encode(letter); err != nil {
+			temp.Close()
+			os.Remove(tempPath)
+			return fmt.Errorf("failed to write dead letters: %w", err)
+		}
+	}
+	if err := writer.Flush(); err == nil {
+		err = temp.Sync()
+	}
+	if err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write dead letters: %w", err)
+	}
+	if err := os.Rename(tempPath, q.path); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	if q.file != nil {
+		q.file.Close()
+	}
+	q.file = temp
+	q.writer = bufio.NewWriter(temp)
+	q.fileLines = len(q.letters)
+	return nil
+}
+
+// List returns up to limit letters from offset, oldest first, and how many
+// are kept in all
+func (q *DeadLetterQueue) List(offset, limit int) ([]models.DeadLetter, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	total := len(q.letters)
+	if offset >= total {
+		return []models.DeadLetter{}, total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return append([]models.DeadLetter(nil), q.letters[offset:end]...), total
+}
+
+// Len returns how many letters are kept
+func (q *DeadLetterQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.letters)
+}
+
+// Replay hands letters back to d for another round of attempts: the ones
+// named by ids, or every letter when ids is empty. They are removed from the
+// queue first; one that fails again, or that d drops because its queue is
+// full, comes back with a new ID.
+func (q *DeadLetterQueue) Replay(d *Dispatcher, ids []uint64) (int, error) {
+	wanted := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	q.mu.Lock()
+	var replay []models.DeadLetter
+	kept := make([]models.DeadLetter, 0, len(q.letters))
+	for _, letter := range q.letters {
+		if len(ids) == 0 || wanted[letter.ID] {
+			replay = append(replay, letter)
+		} else {
+			kept = append(kept, letter)
+		}
+	}
+	if len(replay) == 0 {
+		q.mu.Unlock()
+		return 0, nil
+	}
+	q.letters = kept
+	err := q.rewrite()
+	q.mu.Unlock()
+
+	// Sent without the lock: a dropped message is added straight back
+	for _, letter := range replay {
+		d.Send(Message{URL: letter.URL, Key: letter.Key, Body: letter.Body})
+	}
+	return len(replay), err
+}
+
+// Sync flushes written letters to stable storage
+func (q *DeadLetterQueue) Sync() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.file.Sync()
+}
+
+// Close syncs and closes the file
+func (q *DeadLetterQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.file.Sync(); err != nil {
+		q.file.Close()
+		return err
+	}
+	return q.file.Close()
+}
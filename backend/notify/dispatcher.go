@@ -0,0 +1,414 @@
+// Package notify delivers outgoing webhooks off the request and store
+// paths. Messages wait in a bounded queue for a fixed pool of workers, so a
+// slow or dead endpoint can only ever cost a full queue, never a blocked
+// writer or an unbounded pile of goroutines.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"leaderboard-backend/metrics"
+)
+
+// Policy decides what happens to a message that arrives while others wait
+type Policy string
+
+const (
+	// PolicyDrop queues every message and drops new ones while the queue is
+	// full
+	PolicyDrop Policy = "drop"
+	// PolicyAggregate replaces a still-queued message for the same endpoint
+	// and key with the newer one, so a receiver gets the latest state rather
+	// than every step; new keys are dropped while the queue is full
+	PolicyAggregate Policy = "aggregate"
+)
+
+// ParsePolicy validates a policy name
+func ParsePolicy(name string) (Policy, error) {
+	switch policy := Policy(name); policy {
+	case PolicyDrop, PolicyAggregate:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown webhook policy %q (want %q or %q)", name, PolicyDrop, PolicyAggregate)
+}
+
+// Message is one webhook POST. Key groups messages about the same thing,
+// such as one alert or one user, for PolicyAggregate.
+type Message struct {
+	URL  string
+	Key  string
+	Body []byte // sent as application/json
+}
+
+// DeadLetter is a message given up on after its last failed attempt
+type DeadLetter struct {
+	Message
+	Attempts int
+	Error    string
+	FailedAt time.Time
+}
+
+// Options configures a Dispatcher; zero values take the defaults
+type Options struct {
+	Workers     int           // concurrent deliveries (default 4)
+	QueueSize   int           // messages waiting for a worker (default 1024)
+	Policy      Policy        // default PolicyAggregate
+	MaxAttempts int           // tries per message before it is dead-lettered (default 5)
+	Backoff     time.Duration // wait before the first retry, doubled after each (default 500ms)
+	Timeout     time.Duration // per attempt (default 5s)
+}
+
+const maxBackoff = 30 * time.Second
+
+var (
+	webhookDeliveredTotal  = metrics.NewCounter("webhook_delivered_total", "Webhook messages accepted by their endpoint")
+	webhookFailuresTotal   = metrics.NewCounter("webhook_attempt_failures_total", "Webhook delivery attempts that failed")
+	webhookDroppedTotal    = metrics.NewCounter("webhook_dropped_total", "Webhook messages dropped because the delivery queue was full")
+	webhookAggregatedTotal = metrics.NewCounter("webhook_aggregated_total", "Queued webhook messages replaced by a newer one for the same key")
+	webhookDeadTotal       = metrics.NewCounter("webhook_dead_letters_total", "Webhook messages given up on after their last attempt")
+	webhookLatency         = metrics.NewHistogram("webhook_delivery_seconds", "Duration of webhook delivery attempts")
+)
+
+// Dispatcher delivers messages through a bounded queue and worker pool,
+// retrying failures with exponential backoff. A message that still fails
+// after MaxAttempts is dead-lettered: logged and handed to the dead letter
+// handler. Once an endpoint has dead-lettered a message it is marked
+// failing and its later messages get one attempt each until it answers
+// again, so a dead receiver can't tie up every worker in retries.
+type Dispatcher struct {
+	opts   Options
+	client *http.Client
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	queue     []*Message
+	queued    map[string]*Message // by URL and key, for PolicyAggregate
+	endpoints map[string]*endpoint
+	stopping  bool
+	onDead    func(DeadLetter)
+	dead      uint64
+
+	ctx     context.Context // cancelled when Stop gives up waiting
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+// endpoint tracks one URL's recent health
+type endpoint struct {
+	failing      bool
+	consecutive  int // failed attempts since the last success
+	lastError    string
+	lastDelivery time.Time
+}
+
+// NewDispatcher creates a dispatcher; call Start to begin delivering
+func NewDispatcher(opts Options) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.Policy == "" {
+		opts.Policy = PolicyAggregate
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 500 * time.Millisecond
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		opts:      opts,
+		client:    &http.Client{Timeout: opts.Timeout},
+		queue:     make([]*Message, 0, opts.QueueSize),
+		queued:    make(map[string]*Message),
+		endpoints: make(map[string]*endpoint),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// SetDeadLetterHandler sets a function called with every dead letter, after
+// it is logged; call before Start. It runs on a worker, so keep it quick.
+func (d *Dispatcher) SetDeadLetterHandler(handler func(DeadLetter)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.onDead = handler
+}
+
+// Start launches the workers
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+}
+
+// Send queues msg without blocking. It returns false when the message was
+// dropped because the queue is full or the dispatcher is stopping; a
+// dropped message is dead-lettered like one that failed.
+func (d *Dispatcher) Send(msg Message) bool {
+	if reason := d.enqueue(msg); reason != "" {
+		webhookDroppedTotal.Inc()
+		d.deadLetter(msg, 0, errors.New(reason))
+		return false
+	}
+	return true
+}
+
+// enqueue adds msg to the queue, or aggregates it into a queued message,
+// and returns why it couldn't if it was dropped
+func (d *Dispatcher) enqueue(msg Message) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopping {
+		return "dispatcher stopped"
+	}
+	key := msg.URL + "\x00" + msg.Key
+	if d.opts.Policy == PolicyAggregate && msg.Key != "" {
+		if waiting, ok := d.queued[key]; ok {
+			waiting.Body = msg.Body
+			webhookAggregatedTotal.Inc()
+			return ""
+		}
+	}
+	if len(d.queue) >= d.opts.QueueSize {
+		return "delivery queue full"
+	}
+
+	queued := msg
+	d.queue = append(d.queue, &queued)
+	if d.opts.Policy == PolicyAggregate && msg.Key != "" {
+		d.queued[key] = &queued
+	}
+	d.cond.Signal()
+	return ""
+}
+
+// Backlog returns how many messages wait for a worker and how many fit
+func (d *Dispatcher) Backlog() (int, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.queue), d.opts.QueueSize
+}
+
+// Stop refuses new messages and waits for the queue to drain. If ctx ends
+// first, in-flight attempts are abandoned and whatever is left is
+// dead-lettered rather than lost without a trace.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	d.stopping = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for {
+		d.mu.Lock()
+		for len(d.queue) == 0 && !d.stopping {
+			d.cond.Wait()
+		}
+		if len(d.queue) == 0 {
+			d.mu.Unlock()
+			return
+		}
+		msg := d.queue[0]
+		d.queue[0] = nil
+		d.queue = d.queue[1:]
+		if d.queued[msg.URL+"\x00"+msg.Key] == msg {
+			delete(d.queued, msg.URL+"\x00"+msg.Key)
+		}
+		d.mu.Unlock()
+
+		d.deliver(*msg)
+	}
+}
+
+// deliver tries msg until it is accepted, it runs out of attempts or the
+// endpoint turns out to be failing
+func (d *Dispatcher) deliver(msg Message) {
+	backoff := d.opts.Backoff
+	attempts := 0
+	for {
+		attempts++
+		err := d.attempt(msg)
+		if err == nil {
+			d.succeeded(msg.URL)
+			return
+		}
+		webhookFailuresTotal.Inc()
+		failing := d.failed(msg.URL, err)
+
+		var permanent permanentError
+		if errors.As(err, &permanent) || failing || attempts >= d.opts.MaxAttempts || d.ctx.Err() != nil {
+			d.deadLetter(msg, attempts, err)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// permanentError is a failure that retrying won't change, such as a 400
+type permanentError struct{ error }
+
+func (d *Dispatcher) attempt(msg Message) error {
+	if err := d.ctx.Err(); err != nil {
+		return fmt.Errorf("dispatcher stopped: %w", err)
+	}
+	start := time.Now()
+	defer func() { webhookLatency.Observe(time.Since(start)) }()
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return permanentError{fmt.Errorf("endpoint rejected the message with status %d", resp.StatusCode)}
+	}
+	return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+}
+
+// endpointFor returns url's health record; the caller holds d.mu
+func (d *Dispatcher) endpointFor(url string) *endpoint {
+	ep, ok := d.endpoints[url]
+	if !ok {
+		ep = &endpoint{}
+		d.endpoints[url] = ep
+	}
+	return ep
+}
+
+func (d *Dispatcher) succeeded(url string) {
+	webhookDeliveredTotal.Inc()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ep := d.endpointFor(url)
+	if ep.failing {
+		log.Printf("Webhook endpoint %s recovered\n", url)
+	}
+	ep.failing = false
+	ep.consecutive = 0
+	ep.lastDelivery = time.Now()
+}
+
+// failed records a failed attempt and reports whether the endpoint is
+// marked failing
+func (d *Dispatcher) failed(url string, err error) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ep := d.endpointFor(url)
+	ep.consecutive++
+	ep.lastError = err.Error()
+	return ep.failing
+}
+
+// deadLetter gives up on msg. Only messages that were attempted mark their
+// endpoint failing; drops are the queue's fault, not the endpoint's.
+func (d *Dispatcher) deadLetter(msg Message, attempts int, err error) {
+	webhookDeadTotal.Inc()
+	log.Printf("Webhook dead letter: %s key=%q after %d attempt(s): %v\n", msg.URL, msg.Key, attempts, err)
+
+	d.mu.Lock()
+	ep := d.endpointFor(msg.URL)
+	if attempts > 0 && !ep.failing {
+		ep.failing = true
+		log.Printf("Webhook endpoint %s marked failing; its messages get one attempt until it recovers\n", msg.URL)
+	}
+	d.dead++
+	onDead := d.onDead
+	d.mu.Unlock()
+
+	if onDead != nil {
+		onDead(DeadLetter{Message: msg, Attempts: attempts, Error: err.Error(), FailedAt: time.Now()})
+	}
+}
+
+// GetStats returns the queue, policy and per-endpoint health
+func (d *Dispatcher) GetStats() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	endpoints := make(map[string]interface{}, len(d.endpoints))
+	for url, ep := range d.endpoints {
+		stats := map[string]interface{}{
+			"failing":              ep.failing,
+			"consecutive_failures": ep.consecutive,
+		}
+		if ep.lastError != "" {
+			stats["last_error"] = ep.lastError
+		}
+		if !ep.lastDelivery.IsZero() {
+			stats["last_delivery"] = ep.lastDelivery.UTC().Format(time.RFC3339)
+		}
+		endpoints[url] = stats
+	}
+	return map[string]interface{}{
+		"policy":       d.opts.Policy,
+		"workers":      d.opts.Workers,
+		"queued":       len(d.queue),
+		"queue_size":   d.opts.QueueSize,
+		"max_attempts": d.opts.MaxAttempts,
+		"dropped":      webhookDroppedTotal.Value(),
+		"aggregated":   webhookAggregatedTotal.Value(),
+		"dead_letters": d.dead,
+		"endpoints":    endpoints,
+	}
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locker coordinates exclusive jobs across replicas. TryLock acquires a lease
+// on key for ttl and reports false when another replica holds a live lease.
+// Leases are not released after a run: holding the lease for most of the
+// interval is what limits a job to one run per period across replicas.
+type Locker interface {
+	TryLock(key string, ttl time.Duration) (bool, error)
+}
+
+// LocalLocker is used for single-replica deployments; every lock succeeds
+type LocalLocker struct{}
+
+func (LocalLocker) TryLock(key string, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+// FileLocker implements leases as files in a directory shared by all
+// replicas (e.g. a mounted volume). Each lease is a numbered generation,
+// key.N.lock. A lease is written in full to a temporary file and then
+// hard-linked into place, which fails if the name exists, so a lease is
+// never seen half-written and only one replica can create generation N.
+// An expired lease is never moved or rewritten: it is superseded by
+// creating generation N+1, and the winner then checks that no later
+// generation exists, in case its view of the directory was stale.
+type FileLocker struct {
+	dir   string
+	owner string
+}
+
+// NewFileLocker creates a locker storing leases in dir, identified as owner
+func NewFileLocker(dir, owner string) (*FileLocker, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	return &FileLocker{dir: dir, owner: owner}, nil
+}
+
+func (f *FileLocker) TryLock(key string, ttl time.Duration) (bool, error) {
+	prefix := sanitizeKey(key)
+	latest, err := f.generations(prefix)
+	if err != nil {
+		return false, err
+	}
+	current := 0
+	if len(latest) > 0 {
+		current = latest[len(latest)-1]
+		if !leaseExpired(f.leasePath(prefix, current)) {
+			return false, nil
+		}
+	}
+
+	next := current + 1
+	created, err := f.createLease(f.leasePath(prefix, next), ttl)
+	if err != nil || !created {
+		return false, err
+	}
+
+	// A replica that listed the directory before generation next-1 was
+	// superseded and cleaned up could have just recreated an old number;
+	// only the newest generation holds the lease
+	after, err := f.generations(prefix)
+	if err != nil {
+		return false, err
+	}
+	if len(after) == 0 || after[len(after)-1] != next {
+		os.Remove(f.leasePath(prefix, next))
+		return false, nil
+	}
+	for _, gen := range after[:len(after)-1] {
+		os.Remove(f.leasePath(prefix, gen))
+	}
+	return true, nil
+}
+
+func (f *FileLocker) leasePath(prefix string, gen int) string {
+	return filepath.Join(f.dir, fmt.Sprintf("%s.%d.lock", prefix, gen))
+}
+
+// generations lists the lease generations on disk for prefix, oldest first
+func (f *FileLocker) generations(prefix string) ([]int, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+	var gens []int
+	for _, entry := range entries {
+		rest, ok := strings.CutPrefix(entry.Name(), prefix+".")
+		if !ok {
+			continue
+		}
+		number, ok := strings.CutSuffix(rest, ".lock")
+		if !ok {
+			continue
+		}
+		if gen, err := strconv.Atoi(number); err == nil && gen > 0 {
+			gens = append(gens, gen)
+		}
+	}
+	sort.Ints(gens)
+	return gens, nil
+}
+
+// createLease writes a lease for ttl to a temporary file and links it to
+// path, reporting false if path already exists
+func (f *FileLocker) createLease(path string, ttl time.Duration) (bool, error) {
+	tmp, err := os.CreateTemp(f.dir, "lease-*.tmp")
+	if err != nil {
+		return false, fmt.Errorf("failed to create lease: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	expiry := time.Now().Add(ttl).UnixNano()
+	_, werr := fmt.Fprintf(tmp, "%s\n%d\n", f.owner, expiry)
+	cerr := tmp.Close()
+	if werr != nil {
+		return false, fmt.Errorf("failed to write lease: %w", werr)
+	}
+	if cerr != nil {
+		return false, fmt.Errorf("failed to write lease: %w", cerr)
+	}
+
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create lease: %w", err)
+	}
+	return true, nil
+}
+
+// leaseExpired reads the expiry written by createLease. Leases appear on
+// disk complete, so one that is corrupt was damaged afterwards and is
+// treated as expired, so it cannot wedge a job.
+func leaseExpired(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return !os.IsNotExist(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 {
+		return true
+	}
+	expiry, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return true
+	}
+	return time.Now().UnixNano() > expiry
+}
+
+func sanitizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, key)
+}
@@ -0,0 +1,264 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobFunc is the work performed on each run of a job
+type JobFunc func(ctx context.Context) error
+
+// JobStatus describes a job's schedule and its most recent run
+type JobStatus struct {
+	Name           string    `json:"name"`
+	IntervalMs     int64     `json:"interval_ms"`
+	Running        bool      `json:"running"`
+	LastRun        time.Time `json:"last_run,omitempty"`
+	NextRun        time.Time `json:"next_run"`
+	LastDurationMs float64   `json:"last_duration_ms"`
+	LastError      string    `json:"last_error,omitempty"`
+	RunCount       int64     `json:"run_count"`
+	FailCount      int64     `json:"fail_count"`
+	Exclusive      bool      `json:"exclusive"`
+	SkippedCount   int64     `json:"skipped_count"` // periods run by another replica
+}
+
+type job struct {
+	name      string
+	interval  time.Duration
+	fn        JobFunc
+	trigger   chan struct{}
+	exclusive bool
+
+	// guarded by Scheduler.mu
+	status JobStatus
+}
+
+// Scheduler runs registered jobs on fixed intervals, one goroutine per job,
+// and keeps per-job status for the admin API
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	locker  Locker
+	started bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New creates an idle scheduler
+func New() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		jobs:   make(map[string]*job),
+		locker: LocalLocker{},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// SetLocker sets the lock used by exclusive jobs. Call before Start.
+func (s *Scheduler) SetLocker(locker Locker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locker = locker
+}
+
+// Register adds a job that runs on every replica. Jobs registered after
+// Start begin immediately.
+func (s *Scheduler) Register(name string, interval time.Duration, fn JobFunc) error {
+	return s.register(name, interval, fn, false)
+}
+
+// RegisterExclusive adds a job that, across all replicas sharing the locker,
+// runs at most once per interval (decay, resets, snapshots)
+func (s *Scheduler) RegisterExclusive(name string, interval time.Duration, fn JobFunc) error {
+	return s.register(name, interval, fn, true)
+}
+
+func (s *Scheduler) register(name string, interval time.Duration, fn JobFunc, exclusive bool) error {
+	if interval <= 0 {
+		return fmt.Errorf("job %s: interval must be positive", name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job %s already registered", name)
+	}
+
+	j := &job{
+		name:      name,
+		interval:  interval,
+		fn:        fn,
+		trigger:   make(chan struct{}, 1),
+		exclusive: exclusive,
+		status: JobStatus{
+			Name:       name,
+			IntervalMs: interval.Milliseconds(),
+			NextRun:    time.Now().Add(interval),
+			Exclusive:  exclusive,
+		},
+	}
+	s.jobs[name] = j
+
+	if s.started {
+		s.launch(j)
+	}
+	return nil
+}
+
+// Start begins running all registered jobs
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+	for _, j := range s.jobs {
+		s.launch(j)
+	}
+}
+
+// launch starts the loop for a job; caller must hold s.mu
+func (s *Scheduler) launch(j *job) {
+	j.status.NextRun = time.Now().Add(j.interval)
+	s.wg.Add(1)
+	go s.loop(j)
+}
+
+func (s *Scheduler) loop(j *job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-j.trigger:
+		}
+		s.execute(j)
+	}
+}
+
+// execute performs one run of a job and records its outcome
+func (s *Scheduler) execute(j *job) {
+	s.mu.Lock()
+	locker := s.locker
+	s.mu.Unlock()
+
+	if j.exclusive {
+		// Hold the lease for most of the interval so other replicas skip
+		// this period, while leaving slack for the next tick
+		acquired, err := locker.TryLock(j.name, j.interval*9/10)
+		if err != nil || !acquired {
+			s.mu.Lock()
+			j.status.NextRun = time.Now().Add(j.interval)
+			if err != nil {
+				j.status.FailCount++
+				j.status.LastError = fmt.Sprintf("lock: %v", err)
+				log.Printf("Job %s: failed to acquire lock: %v\n", j.name, err)
+			} else {
+				j.status.SkippedCount++
+			}
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	s.mu.Lock()
+	j.status.Running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := safeRun(s.ctx, j.fn)
+	duration := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j.status.Running = false
+	j.status.LastRun = start
+	j.status.NextRun = time.Now().Add(j.interval)
+	j.status.LastDurationMs = float64(duration.Microseconds()) / 1000
+	j.status.RunCount++
+	j.status.LastError = ""
+	if err != nil {
+		j.status.FailCount++
+		j.status.LastError = err.Error()
+		log.Printf("Job %s failed: %v\n", j.name, err)
+	}
+}
+
+func safeRun(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// RunNow triggers an immediate run of a job without waiting for its interval
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, exists := s.jobs[name]
+	if !exists {
+		return fmt.Errorf("job %s not found", name)
+	}
+	if !s.started {
+		return fmt.Errorf("scheduler not started")
+	}
+
+	select {
+	case j.trigger <- struct{}{}:
+	default: // a run is already pending
+	}
+	return nil
+}
+
+// Stop cancels all jobs and waits for in-flight runs to finish or ctx to expire
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running: %w", ctx.Err())
+	}
+}
+
+// Statuses returns the status of every job, sorted by name
+func (s *Scheduler) Statuses() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	sort.Slice(statuses, func(i, k int) bool {
+		return statuses[i].Name < statuses[k].Name
+	})
+	return statuses
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// Achievement IDs
+const (
+	AchievementTop100     = "top_100"
+	AchievementRating4000 = "rating_4000"
+	AchievementWinStreak  = "win_streak_10"
+)
+
+const (
+	achievementTopRank    = 100
+	achievementRating     = 4000
+	achievementStreak     = 10
+	achievementFeedBuffer = 4096 // changes queued between the store and the evaluator
+)
+
+// achievementCatalog names and describes every achievement
+var achievementCatalog = map[string]models.Achievement{
+	AchievementTop100:     {ID: AchievementTop100, Name: "Top 100", Description: "Ranked in the top 100 for the first time"},
+	AchievementRating4000: {ID: AchievementRating4000, Name: "Grandmaster", Description: "Reached a rating of 4000"},
+	AchievementWinStreak:  {ID: AchievementWinStreak, Name: "On Fire", Description: "Won 10 matches in a row"},
+}
+
+// AchievementService awards achievements as the store changes. A win is a
+// match result that raised the user's rating and a loss one that lowered
+// it; ratings set any other way leave the streak alone.
+type AchievementService struct {
+	store   *store.MemoryStore
+	ledger  *store.AchievementLedger
+	live    *LiveHub // optional; receives an event per unlock
+	changes chan store.Change
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewAchievementService(memoryStore *store.MemoryStore, ledger *store.AchievementLedger) *AchievementService {
+	return &AchievementService{
+		store:   memoryStore,
+		ledger:  ledger,
+		changes: make(chan store.Change, achievementFeedBuffer),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// SetLiveHub announces every unlock to live clients
+func (a *AchievementService) SetLiveHub(hub *LiveHub) {
+	a.live = hub
+}
+
+// Feed is the channel to pass to MemoryStore.AddChangeFeed
+func (a *AchievementService) Feed() chan<- store.Change {
+	return a.changes
+}
+
+// Backlog returns how many changes are queued for evaluation and how many
+// fit before they are dropped
+func (a *AchievementService) Backlog() (int, int) {
+	return len(a.changes), cap(a.changes)
+}
+
+// Start begins evaluating changes in the background
+func (a *AchievementService) Start() {
+	go a.run()
+}
+
+func (a *AchievementService) run() {
+	defer close(a.done)
+	for {
+		select {
+		case change := <-a.changes:
+			a.evaluate(change)
+		case <-a.stop:
+			// Writers have stopped by now; finish what they queued
+			for {
+				select {
+				case change := <-a.changes:
+					a.evaluate(change)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stop evaluates the changes already queued and then ends evaluation
+func (a *AchievementService) Stop(ctx context.Context) error {
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *AchievementService) evaluate(change store.Change) {
+	userID := change.User.ID
+	switch change.Type {
+	case store.ChangeCleared:
+		a.ledger.Clear()
+		return
+	case store.ChangeRemoved:
+		a.ledger.Forget(userID)
+		return
+	}
+
+	if change.Type == store.ChangeRating && change.Source == store.SourceMatch {
+		streak := 0
+		if change.User.Rating > change.OldRating {
+			streak = a.ledger.Streak(userID) + 1
+		}
+		a.ledger.SetStreak(userID, streak)
+		if streak >= achievementStreak {
+			a.unlock(change, AchievementWinStreak)
+		}
+	}
+	if change.Rank > 0 && change.Rank <= achievementTopRank {
+		a.unlock(change, AchievementTop100)
+	}
+	if change.User.Rating >= achievementRating {
+		a.unlock(change, AchievementRating4000)
+	}
+}
+
+func (a *AchievementService) unlock(change store.Change, id string) {
+	if !a.ledger.Unlock(change.User.ID, id, change.At) {
+		return
+	}
+	if a.live != nil {
+		achievement := achievementCatalog[id]
+		achievement.UnlockedAt = change.At
+		user := withRank(&change.User, change.Rank, change.Rank-1, change.TotalUsers)
+		a.live.Publish(models.LiveEvent{
+			Type:        "achievement",
+			User:        &user,
+			Achievement: &achievement,
+			Source:      string(change.Source),
+			Trace:       change.Trace,
+			At:          change.At,
+		})
+	}
+}
+
+// GetAchievements returns what a user has unlocked, in the order earned,
+// and their current win streak
+func (a *AchievementService) GetAchievements(ctx context.Context, userID string) (*models.AchievementsResponse, error) {
+	if _, err := a.store.GetUserContext(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	unlocked := a.ledger.Unlocked(userID)
+	achievements := make([]models.Achievement, 0, len(unlocked))
+	for _, entry := range unlocked {
+		achievement, known := achievementCatalog[entry.ID]
+		if !known {
+			continue
+		}
+		achievement.UnlockedAt = entry.UnlockedAt
+		achievements = append(achievements, achievement)
+	}
+	return &models.AchievementsResponse{
+		UserID:       userID,
+		Achievements: achievements,
+		WinStreak:    a.ledger.Streak(userID),
+	}, nil
+}
+
+// Save writes the ledger to disk if it changed
+func (a *AchievementService) Save() error {
+	return a.ledger.Save()
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"math"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// ArchiveService closes seasons and serves the final standings of closed
+// seasons from disk
+type ArchiveService struct {
+	archive    *store.SeasonArchive
+	store      *store.MemoryStore
+	baseRating int // where season resets move ratings by default
+}
+
+func NewArchiveService(archive *store.SeasonArchive, s *store.MemoryStore) *ArchiveService {
+	return &ArchiveService{
+		archive:    archive,
+		store:      s,
+		baseRating: DefaultSeasonBaseRating,
+	}
+}
+
+// ArchiveCurrent writes the live leaderboard as the final standings of season
+func (a *ArchiveService) ArchiveCurrent(season string) (*models.SeasonInfo, error) {
+	return a.archive.Archive(season, a.store.Snapshot())
+}
+
+// ListSeasons returns every archived season
+func (a *ArchiveService) ListSeasons() (*models.SeasonListResponse, error) {
+	seasons, err := a.archive.List()
+	if err != nil {
+		return nil, err
+	}
+	return &models.SeasonListResponse{
+		Seasons: seasons,
+		Count:   len(seasons),
+		Cache:   a.archive.GetCacheStats(),
+	}, nil
+}
+
+// GetLeaderboard returns one page of an archived season's final standings
+func (a *ArchiveService) GetLeaderboard(season string, limit, offset int) (*models.SeasonLeaderboardResponse, error) {
+	users, info, err := a.archive.Page(season, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SeasonLeaderboardResponse{
+		Season:     season,
+		Users:      users,
+		TotalUsers: info.UserCount,
+		Page:       offset/limit + 1,
+		PageSize:   limit,
+		HasMore:    offset+limit < info.UserCount,
+	}, nil
+}
+
+// GetUserStanding returns a user's final rank and percentile in a season
+func (a *ArchiveService) GetUserStanding(season, userID string) (*models.SeasonStandingResponse, error) {
+	standing, info, err := a.archive.FindUser(season, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SeasonStandingResponse{
+		Season:           season,
+		ArchivedStanding: *standing,
+		Percentile:       percentile(standing.Rank, info.UserCount),
+		TotalUsers:       info.UserCount,
+	}, nil
+}
+
+// percentile is the share of players ranked below rank, rounded to 2 decimals
+func percentile(rank, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(total-rank)*10000/float64(total)) / 100
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// DefaultBoard names the board the unprefixed /api routes serve
+const DefaultBoard = "global"
+
+var boardNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+var (
+	// ErrBoardNotFound is returned for a board name that doesn't exist
+	ErrBoardNotFound = errors.New("board not found")
+	// ErrBoardExists is returned when creating a board that already exists
+	ErrBoardExists = errors.New("board already exists")
+	// ErrDefaultBoard is returned when deleting the default board
+	ErrDefaultBoard = errors.New("the default board cannot be deleted")
+	// ErrTooManyBoards is returned when creating a board past the limit
+	ErrTooManyBoards = errors.New("board limit reached")
+)
+
+// Board is one named leaderboard with its own users, ratings and ranking
+type Board struct {
+	Name        string
+	Store       *store.MemoryStore
+	RatingIndex *store.RatingBucketIndex
+	Users       *UserService
+	Leaderboard *LeaderboardService
+
+	persistence *store.Persistence // nil for the default board, which main saves
+	savedAt     uint64             // store mutation count at the last save
+	autoSaver   *store.AutoSaver   // decides when Autosave saves the board
+}
+
+// BoardOptions are the settings every board created by a manager shares
+// with the default board
+type BoardOptions struct {
+	SkipListImpl    store.SkipListImpl
+	SkipListParams  store.SkipListParams // zero means store.DefaultSkipListParams
+	RatingRangeMode store.RatingRangeMode
+	MinRating       int
+	MaxRating       int
+	Ranking         RankingMode
+	UsernamePolicy  *UsernamePolicy // optional
+	RatingEngine    RatingEngine    // optional, Elo with DefaultEloK when nil
+	Presence        *store.PresenceTracker
+	MaxBoards       int // boards besides the default one (0 = unlimited)
+
+	// Saving, as for the default board's file
+	PersistenceShards  int              // 0 keeps a single file
+	PersistenceWorkers int              // 0 keeps one per CPU
+	SyncPolicy         store.SyncPolicy // "" keeps store.SyncOnSave
+	AutosaveInterval   time.Duration    // 0 disables timed saves
+	AutosaveWrites     int              // 0 disables write-count saves
+}
+
+// LeaderboardManager owns every named board: the default one the server
+// always had and any created since, each kept in its own file under dir.
+// Boards share presence, so a heartbeat marks a user online on all of them.
+type LeaderboardManager struct {
+	mu     sync.RWMutex
+	boards map[string]*Board
+	dir    string
+	opts   BoardOptions
+	saveMu sync.Mutex // one Save at a time, guards Board.savedAt
+}
+
+// NewLeaderboardManager creates a manager whose default board is served by
+// the given services; call Load to bring back saved boards
+func NewLeaderboardManager(dir string, defaultBoard *Board, opts BoardOptions) *LeaderboardManager {
+	defaultBoard.Name = DefaultBoard
+	return &LeaderboardManager{
+		boards: map[string]*Board{DefaultBoard: defaultBoard},
+		dir:    dir,
+		opts:   opts,
+	}
+}
+
+// ValidateBoardName checks a name is 1-32 lowercase letters, digits, '-'
+// or '_', starting with a letter or digit
+func ValidateBoardName(name string) error {
+	if !boardNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid board name %q: use 1-32 lowercase letters, digits, '-' or '_'", name)
+	}
+	return nil
+}
+
+// newBoard builds an empty board saved to its file under the manager's dir
+func (m *LeaderboardManager) newBoard(name string) *Board {
+	ratingIndex := store.NewRatingBucketIndex()
+	params := m.opts.SkipListParams
+	if params.MaxLevel == 0 {
+		params = store.DefaultSkipListParams()
+	}
+	memoryStore := store.NewMemoryStoreWithSkipListParams(ratingIndex, m.opts.SkipListImpl, params)
+	memoryStore.SetRatingRangeMode(m.opts.RatingRangeMode)
+
+	users := NewUserService(memoryStore, ratingIndex, m.opts.Presence, m.opts.MinRating, m.opts.MaxRating)
+	if m.opts.UsernamePolicy != nil {
+		users.SetUsernamePolicy(m.opts.UsernamePolicy)
+	}
+	if m.opts.RatingEngine != nil {
+		users.SetRatingEngine(m.opts.RatingEngine)
+	}
+	leaderboard := NewLeaderboardService(memoryStore, ratingIndex, m.opts.Presence)
+	leaderboard.SetRankingMode(m.opts.Ranking)
+
+	persistence := store.NewPersistence(filepath.Join(m.dir, name+".json"))
+	if m.opts.PersistenceShards > 0 {
+		persistence.SetShards(m.opts.PersistenceShards)
+	}
+	if m.opts.PersistenceWorkers > 0 {
+		persistence.SetWorkers(m.opts.PersistenceWorkers)
+	}
+	if m.opts.SyncPolicy != "" {
+		persistence.SetSyncPolicy(m.opts.SyncPolicy)
+	}
+
+	return &Board{
+		Name:        name,
+		Store:       memoryStore,
+		RatingIndex: ratingIndex,
+		Users:       users,
+		Leaderboard: leaderboard,
+		persistence: persistence,
+	}
+}
+
+// Load brings back every board saved under dir
+func (m *LeaderboardManager) Load() error {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if ValidateBoardName(name) != nil || name == DefaultBoard {
+			continue
+		}
+		board := m.newBoard(name)
+		if err := board.persistence.Load(board.Store, board.RatingIndex); err != nil {
+			return fmt.Errorf("failed to load board %s: %w", name, err)
+		}
+		board.savedAt = board.Store.GetMutationCount()
+		m.watch(board)
+		m.boards[name] = board
+	}
+	return nil
+}
+
+// Create adds an empty board and saves it, so it exists after a restart
+// even before it has users
+func (m *LeaderboardManager) Create(name string) (*Board, error) {
+	if err := ValidateBoardName(name); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.boards[name]; exists {
+		return nil, ErrBoardExists
+	}
+	if m.opts.MaxBoards > 0 && len(m.boards)-1 >= m.opts.MaxBoards {
+		return nil, ErrTooManyBoards
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	board := m.newBoard(name)
+	if err := board.persistence.Save(board.Store); err != nil {
+		return nil, err
+	}
+	m.watch(board)
+	m.boards[name] = board
+	return board, nil
+}
+
+// boardSaver is the store.Saver a board's AutoSaver saves through, so that
+// autosaves and Save agree on what is unsaved
+type boardSaver struct {
+	manager *LeaderboardManager
+	board   *Board
+}
+
+// Save is called with the manager's saveMu held
+func (s boardSaver) Save(*store.MemoryStore) error {
+	return s.manager.saveBoard(s.board)
+}
+
+// watch starts tracking a board's writes for Autosave from its saved state
+func (m *LeaderboardManager) watch(board *Board) {
+	board.autoSaver = store.NewAutoSaver(boardSaver{m, board}, board.Store, m.opts.AutosaveInterval, m.opts.AutosaveWrites)
+}
+
+// Get returns the named board
+func (m *LeaderboardManager) Get(name string) (*Board, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	board, ok := m.boards[name]
+	if !ok {
+		return nil, ErrBoardNotFound
+	}
+	return board, nil
+}
+
+// Delete removes a board and its file; the default board can't be deleted
+func (m *LeaderboardManager) Delete(name string) error {
+	if name == DefaultBoard {
+		return ErrDefaultBoard
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	board, ok := m.boards[name]
+	if !ok {
+		return ErrBoardNotFound
+	}
+	delete(m.boards, name)
+	if err := board.persistence.Delete(); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete board file: %w", err)
+	}
+	return nil
+}
+
+// List describes every board, the default one first
+func (m *LeaderboardManager) List() []models.BoardInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	boards := make([]models.BoardInfo, 0, len(m.boards))
+	for _, board := range m.boards {
+		boards = append(boards, models.BoardInfo{
+			Name:       board.Name,
+			TotalUsers: board.Store.GetUserCount(),
+			Default:    board.Name == DefaultBoard,
+		})
+	}
+	sort.Slice(boards, func(i, j int) bool {
+		if boards[i].Default != boards[j].Default {
+			return boards[i].Default
+		}
+		return boards[i].Name < boards[j].Name
+	})
+	return boards
+}
+
+// created returns the boards besides the default one; the caller holds m.mu
+func (m *LeaderboardManager) created() []*Board {
+	boards := make([]*Board, 0, len(m.boards)-1)
+	for _, board := range m.boards {
+		if board.persistence != nil {
+			boards = append(boards, board)
+		}
+	}
+	return boards
+}
+
+// Save writes every created board that changed since its last save. A
+// failed board is logged and the rest are still saved. Boards can't be
+// created or deleted meanwhile, so a deleted board's file isn't written
+// back.
+func (m *LeaderboardManager) Save() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var failed error
+	for _, board := range m.created() {
+		if err := m.saveBoard(board); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// Autosave saves the created boards whose AutoSaver says a save is due, by
+// the AutosaveInterval and AutosaveWrites options. Call it every
+// store.AutosaveCheckInterval.
+func (m *LeaderboardManager) Autosave() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var failed error
+	for _, board := range m.created() {
+		if err := board.autoSaver.Check(); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// saveBoard saves a board if it changed since its last save; the caller
+// holds saveMu
+func (m *LeaderboardManager) saveBoard(board *Board) error {
+	mutations := board.Store.GetMutationCount()
+	if mutations == board.savedAt {
+		return nil
+	}
+	if err := board.persistence.Save(board.Store); err != nil {
+		log.Printf("Failed to save board %s: %v\n", board.Name, err)
+		return err
+	}
+	board.savedAt = mutations
+	return nil
+}
+
+// SnapshotRanks takes the rank_change snapshot of every created board; the
+// default board's is taken with the rest of its service
+func (m *LeaderboardManager) SnapshotRanks(ctx context.Context) error {
+	m.mu.RLock()
+	boards := m.created()
+	m.mu.RUnlock()
+
+	for _, board := range boards {
+		if err := board.Leaderboard.SnapshotRanks(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InflateDeviations ends the Glicko-2 rating period of every created board;
+// the default board's is ended with the rest of its service
+func (m *LeaderboardManager) InflateDeviations() int {
+	m.mu.RLock()
+	boards := m.created()
+	m.mu.RUnlock()
+
+	changed := 0
+	for _, board := range boards {
+		changed += board.Users.InflateDeviations()
+	}
+	return changed
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/models"
+)
+
+var leaderboardCoalescedTotal = metrics.NewCounter("leaderboard_coalesced_total", "Leaderboard requests answered by another request's identical page load")
+
+// loadedPage is one traversal of the store for a leaderboard page
+type loadedPage struct {
+	version    uint64
+	users      []models.UserWithRank // shared by every waiter; read only
+	totalUsers int
+}
+
+// pageFlight is a page load in progress that identical requests wait on
+type pageFlight struct {
+	done chan struct{}
+	page loadedPage
+	err  error
+}
+
+// pageFlights coalesces identical concurrent page loads, so a burst of
+// requests for the same page at the same store version walks the store once
+type pageFlights struct {
+	mu      sync.Mutex
+	flights map[string]*pageFlight
+	hook    func(leader bool) // optional; see SetPageFlightHook
+}
+
+func newPageFlights() *pageFlights {
+	return &pageFlights{flights: make(map[string]*pageFlight)}
+}
+
+// do runs load for key, or waits for the run already in progress and shares
+// its result. A waiter whose own request is still live loads the page itself
+// if the run it waited on was abandoned with its request.
+func (p *pageFlights) do(ctx context.Context, key string, load func(ctx context.Context) (loadedPage, error)) (loadedPage, error) {
+	p.mu.Lock()
+	if f, ok := p.flights[key]; ok {
+		p.mu.Unlock()
+		if p.hook != nil {
+			p.hook(false)
+		}
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return loadedPage{}, ctx.Err()
+		}
+		if isContextError(f.err) && ctx.Err() == nil {
+			return load(ctx)
+		}
+		leaderboardCoalescedTotal.Inc()
+		return f.page, f.err
+	}
+	f := &pageFlight{done: make(chan struct{})}
+	p.flights[key] = f
+	p.mu.Unlock()
+
+	if p.hook != nil {
+		p.hook(true)
+	}
+	f.page, f.err = load(ctx)
+
+	p.mu.Lock()
+	delete(p.flights, key)
+	p.mu.Unlock()
+	close(f.done)
+	return f.page, f.err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// SetPageFlightHook has fn called as each page request joins a load: with
+// leader true just before the request walks the store itself, and false
+// when it waits on an identical load already under way. A leader's hook
+// holds up that load and everyone waiting on it, which lets tests line
+// requests up deterministically. Set it before serving requests.
+func (l *LeaderboardService) SetPageFlightHook(fn func(leader bool)) {
+	l.inflight.hook = fn
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"leaderboard-backend/models"
+)
+
+// LeaderboardCursor is a position in leaderboard order: just after the user
+// with this rating and username. It is written "rating,username".
+type LeaderboardCursor struct {
+	Rating   int
+	Username string
+}
+
+// ParseCursor reads a cursor written by LeaderboardCursor.String. Only the
+// first comma separates the fields, as usernames may contain commas.
+func ParseCursor(s string) (LeaderboardCursor, error) {
+	ratingStr, username, ok := strings.Cut(s, ",")
+	if !ok {
+		return LeaderboardCursor{}, fmt.Errorf("cursor %q is not rating,username", s)
+	}
+	rating, err := strconv.Atoi(ratingStr)
+	if err != nil {
+		return LeaderboardCursor{}, fmt.Errorf("cursor %q has no valid rating", s)
+	}
+	return LeaderboardCursor{Rating: rating, Username: username}, nil
+}
+
+func (c LeaderboardCursor) String() string {
+	return strconv.Itoa(c.Rating) + "," + c.Username
+}
+
+// cursorAfter is the cursor for the page following user
+func cursorAfter(user models.UserWithRank) LeaderboardCursor {
+	return LeaderboardCursor{Rating: user.Rating, Username: user.Username}
+}
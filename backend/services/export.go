@@ -0,0 +1,81 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"leaderboard-backend/store"
+)
+
+// ExportSchemaVersion identifies the export columns. It changes only when
+// columns are removed or change meaning; new columns are added at the end.
+const ExportSchemaVersion = 1
+
+// exportColumns is the header row of a user export
+var exportColumns = []string{"rank", "id", "username", "rating", "tier", "bot"}
+
+// UserExport is every user at one instant, in leaderboard order, ready to
+// be written for the data warehouse
+type UserExport struct {
+	standings *store.Standings
+}
+
+// ExportUsers reads every user and their rank under one brief write freeze,
+// so the export is a consistent picture of the board
+func (l *LeaderboardService) ExportUsers(ctx context.Context) (*UserExport, error) {
+	standings, err := l.store.GetStandingsContext(ctx, l.store.GetUserCount())
+	if err != nil {
+		return nil, err
+	}
+	return &UserExport{standings: standings}, nil
+}
+
+// Version is the store version the export was read at
+func (e *UserExport) Version() uint64 {
+	return e.standings.Version
+}
+
+// TakenAt is when the export was read
+func (e *UserExport) TakenAt() time.Time {
+	return e.standings.TakenAt
+}
+
+// Count is the number of users exported
+func (e *UserExport) Count() int {
+	return len(e.standings.Users)
+}
+
+// WriteCSV writes the export as gzip-compressed CSV with a header row of
+// exportColumns. Ratings and ranks are integers, bot is true or false.
+func (e *UserExport) WriteCSV(w io.Writer) error {
+	compressed := gzip.NewWriter(w)
+	compressed.Name = "users.csv"
+	compressed.ModTime = e.standings.TakenAt
+
+	out := csv.NewWriter(compressed)
+	if err := out.Write(exportColumns); err != nil {
+		return err
+	}
+	for i, user := range e.standings.Users {
+		tier, _ := TierForRating(user.Rating)
+		if err := out.Write([]string{
+			strconv.Itoa(e.standings.Ranks[i]),
+			user.ID,
+			user.Username,
+			strconv.Itoa(user.Rating),
+			tier,
+			strconv.FormatBool(user.Bot),
+		}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"leaderboard-backend/models"
+)
+
+// MaxFinalTop caps how many places a final standings request may certify;
+// the read holds off every write for its duration
+const MaxFinalTop = 1000
+
+// finalSignatureAlgorithm names how Signature is computed from Digest
+const finalSignatureAlgorithm = "hmac-sha256"
+
+// SetSigningKey makes final standings carry an HMAC signature of their
+// digest. Without a key they carry only the digest.
+func (l *LeaderboardService) SetSigningKey(key []byte) {
+	l.signingKey = key
+}
+
+// GetFinalStandings returns the top places read under a brief write freeze,
+// with a digest that pins down exactly what was certified
+func (l *LeaderboardService) GetFinalStandings(ctx context.Context, top int) (*models.FinalStandingsResponse, error) {
+	standings, err := l.store.GetStandingsContext(ctx, top)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]models.UserWithRank, len(standings.Users))
+	for i, user := range standings.Users {
+		users[i] = withRank(user, standings.Ranks[i], standings.Ranks[i]-1, standings.TotalUsers)
+	}
+
+	response := &models.FinalStandingsResponse{
+		Users:       users,
+		Top:         top,
+		TotalUsers:  standings.TotalUsers,
+		Version:     standings.Version,
+		GeneratedAt: standings.TakenAt,
+	}
+	digest := sha256.Sum256(CanonicalStandings(response))
+	response.Digest = hex.EncodeToString(digest[:])
+	if len(l.signingKey) > 0 {
+		response.Signature = signDigest(l.signingKey, response.Digest)
+		response.SignatureAlgorithm = finalSignatureAlgorithm
+	}
+	return response, nil
+}
+
+// CanonicalStandings is the byte form the digest covers: a header line,
+// a line with the totals, then one line per place with rank, ID, quoted
+// username and rating separated by tabs
+func CanonicalStandings(response *models.FinalStandingsResponse) []byte {
+	var b strings.Builder
+	b.WriteString("leaderboard-final v1\n")
+	fmt.Fprintf(&b, "top=%d total_users=%d version=%d generated_at=%s\n",
+		response.Top, response.TotalUsers, response.Version, response.GeneratedAt.UTC().Format(time.RFC3339Nano))
+	for _, user := range response.Users {
+		fmt.Fprintf(&b, "%d\t%s\t%s\t%d\n", user.Rank, user.ID, strconv.Quote(user.Username), user.Rating)
+	}
+	return []byte(b.String())
+}
+
+// VerifyFinalStandings reports whether response is unaltered: its digest
+// matches its contents and, given the server's key, its signature matches
+// the digest
+func VerifyFinalStandings(response *models.FinalStandingsResponse, key []byte) bool {
+	digest := sha256.Sum256(CanonicalStandings(response))
+	if !hmac.Equal([]byte(hex.EncodeToString(digest[:])), []byte(response.Digest)) {
+		return false
+	}
+	if len(key) == 0 {
+		return true
+	}
+	return hmac.Equal([]byte(signDigest(key, response.Digest)), []byte(response.Signature))
+}
+
+func signDigest(key []byte, digest string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(digest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"math"
+
+	"leaderboard-backend/models"
+)
+
+// Glicko-2 defaults for a player who has not played yet
+const (
+	DefaultGlickoRD         = 350.0
+	DefaultGlickoVolatility = 0.06
+	// DefaultGlickoTau limits how fast volatility changes; Glickman suggests
+	// 0.3 to 1.2
+	DefaultGlickoTau = 0.5
+)
+
+const (
+	glickoScale   = 173.7178 // converts ratings to and from the Glicko-2 scale
+	glickoCenter  = 1500
+	glickoEpsilon = 0.000001 // convergence tolerance of the volatility search
+)
+
+// GlickoEngine rates matches with the Glicko-2 system. Each match is its own
+// rating period, so ratings move as soon as a result comes in; a player's
+// rating deviation (RD) shrinks as they play and widens again through
+// Inflate while they don't. Users with no RD yet start from the defaults.
+type GlickoEngine struct {
+	Tau float64
+}
+
+// NewGlickoEngine creates a Glicko-2 engine (tau 0 = DefaultGlickoTau)
+func NewGlickoEngine(tau float64) *GlickoEngine {
+	if tau <= 0 {
+		tau = DefaultGlickoTau
+	}
+	return &GlickoEngine{Tau: tau}
+}
+
+func (g *GlickoEngine) Name() string {
+	return RatingSystemGlicko2
+}
+
+func (g *GlickoEngine) Rate(a, b models.User, score float64) (models.User, models.User) {
+	ratedA := g.rate(a, b, score)
+	ratedB := g.rate(b, a, 1-score)
+	return ratedA, ratedB
+}
+
+// Inflate returns the RD of a player after one rating period without a
+// match, never more than a new player's
+func (g *GlickoEngine) Inflate(rd, volatility float64) float64 {
+	rd, volatility = glickoDefaults(rd, volatility)
+	phi := rd / glickoScale
+	return math.Min(math.Sqrt(phi*phi+volatility*volatility)*glickoScale, DefaultGlickoRD)
+}
+
+// rate updates player after one game against opponent, both as they stood
+// before it
+func (g *GlickoEngine) rate(player, opponent models.User, score float64) models.User {
+	rd, volatility := glickoDefaults(player.RD, player.Volatility)
+	opponentRD, _ := glickoDefaults(opponent.RD, opponent.Volatility)
+
+	mu := float64(player.Rating-glickoCenter) / glickoScale
+	phi := rd / glickoScale
+	opponentMu := float64(opponent.Rating-glickoCenter) / glickoScale
+	gPhi := 1 / math.Sqrt(1+3*math.Pow(opponentRD/glickoScale, 2)/(math.Pi*math.Pi))
+
+	expected := 1 / (1 + math.Exp(-gPhi*(mu-opponentMu)))
+	v := 1 / (gPhi * gPhi * expected * (1 - expected))
+	delta := v * gPhi * (score - expected)
+
+	volatility = g.volatility(phi, volatility, v, delta)
+	phiStar := math.Sqrt(phi*phi + volatility*volatility)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * gPhi * (score - expected)
+
+	player.Rating = int(math.Round(mu*glickoScale)) + glickoCenter
+	player.RD = math.Min(phi*glickoScale, DefaultGlickoRD)
+	player.Volatility = volatility
+	return player
+}
+
+// volatility finds the new volatility with the Illinois algorithm, step 5 of
+// Glickman's "Example of the Glicko-2 system"
+func (g *GlickoEngine) volatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	tau2 := g.Tau * g.Tau
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/tau2
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*g.Tau) < 0 {
+			k++
+		}
+		B = a - k*g.Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// glickoDefaults fills in the RD and volatility of a player who has none
+func glickoDefaults(rd, volatility float64) (float64, float64) {
+	if rd <= 0 {
+		rd = DefaultGlickoRD
+	}
+	if volatility <= 0 {
+		volatility = DefaultGlickoVolatility
+	}
+	return rd, volatility
+}
@@ -1,6 +1,11 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+
 	"leaderboard-backend/models"
 	"leaderboard-backend/store"
 )
@@ -8,74 +13,600 @@ import (
 type LeaderboardService struct {
 	store       *store.MemoryStore
 	ratingIndex *store.RatingBucketIndex
+	presence    *store.PresenceTracker
+	snapshots   *pageSnapshots
+	inflight    *pageFlights                 // identical page loads in progress
+	signingKey  []byte                       // optional key for final standings signatures
+	ranking     RankingMode                  // used unless a filter asks for another
+	baseline    atomic.Pointer[rankBaseline] // ranks rank_change is measured from; see SnapshotRanks
+}
+
+// ErrCompactFiltered is returned for a compact page asked to filter users
+var ErrCompactFiltered = errors.New("compact pages cannot be filtered by online, exclude_bots or tier")
+
+// LeaderboardFilter restricts which users appear in leaderboard and search
+// results. Ranks are always global, even when users are filtered out, so
+// hiding bots leaves gaps in the ranks rather than promoting real players.
+// Ranking picks how those ranks treat ties ("" = the service default).
+// Tier keeps only users in that tier's rating band ("" = every tier).
+type LeaderboardFilter struct {
+	OnlineOnly  bool
+	ExcludeBots bool
+	Ranking     RankingMode
+	Tier        string
+}
+
+func (f LeaderboardFilter) active() bool {
+	return f.OnlineOnly || f.ExcludeBots || f.Tier != ""
 }
 
-func NewLeaderboardService(s *store.MemoryStore, ri *store.RatingBucketIndex) *LeaderboardService {
+// tierBand returns the rating band of the filter's tier; ok is false when
+// the filter has no tier
+func (f LeaderboardFilter) tierBand() (minRating, maxRating int, ok bool) {
+	if f.Tier == "" {
+		return 0, 0, false
+	}
+	tier, maxRating, err := FindTier(f.Tier)
+	if err != nil {
+		return 0, 0, false
+	}
+	return tier.MinRating, maxRating, true
+}
+
+func NewLeaderboardService(s *store.MemoryStore, ri *store.RatingBucketIndex, presence *store.PresenceTracker) *LeaderboardService {
 	return &LeaderboardService{
 		store:       s,
 		ratingIndex: ri,
+		presence:    presence,
+		snapshots:   newPageSnapshots(),
+		inflight:    newPageFlights(),
+		ranking:     RankingCompetition,
 	}
 }
 
-func (l *LeaderboardService) GetLeaderboard(limit, offset int) *models.LeaderboardResponse {
-	users := l.store.GetTopUsers(limit, offset)
-	totalUsers := l.store.GetUserCount()
+// matches reports whether a user passes the filter
+func (l *LeaderboardService) matches(user *models.User, filter LeaderboardFilter) bool {
+	if filter.OnlineOnly && !l.presence.IsOnline(user.ID) {
+		return false
+	}
+	if filter.ExcludeBots && user.Bot {
+		return false
+	}
+	if minRating, maxRating, ok := filter.tierBand(); ok && !inTier(user.Rating, minRating, maxRating) {
+		return false
+	}
+	return true
+}
 
-	usersWithRank := make([]models.UserWithRank, 0, len(users))
-	for _, user := range users {
-		rank := l.ratingIndex.GetRank(user.Rating)
-		usersWithRank = append(usersWithRank, models.UserWithRank{
-			ID:       user.ID,
-			Username: user.Username,
-			Rating:   user.Rating,
-			Rank:     rank,
-		})
+// withRank builds the public view of a user, attaching rank, tier and
+// percentile. usersAbove counts the users rated higher, whatever the
+// ranking mode, out of totalUsers.
+func withRank(user *models.User, rank, usersAbove, totalUsers int) models.UserWithRank {
+	tier, nextTierRating := TierForRating(user.Rating)
+	return models.UserWithRank{
+		ID:             user.ID,
+		Username:       user.Username,
+		Rating:         user.Rating,
+		Rank:           rank,
+		Percentile:     percentile(usersAbove+1, totalUsers),
+		TopPercent:     topPercent(usersAbove, totalUsers),
+		Tier:           tier,
+		NextTierRating: nextTierRating,
+		Bot:            user.Bot,
 	}
+}
 
-	hasMore := offset+limit < totalUsers
+func (l *LeaderboardService) GetLeaderboard(ctx context.Context, limit, offset int, filter LeaderboardFilter) (*models.LeaderboardResponse, error) {
+	version, usersWithRank, totalUsers, err := l.loadPage(ctx, limit, offset, filter)
+	if err != nil {
+		return nil, err
+	}
 
-	return &models.LeaderboardResponse{
+	response := &models.LeaderboardResponse{
 		Users:      usersWithRank,
 		TotalUsers: totalUsers,
 		Page:       offset/limit + 1,
 		PageSize:   limit,
-		HasMore:    hasMore,
+		HasMore:    offset+limit < totalUsers,
+		Version:    version,
 	}
+	response.RankChangeSince = l.rankChangeSince()
+	if response.HasMore && len(usersWithRank) > 0 {
+		response.NextCursor = cursorAfter(usersWithRank[len(usersWithRank)-1]).String()
+	}
+	return response, nil
 }
 
-func (l *LeaderboardService) SearchUsers(query string) *models.SearchResponse {
-	users := l.store.SearchUsers(query)
+// GetLeaderboardAfter returns the page of up to limit users ranked after
+// cursor. Unlike an offset the cursor is a position in the ordering, so
+// pages neither skip nor repeat users when others move between requests,
+// and it is found by a skip list seek rather than a walk from the top.
+// Page is 0 in this mode.
+func (l *LeaderboardService) GetLeaderboardAfter(ctx context.Context, limit int, cursor LeaderboardCursor, filter LeaderboardFilter) (*models.LeaderboardResponse, error) {
+	version := l.store.GetMutationCount()
+
+	var keep func(user *models.User) bool
+	if filter.active() {
+		keep = func(user *models.User) bool { return l.matches(user, filter) }
+	}
+	// One extra user tells whether another page follows
+	users, err := l.store.GetUsersAfterContext(ctx, cursor.Rating, cursor.Username, limit+1, keep)
+	if err != nil {
+		return nil, err
+	}
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
 
+	mode := l.rankingFor(filter)
 	usersWithRank := make([]models.UserWithRank, 0, len(users))
 	for _, user := range users {
-		rank := l.ratingIndex.GetRank(user.Rating)
-		usersWithRank = append(usersWithRank, models.UserWithRank{
-			ID:       user.ID,
-			Username: user.Username,
-			Rating:   user.Rating,
-			Rank:     rank,
+		usersWithRank = append(usersWithRank, l.ranked(user, mode))
+	}
+
+	response := &models.LeaderboardResponse{
+		Users:      usersWithRank,
+		TotalUsers: l.totalUsers(filter),
+		PageSize:   limit,
+		HasMore:    hasMore,
+		Version:    version,
+	}
+	response.RankChangeSince = l.rankChangeSince()
+	if hasMore {
+		response.NextCursor = cursorAfter(usersWithRank[len(usersWithRank)-1]).String()
+	}
+	return response, nil
+}
+
+// GetCompactLeaderboard reads a page as CompactUser rows, picking the few
+// fields out of each user in place instead of copying whole users. It
+// bypasses the page snapshots and coalescing of GetLeaderboard, which hold
+// full rows, and only the filter's ranking mode applies.
+func (l *LeaderboardService) GetCompactLeaderboard(ctx context.Context, limit, offset int, filter LeaderboardFilter) (*models.CompactLeaderboardResponse, error) {
+	if filter.active() {
+		return nil, ErrCompactFiltered
+	}
+	version := l.store.GetMutationCount()
+
+	rows := make([]models.CompactUser, 0, limit)
+	err := l.store.VisitTopUsersContext(ctx, limit, offset, func(user *models.User) bool {
+		rows = append(rows, models.CompactUser{ID: user.ID, Username: user.Username, Rating: user.Rating})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := make([]int, len(rows))
+	for i, row := range rows {
+		ratings[i] = row.Rating
+	}
+	mode := l.rankingFor(filter)
+	for i, standing := range l.ratingIndex.GetRatingStandings(ratings) {
+		rows[i].Rank = rankFor(standing, mode)
+	}
+
+	totalUsers := l.store.GetUserCount()
+	return &models.CompactLeaderboardResponse{
+		Users:      rows,
+		TotalUsers: totalUsers,
+		Page:       offset/limit + 1,
+		PageSize:   limit,
+		HasMore:    offset+limit < totalUsers,
+		Version:    version,
+	}, nil
+}
+
+// totalUsers counts the users a filter lets through. With both filters this
+// counts online bots too; there is no cheap way to count online humans. A
+// tier combined with another filter counts the whole tier.
+func (l *LeaderboardService) totalUsers(filter LeaderboardFilter) int {
+	if minRating, maxRating, ok := filter.tierBand(); ok {
+		users, _ := usersInTier(l.ratingIndex, minRating, maxRating)
+		return users
+	}
+	switch {
+	case filter.OnlineOnly:
+		return l.presence.OnlineCount()
+	case filter.ExcludeBots:
+		return l.store.GetUserCount() - l.store.GetBotCount()
+	}
+	return l.store.GetUserCount()
+}
+
+// GetLeaderboardDelta returns only the rows of a page whose rank or rating
+// changed since the client's copy at sinceVersion, plus the IDs that left the
+// page. If that copy is no longer known the whole page is returned with Full set.
+func (l *LeaderboardService) GetLeaderboardDelta(ctx context.Context, limit, offset int, filter LeaderboardFilter, sinceVersion uint64) (*models.LeaderboardDeltaResponse, error) {
+	version, usersWithRank, totalUsers, err := l.loadPage(ctx, limit, offset, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.LeaderboardDeltaResponse{
+		Version:      version,
+		SinceVersion: sinceVersion,
+		TotalUsers:   totalUsers,
+		Page:         offset/limit + 1,
+		PageSize:     limit,
+		HasMore:      offset+limit < totalUsers,
+	}
+
+	previous, ok := l.snapshots.get(pageKey(limit, offset, filter, sinceVersion))
+	if !ok {
+		response.Full = true
+		response.Changed = usersWithRank
+		response.Removed = []string{}
+		return response, nil
+	}
+
+	response.Changed, response.Removed = diffPage(previous, usersWithRank)
+	return response, nil
+}
+
+// loadPage reads one leaderboard page. Identical requests arriving while
+// it is being read, at the same store version, share the one read rather
+// than each walking the store; the rows returned may be shared and must not
+// be modified.
+func (l *LeaderboardService) loadPage(ctx context.Context, limit, offset int, filter LeaderboardFilter) (uint64, []models.UserWithRank, int, error) {
+	key := pageKey(limit, offset, filter, l.store.GetMutationCount())
+	page, err := l.inflight.do(ctx, key, func(ctx context.Context) (loadedPage, error) {
+		return l.readPage(ctx, limit, offset, filter)
+	})
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	return page.version, page.users, page.totalUsers, nil
+}
+
+// readPage reads one leaderboard page and remembers it under the store
+// version for later delta requests. The page is only remembered when no
+// write landed while it was being read, so a version always names exactly
+// one set of rows.
+func (l *LeaderboardService) readPage(ctx context.Context, limit, offset int, filter LeaderboardFilter) (loadedPage, error) {
+	// Reads may not hold the store lock, so check no write overlapped them
+	seq := l.store.GetRankingSeq()
+	version := l.store.GetMutationCount()
+
+	var users []*models.User
+	var totalUsers int
+	var err error
+	if filter.Tier != "" && !filter.OnlineOnly && !filter.ExcludeBots {
+		users, err = l.readTierPage(ctx, limit, offset, filter)
+	} else if filter.active() {
+		users, err = l.store.GetTopUsersFilteredContext(ctx, limit, offset, func(user *models.User) bool {
+			return l.matches(user, filter)
 		})
+	} else {
+		users, err = l.store.GetTopUsersContext(ctx, limit, offset)
+	}
+	totalUsers = l.totalUsers(filter)
+	if err != nil {
+		return loadedPage{}, err
+	}
+
+	mode := l.rankingFor(filter)
+	usersWithRank := make([]models.UserWithRank, 0, len(users))
+	for _, user := range users {
+		usersWithRank = append(usersWithRank, l.ranked(user, mode))
+	}
+
+	if seq%2 == 0 && l.store.GetRankingSeq() == seq {
+		l.snapshots.put(pageKey(limit, offset, filter, version), usersWithRank)
+	}
+	return loadedPage{version: version, users: usersWithRank, totalUsers: totalUsers}, nil
+}
+
+// readTierPage reads a page of one tier. Users are ordered by rating, so the
+// tier starts right after the users rated above it and the page is read by
+// offset instead of filtering every user above the tier out.
+func (l *LeaderboardService) readTierPage(ctx context.Context, limit, offset int, filter LeaderboardFilter) ([]*models.User, error) {
+	minRating, maxRating, _ := filter.tierBand()
+	count, above := usersInTier(l.ratingIndex, minRating, maxRating)
+	if offset >= count {
+		return []*models.User{}, nil
+	}
+	users, err := l.store.GetTopUsersContext(ctx, min(limit, count-offset), above+offset)
+	if err != nil {
+		return nil, err
+	}
+	// A write between counting and reading can shift users across the edge
+	kept := make([]*models.User, 0, len(users))
+	for _, user := range users {
+		if inTier(user.Rating, minRating, maxRating) {
+			kept = append(kept, user)
+		}
+	}
+	return kept, nil
+}
+
+func (l *LeaderboardService) SearchUsers(ctx context.Context, query string, filter LeaderboardFilter) (*models.SearchResponse, error) {
+	users, truncated, err := l.store.SearchUsersContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := l.rankingFor(filter)
+	usersWithRank := make([]models.UserWithRank, 0, len(users))
+	for _, user := range users {
+		if !l.matches(user, filter) {
+			continue
+		}
+		usersWithRank = append(usersWithRank, l.ranked(user, mode))
 	}
 
 	return &models.SearchResponse{
-		Users: usersWithRank,
-		Query: query,
-		Count: len(usersWithRank),
+		Users:     usersWithRank,
+		Query:     query,
+		Count:     len(usersWithRank),
+		Truncated: truncated,
+	}, nil
+}
+
+// SuggestUsernames returns typeahead suggestions for a username prefix
+func (l *LeaderboardService) SuggestUsernames(ctx context.Context, prefix string, limit int) (*models.SuggestResponse, error) {
+	suggestions, truncated, err := l.store.SuggestUsernamesContext(ctx, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SuggestResponse{
+		Suggestions: suggestions,
+		Query:       prefix,
+		Truncated:   truncated,
+	}, nil
+}
+
+func (l *LeaderboardService) GetUserWithRank(ctx context.Context, id string) (*models.UserWithRank, error) {
+	user, err := l.store.GetUserContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	userWithRank := l.ranked(user, l.ranking)
+
+	return &userWithRank, nil
+}
+
+// GetUserByUsername returns the ranked view of the user with the given handle
+func (l *LeaderboardService) GetUserByUsername(ctx context.Context, username string) (*models.UserWithRank, error) {
+	user, err := l.store.GetUserByUsernameContext(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	userWithRank := l.ranked(user, l.ranking)
+
+	return &userWithRank, nil
+}
+
+// GetRecentlyUpdated returns the users whose rating changed most recently,
+// only counting changes from source when it is set
+func (l *LeaderboardService) GetRecentlyUpdated(ctx context.Context, limit int, source store.Source) (*models.RecentUsersResponse, error) {
+	updates, err := l.store.GetRecentlyUpdatedContext(ctx, limit, source)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]models.RecentUser, 0, len(updates))
+	for _, update := range updates {
+		users = append(users, models.RecentUser{
+			UserWithRank: l.ranked(update.User, l.ranking),
+			UpdatedAt:    update.UpdatedAt,
+			Source:       string(update.Source),
+		})
+	}
+
+	return &models.RecentUsersResponse{
+		Users: users,
+		Count: len(users),
+	}, nil
+}
+
+// MaxAroundWindow caps how many neighbours on each side an "around me"
+// request may ask for
+const MaxAroundWindow = 50
+
+// GetAroundUser returns a user with the window users ranked directly above
+// and below them
+func (l *LeaderboardService) GetAroundUser(ctx context.Context, id string, window int) (*models.AroundUserResponse, error) {
+	around, err := l.store.GetAroundContext(ctx, id, window)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.AroundUserResponse{
+		Above:      make([]models.UserWithRank, 0, around.Index),
+		Below:      make([]models.UserWithRank, 0, len(around.Users)-around.Index-1),
+		Window:     window,
+		TotalUsers: around.TotalUsers,
+	}
+	ranks := around.Ranks
+	if l.ranking == RankingDense {
+		ratings := make([]int, len(around.Users))
+		for i, user := range around.Users {
+			ratings[i] = user.Rating
+		}
+		ranks = l.ratingIndex.GetDenseRanks(ratings)
+	}
+	for i, user := range around.Users {
+		ranked := l.withRankChange(withRank(user, ranks[i], around.Ranks[i]-1, around.TotalUsers), l.ranking)
+		switch {
+		case i < around.Index:
+			response.Above = append(response.Above, ranked)
+		case i == around.Index:
+			response.User = ranked
+		default:
+			response.Below = append(response.Below, ranked)
+		}
 	}
+	return response, nil
 }
 
-func (l *LeaderboardService) GetUserWithRank(id string) (*models.UserWithRank, error) {
-	user, err := l.store.GetUser(id)
+// MaxSampleSize caps how many users one sample may return
+const MaxSampleSize = 100
+
+// SampleUsers picks up to n distinct users at random, weighted as asked,
+// for featuring players
+func (l *LeaderboardService) SampleUsers(ctx context.Context, n int, weight store.SampleWeight) (*models.SampleResponse, error) {
+	sample, err := l.store.SampleUsersContext(ctx, n, weight)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]models.UserWithRank, len(sample))
+	for i := range sample {
+		users[i] = l.ranked(&sample[i], l.ranking)
+	}
+	return &models.SampleResponse{
+		Users:  users,
+		Count:  len(users),
+		Weight: string(weight),
+	}, nil
+}
+
+// GetRatingDistribution returns the non-empty rating buckets, merged into
+// bands of step rating points
+func (l *LeaderboardService) GetRatingDistribution(step int) *models.RatingDistributionResponse {
+	return &models.RatingDistributionResponse{
+		Buckets:    l.ratingIndex.GetDistribution(step),
+		Step:       step,
+		TotalUsers: l.ratingIndex.GetTotalUsers(),
+	}
+}
+
+// LookupRanks resolves ranks for many users and/or raw ratings at once.
+// All ranks are read under one index lock so they are mutually consistent.
+func (l *LeaderboardService) LookupRanks(ctx context.Context, userIDs []string, ratings []int) (*models.RankLookupResponse, error) {
+	users, missing, err := l.store.GetUsersContext(ctx, userIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	rank := l.ratingIndex.GetRank(user.Rating)
+	allRatings := make([]int, 0, len(users)+len(ratings))
+	for _, user := range users {
+		allRatings = append(allRatings, user.Rating)
+	}
+	for _, rating := range ratings {
+		clamped, err := l.store.CheckRating(rating)
+		if err != nil {
+			return nil, err
+		}
+		allRatings = append(allRatings, clamped)
+	}
+	standings := l.ratingIndex.GetRatingStandings(allRatings)
+
+	usersWithRank := make([]models.UserWithRank, 0, len(users))
+	for i, user := range users {
+		ranked := withRank(user, rankFor(standings[i], l.ranking), standings[i].UsersAbove, standings[i].TotalUsers)
+		usersWithRank = append(usersWithRank, l.withRankChange(ranked, l.ranking))
+	}
 
-	return &models.UserWithRank{
-		ID:       user.ID,
-		Username: user.Username,
-		Rating:   user.Rating,
-		Rank:     rank,
+	ratingRanks := make([]models.RatingRank, 0, len(ratings))
+	for i, rating := range ratings {
+		ratingRanks = append(ratingRanks, models.RatingRank{
+			Rating: rating,
+			Rank:   rankFor(standings[len(users)+i], l.ranking),
+		})
+	}
+
+	return &models.RankLookupResponse{
+		Users:    usersWithRank,
+		Ratings:  ratingRanks,
+		NotFound: missing,
 	}, nil
 }
+
+// GetSubsetLeaderboard ranks a chosen set of users, such as a player's
+// friends, against each other: they come back in leaderboard order with
+// their global ranks, read under one index lock like LookupRanks. Repeated
+// IDs are listed once; users the filter rejects are left out.
+func (l *LeaderboardService) GetSubsetLeaderboard(ctx context.Context, userIDs []string, filter LeaderboardFilter) (*models.SubsetLeaderboardResponse, error) {
+	seen := make(map[string]bool, len(userIDs))
+	unique := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	found, missing, err := l.store.GetUsersContext(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*models.User, 0, len(found))
+	for _, user := range found {
+		if l.matches(user, filter) {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Rating != users[j].Rating {
+			return users[i].Rating > users[j].Rating
+		}
+		if users[i].Username != users[j].Username {
+			return users[i].Username < users[j].Username
+		}
+		return users[i].ID < users[j].ID
+	})
+
+	return &models.SubsetLeaderboardResponse{
+		Users:    l.rankedAll(users, l.rankingFor(filter)),
+		NotFound: missing,
+	}, nil
+}
+
+// GetUsersWithRank is GetUserWithRank for many users at once: one store
+// read lock finds them all and one index lock ranks them. Users come back
+// in request order, each once; IDs merged into another account are listed
+// with the surviving ID rather than as not found.
+func (l *LeaderboardService) GetUsersWithRank(ctx context.Context, ids []string) (*models.BatchUsersResponse, error) {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	users, missing, err := l.store.GetUsersContext(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BatchUsersResponse{
+		Users:    l.rankedAll(users, l.ranking),
+		NotFound: make([]string, 0, len(missing)),
+	}
+	for _, id := range missing {
+		if into, merged := l.store.MergedInto(id); merged {
+			if response.Merged == nil {
+				response.Merged = make(map[string]string)
+			}
+			response.Merged[id] = into
+			continue
+		}
+		response.NotFound = append(response.NotFound, id)
+	}
+	return response, nil
+}
+
+// rankedAll is ranked for many users, with every rank read under one index
+// lock so they are consistent with each other
+func (l *LeaderboardService) rankedAll(users []*models.User, mode RankingMode) []models.UserWithRank {
+	ratings := make([]int, len(users))
+	for i, user := range users {
+		ratings[i] = user.Rating
+	}
+	standings := l.ratingIndex.GetRatingStandings(ratings)
+
+	usersWithRank := make([]models.UserWithRank, 0, len(users))
+	for i, user := range users {
+		ranked := withRank(user, rankFor(standings[i], mode), standings[i].UsersAbove, standings[i].TotalUsers)
+		usersWithRank = append(usersWithRank, l.withRankChange(ranked, mode))
+	}
+	return usersWithRank
+}
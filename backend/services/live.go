@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+const (
+	liveFeedBuffer   = 4096 // changes queued between the store and the hub
+	liveEventBuffer  = 256  // other events queued for the hub, such as unlocks
+	liveClientBuffer = 256  // events queued for each subscriber
+)
+
+// ErrHubStopped is returned when subscribing to a hub that has shut down
+var ErrHubStopped = errors.New("live updates are shutting down")
+
+// LiveHub fans store changes out to live subscribers such as websocket
+// clients. A subscriber that falls more than liveClientBuffer events behind
+// is cut off rather than allowed to hold up the others.
+type LiveHub struct {
+	changes chan store.Change
+	events  chan models.LiveEvent
+
+	mu      sync.Mutex
+	subs    map[*LiveSubscription]struct{}
+	stopped bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// LiveSubscription is one subscriber's stream of events. The channel is
+// closed when the subscriber is cut off for being slow or the hub stops.
+type LiveSubscription struct {
+	events chan models.LiveEvent
+	slow   bool // set before events is closed
+}
+
+// Events returns the subscriber's event stream
+func (s *LiveSubscription) Events() <-chan models.LiveEvent {
+	return s.events
+}
+
+// Slow reports whether the stream ended because the subscriber fell behind.
+// Only meaningful once Events has been closed.
+func (s *LiveSubscription) Slow() bool {
+	return s.slow
+}
+
+// NewLiveHub creates a hub; attach Feed to the store and call Start
+func NewLiveHub() *LiveHub {
+	return &LiveHub{
+		changes: make(chan store.Change, liveFeedBuffer),
+		events:  make(chan models.LiveEvent, liveEventBuffer),
+		subs:    make(map[*LiveSubscription]struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Feed is the channel to pass to MemoryStore.AddChangeFeed
+func (h *LiveHub) Feed() chan<- store.Change {
+	return h.changes
+}
+
+// Publish sends an event that isn't a store change to every subscriber.
+// Like the change feed it never blocks: when the hub is backed up the event
+// is dropped.
+func (h *LiveHub) Publish(event models.LiveEvent) {
+	select {
+	case h.events <- event:
+	default:
+	}
+}
+
+// Backlog returns how many changes and events are queued for the hub and
+// how many fit before they are dropped
+func (h *LiveHub) Backlog() (int, int) {
+	return len(h.changes) + len(h.events), cap(h.changes) + cap(h.events)
+}
+
+// Start begins delivering changes in the background
+func (h *LiveHub) Start() {
+	go h.run()
+}
+
+func (h *LiveHub) run() {
+	defer close(h.done)
+	for {
+		select {
+		case change := <-h.changes:
+			h.broadcast(liveEvent(change))
+		case event := <-h.events:
+			h.broadcast(event)
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+func (h *LiveHub) broadcast(event models.LiveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs {
+		select {
+		case sub.events <- event:
+		default:
+			sub.slow = true
+			h.drop(sub)
+		}
+	}
+}
+
+// drop closes a subscription; the caller holds h.mu
+func (h *LiveHub) drop(sub *LiveSubscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Stop ends delivery and closes every subscription, so connected clients
+// are told to go away
+func (h *LiveHub) Stop(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.stopped {
+		h.stopped = true
+		close(h.stop)
+	}
+	h.mu.Unlock()
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		h.drop(sub)
+	}
+	return nil
+}
+
+// Subscribe starts a stream of every later change
+func (h *LiveHub) Subscribe() (*LiveSubscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.stopped {
+		return nil, ErrHubStopped
+	}
+	sub := &LiveSubscription{events: make(chan models.LiveEvent, liveClientBuffer)}
+	h.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Unsubscribe ends a stream; it is safe to call after the hub dropped it
+func (h *LiveHub) Unsubscribe(sub *LiveSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.drop(sub)
+}
+
+// Subscribers returns how many streams are open
+func (h *LiveHub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subs)
+}
+
+func liveEvent(change store.Change) models.LiveEvent {
+	event := models.LiveEvent{
+		Type:      string(change.Type),
+		OldRating: change.OldRating,
+		OldRank:   change.OldRank,
+		Source:    string(change.Source),
+		Trace:     change.Trace,
+		At:        change.At,
+	}
+	if change.Type != store.ChangeCleared {
+		user := withRank(&change.User, change.Rank, change.Rank-1, change.TotalUsers)
+		event.User = &user
+	}
+	return event
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// ErrInvalidMatch is returned for a match without two distinct players
+var ErrInvalidMatch = errors.New("invalid match")
+
+// SetRatingEngine sets the rating system match results are rated with
+func (u *UserService) SetRatingEngine(engine RatingEngine) {
+	u.ratingEngine = engine
+	if _, ok := engine.(*GlickoEngine); ok {
+		u.played = make(map[string]bool)
+	} else {
+		u.played = nil
+	}
+}
+
+// RatingSystem names the rating system match results are rated with
+func (u *UserService) RatingSystem() string {
+	return u.ratingEngine.Name()
+}
+
+// RecordMatch rates a played match and applies both players' new ratings
+// with source match; the players in the result are not ranked. The
+// ratings are read, rated and written under both players' locks, so a
+// rating change landing meanwhile is neither lost nor overwritten, and two
+// results for the same player are both counted. A rating the range check
+// would reject fails the match before either player changes.
+func (u *UserService) RecordMatch(ctx context.Context, req models.MatchRequest) (*models.MatchResponse, error) {
+	if req.WinnerID == "" || req.LoserID == "" {
+		return nil, fmt.Errorf("%w: winner_id and loser_id are required", ErrInvalidMatch)
+	}
+	if req.WinnerID == req.LoserID {
+		return nil, fmt.Errorf("%w: a user cannot play themselves", ErrInvalidMatch)
+	}
+
+	score := ScoreWin
+	if req.Draw {
+		score = ScoreDraw
+	}
+
+	// Under Glicko-2, held throughout so a rating period cannot end between
+	// a match being applied and its players being marked as having played.
+	// Matches only share it, and Elo matches need nothing beyond the
+	// players' own locks.
+	_, glicko := u.ratingEngine.(*GlickoEngine)
+	if glicko {
+		u.periodMu.RLock()
+		defer u.periodMu.RUnlock()
+	}
+
+	before, after, err := u.store.UpdatePairRatingState(ctx, req.WinnerID, req.LoserID, func(winner, loser models.User) (store.RatingState, store.RatingState, error) {
+		winnerAfter, loserAfter := u.ratingEngine.Rate(winner, loser, score)
+		return ratingState(winnerAfter), ratingState(loserAfter), nil
+	}, store.SourceMatch)
+	if err != nil {
+		return nil, err
+	}
+	winner, loser := before[0], before[1]
+
+	if glicko {
+		u.playedMu.Lock()
+		u.played[winner.ID] = true
+		u.played[loser.ID] = true
+		u.playedMu.Unlock()
+	}
+
+	return &models.MatchResponse{
+		Winner:       matchPlayer(&winner, after[0]),
+		Loser:        matchPlayer(&loser, after[1]),
+		Draw:         req.Draw,
+		RatingSystem: u.ratingEngine.Name(),
+	}, nil
+}
+
+// InflateDeviations ends a Glicko-2 rating period: every rated user who
+// played no match during it has their RD widened, up to a new player's.
+// It returns how many changed, and does nothing under other rating systems.
+func (u *UserService) InflateDeviations() int {
+	glicko, ok := u.ratingEngine.(*GlickoEngine)
+	if !ok {
+		return 0
+	}
+	u.periodMu.Lock()
+	played := u.played
+	u.played = make(map[string]bool)
+	u.periodMu.Unlock()
+
+	return u.store.UpdateDeviations(func(user models.User) (float64, bool) {
+		if played[user.ID] || user.RD == 0 {
+			return 0, false
+		}
+		return glicko.Inflate(user.RD, user.Volatility), true
+	})
+}
+
+// matchPlayer describes user moving to after; the caller adds the rank
+func matchPlayer(user *models.User, after models.User) models.MatchPlayer {
+	return models.MatchPlayer{
+		UserWithRank: models.UserWithRank{ID: user.ID, Username: user.Username, Rating: after.Rating, Bot: user.Bot},
+		OldRating:    user.Rating,
+		Change:       after.Rating - user.Rating,
+		RD:           after.RD,
+		Volatility:   after.Volatility,
+	}
+}
+
+func ratingState(user models.User) store.RatingState {
+	return store.RatingState{Rating: user.Rating, RD: user.RD, Volatility: user.Volatility}
+}
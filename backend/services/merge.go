@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"leaderboard-backend/models"
+)
+
+// MergeStrategy decides the surviving account's rating when two accounts
+// are merged
+type MergeStrategy string
+
+const (
+	MergeMax     MergeStrategy = "max"     // the higher of the two ratings
+	MergeKeep    MergeStrategy = "keep"    // the kept account's rating
+	MergeDupe    MergeStrategy = "dupe"    // the duplicate's rating
+	MergeAverage MergeStrategy = "average" // the mean, rounded down
+)
+
+// ParseMergeStrategy validates a strategy name; empty means MergeMax
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	switch strategy := MergeStrategy(name); strategy {
+	case "":
+		return MergeMax, nil
+	case MergeMax, MergeKeep, MergeDupe, MergeAverage:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown rating strategy %q (want max, keep, dupe or average)", name)
+}
+
+func (s MergeStrategy) rating(keep, dupe int) int {
+	switch s {
+	case MergeKeep:
+		return keep
+	case MergeDupe:
+		return dupe
+	case MergeAverage:
+		return (keep + dupe) / 2
+	}
+	if dupe > keep {
+		return dupe
+	}
+	return keep
+}
+
+// SetMergeStrategy sets the rating strategy used when a merge request
+// doesn't name one
+func (u *UserService) SetMergeStrategy(strategy MergeStrategy) {
+	u.mergeStrategy = strategy
+}
+
+// MergeUsers folds the duplicate account dupeID into keepID, which takes a
+// rating chosen by strategy (the configured default when empty). The
+// duplicate is removed from every index and its ID tombstoned.
+func (u *UserService) MergeUsers(ctx context.Context, keepID, dupeID string, strategy MergeStrategy) (*models.MergeUsersResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if strategy == "" {
+		strategy = u.mergeStrategy
+	}
+	if strategy == "" {
+		strategy = MergeMax
+	}
+
+	response := &models.MergeUsersResponse{MergedID: dupeID, RatingStrategy: string(strategy)}
+	kept, err := u.store.MergeUsers(keepID, dupeID, func(keep, dupe models.User) int {
+		response.KeepRating, response.DupeRating = keep.Rating, dupe.Rating
+		return strategy.rating(keep.Rating, dupe.Rating)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.presence.Forget(dupeID)
+
+	response.User = *kept
+	return response, nil
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"leaderboard-backend/models"
+)
+
+// maxPageSnapshots bounds how many served pages are kept for delta requests
+const maxPageSnapshots = 256
+
+// pageSnapshots remembers the rows of recently served leaderboard pages, keyed
+// by page parameters and store version, so a later request can be answered
+// with only what changed since the client's copy
+type pageSnapshots struct {
+	mu    sync.Mutex
+	pages map[string][]models.UserWithRank
+	order []string // insertion order, oldest first
+}
+
+func newPageSnapshots() *pageSnapshots {
+	return &pageSnapshots{
+		pages: make(map[string][]models.UserWithRank),
+		order: make([]string, 0, maxPageSnapshots),
+	}
+}
+
+func pageKey(limit, offset int, filter LeaderboardFilter, version uint64) string {
+	return fmt.Sprintf("%d:%d:%t:%t:%s:%s:%d", limit, offset, filter.OnlineOnly, filter.ExcludeBots, filter.Ranking, strings.ToLower(filter.Tier), version)
+}
+
+func (p *pageSnapshots) put(key string, rows []models.UserWithRank) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.pages[key]; exists {
+		return
+	}
+	if len(p.order) >= maxPageSnapshots {
+		delete(p.pages, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.pages[key] = rows
+	p.order = append(p.order, key)
+}
+
+func (p *pageSnapshots) get(key string) ([]models.UserWithRank, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rows, ok := p.pages[key]
+	return rows, ok
+}
+
+// diffPage returns the rows of current whose rank or rating differ from
+// previous (or that are new to the page) and the IDs that left the page
+func diffPage(previous, current []models.UserWithRank) ([]models.UserWithRank, []string) {
+	before := make(map[string]models.UserWithRank, len(previous))
+	for _, row := range previous {
+		before[row.ID] = row
+	}
+
+	changed := make([]models.UserWithRank, 0)
+	for _, row := range current {
+		old, ok := before[row.ID]
+		if !ok || old.Rank != row.Rank || old.Rating != row.Rating {
+			changed = append(changed, row)
+		}
+		delete(before, row.ID)
+	}
+
+	removed := make([]string, 0, len(before))
+	for _, row := range previous {
+		if _, gone := before[row.ID]; gone {
+			removed = append(removed, row.ID)
+		}
+	}
+	return changed, removed
+}
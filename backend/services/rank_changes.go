@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"leaderboard-backend/models"
+)
+
+// snapshotRank is a user's rank in each ranking mode at the last snapshot
+type snapshotRank struct {
+	competition int32
+	dense       int32
+}
+
+// rankBaseline is every user's rank when SnapshotRanks last ran; rank_change
+// in responses is measured against it
+type rankBaseline struct {
+	ranks   map[string]snapshotRank
+	takenAt time.Time
+}
+
+// SnapshotRanks records every user's current rank as the baseline for
+// rank_change. Run it periodically: a user's rank_change is how far they
+// moved since the last run. Users who joined since have no rank_change.
+func (l *LeaderboardService) SnapshotRanks(ctx context.Context) error {
+	users := l.store.Snapshot()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ratings := make([]int, len(users))
+	for i := range users {
+		ratings[i] = users[i].Rating
+	}
+	standings := l.ratingIndex.GetRatingStandings(ratings)
+
+	ranks := make(map[string]snapshotRank, len(users))
+	for i := range users {
+		ranks[users[i].ID] = snapshotRank{
+			competition: int32(rankFor(standings[i], RankingCompetition)),
+			dense:       int32(rankFor(standings[i], RankingDense)),
+		}
+	}
+	l.baseline.Store(&rankBaseline{ranks: ranks, takenAt: time.Now()})
+	return nil
+}
+
+// rankChangeSince returns when the rank_change baseline was taken, nil if
+// never
+func (l *LeaderboardService) rankChangeSince() *time.Time {
+	if baseline := l.baseline.Load(); baseline != nil {
+		takenAt := baseline.takenAt.UTC()
+		return &takenAt
+	}
+	return nil
+}
+
+// withRankChange sets ranked.RankChange to the places the user climbed
+// (positive) or fell (negative) since the last snapshot in mode; it is left
+// nil when the user wasn't in the snapshot
+func (l *LeaderboardService) withRankChange(ranked models.UserWithRank, mode RankingMode) models.UserWithRank {
+	baseline := l.baseline.Load()
+	if baseline == nil {
+		return ranked
+	}
+	previous, ok := baseline.ranks[ranked.ID]
+	if !ok {
+		return ranked
+	}
+	before := int(previous.competition)
+	if mode == RankingDense {
+		before = int(previous.dense)
+	}
+	change := before - ranked.Rank
+	ranked.RankChange = &change
+	return ranked
+}
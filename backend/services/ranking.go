@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// RankingMode decides how users sharing a rating are ranked
+type RankingMode string
+
+const (
+	// RankingCompetition gives tied users the same rank and skips the places
+	// they take up: 1, 2, 2, 4
+	RankingCompetition RankingMode = "competition"
+	// RankingDense gives tied users the same rank without skipping: 1, 2, 2, 3
+	RankingDense RankingMode = "dense"
+)
+
+// ParseRankingMode validates a mode name; "" is returned as is and means the
+// service default
+func ParseRankingMode(name string) (RankingMode, error) {
+	switch mode := RankingMode(name); mode {
+	case "", RankingCompetition, RankingDense:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown ranking mode %q (want %q or %q)", name, RankingCompetition, RankingDense)
+}
+
+// SetRankingMode sets the ranking used when a request doesn't ask for one;
+// call before serving requests
+func (l *LeaderboardService) SetRankingMode(mode RankingMode) {
+	if mode == "" {
+		mode = RankingCompetition
+	}
+	l.ranking = mode
+}
+
+// rankingFor resolves the mode a filter asks for
+func (l *LeaderboardService) rankingFor(filter LeaderboardFilter) RankingMode {
+	if filter.Ranking != "" {
+		return filter.Ranking
+	}
+	return l.ranking
+}
+
+// rankFor returns the rank a standing earns in mode
+func rankFor(standing store.RatingStanding, mode RankingMode) int {
+	if mode == RankingDense {
+		return standing.RatingsAbove + 1
+	}
+	return standing.UsersAbove + 1
+}
+
+// ranked builds the public view of user with their rank in mode and
+// percentile, all read under one index lock, and their rank change since the
+// last rank snapshot
+func (l *LeaderboardService) ranked(user *models.User, mode RankingMode) models.UserWithRank {
+	standing := l.ratingIndex.GetRatingStanding(user.Rating)
+	return l.withRankChange(withRank(user, rankFor(standing, mode), standing.UsersAbove, standing.TotalUsers), mode)
+}
+
+// topPercent is the share of players ranked at or above a user with
+// usersAbove players ahead of them, rounded to 2 decimals: the X in "top X%"
+func topPercent(usersAbove, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(usersAbove+1)*10000/float64(total)) / 100
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// maxRateWindow is the longest window rateCounter can report on. One extra
+// slot holds the current, still-filling second.
+const (
+	maxRateWindow = 60
+	rateSlots     = maxRateWindow + 1
+)
+
+// rateCounter counts events in per-second slots over the last minute so
+// rolling rates can be reported for any window up to 60 seconds
+type rateCounter struct {
+	mu     sync.Mutex
+	counts [rateSlots]int64
+	stamps [rateSlots]int64 // unix second each slot belongs to
+}
+
+func (r *rateCounter) add(n int64, now time.Time) {
+	sec := now.Unix()
+	slot := sec % rateSlots
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stamps[slot] != sec {
+		r.stamps[slot] = sec
+		r.counts[slot] = 0
+	}
+	r.counts[slot] += n
+}
+
+// perSecond returns the average rate over the last `window` completed
+// seconds; the current, partial second is excluded
+func (r *rateCounter) perSecond(window int, now time.Time) float64 {
+	if window < 1 || window > maxRateWindow {
+		window = maxRateWindow
+	}
+	current := now.Unix()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total int64
+	for sec := current - int64(window); sec < current; sec++ {
+		slot := sec % rateSlots
+		if r.stamps[slot] == sec {
+			total += r.counts[slot]
+		}
+	}
+	return float64(total) / float64(window)
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"leaderboard-backend/models"
+)
+
+// DefaultEloK is the Elo K-factor: the most a single match can move a rating
+const DefaultEloK = 32
+
+// Match scores from the first player's side
+const (
+	ScoreWin  = 1.0
+	ScoreDraw = 0.5
+	ScoreLoss = 0.0
+)
+
+// Rating systems match results can be rated with
+const (
+	RatingSystemElo     = "elo"
+	RatingSystemGlicko2 = "glicko2"
+)
+
+// RatingEngine computes where two players stand after a match
+type RatingEngine interface {
+	// Name identifies the rating system in responses
+	Name() string
+	// Rate returns a and b after a scored score against b. Only the rating
+	// fields (Rating, RD and Volatility) change.
+	Rate(a, b models.User, score float64) (models.User, models.User)
+}
+
+// NewRatingEngine creates the engine for a rating system by name; empty
+// means Elo. eloK is the Elo K-factor.
+func NewRatingEngine(system string, eloK float64) (RatingEngine, error) {
+	switch system {
+	case "", RatingSystemElo:
+		return NewEloEngine(eloK), nil
+	case RatingSystemGlicko2:
+		return NewGlickoEngine(DefaultGlickoTau), nil
+	}
+	return nil, fmt.Errorf("unknown rating system %q (want elo or glicko2)", system)
+}
+
+// EloEngine rates matches with the Elo system. Rating changes are zero-sum:
+// whatever one player gains the other loses.
+type EloEngine struct {
+	K float64
+}
+
+// NewEloEngine creates an Elo engine with K-factor k (0 = DefaultEloK)
+func NewEloEngine(k float64) *EloEngine {
+	if k <= 0 {
+		k = DefaultEloK
+	}
+	return &EloEngine{K: k}
+}
+
+func (e *EloEngine) Name() string {
+	return RatingSystemElo
+}
+
+// Expected is the score a player rated a is expected to take off one rated b
+func (e *EloEngine) Expected(a, b int) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
+
+func (e *EloEngine) Rate(a, b models.User, score float64) (models.User, models.User) {
+	delta := int(math.Round(e.K * (score - e.Expected(a.Rating, b.Rating))))
+	a.Rating += delta
+	b.Rating -= delta
+	return a, b
+}
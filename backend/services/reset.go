@@ -0,0 +1,67 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResetTokenTTL is how long a reset confirmation token stays valid
+const ResetTokenTTL = 60 * time.Second
+
+// ResetGuard issues short-lived, single-use confirmation tokens that must be
+// echoed back before destructive operations are carried out
+type ResetGuard struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time // token -> expiry
+	ttl    time.Duration
+}
+
+func NewResetGuard(ttl time.Duration) *ResetGuard {
+	return &ResetGuard{
+		tokens: make(map[string]time.Time),
+		ttl:    ttl,
+	}
+}
+
+// Issue creates a new confirmation token and returns it with its expiry
+func (g *ResetGuard) Issue() (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for t, expiry := range g.tokens {
+		if now.After(expiry) {
+			delete(g.tokens, t)
+		}
+	}
+
+	expiresAt := now.Add(g.ttl)
+	g.tokens[token] = expiresAt
+	return token, expiresAt, nil
+}
+
+// Consume validates and invalidates a token
+func (g *ResetGuard) Consume(token string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	expiry, exists := g.tokens[token]
+	if !exists {
+		return fmt.Errorf("unknown or already used confirmation token")
+	}
+	delete(g.tokens, token)
+
+	if time.Now().After(expiry) {
+		return fmt.Errorf("confirmation token expired")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// DefaultSeasonBaseRating is the rating season resets move towards unless
+// told otherwise
+const DefaultSeasonBaseRating = 1500
+
+// defaultSeasonCarry is the share of a user's distance from the base rating
+// a soft reset keeps
+const defaultSeasonCarry = 0.5
+
+// ErrInvalidSeasonReset is returned for an unknown reset or carry
+var ErrInvalidSeasonReset = errors.New("invalid season reset")
+
+// SeasonReset is what happens to ratings when a season closes
+type SeasonReset string
+
+const (
+	SeasonResetNone SeasonReset = "none" // ratings carry over unchanged
+	SeasonResetSoft SeasonReset = "soft" // ratings move part of the way to the base rating
+	SeasonResetHard SeasonReset = "hard" // every rating goes back to the base rating
+)
+
+// ParseSeasonReset validates a reset name; empty means SeasonResetNone
+func ParseSeasonReset(name string) (SeasonReset, error) {
+	switch SeasonReset(name) {
+	case "", SeasonResetNone:
+		return SeasonResetNone, nil
+	case SeasonResetSoft, SeasonResetHard:
+		return SeasonReset(name), nil
+	}
+	return "", fmt.Errorf("%w: unknown reset %q (want none, soft or hard)", ErrInvalidSeasonReset, name)
+}
+
+// SetBaseRating sets the rating season resets move towards when a request
+// doesn't name one
+func (a *ArchiveService) SetBaseRating(rating int) {
+	a.baseRating = rating
+}
+
+// CloseSeason archives the live leaderboard as the final standings of
+// season, then resets ratings for the next one. Ratings are reset from the
+// archived standings, so a change made while the season closes is
+// overwritten; pause the simulator first. Users who join meanwhile keep
+// their rating.
+func (a *ArchiveService) CloseSeason(ctx context.Context, season string, req models.CloseSeasonRequest) (*models.CloseSeasonResponse, error) {
+	reset, err := ParseSeasonReset(req.Reset)
+	if err != nil {
+		return nil, err
+	}
+	carry := defaultSeasonCarry
+	if reset == SeasonResetHard {
+		carry = 0
+	} else if req.Carry != nil {
+		carry = *req.Carry
+	}
+	if carry < 0 || carry > 1 {
+		return nil, fmt.Errorf("%w: carry must be between 0 and 1", ErrInvalidSeasonReset)
+	}
+	base := req.BaseRating
+	if base == 0 {
+		base = a.baseRating
+	}
+	if reset != SeasonResetNone {
+		if _, err := a.store.CheckRating(base); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSeasonReset, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	users := a.store.Snapshot()
+	info, err := a.archive.Archive(season, users)
+	if err != nil {
+		return nil, err
+	}
+	response := &models.CloseSeasonResponse{Season: *info, Reset: string(reset)}
+	if reset == SeasonResetNone {
+		return response, nil
+	}
+
+	updates := make([]store.RatingUpdate, 0, len(users))
+	for _, user := range users {
+		rating := base + int(math.Round(float64(user.Rating-base)*carry))
+		if rating != user.Rating {
+			updates = append(updates, store.RatingUpdate{ID: user.ID, Rating: rating, Source: store.SourceAdmin})
+		}
+	}
+	failed := a.store.UpdateRatings(updates)
+
+	response.BaseRating = base
+	response.UsersReset = len(updates) - len(failed)
+	return response, nil
+}
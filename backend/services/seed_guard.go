@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// SeedGuard protects the seed endpoint from accidental repeated calls and,
+// in production, from being called at all unless explicitly forced
+type SeedGuard struct {
+	mu         sync.Mutex
+	cooldown   time.Duration
+	production bool
+	lastSeed   time.Time
+}
+
+// SeedCooldownError is returned while the cooldown window is still open
+type SeedCooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SeedCooldownError) Error() string {
+	return fmt.Sprintf("seeding is on cooldown, retry in %ds", int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// ErrSeedForbidden is returned in production mode when force is not set
+var ErrSeedForbidden = errors.New("seeding is disabled in production; pass force=true to override")
+
+func NewSeedGuard(cooldown time.Duration, production bool) *SeedGuard {
+	return &SeedGuard{
+		cooldown:   cooldown,
+		production: production,
+	}
+}
+
+// Acquire checks whether a seed may run now and, if so, starts a new
+// cooldown window. force only lifts the production block, not the cooldown.
+func (g *SeedGuard) Acquire(force bool) error {
+	if g.production && !force {
+		return ErrSeedForbidden
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.lastSeed.IsZero() {
+		if elapsed := time.Since(g.lastSeed); elapsed < g.cooldown {
+			return &SeedCooldownError{RetryAfter: g.cooldown - elapsed}
+		}
+	}
+	g.lastSeed = time.Now()
+	return nil
+}
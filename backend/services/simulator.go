@@ -1,7 +1,9 @@
 package services
 
 import (
+	"errors"
 	"leaderboard-backend/store"
+	"log"
 	"math/rand"
 	"sync"
 	"sync/atomic"
@@ -15,16 +17,32 @@ type ScoreSimulator struct {
 	maxRating   int
 	interval    time.Duration
 	running     int32 // atomic for lock-free check
+	paused      int32 // atomic; a paused run keeps its loop and cache but skips ticks
+	pausedAt    int64 // unix nanoseconds of the last Pause
 	mu          sync.Mutex
 	stopChan    chan struct{}
+	doneChan    chan struct{} // closed when the run loop has exited
 	updateCount int64
+	updateRate  rateCounter
+	lastUpdate  int64 // unix nanoseconds of the last applied update, or of Start
 	batchSize   int
 
 	// Cached user IDs to avoid allocations every tick
-	cachedIDs    []string
-	cacheVersion int64
+	cachedIDs      []string
+	cacheVersion   int64
+	cacheRefreshed time.Time
+	cacheStale     int32 // atomic; set by InvalidateCache when users join or leave
 }
 
+// simulatorCacheInterval is how often the ID cache is rebuilt regardless;
+// simulatorCacheMinAge is how soon after a rebuild an invalidated cache is
+// rebuilt again, so a burst of sign-ups costs one rebuild a second rather
+// than one a tick
+const (
+	simulatorCacheInterval = 10 * time.Second
+	simulatorCacheMinAge   = time.Second
+)
+
 func NewScoreSimulator(s *store.MemoryStore, ri *store.RatingBucketIndex, minRating, maxRating int, intervalMs int) *ScoreSimulator {
 	return &ScoreSimulator{
 		store:       s,
@@ -33,6 +51,7 @@ func NewScoreSimulator(s *store.MemoryStore, ri *store.RatingBucketIndex, minRat
 		maxRating:   maxRating,
 		interval:    time.Duration(intervalMs) * time.Millisecond,
 		stopChan:    make(chan struct{}),
+		doneChan:    closedChan(),
 		batchSize:   10, // Update 10 users per tick for more realistic simulation
 		cachedIDs:   make([]string, 0),
 	}
@@ -45,21 +64,91 @@ func (s *ScoreSimulator) Start() {
 		return
 	}
 	atomic.StoreInt32(&s.running, 1)
+	atomic.StoreInt64(&s.lastUpdate, time.Now().UnixNano())
 	s.stopChan = make(chan struct{})
+	s.doneChan = make(chan struct{})
+	stop, done := s.stopChan, s.doneChan
 	s.mu.Unlock()
 
-	go s.run()
+	// Built before returning, so status shows the users being simulated
+	s.refreshCache()
+	go s.run(stop, done)
 }
 
+// ErrSimulatorNotRunning is returned when pausing or resuming a stopped
+// simulator
+var ErrSimulatorNotRunning = errors.New("simulator is not running")
+
+// simulatorStopTimeout bounds how long Stop waits for an in-flight batch
+const simulatorStopTimeout = 5 * time.Second
+
+// Stop signals the run loop to exit and blocks until the batch in progress
+// has finished (or the stop timeout expires), so no simulated update lands
+// after Stop returns
 func (s *ScoreSimulator) Stop() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	if atomic.LoadInt32(&s.running) == 0 {
+		s.mu.Unlock()
 		return
 	}
 	atomic.StoreInt32(&s.running, 0)
+	atomic.StoreInt32(&s.paused, 0)
 	close(s.stopChan)
+	done := s.doneChan
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(simulatorStopTimeout):
+		log.Printf("Simulator did not stop within %v\n", simulatorStopTimeout)
+	}
+}
+
+// Pause freezes the running simulator: ticks are skipped until Resume, while
+// the run loop, ID cache and counters are kept. Pausing twice is harmless.
+func (s *ScoreSimulator) Pause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if atomic.LoadInt32(&s.running) == 0 {
+		return ErrSimulatorNotRunning
+	}
+	if atomic.CompareAndSwapInt32(&s.paused, 0, 1) {
+		atomic.StoreInt64(&s.pausedAt, time.Now().UnixNano())
+	}
+	return nil
+}
+
+// Resume continues a paused run from where it stopped
+func (s *ScoreSimulator) Resume() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if atomic.LoadInt32(&s.running) == 0 {
+		return ErrSimulatorNotRunning
+	}
+	if atomic.CompareAndSwapInt32(&s.paused, 1, 0) {
+		// The pause is not a stall
+		atomic.StoreInt64(&s.lastUpdate, time.Now().UnixNano())
+	}
+	return nil
+}
+
+// IsPaused reports whether a running simulator is paused
+func (s *ScoreSimulator) IsPaused() bool {
+	return atomic.LoadInt32(&s.paused) == 1
+}
+
+// Done returns a channel that is closed once the current run has fully
+// stopped. It is already closed when the simulator is not running.
+func (s *ScoreSimulator) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.doneChan
+}
+
+func closedChan() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
 }
 
 func (s *ScoreSimulator) IsRunning() bool {
@@ -70,24 +159,37 @@ func (s *ScoreSimulator) GetUpdateCount() int64 {
 	return atomic.LoadInt64(&s.updateCount)
 }
 
-func (s *ScoreSimulator) run() {
+// StalledFor returns how long a running simulator has gone without applying
+// an update. It is zero when the simulator is stopped or paused.
+func (s *ScoreSimulator) StalledFor() time.Duration {
+	if !s.IsRunning() || s.IsPaused() {
+		return 0
+	}
+	return time.Since(time.Unix(0, atomic.LoadInt64(&s.lastUpdate)))
+}
+
+func (s *ScoreSimulator) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
 	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
 
-	// Refresh cache every 10 seconds
-	cacheTicker := time.NewTicker(10 * time.Second)
+	cacheTicker := time.NewTicker(simulatorCacheInterval)
 	defer cacheTicker.Stop()
 
-	// Initial cache
-	s.refreshCache()
-
 	for {
 		select {
-		case <-s.stopChan:
+		case <-stop:
 			return
 		case <-cacheTicker.C:
 			s.refreshCache()
 		case <-ticker.C:
+			if s.IsPaused() {
+				continue
+			}
+			if atomic.LoadInt32(&s.cacheStale) == 1 && s.cacheAge() >= simulatorCacheMinAge {
+				s.refreshCache()
+			}
 			s.updateRandomUsers()
 		}
 	}
@@ -98,8 +200,33 @@ func (s *ScoreSimulator) refreshCache() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Cleared first, so users who join while the IDs are read mark it again
+	atomic.StoreInt32(&s.cacheStale, 0)
 	s.cachedIDs = s.store.GetAllUserIDs()
 	s.cacheVersion++
+	s.cacheRefreshed = time.Now()
+}
+
+// RefreshCache rebuilds the ID cache now, so users just seeded or imported
+// are simulated from the next tick. It returns the number of cached IDs.
+func (s *ScoreSimulator) RefreshCache() int {
+	s.refreshCache()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.cachedIDs)
+}
+
+// InvalidateCache marks the ID cache stale; a running simulator rebuilds it
+// within simulatorCacheMinAge. It only sets a flag, so it is safe to call
+// from MemoryStore.OnMembershipChange with the store locked.
+func (s *ScoreSimulator) InvalidateCache() {
+	atomic.StoreInt32(&s.cacheStale, 1)
+}
+
+func (s *ScoreSimulator) cacheAge() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.cacheRefreshed)
 }
 
 // updateRandomUsers updates multiple random users per tick
@@ -124,7 +251,9 @@ func (s *ScoreSimulator) updateRandomUsers() {
 		selectedIndices[i] = rand.Intn(len(ids))
 	}
 
-	// Process updates one at a time with minimal lock time
+	// Compute the new ratings from lock-free reads, then apply the whole
+	// batch under a single acquisition of the store lock
+	updates := make([]store.RatingUpdate, 0, batchCount)
 	for _, idx := range selectedIndices {
 		randomID := ids[idx]
 
@@ -143,9 +272,21 @@ func (s *ScoreSimulator) updateRandomUsers() {
 			newRating = s.maxRating
 		}
 
-		s.store.UpdateRating(randomID, newRating)
-		atomic.AddInt64(&s.updateCount, 1)
+		updates = append(updates, store.RatingUpdate{ID: randomID, Rating: newRating, Source: store.SourceSimulator})
+	}
+	if len(updates) == 0 {
+		return
 	}
+
+	failed := s.store.UpdateRatings(updates)
+	applied := int64(len(updates) - len(failed))
+	if applied == 0 {
+		return
+	}
+	atomic.AddInt64(&s.updateCount, applied)
+	now := time.Now()
+	s.updateRate.add(applied, now)
+	atomic.StoreInt64(&s.lastUpdate, now.UnixNano())
 }
 
 // GetStats returns simulator statistics
@@ -153,14 +294,38 @@ func (s *ScoreSimulator) GetStats() map[string]interface{} {
 	s.mu.Lock()
 	cacheSize := len(s.cachedIDs)
 	cacheVer := s.cacheVersion
+	cacheRefreshed := s.cacheRefreshed
 	s.mu.Unlock()
 
+	now := time.Now()
+	pausedFor := int64(0)
+	if s.IsPaused() {
+		pausedFor = now.Sub(time.Unix(0, atomic.LoadInt64(&s.pausedAt))).Milliseconds()
+	}
+
 	return map[string]interface{}{
 		"running":       s.IsRunning(),
+		"paused":        s.IsPaused(),
+		"paused_for_ms": pausedFor,
 		"update_count":  atomic.LoadInt64(&s.updateCount),
 		"batch_size":    s.batchSize,
 		"interval_ms":   s.interval.Milliseconds(),
 		"cache_size":    cacheSize,
 		"cache_version": cacheVer,
+		"cache_stale":   atomic.LoadInt32(&s.cacheStale) == 1,
+		"cache_age_ms":  cacheAgeMs(cacheRefreshed, now),
+		"updates_per_second": map[string]float64{
+			"1s":  s.updateRate.perSecond(1, now),
+			"10s": s.updateRate.perSecond(10, now),
+			"60s": s.updateRate.perSecond(60, now),
+		},
+	}
+}
+
+// cacheAgeMs is how old the ID cache is, or -1 if it was never built
+func cacheAgeMs(refreshed, now time.Time) int64 {
+	if refreshed.IsZero() {
+		return -1
 	}
+	return now.Sub(refreshed).Milliseconds()
 }
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"sort"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+type SnapshotService struct {
+	snapshots *store.NamedSnapshots
+	store     *store.MemoryStore
+}
+
+func NewSnapshotService(snapshots *store.NamedSnapshots, s *store.MemoryStore) *SnapshotService {
+	return &SnapshotService{
+		snapshots: snapshots,
+		store:     s,
+	}
+}
+
+// Create saves the live leaderboard under name
+func (s *SnapshotService) Create(name string) (*models.SnapshotInfo, error) {
+	return s.snapshots.Create(name, s.store)
+}
+
+// List returns the stored snapshots, oldest first
+func (s *SnapshotService) List() (*models.SnapshotListResponse, error) {
+	list, err := s.snapshots.List()
+	if err != nil {
+		return nil, err
+	}
+	return &models.SnapshotListResponse{
+		Snapshots: list,
+		Count:     len(list),
+	}, nil
+}
+
+// Diff compares two snapshots. Only users ranked within top in a snapshot
+// take part (top <= 0 means everyone): users in both are reported as
+// movements if their rank changed, the rest as new entrants or dropouts.
+func (s *SnapshotService) Diff(from, to string, top int) (*models.SnapshotDiffResponse, error) {
+	fromUsers, err := s.snapshots.Load(from)
+	if err != nil {
+		return nil, err
+	}
+	toUsers, err := s.snapshots.Load(to)
+	if err != nil {
+		return nil, err
+	}
+
+	before := rankSnapshot(fromUsers, top)
+	after := rankSnapshot(toUsers, top)
+
+	beforeByID := make(map[string]models.SnapshotEntry, len(before))
+	for _, entry := range before {
+		beforeByID[entry.ID] = entry
+	}
+
+	response := &models.SnapshotDiffResponse{
+		From:        from,
+		To:          to,
+		Top:         top,
+		Movements:   make([]models.RankMovement, 0),
+		NewEntrants: make([]models.SnapshotEntry, 0),
+		Dropouts:    make([]models.SnapshotEntry, 0),
+	}
+
+	for _, entry := range after {
+		old, ok := beforeByID[entry.ID]
+		if !ok {
+			response.NewEntrants = append(response.NewEntrants, entry)
+			continue
+		}
+		delete(beforeByID, entry.ID)
+		if old.Rank != entry.Rank {
+			response.Movements = append(response.Movements, models.RankMovement{
+				ID:         entry.ID,
+				Username:   entry.Username,
+				FromRank:   old.Rank,
+				ToRank:     entry.Rank,
+				Change:     old.Rank - entry.Rank,
+				FromRating: old.Rating,
+				ToRating:   entry.Rating,
+			})
+		}
+	}
+
+	for _, entry := range before {
+		if _, dropped := beforeByID[entry.ID]; dropped {
+			response.Dropouts = append(response.Dropouts, entry)
+		}
+	}
+
+	// Biggest climbers first
+	sort.SliceStable(response.Movements, func(i, j int) bool {
+		return response.Movements[i].Change > response.Movements[j].Change
+	})
+
+	return response, nil
+}
+
+// rankSnapshot orders users like the live leaderboard (rating descending,
+// then username) and assigns competition ranks, keeping ranks <= top
+func rankSnapshot(users []models.User, top int) []models.SnapshotEntry {
+	sorted := make([]models.User, len(users))
+	copy(sorted, users)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Rating != sorted[j].Rating {
+			return sorted[i].Rating > sorted[j].Rating
+		}
+		return sorted[i].Username < sorted[j].Username
+	})
+
+	entries := make([]models.SnapshotEntry, 0, len(sorted))
+	rank := 0
+	for i, user := range sorted {
+		if i == 0 || user.Rating != sorted[i-1].Rating {
+			rank = i + 1
+		}
+		if top > 0 && rank > top {
+			break
+		}
+		entries = append(entries, models.SnapshotEntry{
+			ID:       user.ID,
+			Username: user.Username,
+			Rank:     rank,
+			Rating:   user.Rating,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// LiveBoard names the live leaderboard in a user summary
+const LiveBoard = "live"
+
+// ErrNoStandings is returned when a user is on no leaderboard at all
+var ErrNoStandings = errors.New("user does not appear on any leaderboard")
+
+// SummaryService assembles a user's standing across the live leaderboard
+// and every archived season, for profile pages
+type SummaryService struct {
+	store       *store.MemoryStore
+	ratingIndex *store.RatingBucketIndex
+	archive     *store.SeasonArchive
+}
+
+func NewSummaryService(s *store.MemoryStore, ri *store.RatingBucketIndex, archive *store.SeasonArchive) *SummaryService {
+	return &SummaryService{
+		store:       s,
+		ratingIndex: ri,
+		archive:     archive,
+	}
+}
+
+// GetUserSummary returns the user's rating, rank, percentile and tier on
+// each board they appear in. A user removed from the live board still has
+// a summary while any archived season holds them.
+func (s *SummaryService) GetUserSummary(ctx context.Context, id string) (*models.UserSummaryResponse, error) {
+	summary := &models.UserSummaryResponse{ID: id, Boards: []models.BoardStanding{}}
+
+	if user, err := s.store.GetUserContext(ctx, id); err == nil {
+		rank, total := s.ratingIndex.GetRankAndTotal(user.Rating)
+		summary.Username = user.Username
+		summary.Boards = append(summary.Boards, boardStanding(LiveBoard, false, user.Rating, rank, total))
+	} else if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	seasons, err := s.archive.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(seasons) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		standing, info, err := s.archive.FindUser(seasons[i].ID, id)
+		if errors.Is(err, store.ErrNoStanding) || errors.Is(err, store.ErrSeasonNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("season %s: %w", seasons[i].ID, err)
+		}
+		if summary.Username == "" {
+			summary.Username = standing.Username
+		}
+		summary.Boards = append(summary.Boards, boardStanding(info.ID, true, standing.Rating, standing.Rank, info.UserCount))
+	}
+
+	if len(summary.Boards) == 0 {
+		return nil, ErrNoStandings
+	}
+	return summary, nil
+}
+
+func boardStanding(board string, final bool, rating, rank, total int) models.BoardStanding {
+	tier, nextTierRating := TierForRating(rating)
+	return models.BoardStanding{
+		Board:          board,
+		Final:          final,
+		Rating:         rating,
+		Rank:           rank,
+		Percentile:     percentile(rank, total),
+		TotalUsers:     total,
+		Tier:           tier,
+		NextTierRating: nextTierRating,
+	}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// Tier is a named rating band. A user belongs to the highest tier whose
+// MinRating they meet.
+type Tier struct {
+	Name      string `json:"name"`
+	MinRating int    `json:"min_rating"`
+}
+
+// DefaultTiers are ordered from lowest to highest
+var DefaultTiers = []Tier{
+	{Name: "Bronze", MinRating: 100},
+	{Name: "Silver", MinRating: 1000},
+	{Name: "Gold", MinRating: 2000},
+	{Name: "Platinum", MinRating: 3000},
+	{Name: "Diamond", MinRating: 4000},
+	{Name: "Master", MinRating: 4500},
+}
+
+// TierForRating returns the tier name for a rating and the rating required to
+// reach the next tier (0 when already in the top tier)
+func TierForRating(rating int) (string, int) {
+	idx := 0
+	for i, tier := range DefaultTiers {
+		if rating >= tier.MinRating {
+			idx = i
+		}
+	}
+
+	nextTierRating := 0
+	if idx+1 < len(DefaultTiers) {
+		nextTierRating = DefaultTiers[idx+1].MinRating
+	}
+	return DefaultTiers[idx].Name, nextTierRating
+}
+
+// ErrUnknownTier is returned for a tier name not in DefaultTiers
+var ErrUnknownTier = errors.New("unknown tier")
+
+// FindTier looks a tier up by name, ignoring case, and returns it with the
+// highest rating it covers (0 for the top tier, which has no ceiling)
+func FindTier(name string) (Tier, int, error) {
+	for i, tier := range DefaultTiers {
+		if strings.EqualFold(tier.Name, name) {
+			maxRating := 0
+			if i+1 < len(DefaultTiers) {
+				maxRating = DefaultTiers[i+1].MinRating - 1
+			}
+			return tier, maxRating, nil
+		}
+	}
+	names := make([]string, len(DefaultTiers))
+	for i, tier := range DefaultTiers {
+		names[i] = strings.ToLower(tier.Name)
+	}
+	return Tier{}, 0, fmt.Errorf("%w %q (want one of %s)", ErrUnknownTier, name, strings.Join(names, ", "))
+}
+
+// inTier reports whether rating falls in the band from minRating to
+// maxRating (0 = no ceiling)
+func inTier(rating, minRating, maxRating int) bool {
+	return rating >= minRating && (maxRating == 0 || rating <= maxRating)
+}
+
+// usersInTier counts the users rated from minRating to maxRating, and how
+// many are rated above the band
+func usersInTier(ri *store.RatingBucketIndex, minRating, maxRating int) (count, above int) {
+	if maxRating != 0 {
+		above = ri.GetUsersAbove(maxRating)
+	}
+	// The index clamps ratings below its floor, so count from the total there
+	if minRating <= store.MinRating {
+		return ri.GetTotalUsers() - above, above
+	}
+	return ri.GetUsersAbove(minRating-1) - above, above
+}
+
+// TierService reports how users are spread across the tiers, read from the
+// rating bucket index rather than by visiting users
+type TierService struct {
+	ratingIndex *store.RatingBucketIndex
+}
+
+func NewTierService(ri *store.RatingBucketIndex) *TierService {
+	return &TierService{ratingIndex: ri}
+}
+
+// GetTiers lists every tier from the lowest up with its rating band and the
+// users in it
+func (t *TierService) GetTiers() *models.TierListResponse {
+	total := t.ratingIndex.GetTotalUsers()
+	tiers := make([]models.TierInfo, 0, len(DefaultTiers))
+	for _, tier := range DefaultTiers {
+		_, maxRating, _ := FindTier(tier.Name)
+		users, _ := usersInTier(t.ratingIndex, tier.MinRating, maxRating)
+		info := models.TierInfo{Name: tier.Name, MinRating: tier.MinRating, MaxRating: maxRating, Users: users}
+		if total > 0 {
+			info.Percent = float64(users) * 100 / float64(total)
+		}
+		tiers = append(tiers, info)
+	}
+	return &models.TierListResponse{Tiers: tiers, TotalUsers: total}
+}
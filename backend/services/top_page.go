@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// TopPageSize is how many places the broadcast first page holds
+const TopPageSize = 50
+
+// TopPageBroadcaster pushes the first leaderboard page to every live
+// subscriber as a "top_page" event. The page is computed once per tick and
+// shared by all of them, and only when the board has changed since the last
+// one was sent, so a client showing the top of the board needn't poll.
+type TopPageBroadcaster struct {
+	hub         *LiveHub
+	leaderboard *LeaderboardService
+	store       *store.MemoryStore
+	interval    time.Duration
+
+	mu          sync.Mutex
+	latest      *models.LiveEvent
+	sentVersion uint64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewTopPageBroadcaster(hub *LiveHub, leaderboard *LeaderboardService, memoryStore *store.MemoryStore, interval time.Duration) *TopPageBroadcaster {
+	return &TopPageBroadcaster{
+		hub:         hub,
+		leaderboard: leaderboard,
+		store:       memoryStore,
+		interval:    interval,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start begins broadcasting in the background
+func (b *TopPageBroadcaster) Start() {
+	go b.run()
+}
+
+func (b *TopPageBroadcaster) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.Tick(context.Background()); err != nil {
+				log.Printf("Top page broadcast failed: %v", err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Tick broadcasts the first page if anyone is listening and the board has
+// changed since the last broadcast
+func (b *TopPageBroadcaster) Tick(ctx context.Context) error {
+	if b.hub.Subscribers() == 0 {
+		return nil
+	}
+	version := b.store.GetMutationCount()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.latest != nil && version == b.sentVersion {
+		return nil
+	}
+	page, err := b.leaderboard.GetLeaderboard(ctx, TopPageSize, 0, LeaderboardFilter{})
+	if err != nil {
+		return err
+	}
+	event := models.LiveEvent{Type: "top_page", Page: page, At: time.Now().UTC()}
+	b.latest = &event
+	b.sentVersion = version
+	b.hub.Publish(event)
+	return nil
+}
+
+// Latest returns the last page broadcast, for a client that has just
+// connected; false before the first one
+func (b *TopPageBroadcaster) Latest() (models.LiveEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.latest == nil {
+		return models.LiveEvent{}, false
+	}
+	return *b.latest, true
+}
+
+// Stop ends broadcasting
+func (b *TopPageBroadcaster) Stop(ctx context.Context) error {
+	b.stopOnce.Do(func() { close(b.stop) })
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,27 +1,40 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"leaderboard-backend/models"
 	"leaderboard-backend/store"
 	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type UserService struct {
-	store       *store.MemoryStore
-	ratingIndex *store.RatingBucketIndex
-	minRating   int
-	maxRating   int
+	store          *store.MemoryStore
+	ratingIndex    *store.RatingBucketIndex
+	presence       *store.PresenceTracker
+	minRating      int
+	maxRating      int
+	mergeStrategy  MergeStrategy   // default rating strategy for account merges
+	usernamePolicy *UsernamePolicy // optional rules for new and changed usernames
+	ratingEngine   RatingEngine    // rates match results
+	periodMu       sync.RWMutex    // Glicko-2 matches share it; ending a rating period takes it alone
+	playedMu       sync.Mutex      // guards played between matches
+	played         map[string]bool // Glicko-2 only: who has played this rating period
 }
 
-func NewUserService(s *store.MemoryStore, ri *store.RatingBucketIndex, minRating, maxRating int) *UserService {
+func NewUserService(s *store.MemoryStore, ri *store.RatingBucketIndex, presence *store.PresenceTracker, minRating, maxRating int) *UserService {
 	return &UserService{
-		store:       s,
-		ratingIndex: ri,
-		minRating:   minRating,
-		maxRating:   maxRating,
+		store:        s,
+		ratingIndex:  ri,
+		presence:     presence,
+		minRating:    minRating,
+		maxRating:    maxRating,
+		ratingEngine: NewEloEngine(DefaultEloK),
 	}
 }
 
@@ -69,36 +82,208 @@ func (u *UserService) GenerateRating() int {
 	return u.minRating + rand.Intn(u.maxRating-u.minRating+1)
 }
 
-func (u *UserService) SeedUsers(count int) (int, error) {
+// SeedUsers adds count generated users. If ctx ends part-way, the users
+// added so far are kept and the error is returned with their count.
+func (u *UserService) SeedUsers(ctx context.Context, count int) (int, error) {
 	added := 0
 	for i := 0; i < count; i++ {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return added, ctx.Err()
+		}
 		user := &models.User{
 			ID:       uuid.New().String(),
 			Username: u.GenerateUsername(),
 			Rating:   u.GenerateRating(),
 		}
-		if err := u.store.AddUser(user); err == nil {
+		// Seeded users are generated, so they count as simulation
+		if err := u.store.AddUserFrom(user, store.SourceSimulator); err == nil {
 			added++
 		}
 	}
 	return added, nil
 }
 
-func (u *UserService) UpdateRating(id string, newRating int) error {
-	if newRating < u.minRating || newRating > u.maxRating {
-		return fmt.Errorf("rating must be between %d and %d", u.minRating, u.maxRating)
+// CreateUser adds a user with a new ID. The username must pass the
+// username policy; rating defaults to the minimum rating and is clamped or
+// rejected when out of range, as the store's rating range mode says.
+func (u *UserService) CreateUser(ctx context.Context, username string, rating *int) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := u.ValidateUsername(username); err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		ID:       uuid.New().String(),
+		Username: username,
+		Rating:   u.minRating,
+	}
+	if rating != nil {
+		user.Rating = *rating
+	}
+
+	if err := u.store.AddUserContext(ctx, user, store.SourceAPI); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ErrSourceNotAllowed is returned when a client without the admin role tags
+// a rating change with anything but api
+var ErrSourceNotAllowed = errors.New("source not allowed")
+
+// UpdateRating sets a user's rating on behalf of a client. Clients may only
+// tag the change as api; admins may also tag it match, decay or admin, and
+// simulator and import changes only come from the server itself. An
+// out-of-range rating is clamped or rejected as the store's rating range
+// mode says.
+func (u *UserService) UpdateRating(ctx context.Context, id string, newRating int, source store.Source, admin bool) error {
+	switch {
+	case source == "" || source == store.SourceAPI:
+	case source == store.SourceSimulator || source == store.SourceImport:
+		return fmt.Errorf("source %q cannot be set by clients", source)
+	case !admin:
+		return fmt.Errorf("%w: only admins may tag a change as %q", ErrSourceNotAllowed, source)
 	}
-	return u.store.UpdateRating(id, newRating)
+	return u.store.UpdateRatingFrom(ctx, id, newRating, source)
 }
 
-func (u *UserService) GetUser(id string) (*models.User, error) {
-	return u.store.GetUser(id)
+func (u *UserService) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return u.store.GetUserContext(ctx, id)
+}
+
+// GetRatingHistory returns up to limit of the user's latest rating changes,
+// newest first
+func (u *UserService) GetRatingHistory(ctx context.Context, id string, limit int) (*models.RatingHistoryResponse, error) {
+	user, err := u.store.GetUserContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := u.store.GetRatingHistory(ctx, id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]models.RatingHistoryEntry, 0, len(changes))
+	for _, change := range changes {
+		history = append(history, models.RatingHistoryEntry{
+			Rating:    change.Rating,
+			OldRating: change.OldRating,
+			Change:    change.Rating - change.OldRating,
+			Source:    string(change.Source),
+			At:        change.At,
+		})
+	}
+	return &models.RatingHistoryResponse{
+		ID:       user.ID,
+		Username: user.Username,
+		Rating:   user.Rating,
+		History:  history,
+		Count:    len(history),
+		Capacity: u.store.GetRatingHistorySize(),
+	}, nil
 }
 
 func (u *UserService) GetUserCount() int {
 	return u.store.GetUserCount()
 }
 
+// DeleteUser removes one user from every index in a single store write and
+// forgets their presence
+func (u *UserService) DeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := u.store.DeleteUserContext(ctx, id); err != nil {
+		return err
+	}
+	u.presence.Forget(id)
+	return nil
+}
+
+// SetBot flags or unflags a user as a bot; see MemoryStore.SetBot
+func (u *UserService) SetBot(ctx context.Context, id string, bot bool) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return u.store.SetBot(id, bot)
+}
+
+// deleteBatchSize bounds how many users are removed per store write lock, so
+// large bulk deletes don't starve readers
+const deleteBatchSize = 1000
+
+// DeleteUsers removes users in batches and reports the outcome for each ID.
+// If ctx ends between batches the remaining IDs are left alone and the
+// error is returned with the outcome so far.
+func (u *UserService) DeleteUsers(ctx context.Context, ids []string) (*models.BulkDeleteResponse, error) {
+	response := &models.BulkDeleteResponse{
+		Results: make([]models.DeleteResult, 0, len(ids)),
+	}
+
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		if err := ctx.Err(); err != nil {
+			return response, err
+		}
+		end := start + deleteBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		failed := u.store.DeleteUsers(batch)
+		for _, id := range batch {
+			if err, isFailed := failed[id]; isFailed {
+				response.Results = append(response.Results, models.DeleteResult{ID: id, Error: err.Error()})
+				response.Failed++
+				continue
+			}
+			u.presence.Forget(id)
+			response.Results = append(response.Results, models.DeleteResult{ID: id, Deleted: true})
+			response.Deleted++
+		}
+	}
+
+	return response, nil
+}
+
+// FindUsersMatching returns the IDs of users matching a bulk delete filter.
+// Users that never sent a heartbeat count as inactive.
+func (u *UserService) FindUsersMatching(ctx context.Context, filter models.BulkDeleteFilter) ([]string, error) {
+	inactiveCutoff := time.Now().Add(-time.Duration(filter.InactiveDays) * 24 * time.Hour)
+
+	ids := make([]string, 0)
+	for i, user := range u.store.GetAllUsers() {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if filter.RatingBelow > 0 && user.Rating >= filter.RatingBelow {
+			continue
+		}
+		if filter.InactiveDays > 0 {
+			if lastSeen, seen := u.presence.LastSeen(user.ID); seen && lastSeen.After(inactiveCutoff) {
+				continue
+			}
+		}
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
+
+// Heartbeat records that a user is currently active
+func (u *UserService) Heartbeat(ctx context.Context, id string) (time.Time, error) {
+	if _, err := u.store.GetUserContext(ctx, id); err != nil {
+		return time.Time{}, err
+	}
+	return u.presence.Heartbeat(id), nil
+}
+
+// GetOnlineCount returns the number of users seen within the online window
+func (u *UserService) GetOnlineCount() int {
+	return u.presence.OnlineCount()
+}
+
 func (u *UserService) Clear() {
 	u.store.Clear()
+	u.presence.Clear()
 }
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"leaderboard-backend/models"
+)
+
+// Username policy violation codes
+const (
+	UsernameTooShort     = "too_short"
+	UsernameTooLong      = "too_long"
+	UsernameInvalidChars = "invalid_characters"
+	UsernameReserved     = "reserved"
+	UsernameBlocked      = "blocked"
+)
+
+// DefaultReservedUsernames can't be claimed because they could pass for
+// staff or system accounts
+var DefaultReservedUsernames = []string{
+	"admin", "administrator", "root", "system", "support", "moderator", "mod",
+	"staff", "official", "api", "null", "undefined", "anonymous", "leaderboard",
+}
+
+// UsernameError explains why a username was refused
+type UsernameError struct {
+	Code    string
+	Message string
+}
+
+func (e *UsernameError) Error() string {
+	return e.Message
+}
+
+// UsernamePolicy decides which usernames may be used. Blocked words match
+// anywhere in the name, ignoring case, separators and common digit-for-
+// letter swaps; reserved names match the whole name, ignoring case.
+type UsernamePolicy struct {
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+	blocked   []string
+	reserved  map[string]bool
+}
+
+// UsernamePolicyConfig holds the policy settings as configured
+type UsernamePolicyConfig struct {
+	MinLength     int
+	MaxLength     int
+	Pattern       string   // regular expression the whole name must match
+	Blocklist     []string // words that may not appear in a name
+	BlocklistFile string   // more blocked words, one per line ("" = none)
+	Reserved      []string // names nobody may take (nil = DefaultReservedUsernames)
+}
+
+// NewUsernamePolicy compiles a policy, reading the blocklist file if set
+func NewUsernamePolicy(cfg UsernamePolicyConfig) (*UsernamePolicy, error) {
+	if cfg.MinLength < 1 || cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("username lengths must satisfy 1 <= min <= max, got %d and %d", cfg.MinLength, cfg.MaxLength)
+	}
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid username pattern: %w", err)
+	}
+
+	words := cfg.Blocklist
+	if cfg.BlocklistFile != "" {
+		fromFile, err := readWordList(cfg.BlocklistFile)
+		if err != nil {
+			return nil, err
+		}
+		words = append(append([]string{}, words...), fromFile...)
+	}
+
+	reserved := cfg.Reserved
+	if reserved == nil {
+		reserved = DefaultReservedUsernames
+	}
+
+	p := &UsernamePolicy{
+		minLength: cfg.MinLength,
+		maxLength: cfg.MaxLength,
+		pattern:   pattern,
+		reserved:  make(map[string]bool, len(reserved)),
+	}
+	for _, word := range words {
+		if folded := foldUsername(word); folded != "" {
+			p.blocked = append(p.blocked, folded)
+		}
+	}
+	for _, name := range reserved {
+		p.reserved[strings.ToLower(name)] = true
+	}
+	return p, nil
+}
+
+func readWordList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open username blocklist: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read username blocklist: %w", err)
+	}
+	return words, nil
+}
+
+// leetReplacer undoes the usual digit and symbol stand-ins for letters
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// foldUsername reduces a name to lowercase letters for blocklist matching,
+// so "B.a-d_W0rd" matches "badword"
+func foldUsername(name string) string {
+	folded := leetReplacer.Replace(strings.ToLower(name))
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, folded)
+}
+
+// Check returns nil if name is allowed, or a *UsernameError saying why not
+func (p *UsernamePolicy) Check(name string) error {
+	length := utf8.RuneCountInString(name)
+	switch {
+	case length < p.minLength:
+		return &UsernameError{UsernameTooShort, fmt.Sprintf("Username must be at least %d characters", p.minLength)}
+	case length > p.maxLength:
+		return &UsernameError{UsernameTooLong, fmt.Sprintf("Username must be at most %d characters", p.maxLength)}
+	case !p.pattern.MatchString(name):
+		return &UsernameError{UsernameInvalidChars, "Username contains characters that are not allowed"}
+	case p.reserved[strings.ToLower(name)]:
+		return &UsernameError{UsernameReserved, "Username is reserved"}
+	}
+
+	folded := foldUsername(name)
+	for _, word := range p.blocked {
+		if strings.Contains(folded, word) {
+			return &UsernameError{UsernameBlocked, "Username contains a blocked word"}
+		}
+	}
+	return nil
+}
+
+// SetUsernamePolicy makes new and changed usernames follow policy. Without
+// one any username is accepted.
+func (u *UserService) SetUsernamePolicy(policy *UsernamePolicy) {
+	u.usernamePolicy = policy
+}
+
+// ValidateUsername returns nil if name may be used, or a *UsernameError
+func (u *UserService) ValidateUsername(name string) error {
+	if u.usernamePolicy == nil {
+		return nil
+	}
+	return u.usernamePolicy.Check(name)
+}
+
+// CheckUsername reports whether name passes the policy and whether another
+// user already goes by it, for signup forms to check before submitting
+func (u *UserService) CheckUsername(ctx context.Context, name string) (*models.UsernameCheckResponse, error) {
+	response := &models.UsernameCheckResponse{Username: name, Valid: true}
+	if err := u.ValidateUsername(name); err != nil {
+		response.Valid = false
+		var usernameErr *UsernameError
+		if errors.As(err, &usernameErr) {
+			response.Reason = usernameErr.Code
+		}
+		response.Message = err.Error()
+		return response, nil
+	}
+
+	if _, err := u.store.GetUserByUsernameContext(ctx, name); err == nil {
+		response.Taken = true
+	} else if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return response, nil
+}
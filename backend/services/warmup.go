@@ -0,0 +1,89 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// Warmup builds a store's deferred indexes in the background, so a large
+// snapshot doesn't keep the server from answering liveness probes while
+// the skip list and username index are built
+type Warmup struct {
+	store *store.MemoryStore
+
+	mu       sync.Mutex
+	started  time.Time
+	finished time.Time
+	onReady  []func()
+}
+
+func NewWarmup(memoryStore *store.MemoryStore) *Warmup {
+	return &Warmup{store: memoryStore}
+}
+
+// OnReady runs fn once the indexes are built, as for work that needs the
+// full ranking. Register before Start.
+func (w *Warmup) OnReady(fn func()) {
+	w.onReady = append(w.onReady, fn)
+}
+
+// Start builds the indexes in the background
+func (w *Warmup) Start() {
+	w.mu.Lock()
+	w.started = time.Now()
+	w.mu.Unlock()
+
+	go func() {
+		w.store.BuildIndexes()
+
+		w.mu.Lock()
+		w.finished = time.Now()
+		elapsed := w.finished.Sub(w.started)
+		w.mu.Unlock()
+		log.Printf("Indexes built for %d users in %s\n", w.store.IndexProgress().Total, elapsed.Round(time.Millisecond))
+
+		for _, fn := range w.onReady {
+			fn()
+		}
+	}()
+}
+
+// Ready reports whether the indexes are built
+func (w *Warmup) Ready() bool {
+	return w.store.IndexProgress().Ready
+}
+
+// Status reports the build's progress without touching the store's locks,
+// so it can answer while the build holds them
+func (w *Warmup) Status() models.ReadinessResponse {
+	progress := w.store.IndexProgress()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	response := models.ReadinessResponse{
+		Status:  "warming_up",
+		Indexed: progress.Indexed,
+		Total:   progress.Total,
+	}
+	if progress.Ready {
+		response.Status = "ready"
+	}
+	if progress.Total > 0 {
+		response.Percent = float64(progress.Indexed) * 100 / float64(progress.Total)
+	} else if progress.Ready {
+		response.Percent = 100
+	}
+	if !w.started.IsZero() {
+		end := w.finished
+		if end.IsZero() {
+			end = time.Now()
+		}
+		response.ElapsedMs = end.Sub(w.started).Milliseconds()
+	}
+	return response
+}
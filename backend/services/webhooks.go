@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"leaderboard-backend/notify"
+	"leaderboard-backend/store"
+)
+
+// changeWebhookBuffer is how many changes are queued between the store and
+// the encoder feeding the dispatcher
+const changeWebhookBuffer = 4096
+
+// ChangeWebhook posts every store change, as the same JSON the live feed
+// sends, to a webhook. Changes are keyed by user, so under the aggregate
+// policy a user who moves again before their last change went out is only
+// posted once, with their latest rating and rank.
+type ChangeWebhook struct {
+	url        string
+	dispatcher *notify.Dispatcher
+	changes    chan store.Change
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewChangeWebhook creates a publisher for url; attach Feed to the store and
+// call Start
+func NewChangeWebhook(url string, dispatcher *notify.Dispatcher) *ChangeWebhook {
+	return &ChangeWebhook{
+		url:        url,
+		dispatcher: dispatcher,
+		changes:    make(chan store.Change, changeWebhookBuffer),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Feed is the channel to pass to MemoryStore.AddChangeFeed
+func (c *ChangeWebhook) Feed() chan<- store.Change {
+	return c.changes
+}
+
+// Backlog returns how many changes wait to be handed to the dispatcher and
+// how many fit
+func (c *ChangeWebhook) Backlog() (int, int) {
+	return len(c.changes), cap(c.changes)
+}
+
+// Start begins publishing in the background
+func (c *ChangeWebhook) Start() {
+	go c.run()
+}
+
+func (c *ChangeWebhook) run() {
+	defer close(c.done)
+	for {
+		select {
+		case change := <-c.changes:
+			c.send(change)
+		case <-c.stop:
+			// Hand over what the store already published before stopping
+			for {
+				select {
+				case change := <-c.changes:
+					c.send(change)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *ChangeWebhook) send(change store.Change) {
+	body, err := json.Marshal(liveEvent(change))
+	if err != nil {
+		return
+	}
+	key := "user:" + change.User.ID
+	if change.Type == store.ChangeCleared {
+		key = "cleared"
+	}
+	c.dispatcher.Send(notify.Message{URL: c.url, Key: key, Body: body})
+}
+
+// Stop hands any queued changes to the dispatcher and ends publishing; stop
+// the dispatcher afterwards so they are delivered
+func (c *ChangeWebhook) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() { close(c.stop) })
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
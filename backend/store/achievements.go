@@ -0,0 +1,172 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// UnlockedAchievement is one achievement a user has earned
+type UnlockedAchievement struct {
+	ID         string    `json:"id"`
+	UnlockedAt time.Time `json:"unlocked_at"`
+}
+
+// achievementData is the on-disk format of the ledger
+type achievementData struct {
+	Unlocked map[string][]UnlockedAchievement `json:"unlocked"`
+	Streaks  map[string]int                   `json:"streaks,omitempty"`
+	Version  int                              `json:"version"`
+}
+
+// AchievementLedger records the achievements each user has unlocked and
+// their current match win streak, kept in a JSON file next to the data
+type AchievementLedger struct {
+	mu       sync.Mutex
+	path     string
+	unlocked map[string][]UnlockedAchievement
+	streaks  map[string]int
+	dirty    bool
+}
+
+// NewAchievementLedger creates an empty ledger saved to path
+func NewAchievementLedger(path string) *AchievementLedger {
+	return &AchievementLedger{
+		path:     path,
+		unlocked: make(map[string][]UnlockedAchievement),
+		streaks:  make(map[string]int),
+	}
+}
+
+// Load replaces the ledger with the saved one. A missing file leaves it
+// empty.
+func (l *AchievementLedger) Load() error {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open achievements: %w", err)
+	}
+	defer file.Close()
+
+	var data achievementData
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return fmt.Errorf("failed to unmarshal achievements: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unlocked = data.Unlocked
+	if l.unlocked == nil {
+		l.unlocked = make(map[string][]UnlockedAchievement)
+	}
+	l.streaks = data.Streaks
+	if l.streaks == nil {
+		l.streaks = make(map[string]int)
+	}
+	l.dirty = false
+	return nil
+}
+
+// Save writes the ledger if it changed since the last save or load
+func (l *AchievementLedger) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data := achievementData{Unlocked: l.unlocked, Streaks: l.streaks, Version: 1}
+	tempPath := l.path + ".tmp"
+	if err := writeJSONFile(tempPath, data, false); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, l.path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	l.dirty = false
+	return nil
+}
+
+// Unlock records that userID earned id at the given time. It returns false
+// if they already had it.
+func (l *AchievementLedger) Unlock(userID, id string, at time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, unlocked := range l.unlocked[userID] {
+		if unlocked.ID == id {
+			return false
+		}
+	}
+	l.unlocked[userID] = append(l.unlocked[userID], UnlockedAchievement{ID: id, UnlockedAt: at})
+	l.dirty = true
+	return true
+}
+
+// Unlocked returns a copy of the user's achievements in the order earned
+func (l *AchievementLedger) Unlocked(userID string) []UnlockedAchievement {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]UnlockedAchievement(nil), l.unlocked[userID]...)
+}
+
+// Streak returns the user's current match win streak
+func (l *AchievementLedger) Streak(userID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.streaks[userID]
+}
+
+// SetStreak records the user's match win streak
+func (l *AchievementLedger) SetStreak(userID string, streak int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.streaks[userID] == streak {
+		return
+	}
+	if streak == 0 {
+		delete(l.streaks, userID)
+	} else {
+		l.streaks[userID] = streak
+	}
+	l.dirty = true
+}
+
+// Forget drops everything recorded for a user who has left
+func (l *AchievementLedger) Forget(userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.unlocked[userID]; ok {
+		delete(l.unlocked, userID)
+		l.dirty = true
+	}
+	if _, ok := l.streaks[userID]; ok {
+		delete(l.streaks, userID)
+		l.dirty = true
+	}
+}
+
+// Clear drops everything, as when the leaderboard is reset
+func (l *AchievementLedger) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.unlocked = make(map[string][]UnlockedAchievement)
+	l.streaks = make(map[string]int)
+	l.dirty = true
+}
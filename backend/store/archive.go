@@ -0,0 +1,360 @@
+package store
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"leaderboard-backend/models"
+)
+
+var (
+	ErrInvalidSeasonID = errors.New("season IDs may only contain letters, digits, '-' and '_' (max 64)")
+	ErrSeasonArchived  = errors.New("season is already archived")
+	ErrSeasonNotFound  = errors.New("archived season not found")
+	ErrNoStanding      = errors.New("user has no standing in this season")
+)
+
+const archiveExt = ".standings.gz"
+
+// SeasonArchive keeps the final standings of closed seasons on disk. Each
+// season is one gzip file: a JSON header line followed by one tab-separated
+// row per user in rank order. Queries stream the file instead of loading
+// it into the live indexes, unless the season is held in the LRU of
+// loaded seasons.
+type SeasonArchive struct {
+	mu    sync.Mutex
+	dir   string
+	cache *seasonCache // nil streams every query from disk
+}
+
+// NewSeasonArchive stores archives under dir, creating it on first write.
+// Up to cacheSeasons recently queried seasons are kept loaded in memory;
+// zero disables the cache.
+func NewSeasonArchive(dir string, cacheSeasons int) *SeasonArchive {
+	a := &SeasonArchive{dir: dir}
+	if cacheSeasons > 0 {
+		a.cache = newSeasonCache(cacheSeasons)
+	}
+	return a
+}
+
+// GetCacheStats reports which seasons are loaded and the cache hit rate
+func (a *SeasonArchive) GetCacheStats() map[string]interface{} {
+	if a.cache == nil {
+		return map[string]interface{}{"capacity": 0}
+	}
+	return a.cache.stats()
+}
+
+func (a *SeasonArchive) path(season string) string {
+	return filepath.Join(a.dir, season+archiveExt)
+}
+
+// Archive writes the final standings of users under season
+func (a *SeasonArchive) Archive(season string, users []models.User) (*models.SeasonInfo, error) {
+	if !snapshotNamePattern.MatchString(season) {
+		return nil, ErrInvalidSeasonID
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Rating != users[j].Rating {
+			return users[i].Rating > users[j].Rating
+		}
+		return users[i].Username < users[j].Username
+	})
+
+	info := models.SeasonInfo{
+		ID:         season,
+		ArchivedAt: time.Now().UTC(),
+		UserCount:  len(users),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := os.Stat(a.path(season)); err == nil {
+		return nil, ErrSeasonArchived
+	}
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tempPath := a.path(season) + ".tmp"
+	if err := writeStandings(tempPath, info, users); err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+	if err := os.Rename(tempPath, a.path(season)); err != nil {
+		os.Remove(tempPath)
+		return nil, fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	return &info, nil
+}
+
+// writeStandings writes the header and ranked rows of users, which must
+// already be in leaderboard order
+func writeStandings(path string, info models.SeasonInfo, users []models.User) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	gz := gzip.NewWriter(file)
+	buffered := bufio.NewWriterSize(gz, 1<<20)
+
+	header, _ := json.Marshal(info)
+	buffered.Write(header)
+	buffered.WriteByte('\n')
+
+	rows := csv.NewWriter(buffered)
+	rows.Comma = '\t'
+	record := make([]string, 4)
+	rank := 0
+	for i, user := range users {
+		if i == 0 || user.Rating != users[i-1].Rating {
+			rank = i + 1
+		}
+		record[0] = strconv.Itoa(rank)
+		record[1] = strconv.Itoa(user.Rating)
+		record[2] = user.ID
+		record[3] = user.Username
+		rows.Write(record)
+	}
+	rows.Flush()
+
+	if err := rows.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write standings: %w", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write standings: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to compress standings: %w", err)
+	}
+	return file.Close()
+}
+
+// standingsReader streams an archive file row by row
+type standingsReader struct {
+	file *os.File
+	gz   *gzip.Reader
+	rows *csv.Reader
+	info models.SeasonInfo
+}
+
+func (a *SeasonArchive) open(season string) (*standingsReader, error) {
+	if !snapshotNamePattern.MatchString(season) {
+		return nil, ErrSeasonNotFound
+	}
+
+	file, err := os.Open(a.path(season))
+	if os.IsNotExist(err) {
+		return nil, ErrSeasonNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	buffered := bufio.NewReader(gz)
+	header, err := buffered.ReadBytes('\n')
+	if err != nil {
+		gz.Close()
+		file.Close()
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+
+	r := &standingsReader{file: file, gz: gz}
+	if err := json.Unmarshal(header, &r.info); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to parse archive header: %w", err)
+	}
+
+	r.rows = csv.NewReader(buffered)
+	r.rows.Comma = '\t'
+	r.rows.FieldsPerRecord = 4
+	r.rows.ReuseRecord = true
+	return r, nil
+}
+
+// next returns the following standing, or io.EOF after the last one
+func (r *standingsReader) next() (models.ArchivedStanding, error) {
+	record, err := r.rows.Read()
+	if err != nil {
+		return models.ArchivedStanding{}, err
+	}
+
+	rank, err := strconv.Atoi(record[0])
+	if err != nil {
+		return models.ArchivedStanding{}, fmt.Errorf("corrupt archive row: %w", err)
+	}
+	rating, err := strconv.Atoi(record[1])
+	if err != nil {
+		return models.ArchivedStanding{}, fmt.Errorf("corrupt archive row: %w", err)
+	}
+
+	return models.ArchivedStanding{
+		ID:       record[2],
+		Username: record[3],
+		Rank:     rank,
+		Rating:   rating,
+	}, nil
+}
+
+func (r *standingsReader) Close() {
+	r.gz.Close()
+	r.file.Close()
+}
+
+// Info returns an archived season's header without reading its rows
+func (a *SeasonArchive) Info(season string) (*models.SeasonInfo, error) {
+	r, err := a.open(season)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return &r.info, nil
+}
+
+// List returns every archived season, oldest first
+func (a *SeasonArchive) List() ([]models.SeasonInfo, error) {
+	entries, err := os.ReadDir(a.dir)
+	if os.IsNotExist(err) {
+		return []models.SeasonInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	list := make([]models.SeasonInfo, 0, len(entries))
+	for _, entry := range entries {
+		season := strings.TrimSuffix(entry.Name(), archiveExt)
+		if entry.IsDir() || season == entry.Name() {
+			continue
+		}
+		info, err := a.Info(season)
+		if err != nil {
+			continue
+		}
+		list = append(list, *info)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ArchivedAt.Before(list[j].ArchivedAt)
+	})
+	return list, nil
+}
+
+// Page returns the standings at positions [offset, offset+limit)
+func (a *SeasonArchive) Page(season string, limit, offset int) ([]models.ArchivedStanding, *models.SeasonInfo, error) {
+	if a.cache != nil {
+		loaded, err := a.load(season)
+		if err != nil {
+			return nil, nil, err
+		}
+		if offset >= len(loaded.standings) {
+			return []models.ArchivedStanding{}, &loaded.info, nil
+		}
+		end := offset + limit
+		if end > len(loaded.standings) {
+			end = len(loaded.standings)
+		}
+		page := make([]models.ArchivedStanding, end-offset)
+		copy(page, loaded.standings[offset:end])
+		return page, &loaded.info, nil
+	}
+
+	r, err := a.open(season)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	page := make([]models.ArchivedStanding, 0, limit)
+	for i := 0; len(page) < limit; i++ {
+		if i < offset {
+			// Skip without parsing
+			if _, err := r.rows.Read(); err != nil {
+				break
+			}
+			continue
+		}
+
+		standing, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		page = append(page, standing)
+	}
+
+	return page, &r.info, nil
+}
+
+// FindUser returns a user's final standing in an archived season
+func (a *SeasonArchive) FindUser(season, userID string) (*models.ArchivedStanding, *models.SeasonInfo, error) {
+	if a.cache != nil {
+		loaded, err := a.load(season)
+		if err != nil {
+			return nil, nil, err
+		}
+		idx, ok := loaded.byID[userID]
+		if !ok {
+			return nil, nil, ErrNoStanding
+		}
+		standing := loaded.standings[idx]
+		return &standing, &loaded.info, nil
+	}
+
+	r, err := a.open(season)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	for {
+		record, err := r.rows.Read()
+		if err == io.EOF {
+			return nil, nil, ErrNoStanding
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("corrupt archive row: %w", err)
+		}
+		if record[2] != userID {
+			continue
+		}
+
+		rank, _ := strconv.Atoi(record[0])
+		rating, _ := strconv.Atoi(record[1])
+		return &models.ArchivedStanding{
+			ID:       record[2],
+			Username: record[3],
+			Rank:     rank,
+			Rating:   rating,
+		}, &r.info, nil
+	}
+}
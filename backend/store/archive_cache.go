@@ -0,0 +1,125 @@
+package store
+
+import (
+	"container/list"
+	"io"
+	"sync"
+
+	"leaderboard-backend/models"
+)
+
+// loadedSeason is an archived season parsed into memory
+type loadedSeason struct {
+	info      models.SeasonInfo
+	standings []models.ArchivedStanding
+	byID      map[string]int // user ID -> index into standings
+}
+
+// seasonCache holds the most recently used archived seasons in memory so
+// repeated historical queries do not re-read the archive file, while older
+// seasons stay on disk only
+type seasonCache struct {
+	mu        sync.Mutex
+	capacity  int
+	entries   map[string]*list.Element
+	order     *list.List // front = most recently used
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type seasonCacheEntry struct {
+	season string
+	loaded *loadedSeason
+}
+
+func newSeasonCache(capacity int) *seasonCache {
+	return &seasonCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *seasonCache) get(season string) (*loadedSeason, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[season]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*seasonCacheEntry).loaded, true
+}
+
+func (c *seasonCache) put(season string, loaded *loadedSeason) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[season]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[season] = c.order.PushFront(&seasonCacheEntry{season: season, loaded: loaded})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*seasonCacheEntry).season)
+		c.evictions++
+	}
+}
+
+func (c *seasonCache) stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	loaded := make([]string, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		loaded = append(loaded, elem.Value.(*seasonCacheEntry).season)
+	}
+
+	return map[string]interface{}{
+		"capacity":  c.capacity,
+		"loaded":    loaded,
+		"hits":      c.hits,
+		"misses":    c.misses,
+		"evictions": c.evictions,
+	}
+}
+
+// load returns an archived season from the cache, reading it from disk on a miss
+func (a *SeasonArchive) load(season string) (*loadedSeason, error) {
+	if loaded, ok := a.cache.get(season); ok {
+		return loaded, nil
+	}
+
+	r, err := a.open(season)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	loaded := &loadedSeason{
+		info:      r.info,
+		standings: make([]models.ArchivedStanding, 0, r.info.UserCount),
+		byID:      make(map[string]int, r.info.UserCount),
+	}
+	for {
+		standing, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		loaded.byID[standing.ID] = len(loaded.standings)
+		loaded.standings = append(loaded.standings, standing)
+	}
+
+	a.cache.put(season, loaded)
+	return loaded, nil
+}
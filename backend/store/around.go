@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"leaderboard-backend/models"
+)
+
+// Neighborhood is a user together with the users ranked just around them
+type Neighborhood struct {
+	Users      []*models.User // in leaderboard order
+	Ranks      []int          // competition rank of each user
+	Index      int            // position of the requested user in Users
+	TotalUsers int
+}
+
+// GetAroundContext returns user id with up to window users ranked directly
+// above and below them. Order and ranks are read under one read lock, so
+// they agree with each other.
+func (m *MemoryStore) GetAroundContext(ctx context.Context, id string, window int) (*Neighborhood, error) {
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer m.mu.RUnlock()
+
+	users, index := m.skipList.Around(id, window, window)
+	if index < 0 {
+		return nil, fmt.Errorf("user with ID %s not found", id)
+	}
+
+	ratings := make([]int, len(users))
+	for i, user := range users {
+		ratings[i] = user.Rating
+	}
+	return &Neighborhood{
+		Users:      users,
+		Ranks:      m.ratingIndex.GetRanks(ratings),
+		Index:      index,
+		TotalUsers: m.skipList.Length(),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// AutosaveCheckInterval is how often the scheduler should call Check
+const AutosaveCheckInterval = time.Second
+
+// Saver writes a full copy of the store somewhere durable: Persistence to
+// JSON files or PostgresStore to a database
+type Saver interface {
+	Save(store *MemoryStore) error
+}
+
+// AutoSaver decides when to snapshot the store to disk. A save is triggered
+// when the interval elapses with unsaved changes, or as soon as the number
+// of mutations since the last save reaches the write threshold. It owns no
+// goroutine; the job scheduler calls Check periodically.
+type AutoSaver struct {
+	mu             sync.Mutex
+	persistence    Saver
+	store          *MemoryStore
+	interval       time.Duration
+	writeThreshold uint64 // 0 disables write-count saves
+
+	lastSave          time.Time
+	lastSaveMutations uint64
+	saveCount         int64
+	lastErr           error
+}
+
+// NewAutoSaver creates an autosaver. A zero interval disables timed saves and
+// a zero writeThreshold disables write-count saves.
+func NewAutoSaver(p Saver, s *MemoryStore, interval time.Duration, writeThreshold int) *AutoSaver {
+	if writeThreshold < 0 {
+		writeThreshold = 0
+	}
+	return &AutoSaver{
+		persistence:       p,
+		store:             s,
+		interval:          interval,
+		writeThreshold:    uint64(writeThreshold),
+		lastSave:          time.Now(),
+		lastSaveMutations: s.GetMutationCount(),
+	}
+}
+
+// Check saves the store if a save is due and returns any save error
+func (a *AutoSaver) Check() error {
+	reason := a.shouldSave()
+	if reason == "" {
+		return nil
+	}
+	return a.save(reason)
+}
+
+// shouldSave returns the trigger reason, or "" if no save is due
+func (a *AutoSaver) shouldSave() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pending := a.store.GetMutationCount() - a.lastSaveMutations
+	if pending == 0 {
+		return ""
+	}
+	if a.writeThreshold > 0 && pending >= a.writeThreshold {
+		return "write threshold"
+	}
+	if a.interval > 0 && time.Since(a.lastSave) >= a.interval {
+		return "interval"
+	}
+	return ""
+}
+
+func (a *AutoSaver) save(reason string) error {
+	mutations := a.store.GetMutationCount()
+	start := time.Now()
+	err := a.persistence.Save(a.store)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastErr = err
+	if err != nil {
+		return err
+	}
+	a.lastSave = time.Now()
+	a.lastSaveMutations = mutations
+	a.saveCount++
+	log.Printf("Autosave (%s): saved %d users in %v\n", reason, a.store.GetUserCount(), time.Since(start))
+	return nil
+}
+
+// GetStats returns autosave statistics
+func (a *AutoSaver) GetStats() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	lastErr := ""
+	if a.lastErr != nil {
+		lastErr = a.lastErr.Error()
+	}
+
+	return map[string]interface{}{
+		"interval_seconds":  int64(a.interval.Seconds()),
+		"write_threshold":   a.writeThreshold,
+		"pending_mutations": a.store.GetMutationCount() - a.lastSaveMutations,
+		"save_count":        a.saveCount,
+		"last_save":         a.lastSave.UTC().Format(time.RFC3339),
+		"last_error":        lastErr,
+	}
+}
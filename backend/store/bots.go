@@ -0,0 +1,47 @@
+package store
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"leaderboard-backend/models"
+)
+
+// SetBot flags or unflags a user as a bot or simulator account. Flagged
+// users keep their place in every index and still count towards ranks;
+// only filtered results leave them out.
+func (m *MemoryStore) SetBot(id string, bot bool) (*models.User, error) {
+	unlock := m.users.lock(id)
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	user, exists := m.users.get(id)
+	if !exists {
+		return nil, fmt.Errorf("user with ID %s not found", id)
+	}
+
+	if user.Bot != bot {
+		// The concurrent skip list holds its own copy of each user
+		m.skipList.Remove(user.ID)
+		user.Bot = bot
+		m.skipList.Insert(user)
+		if bot {
+			atomic.AddInt64(&m.bots, 1)
+		} else {
+			atomic.AddInt64(&m.bots, -1)
+		}
+		atomic.AddUint64(&m.mutations, 1)
+		if m.journal != nil {
+			m.journal.append(walEntry{Op: walBot, ID: id, Bot: bot})
+		}
+	}
+
+	userCopy := *user
+	return &userCopy, nil
+}
+
+// GetBotCount returns how many users are flagged as bots
+func (m *MemoryStore) GetBotCount() int {
+	return int(atomic.LoadInt64(&m.bots))
+}
@@ -0,0 +1,603 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"leaderboard-backend/models"
+)
+
+// btreeMaxItems is the most users a leaf, or children an inner node, holds
+// before it splits. Below btreeMinItems a node borrows from or merges with
+// a sibling.
+const (
+	btreeMaxItems = 64
+	btreeMinItems = btreeMaxItems / 2
+)
+
+// btreeNode is either a leaf holding users in leaderboard order or an inner
+// node holding children, with the first user under each child to steer
+// searches and the number of users under it to seek by offset
+type btreeNode struct {
+	users    []*models.User // leaf only
+	children []*btreeNode   // inner only
+	firsts   []*models.User // inner only: first user under each child
+	sizes    []int          // inner only: users under each child
+	prev     *btreeNode     // leaf only: neighbouring leaves, nil at the ends
+	next     *btreeNode
+}
+
+func (n *btreeNode) leaf() bool {
+	return n.children == nil
+}
+
+// items is how many users a leaf holds or children an inner node has
+func (n *btreeNode) items() int {
+	if n.leaf() {
+		return len(n.users)
+	}
+	return len(n.children)
+}
+
+// first returns the first user under a non-empty node
+func (n *btreeNode) first() *models.User {
+	if n.leaf() {
+		return n.users[0]
+	}
+	return n.firsts[0]
+}
+
+// size counts the users under n
+func (n *btreeNode) size() int {
+	if n.leaf() {
+		return len(n.users)
+	}
+	total := 0
+	for _, size := range n.sizes {
+		total += size
+	}
+	return total
+}
+
+// childFor returns the child a search for user starts in: the last one
+// whose first user ranks ahead of it
+func (n *btreeNode) childFor(user *models.User) int {
+	i := sort.Search(len(n.firsts), func(i int) bool { return compare(n.firsts[i], user) <= 0 })
+	return max(i-1, 0)
+}
+
+// lowerBound returns the index of the first user in a leaf that does not
+// rank ahead of user
+func (n *btreeNode) lowerBound(user *models.User) int {
+	return sort.Search(len(n.users), func(i int) bool { return compare(n.users[i], user) <= 0 })
+}
+
+// BTree is a B+ tree alternative to the skip list: users sit in the leaves
+// in leaderboard order, the leaves are linked both ways for page scans, and
+// inner nodes count the users under each child so offsets and positions are
+// found in O(log N). It keeps 32 to 64 users per leaf in contiguous slices,
+// trading the skip list's per-node towers for fewer, larger allocations.
+//
+// Like SkipList it has no lock of its own; its owner's lock must be held
+// for reading around queries and for writing around Insert, Remove and
+// Clear.
+type BTree struct {
+	guard   *sync.RWMutex // owner's lock, checked when lock checks are enabled
+	root    *btreeNode
+	height  int // levels of nodes, 1 while the root is a leaf
+	length  int
+	nodeMap map[string]*models.User // userID -> user as inserted, to find it again
+
+	// Diagnostics
+	splits int64
+	merges int64
+}
+
+// NewBTree creates a B-tree for single-goroutine use or for an owner that
+// serializes access itself
+func NewBTree() *BTree {
+	return NewGuardedBTree(nil)
+}
+
+// NewGuardedBTree creates a B-tree protected by guard, its owner's lock
+func NewGuardedBTree(guard *sync.RWMutex) *BTree {
+	return &BTree{
+		guard:   guard,
+		root:    &btreeNode{},
+		height:  1,
+		nodeMap: make(map[string]*models.User),
+	}
+}
+
+// Insert adds a user - O(log N)
+func (t *BTree) Insert(user *models.User) {
+	t.assertWriteHeld()
+
+	if _, exists := t.nodeMap[user.ID]; exists {
+		return
+	}
+
+	if split := t.insert(t.root, user); split != nil {
+		old := t.root
+		t.root = &btreeNode{
+			children: []*btreeNode{old, split},
+			firsts:   []*models.User{old.first(), split.first()},
+			sizes:    []int{old.size(), split.size()},
+		}
+		t.height++
+	}
+	t.nodeMap[user.ID] = user
+	t.length++
+}
+
+// insert adds user under n and returns n's new right sibling if n split
+func (t *BTree) insert(n *btreeNode, user *models.User) *btreeNode {
+	if n.leaf() {
+		i := n.lowerBound(user)
+		n.users = append(n.users, nil)
+		copy(n.users[i+1:], n.users[i:])
+		n.users[i] = user
+		if len(n.users) <= btreeMaxItems {
+			return nil
+		}
+		return t.split(n)
+	}
+
+	i := n.childFor(user)
+	child := n.children[i]
+	split := t.insert(child, user)
+	n.firsts[i] = child.first()
+	n.sizes[i]++
+	if split == nil {
+		return nil
+	}
+
+	splitSize := split.size()
+	n.sizes[i] -= splitSize
+	n.children = append(n.children, nil)
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = split
+	n.firsts = append(n.firsts, nil)
+	copy(n.firsts[i+2:], n.firsts[i+1:])
+	n.firsts[i+1] = split.first()
+	n.sizes = append(n.sizes, 0)
+	copy(n.sizes[i+2:], n.sizes[i+1:])
+	n.sizes[i+1] = splitSize
+	if len(n.children) <= btreeMaxItems {
+		return nil
+	}
+	return t.split(n)
+}
+
+// split moves the back half of an overfull node into a new right sibling
+func (t *BTree) split(n *btreeNode) *btreeNode {
+	t.splits++
+	mid := n.items() / 2
+	right := &btreeNode{}
+	if n.leaf() {
+		right.users = append(make([]*models.User, 0, btreeMaxItems+1), n.users[mid:]...)
+		clear(n.users[mid:])
+		n.users = n.users[:mid]
+		right.prev, right.next = n, n.next
+		if n.next != nil {
+			n.next.prev = right
+		}
+		n.next = right
+		return right
+	}
+	right.children = append(make([]*btreeNode, 0, btreeMaxItems+1), n.children[mid:]...)
+	right.firsts = append(make([]*models.User, 0, btreeMaxItems+1), n.firsts[mid:]...)
+	right.sizes = append(make([]int, 0, btreeMaxItems+1), n.sizes[mid:]...)
+	clear(n.children[mid:])
+	clear(n.firsts[mid:])
+	n.children = n.children[:mid]
+	n.firsts = n.firsts[:mid]
+	n.sizes = n.sizes[:mid]
+	return right
+}
+
+// Remove deletes a user - O(log N)
+func (t *BTree) Remove(userID string) bool {
+	t.assertWriteHeld()
+
+	user, exists := t.nodeMap[userID]
+	if !exists {
+		return false
+	}
+	if !t.remove(t.root, user) {
+		return false
+	}
+	// An inner root left with one child hands the tree over to it
+	for !t.root.leaf() && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+		t.height--
+	}
+	delete(t.nodeMap, userID)
+	t.length--
+	return true
+}
+
+// remove takes user out from under n, reporting whether it was there.
+// Users can tie on rating and username, so a search may have to try every
+// child whose range takes in the user's key.
+func (t *BTree) remove(n *btreeNode, user *models.User) bool {
+	if n.leaf() {
+		for i := n.lowerBound(user); i < len(n.users) && compare(n.users[i], user) == 0; i++ {
+			if n.users[i].ID == user.ID {
+				copy(n.users[i:], n.users[i+1:])
+				n.users[len(n.users)-1] = nil
+				n.users = n.users[:len(n.users)-1]
+				return true
+			}
+		}
+		return false
+	}
+
+	start := n.childFor(user)
+	for i := start; i < len(n.children) && (i == start || compare(n.firsts[i], user) >= 0); i++ {
+		child := n.children[i]
+		if !t.remove(child, user) {
+			continue
+		}
+		n.sizes[i]--
+		if child.items() < btreeMinItems {
+			t.rebalance(n, i)
+		} else {
+			n.firsts[i] = child.first()
+		}
+		return true
+	}
+	return false
+}
+
+// rebalance refills the underfull child i of n from a neighbour: the two
+// merge if they fit in one node and share their items evenly otherwise
+func (t *BTree) rebalance(n *btreeNode, i int) {
+	l := max(i-1, 0)
+	r := l + 1
+	left, right := n.children[l], n.children[r]
+
+	if left.items()+right.items() <= btreeMaxItems {
+		t.merges++
+		if left.leaf() {
+			left.users = append(left.users, right.users...)
+			left.next = right.next
+			if right.next != nil {
+				right.next.prev = left
+			}
+		} else {
+			left.children = append(left.children, right.children...)
+			left.firsts = append(left.firsts, right.firsts...)
+			left.sizes = append(left.sizes, right.sizes...)
+		}
+		n.sizes[l] += n.sizes[r]
+		n.firsts[l] = left.first()
+		last := len(n.children) - 1
+		copy(n.children[r:], n.children[r+1:])
+		copy(n.firsts[r:], n.firsts[r+1:])
+		copy(n.sizes[r:], n.sizes[r+1:])
+		n.children[last], n.firsts[last] = nil, nil
+		n.children, n.firsts, n.sizes = n.children[:last], n.firsts[:last], n.sizes[:last]
+		return
+	}
+
+	half := (left.items() + right.items()) / 2
+	if left.leaf() {
+		all := append(append(make([]*models.User, 0, btreeMaxItems+1), left.users...), right.users...)
+		left.users = append(left.users[:0], all[:half]...)
+		clear(left.users[len(left.users):cap(left.users)])
+		right.users = append(right.users[:0], all[half:]...)
+		clear(right.users[len(right.users):cap(right.users)])
+	} else {
+		children := append(append([]*btreeNode(nil), left.children...), right.children...)
+		firsts := append(append([]*models.User(nil), left.firsts...), right.firsts...)
+		sizes := append(append([]int(nil), left.sizes...), right.sizes...)
+		left.children = append(left.children[:0], children[:half]...)
+		left.firsts = append(left.firsts[:0], firsts[:half]...)
+		left.sizes = append(left.sizes[:0], sizes[:half]...)
+		right.children = append(right.children[:0], children[half:]...)
+		right.firsts = append(right.firsts[:0], firsts[half:]...)
+		right.sizes = append(right.sizes[:0], sizes[half:]...)
+	}
+	n.firsts[l], n.firsts[r] = left.first(), right.first()
+	n.sizes[l], n.sizes[r] = left.size(), right.size()
+}
+
+// btreeCursor steps through the leaves from a position in leaderboard order
+type btreeCursor struct {
+	leaf  *btreeNode
+	index int
+}
+
+// next returns the user at the cursor and moves past it, or nil at the end
+func (c *btreeCursor) next() *models.User {
+	for c.leaf != nil && c.index >= len(c.leaf.users) {
+		c.leaf, c.index = c.leaf.next, 0
+	}
+	if c.leaf == nil {
+		return nil
+	}
+	user := c.leaf.users[c.index]
+	c.index++
+	return user
+}
+
+// first returns a cursor at the top of the leaderboard
+func (t *BTree) first() btreeCursor {
+	n := t.root
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	return btreeCursor{leaf: n}
+}
+
+// seek returns a cursor at offset (0 for the first) by following the child
+// sizes down - O(log N)
+func (t *BTree) seek(offset int) btreeCursor {
+	n := t.root
+	for !n.leaf() {
+		i := 0
+		for i < len(n.sizes)-1 && offset >= n.sizes[i] {
+			offset -= n.sizes[i]
+			i++
+		}
+		n = n.children[i]
+	}
+	return btreeCursor{leaf: n, index: offset}
+}
+
+// locate finds a user's leaf, index in it and 0-based position, or a nil
+// leaf if they aren't in the tree
+func (t *BTree) locate(user *models.User) (*btreeNode, int, int) {
+	return t.locateUnder(t.root, user, 0)
+}
+
+func (t *BTree) locateUnder(n *btreeNode, user *models.User, before int) (*btreeNode, int, int) {
+	if n.leaf() {
+		for i := n.lowerBound(user); i < len(n.users) && compare(n.users[i], user) == 0; i++ {
+			if n.users[i].ID == user.ID {
+				return n, i, before + i
+			}
+		}
+		return nil, 0, 0
+	}
+
+	start := n.childFor(user)
+	for i := 0; i < start; i++ {
+		before += n.sizes[i]
+	}
+	for i := start; i < len(n.children) && (i == start || compare(n.firsts[i], user) >= 0); i++ {
+		if leaf, index, position := t.locateUnder(n.children[i], user, before); leaf != nil {
+			return leaf, index, position
+		}
+		before += n.sizes[i]
+	}
+	return nil, 0, 0
+}
+
+// GetTopN returns top N users starting from offset - O(log N + limit)
+func (t *BTree) GetTopN(limit, offset int) []*models.User {
+	t.assertReadHeld()
+
+	if offset >= t.length {
+		return []*models.User{}
+	}
+
+	cursor := t.seek(offset)
+	result := make([]*models.User, 0, limit)
+	for user := cursor.next(); user != nil && len(result) < limit; user = cursor.next() {
+		userCopy := *user
+		result = append(result, &userCopy)
+	}
+	return result
+}
+
+// VisitTopN passes up to limit users from offset to visit without copying
+// them - O(log N + limit)
+func (t *BTree) VisitTopN(limit, offset int, visit func(user *models.User) bool) {
+	t.assertReadHeld()
+
+	if offset >= t.length {
+		return
+	}
+	cursor := t.seek(offset)
+	for i := 0; i < limit; i++ {
+		user := cursor.next()
+		if user == nil || !visit(user) {
+			return
+		}
+	}
+}
+
+// GetTopNFiltered returns up to limit users that satisfy keep, skipping the
+// first offset matches. Like the skip list it walks every user examined,
+// stopping early once ctx ends.
+func (t *BTree) GetTopNFiltered(ctx context.Context, limit, offset int, keep func(user *models.User) bool) ([]*models.User, error) {
+	t.assertReadHeld()
+
+	result := make([]*models.User, 0, limit)
+	skipped := 0
+	examined := 0
+	cursor := t.first()
+	for user := cursor.next(); user != nil && len(result) < limit; user = cursor.next() {
+		if examined++; examined%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !keep(user) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		userCopy := *user
+		result = append(result, &userCopy)
+	}
+	return result, nil
+}
+
+// After returns up to limit users ranked strictly after the position of
+// after that pass keep, or all users if keep is nil - O(log N + limit)
+func (t *BTree) After(ctx context.Context, after *models.User, limit int, keep func(user *models.User) bool) ([]*models.User, error) {
+	t.assertReadHeld()
+
+	n := t.root
+	for !n.leaf() {
+		i := sort.Search(len(n.firsts), func(i int) bool { return compare(n.firsts[i], after) < 0 })
+		n = n.children[max(i-1, 0)]
+	}
+	index := sort.Search(len(n.users), func(i int) bool { return compare(n.users[i], after) < 0 })
+	cursor := btreeCursor{leaf: n, index: index}
+
+	result := make([]*models.User, 0, limit)
+	examined := 0
+	for user := cursor.next(); user != nil && len(result) < limit; user = cursor.next() {
+		if examined++; examined%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if keep != nil && !keep(user) {
+			continue
+		}
+		userCopy := *user
+		result = append(result, &userCopy)
+	}
+	return result, nil
+}
+
+// Around returns the user with up to above users ranked just ahead of them
+// and up to below just behind, and the user's index in that slice, or -1
+// if they aren't listed. O(log N + above + below).
+func (t *BTree) Around(userID string, above, below int) ([]*models.User, int) {
+	t.assertReadHeld()
+
+	user, exists := t.nodeMap[userID]
+	if !exists {
+		return nil, -1
+	}
+	leaf, index, _ := t.locate(user)
+	if leaf == nil {
+		return nil, -1
+	}
+
+	// Step back above users, crossing into earlier leaves as needed
+	ahead := 0
+	for ahead < above {
+		if index == 0 {
+			if leaf.prev == nil {
+				break
+			}
+			leaf, index = leaf.prev, len(leaf.prev.users)
+		}
+		index--
+		ahead++
+	}
+
+	cursor := btreeCursor{leaf: leaf, index: index}
+	result := make([]*models.User, 0, ahead+1+below)
+	for current := cursor.next(); current != nil && len(result) <= ahead+below; current = cursor.next() {
+		userCopy := *current
+		result = append(result, &userCopy)
+	}
+	return result, ahead
+}
+
+// Position returns the 1-based place of a user in leaderboard order -
+// O(log N)
+func (t *BTree) Position(userID string) (int, bool) {
+	t.assertReadHeld()
+
+	user, exists := t.nodeMap[userID]
+	if !exists {
+		return 0, false
+	}
+	leaf, _, position := t.locate(user)
+	if leaf == nil {
+		return 0, false
+	}
+	return position + 1, true
+}
+
+// LockFreeReads is false: every query needs the owner's lock
+func (t *BTree) LockFreeReads() bool {
+	return false
+}
+
+// Length returns the number of users in the tree
+func (t *BTree) Length() int {
+	t.assertReadHeld()
+	return t.length
+}
+
+// Contains checks if a user exists in the tree
+func (t *BTree) Contains(userID string) bool {
+	t.assertReadHeld()
+	_, exists := t.nodeMap[userID]
+	return exists
+}
+
+// Clear removes all users
+func (t *BTree) Clear() {
+	t.assertWriteHeld()
+
+	t.root = &btreeNode{}
+	t.height = 1
+	t.length = 0
+	t.nodeMap = make(map[string]*models.User)
+}
+
+// GetAllUserIDs returns all user IDs (for simulator)
+func (t *BTree) GetAllUserIDs() []string {
+	t.assertReadHeld()
+
+	ids := make([]string, 0, t.length)
+	for id := range t.nodeMap {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// GetStats returns structural diagnostics: height, node counts and how full
+// the leaves are on average
+func (t *BTree) GetStats() map[string]interface{} {
+	t.assertReadHeld()
+
+	leaves, inner := 0, 0
+	var count func(n *btreeNode)
+	count = func(n *btreeNode) {
+		if n.leaf() {
+			leaves++
+			return
+		}
+		inner++
+		for _, child := range n.children {
+			count(child)
+		}
+	}
+	count(t.root)
+
+	leafFill := 0.0
+	if leaves > 0 {
+		leafFill = float64(t.length) / float64(leaves*btreeMaxItems)
+	}
+
+	return map[string]interface{}{
+		"implementation": SkipListBTree,
+		"length":         t.length,
+		"height":         t.height,
+		"leaves":         leaves,
+		"inner_nodes":    inner,
+		"max_items":      btreeMaxItems,
+		"leaf_fill":      leafFill,
+		"splits":         t.splits,
+		"merges":         t.merges,
+	}
+}
+
+func (t *BTree) assertWriteHeld() {
+	assertGuardWriteHeld(t.guard)
+}
+
+func (t *BTree) assertReadHeld() {
+	assertGuardReadHeld(t.guard)
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"leaderboard-backend/models"
+)
+
+// LoadUsers adds many users under a single write lock, as when restoring a
+// snapshot. Every user is placed in the map, username index and skip list
+// first; the rating index is then built in one pass rather than updated
+// per user. The returned map holds an error for every user that was not
+// added: a duplicate or merged ID, or in strict mode an out-of-range rating.
+func (m *MemoryStore) LoadUsers(users []*models.User, source Source) map[string]error {
+	source = source.orDefault()
+	failed := make(map[string]error)
+	if len(users) == 0 {
+		return failed
+	}
+
+	unlock := m.users.lockAll()
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	now := time.Now()
+	added := make([]*models.User, 0, len(users))
+	ratings := make([]int, 0, len(users))
+	for _, user := range users {
+		rating, err := m.CheckRating(user.Rating)
+		if err != nil {
+			failed[user.ID] = err
+			continue
+		}
+		user.Rating = rating
+		if _, exists := m.users.get(user.ID); exists {
+			failed[user.ID] = fmt.Errorf("user with ID %s already exists", user.ID)
+			continue
+		}
+		if into, merged := m.merged[user.ID]; merged {
+			failed[user.ID] = fmt.Errorf("user with ID %s: %w %s", user.ID, ErrMerged, into)
+			continue
+		}
+
+		m.users.set(user)
+		m.indexUsername(user.ID, user.Username)
+		if user.Bot {
+			atomic.AddInt64(&m.bots, 1)
+		}
+		m.skipList.Insert(user)
+		m.recordChange(user, now, source)
+		m.journalSet(user, source)
+		added = append(added, user)
+		ratings = append(ratings, user.Rating)
+	}
+
+	m.ratingIndex.IncrementBuckets(ratings)
+	atomic.AddUint64(&m.mutations, uint64(len(added)))
+	if m.publishing() {
+		for _, user := range added {
+			m.publishUser(ChangeAdded, user, now, source, "")
+		}
+	}
+	if len(added) > 0 {
+		m.membershipChanged()
+	}
+	return failed
+}
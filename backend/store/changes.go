@@ -0,0 +1,80 @@
+package store
+
+import (
+	"time"
+
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/models"
+)
+
+// ChangeType says what a Change did
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"   // a user joined the board
+	ChangeRating  ChangeType = "rating"  // a user's rating moved
+	ChangeRemoved ChangeType = "removed" // a user was deleted or merged away
+	ChangeCleared ChangeType = "cleared" // every user was removed
+)
+
+// Change is one mutation as published on the change feed. Ranks are as of
+// the moment of the change. Source is set for additions and rating changes,
+// OldRating and OldRank only for rating changes, and User is empty when the
+// store was cleared. TotalUsers is the board size after the change. Trace
+// is the traceparent of the request that made the change, if it came from
+// one.
+type Change struct {
+	Type       ChangeType
+	User       models.User
+	Rank       int
+	OldRating  int
+	OldRank    int
+	TotalUsers int
+	Source     Source
+	Trace      string
+	At         time.Time
+}
+
+var changesDroppedTotal = metrics.NewCounter("store_changes_dropped_total", "Changes not published to a change feed because it was full")
+
+// AddChangeFeed publishes every later mutation on feed, alongside any feeds
+// added before. Writers never wait on a feed: a change that doesn't fit in
+// the channel's buffer is dropped and counted, so give it room for bursts
+// and drain it promptly.
+func (m *MemoryStore) AddChangeFeed(feed chan<- Change) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.changes = append(m.changes, feed)
+}
+
+// publishing reports whether a change feed is attached, so callers can skip
+// rank lookups nobody will read; the caller holds the write lock
+func (m *MemoryStore) publishing() bool {
+	return len(m.changes) > 0
+}
+
+// publish sends change to every feed without blocking; the caller holds the
+// write lock
+func (m *MemoryStore) publish(change Change) {
+	for _, feed := range m.changes {
+		select {
+		case feed <- change:
+		default:
+			changesDroppedTotal.Inc()
+		}
+	}
+}
+
+// publishUser publishes a change to user with their current rank; the
+// caller holds the write lock
+func (m *MemoryStore) publishUser(changeType ChangeType, user *models.User, now time.Time, source Source, trace string) {
+	if !m.publishing() {
+		return
+	}
+	change := Change{Type: changeType, User: *user, TotalUsers: m.ratingIndex.GetTotalUsers(), Source: source, Trace: trace, At: now}
+	if changeType != ChangeRemoved {
+		change.Rank = m.ratingIndex.GetRank(user.Rating)
+	}
+	m.publish(change)
+}
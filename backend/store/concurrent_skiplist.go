@@ -0,0 +1,566 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"leaderboard-backend/models"
+)
+
+const (
+	// reclaimBatch is how many retired nodes accumulate before the writer
+	// checks which of them readers can no longer reach
+	reclaimBatch = 64
+	// maxFreeNodes caps the nodes kept for reuse; beyond it they are left
+	// to the garbage collector
+	maxFreeNodes = 4096
+	// seekAttempts is how often a reader retries a span seek that raced a
+	// writer before walking level 0 instead
+	seekAttempts = 3
+)
+
+// concurrentNode holds its own copy of the user, which is never modified
+// while the node is linked, so readers can copy it without any lock
+type concurrentNode struct {
+	user     models.User
+	forward  []atomic.Pointer[concurrentNode]
+	span     []atomic.Int64                 // level-0 steps to forward[i], or to past the end when it is nil
+	backward atomic.Pointer[concurrentNode] // previous node on level 0, nil for the first
+}
+
+type retiredNode struct {
+	node  *concurrentNode
+	epoch uint64
+}
+
+// ConcurrentSkipList is a skip list whose queries never block. Writers are
+// serialized by the owner's write lock exactly as for SkipList; GetTopN,
+// GetTopNFiltered and Length need no lock at all, so leaderboard reads don't
+// queue behind simulator bursts.
+//
+// Links are published with atomic stores: a new node is fully built before
+// it becomes reachable, and a removed node keeps its forward pointers so a
+// reader standing on it can carry on. Removed nodes are recycled for later
+// inserts only once epoch-based reclamation proves no reader can still
+// reach them.
+//
+// A rating change is a remove followed by an insert, so a reader racing
+// with it may miss that user or meet them twice in one page read, though
+// always in rating order. The store's ranking sequence number lets callers
+// detect such reads.
+//
+// Spans can't be changed together with the links they describe, so an
+// offset seek checks the writer sequence around itself and retries if a
+// write overlapped it, falling back to walking level 0.
+type ConcurrentSkipList struct {
+	guard  *sync.RWMutex // owner's lock, checked when lock checks are enabled
+	params SkipListParams
+	head   atomic.Pointer[concurrentNode]
+	level  int32         // atomic
+	length int64         // atomic
+	writes atomic.Uint64 // odd while a writer is relinking
+
+	// Writer-only state; readers holding the owner's lock may read it
+	nodeMap map[string]*concurrentNode
+	epochs  *epochReclaimer
+	retired []retiredNode
+	free    [MaxLevel][]*concurrentNode // reusable nodes by tower height
+	freeLen int
+
+	// Diagnostics
+	levelCounts     [MaxLevel]int
+	maxLevelReached int
+	searches        int64
+	searchSteps     int64
+	reclaimed       uint64
+	reused          uint64
+}
+
+// NewConcurrentSkipList creates a concurrent skip list whose writers are
+// serialized by guard, its owner's lock
+func NewConcurrentSkipList(guard *sync.RWMutex) *ConcurrentSkipList {
+	return NewConcurrentSkipListWithParams(guard, DefaultSkipListParams())
+}
+
+// NewConcurrentSkipListWithParams is NewConcurrentSkipList shaped by params
+func NewConcurrentSkipListWithParams(guard *sync.RWMutex, params SkipListParams) *ConcurrentSkipList {
+	sl := &ConcurrentSkipList{
+		guard:   guard,
+		params:  params,
+		nodeMap: make(map[string]*concurrentNode),
+		epochs:  newEpochReclaimer(),
+	}
+	sl.head.Store(newConcurrentNode(params.MaxLevel - 1))
+	return sl
+}
+
+func newConcurrentNode(level int) *concurrentNode {
+	return &concurrentNode{
+		forward: make([]atomic.Pointer[concurrentNode], level+1),
+		span:    make([]atomic.Int64, level+1),
+	}
+}
+
+// LockFreeReads reports that queries may run without the owner's lock
+func (sl *ConcurrentSkipList) LockFreeReads() bool {
+	return true
+}
+
+// alloc returns a node with a tower of the given height, reusing a
+// reclaimed one when available
+func (sl *ConcurrentSkipList) alloc(level int) *concurrentNode {
+	if n := len(sl.free[level]); n > 0 {
+		node := sl.free[level][n-1]
+		sl.free[level] = sl.free[level][:n-1]
+		sl.freeLen--
+		sl.reused++
+		return node
+	}
+	return newConcurrentNode(level)
+}
+
+// findPredecessors fills update with the last node before user on each
+// level, and rank with their positions (the head being 0) if not nil
+func (sl *ConcurrentSkipList) findPredecessors(head *concurrentNode, user *models.User, update []*concurrentNode, rank []int64) {
+	level := int(atomic.LoadInt32(&sl.level))
+	current := head
+	steps := 0
+	var position int64
+	for i := level; i >= 0; i-- {
+		for next := current.forward[i].Load(); next != nil && compare(&next.user, user) > 0; next = current.forward[i].Load() {
+			position += current.span[i].Load()
+			current = next
+			steps++
+		}
+		update[i] = current
+		if rank != nil {
+			rank[i] = position
+		}
+	}
+	sl.searches++
+	sl.searchSteps += int64(steps)
+}
+
+// Insert adds a copy of user - O(log N)
+func (sl *ConcurrentSkipList) Insert(user *models.User) {
+	sl.assertWriteHeld()
+
+	if _, exists := sl.nodeMap[user.ID]; exists {
+		return
+	}
+
+	sl.writes.Add(1)
+	defer sl.writes.Add(1)
+
+	head := sl.head.Load()
+	update := make([]*concurrentNode, sl.params.MaxLevel)
+	rank := make([]int64, sl.params.MaxLevel)
+	sl.findPredecessors(head, user, update, rank)
+
+	newLevel := sl.params.randomLevel()
+	level := int(atomic.LoadInt32(&sl.level))
+	if newLevel > level {
+		for i := level + 1; i <= newLevel; i++ {
+			update[i] = head
+			rank[i] = 0
+			head.span[i].Store(atomic.LoadInt64(&sl.length))
+		}
+		if newLevel > sl.maxLevelReached {
+			sl.maxLevelReached = newLevel
+		}
+	}
+
+	// Build the node completely before any reader can reach it
+	node := sl.alloc(newLevel)
+	node.user = *user
+	for i := 0; i <= newLevel; i++ {
+		node.forward[i].Store(update[i].forward[i].Load())
+		node.span[i].Store(update[i].span[i].Load() - (rank[0] - rank[i]))
+	}
+	if update[0] != head {
+		node.backward.Store(update[0])
+	} else {
+		node.backward.Store(nil)
+	}
+	// Publish bottom-up, so a node visible on a level is visible below it
+	for i := 0; i <= newLevel; i++ {
+		update[i].forward[i].Store(node)
+		update[i].span[i].Store(rank[0] - rank[i] + 1)
+		sl.levelCounts[i]++
+	}
+	for i := newLevel + 1; i <= level; i++ {
+		update[i].span[i].Add(1)
+	}
+	if next := node.forward[0].Load(); next != nil {
+		next.backward.Store(node)
+	}
+	if newLevel > level {
+		atomic.StoreInt32(&sl.level, int32(newLevel))
+	}
+
+	sl.nodeMap[user.ID] = node
+	atomic.AddInt64(&sl.length, 1)
+}
+
+// Remove unlinks a user - O(log N)
+func (sl *ConcurrentSkipList) Remove(userID string) bool {
+	sl.assertWriteHeld()
+
+	node, exists := sl.nodeMap[userID]
+	if !exists {
+		return false
+	}
+
+	sl.writes.Add(1)
+	defer sl.writes.Add(1)
+
+	head := sl.head.Load()
+	level := int(atomic.LoadInt32(&sl.level))
+	update := make([]*concurrentNode, sl.params.MaxLevel)
+	sl.findPredecessors(head, &node.user, update, nil)
+
+	// Step over nodes that compare equal to reach this exact node
+	current := update[0].forward[0].Load()
+	for current != nil && current != node && compare(&current.user, &node.user) == 0 {
+		for i := level; i >= 0; i-- {
+			if update[i].forward[i].Load() == current {
+				update[i] = current
+			}
+		}
+		current = current.forward[0].Load()
+	}
+	if current != node {
+		current = head
+		for i := level; i >= 0; i-- {
+			for next := current.forward[i].Load(); next != nil && next != node; next = current.forward[i].Load() {
+				current = next
+			}
+			update[i] = current
+		}
+	}
+
+	// Unlink top-down; node keeps its own links for readers standing on it.
+	// Links passing over it get one shorter.
+	for i := level; i >= 0; i-- {
+		if i < len(node.forward) && update[i].forward[i].Load() == node {
+			update[i].span[i].Add(node.span[i].Load() - 1)
+			update[i].forward[i].Store(node.forward[i].Load())
+		} else {
+			update[i].span[i].Add(-1)
+		}
+	}
+	for i := range node.forward {
+		sl.levelCounts[i]--
+	}
+	if next := node.forward[0].Load(); next != nil {
+		next.backward.Store(node.backward.Load())
+	}
+	for level > 0 && head.forward[level].Load() == nil {
+		level--
+	}
+	atomic.StoreInt32(&sl.level, int32(level))
+
+	delete(sl.nodeMap, userID)
+	atomic.AddInt64(&sl.length, -1)
+	sl.retire(node)
+	return true
+}
+
+// retire queues an unlinked node for reuse and starts a new epoch, so
+// readers arriving from now on are known not to hold it
+func (sl *ConcurrentSkipList) retire(node *concurrentNode) {
+	sl.retired = append(sl.retired, retiredNode{node: node, epoch: sl.epochs.current()})
+	sl.epochs.advance()
+
+	if len(sl.retired) >= reclaimBatch {
+		sl.reclaim()
+	}
+}
+
+// reclaim moves retired nodes no pinned reader can reach to the free lists
+func (sl *ConcurrentSkipList) reclaim() {
+	safe := sl.epochs.safeBefore()
+	kept := sl.retired[:0]
+	for _, r := range sl.retired {
+		if r.epoch >= safe {
+			kept = append(kept, r)
+			continue
+		}
+		sl.reclaimed++
+		if sl.freeLen < maxFreeNodes {
+			height := len(r.node.forward) - 1
+			sl.free[height] = append(sl.free[height], r.node)
+			sl.freeLen++
+		}
+	}
+	for i := len(kept); i < len(sl.retired); i++ {
+		sl.retired[i] = retiredNode{}
+	}
+	sl.retired = kept
+}
+
+// GetTopN returns top N users starting from offset without taking any lock
+func (sl *ConcurrentSkipList) GetTopN(limit, offset int) []*models.User {
+	slot := sl.epochs.pin()
+	defer sl.epochs.unpin(slot)
+
+	current := sl.seek(offset)
+
+	result := make([]*models.User, 0, limit)
+	for i := 0; i < limit && current != nil; i++ {
+		userCopy := current.user
+		result = append(result, &userCopy)
+		current = current.forward[0].Load()
+	}
+	return result
+}
+
+// VisitTopN passes up to limit users from offset to visit without copying
+// them. Removed nodes aren't reused while the walk is pinned, so visit sees
+// each user whole even if it was removed meanwhile.
+func (sl *ConcurrentSkipList) VisitTopN(limit, offset int, visit func(user *models.User) bool) {
+	slot := sl.epochs.pin()
+	defer sl.epochs.unpin(slot)
+
+	current := sl.seek(offset)
+	for i := 0; i < limit && current != nil && visit(&current.user); i++ {
+		current = current.forward[0].Load()
+	}
+}
+
+// seek returns the node at offset (0 for the first), or nil past the end.
+// The caller must be pinned. A span seek is O(log N); it is only trusted if
+// no write overlapped it.
+func (sl *ConcurrentSkipList) seek(offset int) *concurrentNode {
+	for attempt := 0; attempt < seekAttempts; attempt++ {
+		before := sl.writes.Load()
+		if before%2 == 1 {
+			continue
+		}
+		head := sl.head.Load()
+		if int64(offset) >= atomic.LoadInt64(&sl.length) {
+			return nil
+		}
+		current := head
+		var traversed int64
+		for i := int(atomic.LoadInt32(&sl.level)); i >= 0; i-- {
+			for next := current.forward[i].Load(); next != nil && traversed+current.span[i].Load() <= int64(offset)+1; next = current.forward[i].Load() {
+				traversed += current.span[i].Load()
+				current = next
+			}
+		}
+		if sl.writes.Load() == before && current != head {
+			return current
+		}
+	}
+
+	current := sl.head.Load().forward[0].Load()
+	for i := 0; i < offset && current != nil; i++ {
+		current = current.forward[0].Load()
+	}
+	return current
+}
+
+// Position returns the 1-based place of a user in leaderboard order; the
+// owner's lock must be held
+func (sl *ConcurrentSkipList) Position(userID string) (int, bool) {
+	sl.assertReadHeld()
+
+	node, exists := sl.nodeMap[userID]
+	if !exists {
+		return 0, false
+	}
+
+	level := int(atomic.LoadInt32(&sl.level))
+	current := sl.head.Load()
+	var position int64
+	for i := level; i >= 0; i-- {
+		for next := current.forward[i].Load(); next != nil && compare(&next.user, &node.user) > 0; next = current.forward[i].Load() {
+			position += current.span[i].Load()
+			current = next
+		}
+	}
+	for current != node {
+		current = current.forward[0].Load()
+		position++
+	}
+	return int(position), true
+}
+
+// GetTopNFiltered returns up to limit users that satisfy keep, skipping the
+// first offset matches, without taking any lock. The walk stops early once
+// ctx ends.
+func (sl *ConcurrentSkipList) GetTopNFiltered(ctx context.Context, limit, offset int, keep func(user *models.User) bool) ([]*models.User, error) {
+	slot := sl.epochs.pin()
+	defer sl.epochs.unpin(slot)
+
+	result := make([]*models.User, 0, limit)
+	skipped := 0
+	examined := 0
+	for current := sl.head.Load().forward[0].Load(); current != nil && len(result) < limit; current = current.forward[0].Load() {
+		if examined++; examined%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		userCopy := current.user
+		if !keep(&userCopy) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		result = append(result, &userCopy)
+	}
+	return result, nil
+}
+
+// After returns up to limit users ranked strictly after the position of
+// after that pass keep (all users if keep is nil), seeking to the start in
+// O(log N). Like the page reads it needs no lock.
+func (sl *ConcurrentSkipList) After(ctx context.Context, after *models.User, limit int, keep func(user *models.User) bool) ([]*models.User, error) {
+	slot := sl.epochs.pin()
+	defer sl.epochs.unpin(slot)
+
+	current := sl.head.Load()
+	for i := int(atomic.LoadInt32(&sl.level)); i >= 0; i-- {
+		for next := current.forward[i].Load(); next != nil && compare(&next.user, after) >= 0; next = current.forward[i].Load() {
+			current = next
+		}
+	}
+
+	result := make([]*models.User, 0, limit)
+	examined := 0
+	for current = current.forward[0].Load(); current != nil && len(result) < limit; current = current.forward[0].Load() {
+		if examined++; examined%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		userCopy := current.user
+		if keep != nil && !keep(&userCopy) {
+			continue
+		}
+		result = append(result, &userCopy)
+	}
+	return result, nil
+}
+
+// Around returns the user with up to above users ranked just ahead of them
+// and up to below just behind, in leaderboard order, and the user's index
+// in that slice, or -1 if they aren't listed. Unlike the page reads it
+// follows backward links, which are only consistent under the owner's lock.
+func (sl *ConcurrentSkipList) Around(userID string, above, below int) ([]*models.User, int) {
+	sl.assertReadHeld()
+
+	node, exists := sl.nodeMap[userID]
+	if !exists {
+		return nil, -1
+	}
+
+	first := node
+	for i := 0; i < above; i++ {
+		previous := first.backward.Load()
+		if previous == nil {
+			break
+		}
+		first = previous
+	}
+	result := make([]*models.User, 0, above+1+below)
+	index := -1
+	for current, after := first, -1; current != nil && after < below; current = current.forward[0].Load() {
+		if current == node {
+			index = len(result)
+		}
+		if index >= 0 {
+			after++
+		}
+		userCopy := current.user
+		result = append(result, &userCopy)
+	}
+	return result, index
+}
+
+// Length returns the number of elements without taking any lock
+func (sl *ConcurrentSkipList) Length() int {
+	return int(atomic.LoadInt64(&sl.length))
+}
+
+// Contains checks if a user exists; the owner's lock must be held
+func (sl *ConcurrentSkipList) Contains(userID string) bool {
+	sl.assertReadHeld()
+	_, exists := sl.nodeMap[userID]
+	return exists
+}
+
+// Clear removes all elements. Readers still walking the old list finish on
+// it; its nodes are left to the garbage collector rather than reused.
+func (sl *ConcurrentSkipList) Clear() {
+	sl.assertWriteHeld()
+
+	sl.writes.Add(1)
+	defer sl.writes.Add(1)
+
+	sl.head.Store(newConcurrentNode(sl.params.MaxLevel - 1))
+	atomic.StoreInt32(&sl.level, 0)
+	atomic.StoreInt64(&sl.length, 0)
+	sl.nodeMap = make(map[string]*concurrentNode)
+	sl.levelCounts = [MaxLevel]int{}
+	sl.retired = nil
+	sl.free = [MaxLevel][]*concurrentNode{}
+	sl.freeLen = 0
+}
+
+// GetAllUserIDs returns all user IDs; the owner's lock must be held
+func (sl *ConcurrentSkipList) GetAllUserIDs() []string {
+	sl.assertReadHeld()
+
+	ids := make([]string, 0, len(sl.nodeMap))
+	for id := range sl.nodeMap {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// GetStats returns the same diagnostics as SkipList plus reclamation
+// counters; the owner's lock must be held
+func (sl *ConcurrentSkipList) GetStats() map[string]interface{} {
+	sl.assertReadHeld()
+
+	level := int(atomic.LoadInt32(&sl.level))
+	nodesPerLevel := make([]int, 0, level+1)
+	for i := 0; i <= level; i++ {
+		nodesPerLevel = append(nodesPerLevel, sl.levelCounts[i])
+	}
+
+	avgSearchDepth := 0.0
+	if sl.searches > 0 {
+		avgSearchDepth = float64(sl.searchSteps) / float64(sl.searches)
+	}
+
+	return map[string]interface{}{
+		"implementation":    SkipListConcurrent,
+		"length":            sl.Length(),
+		"current_level":     level,
+		"max_level_reached": sl.maxLevelReached,
+		"max_level":         sl.params.MaxLevel,
+		"probability":       sl.params.Probability,
+		"nodes_per_level":   nodesPerLevel,
+		"searches":          sl.searches,
+		"avg_search_depth":  avgSearchDepth,
+		"reclamation": map[string]interface{}{
+			"epoch":          sl.epochs.current(),
+			"active_readers": sl.epochs.activeReaders(),
+			"retired":        len(sl.retired),
+			"reclaimed":      sl.reclaimed,
+			"free_nodes":     sl.freeLen,
+			"reused":         sl.reused,
+		},
+	}
+}
+
+func (sl *ConcurrentSkipList) assertWriteHeld() {
+	assertGuardWriteHeld(sl.guard)
+}
+
+func (sl *ConcurrentSkipList) assertReadHeld() {
+	assertGuardReadHeld(sl.guard)
+}
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"context"
+
+	"leaderboard-backend/models"
+)
+
+// GetUsersAfterContext returns up to limit users ranked after the position
+// (rating, username) in leaderboard order that pass keep, or all users if
+// keep is nil. The position need not belong to a current user, so a page
+// boundary stays put while the users around it move.
+func (m *MemoryStore) GetUsersAfterContext(ctx context.Context, rating int, username string, limit int, keep func(user *models.User) bool) ([]*models.User, error) {
+	after := &models.User{Rating: rating, Username: username}
+	if m.skipList.LockFreeReads() {
+		return m.skipList.After(ctx, after, limit, keep)
+	}
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer m.mu.RUnlock()
+
+	return m.skipList.After(ctx, after, limit, keep)
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"fmt"
+	"os"
+)
+
+// SyncPolicy controls when persisted files are flushed to stable storage
+type SyncPolicy string
+
+const (
+	// SyncNever leaves flushing to the OS: fastest, but a crash or power
+	// loss can lose a save that already reported success
+	SyncNever SyncPolicy = "never"
+	// SyncOnSave fsyncs every written file and its directory before a save
+	// reports success
+	SyncOnSave SyncPolicy = "on-save"
+	// SyncWALBatch additionally fsyncs every batch written to the
+	// write-ahead log, bounding loss to the entries not yet flushed
+	SyncWALBatch SyncPolicy = "wal-batch"
+)
+
+// ParseSyncPolicy validates a policy name
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	switch policy := SyncPolicy(name); policy {
+	case SyncNever, SyncOnSave, SyncWALBatch:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown sync policy %q (want %q, %q or %q)", name, SyncNever, SyncOnSave, SyncWALBatch)
+}
+
+// syncDir makes a rename or file creation inside dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"math"
+	"runtime"
+	"sync/atomic"
+)
+
+// epochSlots bounds how many readers can be pinned at once; further readers
+// wait for a free slot
+const epochSlots = 128
+
+// epochSlot records the epoch a reader pinned, or 0 when unused. Slots are
+// padded to a cache line so readers on different cores don't contend.
+type epochSlot struct {
+	epoch uint64
+	_     [56]byte
+}
+
+// epochReclaimer implements epoch-based reclamation. Readers pin the current
+// epoch for the duration of a traversal; a node unlinked during epoch E may
+// only be reused once every pinned reader has an epoch later than E, since
+// only readers that started before the unlink can still reach it.
+type epochReclaimer struct {
+	global uint64 // atomic; starts at 1 so 0 can mean "slot free"
+	slots  [epochSlots]epochSlot
+	next   uint32 // atomic; spreads readers over the slots
+}
+
+func newEpochReclaimer() *epochReclaimer {
+	return &epochReclaimer{global: 1}
+}
+
+// pin announces a reader and returns its slot for unpin
+func (e *epochReclaimer) pin() *epochSlot {
+	start := atomic.AddUint32(&e.next, 1)
+	for {
+		for i := uint32(0); i < epochSlots; i++ {
+			slot := &e.slots[(start+i)%epochSlots]
+			if atomic.CompareAndSwapUint64(&slot.epoch, 0, atomic.LoadUint64(&e.global)) {
+				return slot
+			}
+		}
+		runtime.Gosched()
+	}
+}
+
+func (e *epochReclaimer) unpin(slot *epochSlot) {
+	atomic.StoreUint64(&slot.epoch, 0)
+}
+
+// current returns the epoch retirements are recorded under
+func (e *epochReclaimer) current() uint64 {
+	return atomic.LoadUint64(&e.global)
+}
+
+// advance starts a new epoch; called by the writer after retiring nodes
+func (e *epochReclaimer) advance() {
+	atomic.AddUint64(&e.global, 1)
+}
+
+// safeBefore returns the oldest epoch still pinned by a reader. Nodes
+// retired in an earlier epoch are unreachable.
+func (e *epochReclaimer) safeBefore() uint64 {
+	oldest := uint64(math.MaxUint64)
+	for i := range e.slots {
+		if epoch := atomic.LoadUint64(&e.slots[i].epoch); epoch != 0 && epoch < oldest {
+			oldest = epoch
+		}
+	}
+	return oldest
+}
+
+// activeReaders counts pinned readers
+func (e *epochReclaimer) activeReaders() int {
+	active := 0
+	for i := range e.slots {
+		if atomic.LoadUint64(&e.slots[i].epoch) != 0 {
+			active++
+		}
+	}
+	return active
+}
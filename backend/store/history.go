@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultRatingHistory is how many rating changes are kept per user
+const DefaultRatingHistory = 100
+
+// RatingChange is one entry of a user's rating history
+type RatingChange struct {
+	Rating    int
+	OldRating int
+	Source    Source
+	At        time.Time
+}
+
+// ratingHistory is one user's latest rating changes. It grows up to the
+// store's history size and then wraps, so users who rarely change cost only
+// what they use. It is not synchronized; MemoryStore guards it with mu.
+type ratingHistory struct {
+	entries []RatingChange
+	next    int // where the next change goes once entries is full
+}
+
+func (h *ratingHistory) record(change RatingChange, size int) {
+	if len(h.entries) < size {
+		h.entries = append(h.entries, change)
+		return
+	}
+	h.entries[h.next] = change
+	h.next = (h.next + 1) % len(h.entries)
+}
+
+// newestFirst copies up to limit changes, newest first
+func (h *ratingHistory) newestFirst(limit int) []RatingChange {
+	n := len(h.entries)
+	if limit > n {
+		limit = n
+	}
+	changes := make([]RatingChange, 0, limit)
+	for i := 0; i < limit; i++ {
+		changes = append(changes, h.entries[(h.next-1-i+n)%n])
+	}
+	return changes
+}
+
+// oldestFirst copies every change, oldest first
+func (h *ratingHistory) oldestFirst() []RatingChange {
+	changes := h.newestFirst(len(h.entries))
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+	return changes
+}
+
+// SetRatingHistorySize sets how many rating changes are kept per user; 0
+// stops recording and forgets what was kept. Histories longer than size
+// are trimmed to their newest changes.
+func (m *MemoryStore) SetRatingHistorySize(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	m.historySize = size
+	if size == 0 {
+		m.history = nil
+		return
+	}
+	for id, h := range m.history {
+		if len(h.entries) > size {
+			kept := h.oldestFirst()
+			m.history[id] = &ratingHistory{entries: kept[len(kept)-size:]}
+		}
+	}
+}
+
+// GetRatingHistorySize returns how many rating changes are kept per user
+func (m *MemoryStore) GetRatingHistorySize() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.historySize
+}
+
+// recordRating appends a rating change to the user's history; the caller
+// holds the write lock
+func (m *MemoryStore) recordRating(userID string, change RatingChange) {
+	if m.historySize == 0 {
+		return
+	}
+	if m.history == nil {
+		m.history = make(map[string]*ratingHistory)
+	}
+	h, ok := m.history[userID]
+	if !ok {
+		h = &ratingHistory{}
+		m.history[userID] = h
+	}
+	h.record(change, m.historySize)
+}
+
+// mergeHistory folds the rating changes of dupeID into those of keepID in
+// the order they happened, keeping the newest history size of them. The
+// caller holds the write lock.
+func (m *MemoryStore) mergeHistory(keepID, dupeID string) {
+	dupe, ok := m.history[dupeID]
+	if !ok || m.historySize == 0 {
+		return
+	}
+	var changes []RatingChange
+	if keep, ok := m.history[keepID]; ok {
+		changes = keep.oldestFirst()
+	}
+	changes = append(changes, dupe.oldestFirst()...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].At.Before(changes[j].At)
+	})
+	if len(changes) > m.historySize {
+		changes = changes[len(changes)-m.historySize:]
+	}
+	m.history[keepID] = &ratingHistory{entries: changes}
+}
+
+// GetRatingHistory returns up to limit of the user's latest rating changes,
+// newest first. Only changes made since the server started are known.
+func (m *MemoryStore) GetRatingHistory(ctx context.Context, id string, limit int) ([]RatingChange, error) {
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer m.mu.RUnlock()
+
+	if _, exists := m.users.get(id); !exists {
+		return nil, fmt.Errorf("user with ID %s not found", id)
+	}
+	h, ok := m.history[id]
+	if !ok {
+		return []RatingChange{}, nil
+	}
+	return h.newestFirst(limit), nil
+}
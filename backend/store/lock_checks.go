@@ -0,0 +1,56 @@
+package store
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// lockChecks turns on ownership assertions for structures that rely on
+// their owner's lock instead of their own
+var lockChecks int32
+
+// SetLockChecks enables or disables lock ownership assertions. They cost an
+// extra try-lock per call and are meant for tests and debugging.
+func SetLockChecks(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&lockChecks, v)
+}
+
+func lockChecksEnabled() bool {
+	return atomic.LoadInt32(&lockChecks) == 1
+}
+
+// assertGuardWriteHeld panics if the owner's lock is not write-locked. When
+// the lock is free or only read-locked, a read try-lock succeeds.
+func assertGuardWriteHeld(guard *sync.RWMutex) {
+	if guard == nil || !lockChecksEnabled() {
+		return
+	}
+	if guard.TryRLock() {
+		guard.RUnlock()
+		panic("store: skip list modified without holding its owner's write lock")
+	}
+}
+
+// assertGuardReadHeld panics if the owner's lock is not held at all. Any
+// holder, reader or writer, makes a write try-lock fail.
+func assertGuardReadHeld(guard *sync.RWMutex) {
+	if guard == nil || !lockChecksEnabled() {
+		return
+	}
+	if guard.TryLock() {
+		guard.Unlock()
+		panic("store: skip list read without holding its owner's lock")
+	}
+}
+
+func (sl *SkipList) assertWriteHeld() {
+	assertGuardWriteHeld(sl.guard)
+}
+
+func (sl *SkipList) assertReadHeld() {
+	assertGuardReadHeld(sl.guard)
+}
@@ -0,0 +1,22 @@
+package store
+
+// OnMembershipChange registers fn to run whenever users join or leave the
+// board: additions, loads, deletions, merges and Clear. It is meant for
+// caches of user IDs, like the simulator's, that would otherwise go stale
+// until their next refresh. fn runs with the store's write lock held, once
+// per change or bulk load, so it must be quick and must not call back into
+// the store; marking the cache stale is the intended use.
+func (m *MemoryStore) OnMembershipChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.membershipHooks = append(m.membershipHooks, fn)
+}
+
+// membershipChanged runs the OnMembershipChange hooks; the caller holds the
+// write lock
+func (m *MemoryStore) membershipChanged() {
+	for _, fn := range m.membershipHooks {
+		fn()
+	}
+}
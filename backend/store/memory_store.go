@@ -1,11 +1,15 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"leaderboard-backend/models"
+	"leaderboard-backend/tracing"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -14,40 +18,186 @@ const (
 
 type MemoryStore struct {
 	mu          sync.RWMutex
-	users       map[string]*models.User // id -> user
+	users       *userStripes        // id -> user, striped; see userStripes for locking
 	usersByName map[string][]string     // username prefix -> user ids (for search)
 	ratingIndex *RatingBucketIndex
-	skipList    *SkipList // O(log N) sorted user list
+	skipList    RankedList // O(log N) sorted user list, guarded by mu
+	mutations   uint64    // atomic count of state changes, used by autosave
+	rankSeq     uint64    // atomic; odd while a ranking write is in progress
+	recent      recentRing
+	bySource    [len(sources)]uint64 // atomic count of rating changes per Source
+	searchLimits SearchLimits // work allowed per search or suggestion query
+	strictRatings   uint32 // atomic; 1 rejects out-of-range ratings instead of clamping
+	ratingsClamped  uint64 // atomic count of ratings pulled into range
+	ratingsRejected uint64 // atomic count of ratings rejected as out of range
+	merged      map[string]string // merged user ID -> surviving ID, guarded by mu
+	bots        int64 // atomic count of users flagged as bots
+	journal     *WAL // optional write-ahead log of mutations
+	changes     []chan<- Change // optional feeds of mutations; see AddChangeFeed
+	membershipHooks []func() // run when users join or leave; see OnMembershipChange
+	history     map[string]*ratingHistory // user ID -> latest rating changes, guarded by mu
+	historySize int // rating changes kept per user (0 = none)
+	warmup      indexWarmup // deferred index building; see DeferIndexes
 }
 
 func NewMemoryStore(ratingIndex *RatingBucketIndex) *MemoryStore {
-	return &MemoryStore{
-		users:       make(map[string]*models.User),
+	return NewMemoryStoreWithSkipList(ratingIndex, SkipListLocked)
+}
+
+// NewMemoryStoreWithSkipList creates a store ranked by the chosen skip list
+// implementation
+func NewMemoryStoreWithSkipList(ratingIndex *RatingBucketIndex, impl SkipListImpl) *MemoryStore {
+	return NewMemoryStoreWithSkipListParams(ratingIndex, impl, DefaultSkipListParams())
+}
+
+// NewMemoryStoreWithSkipListParams is NewMemoryStoreWithSkipList with the
+// skip list shaped by params
+func NewMemoryStoreWithSkipListParams(ratingIndex *RatingBucketIndex, impl SkipListImpl, params SkipListParams) *MemoryStore {
+	m := &MemoryStore{
+		users:       newUserStripes(),
 		usersByName: make(map[string][]string),
-		ratingIndex: ratingIndex,
-		skipList:    NewSkipList(),
+		ratingIndex:  ratingIndex,
+		searchLimits: DefaultSearchLimits,
+		historySize:  DefaultRatingHistory,
 	}
+	// The ranked list is protected by m.mu rather than a lock of its own
+	switch impl {
+	case SkipListConcurrent:
+		m.skipList = NewConcurrentSkipListWithParams(&m.mu, params)
+	case SkipListBTree:
+		m.skipList = NewGuardedBTree(&m.mu)
+	default:
+		m.skipList = NewGuardedSkipListWithParams(&m.mu, params)
+	}
+	return m
 }
 
-func (m *MemoryStore) AddUser(user *models.User) error {
+// lockRanking takes the write lock for a change to users or their ranking.
+// rankSeq is odd until unlockRanking, so readers that skip the lock can tell
+// whether a write overlapped them.
+func (m *MemoryStore) lockRanking() {
+	m.mu.Lock()
+	atomic.AddUint64(&m.rankSeq, 1)
+}
+
+func (m *MemoryStore) unlockRanking() {
+	atomic.AddUint64(&m.rankSeq, 1)
+	m.mu.Unlock()
+}
+
+// GetRankingSeq returns a sequence number that changes around every write.
+// A read that starts and ends with the same even value saw no write.
+func (m *MemoryStore) GetRankingSeq() uint64 {
+	return atomic.LoadUint64(&m.rankSeq)
+}
+
+// ctxCheckInterval is how many items a scan handles between checks of its
+// request context
+const ctxCheckInterval = 256
+
+// rlockContext takes the read lock unless ctx has already ended. A request
+// abandoned while waiting behind a writer gets the lock released again and
+// an error instead of doing the work.
+func (m *MemoryStore) rlockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	if err := ctx.Err(); err != nil {
+		m.mu.RUnlock()
+		return err
+	}
+	return nil
+}
+
+// SetJournal records every later mutation in the write-ahead log. Attach it
+// only after the store has been loaded and recovered.
+func (m *MemoryStore) SetJournal(w *WAL) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.users[user.ID]; exists {
+	m.journal = w
+}
+
+// journalSet logs a user's current state; the caller holds the write lock
+func (m *MemoryStore) journalSet(user *models.User, source Source) {
+	if m.journal != nil {
+		m.journal.append(walEntry{Op: walSet, ID: user.ID, Username: user.Username, Rating: user.Rating, Source: source, Bot: user.Bot,
+			RD: user.RD, Volatility: user.Volatility})
+	}
+}
+
+// recordChange notes a rating change in the activity feed and the per-source
+// counts; the caller holds the write lock
+func (m *MemoryStore) recordChange(user *models.User, now time.Time, source Source) {
+	m.recent.record(user.ID, now, source)
+	atomic.AddUint64(&m.bySource[source.index()], 1)
+}
+
+func (m *MemoryStore) journalDelete(id string) {
+	if m.journal != nil {
+		m.journal.append(walEntry{Op: walDelete, ID: id})
+	}
+}
+
+func (m *MemoryStore) AddUser(user *models.User) error {
+	return m.AddUserFrom(user, SourceAPI)
+}
+
+// AddUserFrom adds a user whose initial rating came from source
+func (m *MemoryStore) AddUserFrom(user *models.User, source Source) error {
+	return m.AddUserContext(context.Background(), user, source)
+}
+
+// AddUserContext is AddUserFrom for a request that may be abandoned; the
+// request's trace is carried on the published change
+func (m *MemoryStore) AddUserContext(ctx context.Context, user *models.User, source Source) error {
+	source = source.orDefault()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer addUserLatency.ObserveSince(time.Now())
+	rating, err := m.CheckRating(user.Rating)
+	if err != nil {
+		return err
+	}
+	user.Rating = rating
+
+	unlock := m.users.lock(user.ID)
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	if _, exists := m.users.get(user.ID); exists {
 		return fmt.Errorf("user with ID %s already exists", user.ID)
 	}
+	if into, merged := m.merged[user.ID]; merged {
+		return fmt.Errorf("user with ID %s: %w %s", user.ID, ErrMerged, into)
+	}
 
-	m.users[user.ID] = user
+	m.users.set(user)
 	m.indexUsername(user.ID, user.Username)
+	if user.Bot {
+		atomic.AddInt64(&m.bots, 1)
+	}
 	m.ratingIndex.IncrementBucket(user.Rating)
 
 	// Insert into skip list - O(log N)
 	m.skipList.Insert(user)
+	now := time.Now()
+	m.recordChange(user, now, source)
+	atomic.AddUint64(&m.mutations, 1)
+	m.journalSet(user, source)
+	m.publishUser(ChangeAdded, user, now, source, tracing.TraceparentFrom(ctx))
+	m.membershipChanged()
 
 	return nil
 }
 
 func (m *MemoryStore) indexUsername(userID, username string) {
+	if m.indexesDeferred() {
+		return
+	}
 	lowerName := strings.ToLower(username)
 	maxLen := len(lowerName)
 	if maxLen > MaxPrefixLength {
@@ -64,6 +214,9 @@ func (m *MemoryStore) indexUsername(userID, username string) {
 }
 
 func (m *MemoryStore) removeUsernameIndex(userID, username string) {
+	if m.indexesDeferred() {
+		return
+	}
 	lowerName := strings.ToLower(username)
 	maxLen := len(lowerName)
 	if maxLen > MaxPrefixLength {
@@ -91,11 +244,23 @@ func (m *MemoryStore) removeUsernameIndex(userID, username string) {
 	}
 }
 
+// GetUser takes only the user's stripe lock, so lookups don't contend with
+// rating changes of other users
 func (m *MemoryStore) GetUser(id string) (*models.User, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	return m.GetUserContext(context.Background(), id)
+}
 
-	user, exists := m.users[id]
+// GetUserContext is GetUser for a request that may be abandoned
+func (m *MemoryStore) GetUserContext(ctx context.Context, id string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stripe := m.users.stripe(id)
+	stripe.mu.RLock()
+	defer stripe.mu.RUnlock()
+
+	user, exists := stripe.users[id]
 	if !exists {
 		return nil, fmt.Errorf("user with ID %s not found", id)
 	}
@@ -104,38 +269,305 @@ func (m *MemoryStore) GetUser(id string) (*models.User, error) {
 	return &userCopy, nil
 }
 
+// GetUserByUsername finds a user by exact, case-insensitive username using the
+// prefix index. Usernames are not unique; if several users share the name the
+// highest-rated one is returned.
+func (m *MemoryStore) GetUserByUsername(username string) (*models.User, error) {
+	return m.GetUserByUsernameContext(context.Background(), username)
+}
+
+// GetUserByUsernameContext is GetUserByUsername for a request that may be
+// abandoned
+func (m *MemoryStore) GetUserByUsernameContext(ctx context.Context, username string) (*models.User, error) {
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer m.mu.RUnlock()
+
+	lowerName := strings.ToLower(strings.TrimSpace(username))
+	if lowerName == "" {
+		return nil, fmt.Errorf("username must not be empty")
+	}
+
+	// Names up to MaxPrefixLength are indexed under their own prefix key;
+	// longer names have a dedicated full-name entry
+	var best *models.User
+	for _, id := range m.usersByName[lowerName] {
+		user, exists := m.users.get(id)
+		if !exists || strings.ToLower(user.Username) != lowerName {
+			continue
+		}
+		if best == nil || compare(user, best) > 0 {
+			best = user
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("user with username %s not found", username)
+	}
+
+	userCopy := *best
+	return &userCopy, nil
+}
+
+// GetUsers looks up many users under a single read lock. Users are returned in
+// request order; IDs that don't exist are returned separately.
+func (m *MemoryStore) GetUsers(ids []string) ([]*models.User, []string) {
+	users, missing, _ := m.GetUsersContext(context.Background(), ids)
+	return users, missing
+}
+
+// GetUsersContext is GetUsers for a request that may be abandoned
+func (m *MemoryStore) GetUsersContext(ctx context.Context, ids []string) ([]*models.User, []string, error) {
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	defer m.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(ids))
+	missing := make([]string, 0)
+	for i, id := range ids {
+		if i%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		user, exists := m.users.get(id)
+		if !exists {
+			missing = append(missing, id)
+			continue
+		}
+		userCopy := *user
+		users = append(users, &userCopy)
+	}
+	return users, missing, nil
+}
+
+// UpdateRating changes one user's rating. The user is looked up under its
+// stripe lock; the store lock is only taken when the ranking structures
+// actually change.
 func (m *MemoryStore) UpdateRating(id string, newRating int) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return m.UpdateRatingContext(context.Background(), id, newRating)
+}
+
+// UpdateRatingContext is UpdateRating for a request that may be abandoned.
+// Once the update is under way it completes; cancellation only prevents it
+// from starting.
+func (m *MemoryStore) UpdateRatingContext(ctx context.Context, id string, newRating int) error {
+	return m.UpdateRatingFrom(ctx, id, newRating, SourceAPI)
+}
 
-	user, exists := m.users[id]
+// UpdateRatingFrom is UpdateRatingContext for a change from source
+func (m *MemoryStore) UpdateRatingFrom(ctx context.Context, id string, newRating int, source Source) error {
+	source = source.orDefault()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	defer updateRatingLatency.ObserveSince(time.Now())
+	newRating, err := m.CheckRating(newRating)
+	if err != nil {
+		return err
+	}
+
+	unlock := m.users.lock(id)
+	defer unlock()
+
+	user, exists := m.users.get(id)
 	if !exists {
 		return fmt.Errorf("user with ID %s not found", id)
 	}
+	if user.Rating == newRating {
+		return nil
+	}
+
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	m.applyRating(user, newRating, time.Now(), source, tracing.TraceparentFrom(ctx))
+	return nil
+}
+
+// RatingUpdate is one entry of a batched rating change
+type RatingUpdate struct {
+	ID     string
+	Rating int
+	Source Source // defaults to SourceAPI
+}
+
+// UpdateRatings applies many rating changes with a single acquisition of the
+// store lock for all ranking-structure mutations. The returned map holds an
+// error for every ID that does not exist or, in strict mode, whose rating
+// is out of range.
+func (m *MemoryStore) UpdateRatings(updates []RatingUpdate) map[string]error {
+	ids := make([]string, len(updates))
+	for i, update := range updates {
+		ids[i] = update.ID
+	}
+	unlock := m.users.lock(ids...)
+	defer unlock()
+
+	failed := make(map[string]error)
+	valid := make([]RatingUpdate, 0, len(updates))
+	anyChange := false
+	for _, update := range updates {
+		user, exists := m.users.get(update.ID)
+		if !exists {
+			failed[update.ID] = fmt.Errorf("user with ID %s not found", update.ID)
+			continue
+		}
+		rating, err := m.CheckRating(update.Rating)
+		if err != nil {
+			failed[update.ID] = err
+			continue
+		}
+		update.Rating = rating
+		// Every valid update is kept: a user listed twice may end on their
+		// current rating only after passing through another
+		valid = append(valid, update)
+		anyChange = anyChange || user.Rating != update.Rating
+	}
+	if !anyChange {
+		return failed
+	}
+
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	now := time.Now()
+	for _, update := range valid {
+		user, _ := m.users.get(update.ID)
+		if user.Rating != update.Rating {
+			m.applyRating(user, update.Rating, now, update.Source.orDefault(), "")
+		}
+	}
+	return failed
+}
 
+// applyRating moves a user within the ranking structures. The caller holds
+// the user's stripe lock and the store write lock.
+func (m *MemoryStore) applyRating(user *models.User, newRating int, now time.Time, source Source, trace string) {
 	oldRating := user.Rating
-	if oldRating != newRating {
-		
-		m.skipList.Remove(id)
+	oldRank := 0
+	if m.publishing() {
+		oldRank = m.ratingIndex.GetRank(oldRating)
+	}
+
+	m.skipList.Remove(user.ID)
 
-		user.Rating = newRating
-		m.ratingIndex.UpdateRating(oldRating, newRating)
+	user.Rating = newRating
+	m.ratingIndex.UpdateRating(oldRating, newRating)
 
-		m.skipList.Insert(user)
+	m.skipList.Insert(user)
+	m.recordChange(user, now, source)
+	m.recordRating(user.ID, RatingChange{Rating: newRating, OldRating: oldRating, Source: source, At: now})
+	atomic.AddUint64(&m.mutations, 1)
+	m.journalSet(user, source)
+	if m.publishing() {
+		m.publish(Change{Type: ChangeRating, User: *user, Rank: m.ratingIndex.GetRank(newRating),
+			OldRating: oldRating, OldRank: oldRank, TotalUsers: m.ratingIndex.GetTotalUsers(), Source: source, Trace: trace, At: now})
 	}
+}
+
+// DeleteUser removes a user from every index (user map, username prefixes,
+// skip list and rating buckets) under a single write lock
+func (m *MemoryStore) DeleteUser(id string) error {
+	return m.DeleteUserContext(context.Background(), id)
+}
+
+// DeleteUserContext is DeleteUser for a request that may be abandoned; the
+// request's trace is carried on the published change
+func (m *MemoryStore) DeleteUserContext(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := m.users.lock(id)
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	user, exists := m.users.get(id)
+	if !exists {
+		return fmt.Errorf("user with ID %s not found", id)
+	}
+
+	m.removeUser(user)
+	m.ratingIndex.DecrementBucket(user.Rating)
+	atomic.AddUint64(&m.mutations, 1)
+	m.journalDelete(id)
+	m.publishUser(ChangeRemoved, user, time.Now(), "", tracing.TraceparentFrom(ctx))
 
 	return nil
 }
 
+// DeleteUsers removes a batch of users under one write lock and updates the
+// rating index once for the whole batch. The returned map holds an error for
+// every ID that could not be removed.
+func (m *MemoryStore) DeleteUsers(ids []string) map[string]error {
+	unlock := m.users.lock(ids...)
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	failed := make(map[string]error)
+	removedRatings := make([]int, 0, len(ids))
+	now := time.Now()
+	for _, id := range ids {
+		user, exists := m.users.get(id)
+		if !exists {
+			failed[id] = fmt.Errorf("user with ID %s not found", id)
+			continue
+		}
+		m.removeUser(user)
+		removedRatings = append(removedRatings, user.Rating)
+		m.journalDelete(id)
+		m.publishUser(ChangeRemoved, user, now, "", "")
+	}
+
+	if len(removedRatings) > 0 {
+		m.ratingIndex.DecrementBuckets(removedRatings)
+		atomic.AddUint64(&m.mutations, uint64(len(removedRatings)))
+	}
+
+	return failed
+}
+
+// removeUser drops a user from the map, username index and skip list.
+// The caller must hold the user's stripe lock and the store write lock, and
+// update the rating index.
+func (m *MemoryStore) removeUser(user *models.User) {
+	m.skipList.Remove(user.ID)
+	m.removeUsernameIndex(user.ID, user.Username)
+	m.users.del(user.ID)
+	delete(m.history, user.ID)
+	if user.Bot {
+		atomic.AddInt64(&m.bots, -1)
+	}
+	m.membershipChanged()
+}
+
 func (m *MemoryStore) GetAllUsers() []*models.User {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	users := make([]*models.User, 0, len(m.users))
-	for _, user := range m.users {
+	users := make([]*models.User, 0, m.users.len())
+	m.users.each(func(user *models.User) bool {
 		userCopy := *user
 		users = append(users, &userCopy)
-	}
+		return true
+	})
+	return users
+}
+
+// Snapshot copies every user into a single contiguous slice. The read lock is
+// held only for the copy, so callers can marshal and write the result without
+// blocking writers.
+func (m *MemoryStore) Snapshot() []models.User {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]models.User, 0, m.users.len())
+	m.users.each(func(user *models.User) bool {
+		users = append(users, *user)
+		return true
+	})
 	return users
 }
 
@@ -144,32 +576,43 @@ func (m *MemoryStore) GetUsersByRating(rating int) []*models.User {
 	defer m.mu.RUnlock()
 
 	users := make([]*models.User, 0)
-	for _, user := range m.users {
+	m.users.each(func(user *models.User) bool {
 		if user.Rating == rating {
 			userCopy := *user
 			users = append(users, &userCopy)
 		}
-	}
+		return true
+	})
 	return users
 }
 
 func (m *MemoryStore) GetUserCount() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return len(m.users)
+	return m.users.len()
 }
 
 func (m *MemoryStore) SearchUsers(query string) []*models.User {
-	m.mu.RLock()
+	users, _, _ := m.SearchUsersContext(context.Background(), query)
+	return users
+}
+
+// SearchUsersContext is SearchUsers for a request that may be abandoned;
+// the scan over matching users stops once ctx ends. truncated reports that
+// the search limits cut the scan short, so better matches may exist.
+func (m *MemoryStore) SearchUsersContext(ctx context.Context, query string) (users []*models.User, truncated bool, err error) {
+	defer searchUsersLatency.ObserveSince(time.Now())
+
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, false, err
+	}
 	defer m.mu.RUnlock()
 
 	if query == "" {
-		return []*models.User{}
+		return []*models.User{}, false, nil
 	}
 
 	lowerQuery := strings.ToLower(strings.TrimSpace(query))
 	if lowerQuery == "" {
-		return []*models.User{}
+		return []*models.User{}, false, nil
 	}
 
 	lookupKey := lowerQuery
@@ -179,15 +622,24 @@ func (m *MemoryStore) SearchUsers(query string) []*models.User {
 
 	userIDs := m.usersByName[lookupKey]
 	seen := make(map[string]bool)
-	users := make([]*models.User, 0)
+	users = make([]*models.User, 0)
+	budget := newSearchBudget(m.searchLimits)
 
-	for _, id := range userIDs {
+	for i, id := range userIDs {
+		if i%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if budget.exhausted(i) {
+			truncated = true
+			searchTruncatedTotal.Inc()
+			break
+		}
 		if seen[id] {
 			continue
 		}
 		seen[id] = true
 
-		if user, exists := m.users[id]; exists {
+		if user, exists := m.users.get(id); exists {
 			if strings.Contains(strings.ToLower(user.Username), lowerQuery) {
 				userCopy := *user
 				users = append(users, &userCopy)
@@ -206,46 +658,263 @@ func (m *MemoryStore) SearchUsers(query string) []*models.User {
 		users = users[:maxSearchResults]
 	}
 
-	return users
+	return users, truncated, nil
+}
+
+// SuggestUsernames returns up to limit usernames starting with prefix, highest
+// rated first. It reads only the username and rating of each candidate from
+// the prefix index and keeps a bounded selection, so no user records are copied.
+func (m *MemoryStore) SuggestUsernames(prefix string, limit int) []models.Suggestion {
+	suggestions, _, _ := m.SuggestUsernamesContext(context.Background(), prefix, limit)
+	return suggestions
+}
+
+// SuggestUsernamesContext is SuggestUsernames for a request that may be
+// abandoned. truncated reports that the search limits cut the scan short.
+func (m *MemoryStore) SuggestUsernamesContext(ctx context.Context, prefix string, limit int) (best []models.Suggestion, truncated bool, err error) {
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, false, err
+	}
+	defer m.mu.RUnlock()
+
+	lowerPrefix := strings.ToLower(strings.TrimSpace(prefix))
+	if lowerPrefix == "" || limit <= 0 {
+		return []models.Suggestion{}, false, nil
+	}
+
+	lookupKey := lowerPrefix
+	if len(lookupKey) > MaxPrefixLength {
+		lookupKey = lowerPrefix[:MaxPrefixLength]
+	}
+
+	// Keep the best `limit` candidates sorted by rating descending; insertion
+	// into this small slice is cheap compared with copying full records
+	best = make([]models.Suggestion, 0, limit)
+	budget := newSearchBudget(m.searchLimits)
+	for i, id := range m.usersByName[lookupKey] {
+		if i%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if budget.exhausted(i) {
+			truncated = true
+			searchTruncatedTotal.Inc()
+			break
+		}
+		user, exists := m.users.get(id)
+		if !exists {
+			continue
+		}
+		if len(best) == limit && user.Rating <= best[limit-1].Rating {
+			continue
+		}
+		if len(user.Username) < len(lowerPrefix) || strings.ToLower(user.Username[:len(lowerPrefix)]) != lowerPrefix {
+			continue
+		}
+
+		pos := sort.Search(len(best), func(i int) bool {
+			return best[i].Rating < user.Rating
+		})
+		if len(best) < limit {
+			best = append(best, models.Suggestion{})
+		}
+		copy(best[pos+1:], best[pos:len(best)-1])
+		best[pos] = models.Suggestion{Username: user.Username, Rating: user.Rating}
+	}
+
+	return best, truncated, nil
 }
 
 // GetTopUsers returns top N users by rating - O(log N + limit) using skip list
 func (m *MemoryStore) GetTopUsers(limit int, offset int) []*models.User {
-	m.mu.RLock()
+	users, _ := m.GetTopUsersContext(context.Background(), limit, offset)
+	return users
+}
+
+// GetTopUsersContext is GetTopUsers for a request that may be abandoned
+func (m *MemoryStore) GetTopUsersContext(ctx context.Context, limit int, offset int) ([]*models.User, error) {
+	defer getTopUsersLatency.ObserveSince(time.Now())
+
+	if m.skipList.LockFreeReads() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return m.skipList.GetTopN(limit, offset), nil
+	}
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
 	defer m.mu.RUnlock()
 
 	// Delegate to skip list - O(log N + limit)
-	return m.skipList.GetTopN(limit, offset)
+	return m.skipList.GetTopN(limit, offset), nil
+}
+
+// VisitTopUsersContext is GetTopUsersContext without copying each user:
+// visit sees them in place while the page is read, so it should only pick
+// out the fields it needs. It must not keep or change the users.
+func (m *MemoryStore) VisitTopUsersContext(ctx context.Context, limit int, offset int, visit func(user *models.User) bool) error {
+	defer getTopUsersLatency.ObserveSince(time.Now())
+
+	if m.skipList.LockFreeReads() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		m.skipList.VisitTopN(limit, offset, visit)
+		return nil
+	}
+	if err := m.rlockContext(ctx); err != nil {
+		return err
+	}
+	defer m.mu.RUnlock()
+
+	m.skipList.VisitTopN(limit, offset, visit)
+	return nil
+}
+
+// Standings is the top of the leaderboard read while writes were held off,
+// so every rank, rating and count in it describes the same moment
+type Standings struct {
+	Users      []*models.User
+	Ranks      []int // competition rank of each user
+	TotalUsers int
+	Version    uint64 // mutation count at the moment of the read
+	TakenAt    time.Time
+}
+
+// GetStandingsContext freezes writes for as long as it takes to read the top
+// n users. Ranks come from the list itself, so ties are ranked exactly as
+// the rest of the board sees them at that instant.
+func (m *MemoryStore) GetStandingsContext(ctx context.Context, n int) (*Standings, error) {
+	// Writers need the write lock, so holding the read lock is the freeze
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer m.mu.RUnlock()
+
+	users := m.skipList.GetTopN(n, 0)
+	ranks := make([]int, len(users))
+	for i, user := range users {
+		if i > 0 && users[i-1].Rating == user.Rating {
+			ranks[i] = ranks[i-1]
+		} else {
+			ranks[i] = i + 1
+		}
+	}
+
+	return &Standings{
+		Users:      users,
+		Ranks:      ranks,
+		TotalUsers: m.skipList.Length(),
+		Version:    atomic.LoadUint64(&m.mutations),
+		TakenAt:    time.Now().UTC(),
+	}, nil
+}
+
+// GetTopUsersFiltered returns the top users matching keep, paginated over
+// the matching users only
+func (m *MemoryStore) GetTopUsersFiltered(limit int, offset int, keep func(user *models.User) bool) []*models.User {
+	users, _ := m.GetTopUsersFilteredContext(context.Background(), limit, offset, keep)
+	return users
+}
+
+// GetTopUsersFilteredContext is GetTopUsersFiltered for a request that may
+// be abandoned; the walk over non-matching users stops once ctx ends
+func (m *MemoryStore) GetTopUsersFilteredContext(ctx context.Context, limit int, offset int, keep func(user *models.User) bool) ([]*models.User, error) {
+	if m.skipList.LockFreeReads() {
+		return m.skipList.GetTopNFiltered(ctx, limit, offset, keep)
+	}
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer m.mu.RUnlock()
+
+	return m.skipList.GetTopNFiltered(ctx, limit, offset, keep)
 }
 
 func (m *MemoryStore) Clear() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	unlock := m.users.lockAll()
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
 
-	m.users = make(map[string]*models.User)
+	m.users.reset()
 	m.usersByName = make(map[string][]string)
 	m.skipList.Clear()
 	m.ratingIndex.Clear()
+	m.recent.clear()
+	m.history = nil
+	m.merged = nil
+	atomic.StoreInt64(&m.bots, 0)
+	atomic.AddUint64(&m.mutations, 1)
+	if m.journal != nil {
+		m.journal.append(walEntry{Op: walClear})
+	}
+	m.publish(Change{Type: ChangeCleared, At: time.Now()})
+	m.membershipChanged()
 }
 
-func (m *MemoryStore) GetRandomUserID() string {
-	m.mu.RLock()
+// GetMutationCount returns the number of state changes since the store was created
+func (m *MemoryStore) GetMutationCount() uint64 {
+	return atomic.LoadUint64(&m.mutations)
+}
+
+// GetRecentlyUpdated returns up to limit distinct users ordered by their most
+// recent change (newest first). Users removed since their change are skipped.
+func (m *MemoryStore) GetRecentlyUpdated(limit int) []RecentUpdate {
+	updates, _ := m.GetRecentlyUpdatedContext(context.Background(), limit, "")
+	return updates
+}
+
+// GetRecentlyUpdatedContext is GetRecentlyUpdated for a request that may be
+// abandoned. A non-empty source only considers changes from that source.
+func (m *MemoryStore) GetRecentlyUpdatedContext(ctx context.Context, limit int, source Source) ([]RecentUpdate, error) {
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
 	defer m.mu.RUnlock()
 
-	for id := range m.users {
-		return id
+	updates := make([]RecentUpdate, 0, limit)
+	seen := make(map[string]bool)
+	m.recent.newestFirst(func(entry recentEntry) bool {
+		if len(updates) >= limit {
+			return false
+		}
+		if source != "" && entry.source != source {
+			return true
+		}
+		if seen[entry.userID] {
+			return true
+		}
+		seen[entry.userID] = true
+
+		if user, exists := m.users.get(entry.userID); exists {
+			userCopy := *user
+			updates = append(updates, RecentUpdate{User: &userCopy, UpdatedAt: entry.updatedAt, Source: entry.source})
+		}
+		return true
+	})
+	return updates, nil
+}
+
+// GetRandomUserID returns a user chosen uniformly at random, or "" when
+// there are none
+func (m *MemoryStore) GetRandomUserID() string {
+	users, _ := m.SampleUsersContext(context.Background(), 1, SampleUniform)
+	if len(users) == 0 {
+		return ""
 	}
-	return ""
+	return users[0].ID
 }
 
 func (m *MemoryStore) GetAllUserIDs() []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	ids := make([]string, 0, len(m.users))
-	for id := range m.users {
-		ids = append(ids, id)
-	}
+	ids := make([]string, 0, m.users.len())
+	m.users.each(func(user *models.User) bool {
+		ids = append(ids, user.ID)
+		return true
+	})
 	return ids
 }
 
@@ -255,8 +924,24 @@ func (m *MemoryStore) GetStats() map[string]interface{} {
 	defer m.mu.RUnlock()
 
 	return map[string]interface{}{
-		"total_users":            len(m.users),
+		"total_users":            m.users.len(),
+		"user_stripes":           userStripeCount,
 		"skip_list_size":         m.skipList.Length(),
 		"username_index_entries": len(m.usersByName),
+		"skip_list":              m.skipList.GetStats(),
+		"changes_by_source":      m.ChangesBySource(),
+		"merged_users":           len(m.merged),
+		"bot_users":              m.GetBotCount(),
+		"rating_range":           m.ratingRangeStats(),
+	}
+}
+
+// ChangesBySource returns how many rating changes, including initial
+// ratings, each source has made since the store was created
+func (m *MemoryStore) ChangesBySource() map[Source]uint64 {
+	counts := make(map[Source]uint64, len(sources))
+	for i, source := range sources {
+		counts[source] = atomic.LoadUint64(&m.bySource[i])
 	}
+	return counts
 }
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"leaderboard-backend/models"
+)
+
+// ErrMerged is returned for an ID that was merged into another account
+var ErrMerged = errors.New("user was merged into another account")
+
+// MergeUsers folds the account dupeID into keepID. keep's rating becomes
+// rating(keep, dupe), dupe leaves every index, its rating history joins
+// keep's and its ID is tombstoned so it can't be recreated. Tombstones that pointed at dupe are repointed at
+// keep. Both users are read and changed under one write lock.
+func (m *MemoryStore) MergeUsers(keepID, dupeID string, rating func(keep, dupe models.User) int) (*models.User, error) {
+	if keepID == dupeID {
+		return nil, fmt.Errorf("cannot merge user %s into itself", keepID)
+	}
+
+	unlock := m.users.lock(keepID, dupeID)
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	keep, exists := m.users.get(keepID)
+	if !exists {
+		return nil, fmt.Errorf("user with ID %s not found", keepID)
+	}
+	dupe, exists := m.users.get(dupeID)
+	if !exists {
+		return nil, fmt.Errorf("user with ID %s not found", dupeID)
+	}
+
+	// The duplicate's history is kept, before the merge's own change
+	m.mergeHistory(keepID, dupeID)
+	if newRating := rating(*keep, *dupe); newRating != keep.Rating {
+		m.applyRating(keep, newRating, time.Now(), SourceAdmin, "")
+	}
+	m.removeUser(dupe)
+	m.ratingIndex.DecrementBucket(dupe.Rating)
+	m.tombstone(dupeID, keepID)
+	atomic.AddUint64(&m.mutations, 1)
+	if m.journal != nil {
+		m.journal.append(walEntry{Op: walMerge, ID: dupeID, Into: keepID})
+	}
+	m.publishUser(ChangeRemoved, dupe, time.Now(), "", "")
+
+	keepCopy := *keep
+	return &keepCopy, nil
+}
+
+// tombstone records that id now lives on as into; the caller holds the
+// write lock
+func (m *MemoryStore) tombstone(id, into string) {
+	if m.merged == nil {
+		m.merged = make(map[string]string)
+	}
+	for old, target := range m.merged {
+		if target == id {
+			m.merged[old] = into
+		}
+	}
+	delete(m.merged, into)
+	m.merged[id] = into
+}
+
+// MergedInto returns the account a merged ID was folded into
+func (m *MemoryStore) MergedInto(id string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	into, merged := m.merged[id]
+	return into, merged
+}
+
+// MergedIDs returns a copy of every tombstone, merged ID to surviving ID
+func (m *MemoryStore) MergedIDs() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	merged := make(map[string]string, len(m.merged))
+	for id, into := range m.merged {
+		merged[id] = into
+	}
+	return merged
+}
+
+// RestoreMerged reinstates tombstones read from a snapshot
+func (m *MemoryStore) RestoreMerged(merged map[string]string) {
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	for id, into := range merged {
+		m.tombstone(id, into)
+	}
+}
+
+// replayMerge applies a logged merge: the duplicate is dropped if it is
+// still present and its tombstone recorded. keep's rating was logged as a
+// separate set.
+func (m *MemoryStore) replayMerge(dupeID, keepID string) {
+	unlock := m.users.lock(dupeID)
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	if dupe, exists := m.users.get(dupeID); exists {
+		m.mergeHistory(keepID, dupeID)
+		m.removeUser(dupe)
+		m.ratingIndex.DecrementBucket(dupe.Rating)
+	}
+	m.tombstone(dupeID, keepID)
+	atomic.AddUint64(&m.mutations, 1)
+}
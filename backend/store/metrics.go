@@ -0,0 +1,11 @@
+package store
+
+import "leaderboard-backend/metrics"
+
+// Latency histograms for the hot store operations, including lock wait time
+var (
+	addUserLatency      = metrics.NewHistogram("store_add_user_seconds", "Latency of MemoryStore.AddUser")
+	updateRatingLatency = metrics.NewHistogram("store_update_rating_seconds", "Latency of MemoryStore.UpdateRating")
+	getTopUsersLatency  = metrics.NewHistogram("store_get_top_users_seconds", "Latency of MemoryStore.GetTopUsers")
+	searchUsersLatency  = metrics.NewHistogram("store_search_users_seconds", "Latency of MemoryStore.SearchUsers")
+)
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS users (
+    id       TEXT PRIMARY KEY,
+    username TEXT NOT NULL,
+    rating   INTEGER NOT NULL,
+    bot      BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS users_rating_idx ON users (rating DESC, username ASC);
+CREATE INDEX IF NOT EXISTS users_username_idx ON users (lower(username) text_pattern_ops);
@@ -0,0 +1,5 @@
+-- Tombstones of accounts merged into another; see MemoryStore.MergeUsers
+CREATE TABLE IF NOT EXISTS merged_users (
+    id          TEXT PRIMARY KEY,
+    merged_into TEXT NOT NULL
+);
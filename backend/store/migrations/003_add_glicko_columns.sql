@@ -0,0 +1,4 @@
+-- Glicko-2 rating deviation and volatility; 0 until a user plays a match
+-- rated with Glicko-2
+ALTER TABLE users ADD COLUMN IF NOT EXISTS rd DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS volatility DOUBLE PRECISION NOT NULL DEFAULT 0;
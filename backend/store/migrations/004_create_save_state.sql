@@ -0,0 +1,9 @@
+-- One row, written by every save, so a server can tell a database that was
+-- never saved to from one whose users were all deleted
+CREATE TABLE IF NOT EXISTS save_state (
+    id       BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    saved_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Databases saved to before this migration already hold users
+INSERT INTO save_state (id) SELECT TRUE WHERE EXISTS (SELECT 1 FROM users) ON CONFLICT DO NOTHING;
@@ -1,75 +1,290 @@
 package store
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"leaderboard-backend/models"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Persistence handles saving and loading data
 type Persistence struct {
 	mu       sync.Mutex
 	filePath string
+	shards   int // number of shard files per save; 1 writes a single file
+	workers  int // goroutines encoding or decoding shard files at once
+	sync     SyncPolicy
+	progress persistenceProgress
+	journal  *WAL            // optional, rotated on every save
+	recovery *RecoveryReport // result of the startup Recover, if run
 }
 
-// PersistenceData is the structure saved to disk
+// PersistenceData is the structure saved to disk. A sharded save writes only
+// the shard file names here; the users live in those files.
 type PersistenceData struct {
-	Users   []*models.User `json:"users"`
-	Version int            `json:"version"`
+	Users   []*models.User    `json:"users"`
+	Version int               `json:"version"`
+	Shards  []string          `json:"shards,omitempty"`
+	Merged  map[string]string `json:"merged,omitempty"` // tombstones of merged users
 }
 
 // NewPersistence creates a new persistence handler
 func NewPersistence(filePath string) *Persistence {
 	return &Persistence{
 		filePath: filePath,
+		shards:   1,
+		workers:  runtime.NumCPU(),
+		sync:     SyncOnSave,
 	}
 }
 
-// Save writes all users to disk atomically
-func (p *Persistence) Save(store *MemoryStore) error {
+// SetShards splits future saves across n files written in parallel, grouped
+// by a hash of the user ID. Files saved with any shard count can be loaded.
+func (p *Persistence) SetShards(n int) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Get all users
-	users := store.GetAllUsers()
+	if n < 1 {
+		n = 1
+	}
+	p.shards = n
+}
+
+// SetWorkers bounds how many shard files are encoded or decoded at once
+func (p *Persistence) SetWorkers(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	data := PersistenceData{
-		Users:   users,
-		Version: 1,
+	if n < 1 {
+		n = 1
 	}
+	p.workers = n
+}
 
-	// Marshal to JSON
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+// SetSyncPolicy chooses whether saves fsync their files before returning
+func (p *Persistence) SetSyncPolicy(policy SyncPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sync = policy
+}
+
+// GetStats reports the running and most recent save and load, including
+// shard progress and timing
+func (p *Persistence) GetStats() map[string]interface{} {
+	stats := p.progress.stats()
+
+	p.mu.Lock()
+	stats["shards"] = p.shards
+	stats["workers"] = p.workers
+	stats["sync_policy"] = p.sync
+	if p.journal != nil {
+		stats["wal"] = p.journal.GetStats()
 	}
+	if p.recovery != nil {
+		stats["recovery"] = p.recovery
+	}
+	p.mu.Unlock()
+
+	return stats
+}
+
+// snapshotData mirrors PersistenceData but holds users by value, matching the
+// contiguous copy produced by MemoryStore.Snapshot
+type snapshotData struct {
+	Users   []models.User     `json:"users"`
+	Version int               `json:"version"`
+	Merged  map[string]string `json:"merged,omitempty"`
+}
+
+// SetJournal makes every save rotate the write-ahead log before taking its
+// snapshot and discard the rotated part once the snapshot is written
+func (p *Persistence) SetJournal(w *WAL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
+	p.journal = w
+}
+
+// Save writes all users to disk atomically. The store is only locked while
+// the snapshot is copied; marshalling and disk I/O happen off the lock.
+func (p *Persistence) Save(store *MemoryStore) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Entries logged before the rotation are all covered by the snapshot
+	if p.journal != nil {
+		if err := p.journal.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	// Fast copy under the store read lock
+	users := store.Snapshot()
+	merged := store.MergedIDs()
+
+	op := p.progress.begin("save", p.shards, p.workers)
+	err := p.save(users, merged, op)
+	p.progress.finish(op, err)
+
+	if err == nil && p.journal != nil {
+		p.journal.DiscardRotated()
+	}
+	return err
+}
+
+func (p *Persistence) save(users []models.User, merged map[string]string, op *persistenceOp) error {
 	// Ensure directory exists
 	dir := filepath.Dir(p.filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Write to temp file first (atomic write)
-	tempPath := p.filePath + ".tmp"
-	if err := os.WriteFile(tempPath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+	if p.shards > 1 {
+		return p.saveSharded(users, merged, op)
+	}
+
+	previous := p.readShardNames()
+	if err := writeFileAtomic(p.filePath, snapshotData{Users: users, Version: 1, Merged: merged}, p.sync); err != nil {
+		return err
+	}
+	p.progress.shardDone(op, len(users))
+	p.removeShards(previous)
+	return nil
+}
+
+// saveSharded encodes and writes the shard files on the worker pool, then
+// swaps in a manifest naming them. Shard files carry a generation in their
+// name, so the previous save stays loadable until the new manifest is in place.
+func (p *Persistence) saveSharded(users []models.User, merged map[string]string, op *persistenceOp) error {
+	parts := make([][]models.User, p.shards)
+	for i := range users {
+		shard := shardFor(users[i].ID, p.shards)
+		parts[shard] = append(parts[shard], users[i])
+	}
+
+	base := strings.TrimSuffix(filepath.Base(p.filePath), filepath.Ext(p.filePath))
+	generation := time.Now().UnixNano()
+	names := make([]string, p.shards)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s.%d.shard-%03d.json", base, generation, i)
+	}
+
+	dir := filepath.Dir(p.filePath)
+	err := runPool(p.workers, p.shards, func(i int) error {
+		if err := writeFileAtomic(filepath.Join(dir, names[i]), snapshotData{Users: parts[i], Version: 1}, p.sync); err != nil {
+			return err
+		}
+		p.progress.shardDone(op, len(parts[i]))
+		return nil
+	})
+	if err != nil {
+		p.removeShards(names)
+		return err
+	}
+
+	previous := p.readShardNames()
+	if err := writeFileAtomic(p.filePath, PersistenceData{Users: []*models.User{}, Version: 2, Shards: names, Merged: merged}, p.sync); err != nil {
+		p.removeShards(names)
+		return err
+	}
+	p.removeShards(previous)
+	return nil
+}
+
+// shardFor maps a user ID to a shard
+func shardFor(id string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// writeFileAtomic writes v to a temp file next to path, then renames it over
+// path. Unless the policy is SyncNever both the file and the rename are fsynced.
+func writeFileAtomic(path string, v interface{}, policy SyncPolicy) error {
+	tempPath := path + ".tmp"
+	if err := writeJSONFile(tempPath, v, policy != SyncNever); err != nil {
+		os.Remove(tempPath)
+		return err
 	}
 
 	// Rename temp file to actual file (atomic on most filesystems)
-	if err := os.Rename(tempPath, p.filePath); err != nil {
+	if err := os.Rename(tempPath, path); err != nil {
 		os.Remove(tempPath) // Clean up temp file
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
+	if policy != SyncNever {
+		return syncDir(filepath.Dir(path))
+	}
+	return nil
+}
+
+// readShardNames returns the shard files named by the current manifest, if any
+func (p *Persistence) readShardNames() []string {
+	file, err := os.Open(p.filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var manifest struct {
+		Shards []string `json:"shards"`
+	}
+	json.NewDecoder(file).Decode(&manifest)
+	return manifest.Shards
+}
+
+func (p *Persistence) removeShards(names []string) {
+	dir := filepath.Dir(p.filePath)
+	for _, name := range names {
+		os.Remove(filepath.Join(dir, filepath.Base(name)))
+	}
+}
+
+// writeJSONFile streams v as indented JSON into path through a buffered writer,
+// avoiding a second full in-memory copy of the encoded data. With fsync set
+// the file is flushed to stable storage before it is closed.
+func writeJSONFile(path string, v interface{}, fsync bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	writer := bufio.NewWriterSize(file, 1<<20)
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if fsync {
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to sync temp file: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
 	return nil
 }
 
-// Load reads users from disk and populates the store
+// Load reads users from disk and populates the store. Sharded saves have
+// their shard files decoded on the worker pool.
 func (p *Persistence) Load(store *MemoryStore, ratingIndex *RatingBucketIndex) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -79,37 +294,91 @@ func (p *Persistence) Load(store *MemoryStore, ratingIndex *RatingBucketIndex) e
 		return nil // No data to load, not an error
 	}
 
+	data, err := readDataFile(p.filePath)
+	if err != nil {
+		return err
+	}
+
+	shards := len(data.Shards)
+	if shards == 0 {
+		shards = 1
+	}
+	op := p.progress.begin("load", shards, p.workers)
+	users := data.Users
+	if len(data.Shards) > 0 {
+		users, err = p.loadShards(data.Shards, op)
+		if err != nil {
+			p.progress.finish(op, err)
+			return err
+		}
+	} else {
+		p.progress.shardDone(op, len(users))
+	}
+
+	// Clear existing data
+	store.Clear()
+
+	// Load users in bulk - log failures but continue with the rest
+	for id, err := range store.LoadUsers(users, SourceImport) {
+		fmt.Printf("Warning: failed to load user %s: %v\n", id, err)
+	}
+	store.RestoreMerged(data.Merged)
+
+	p.progress.finish(op, nil)
+	return nil
+}
+
+// loadShards decodes the shard files on the worker pool and merges the users
+func (p *Persistence) loadShards(names []string, op *persistenceOp) ([]*models.User, error) {
+	dir := filepath.Dir(p.filePath)
+	results := make([][]*models.User, len(names))
+
+	err := runPool(p.workers, len(names), func(i int) error {
+		data, err := readDataFile(filepath.Join(dir, filepath.Base(names[i])))
+		if err != nil {
+			return fmt.Errorf("shard %s: %w", names[i], err)
+		}
+		results[i] = data.Users
+		p.progress.shardDone(op, len(data.Users))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, part := range results {
+		total += len(part)
+	}
+
+	users := make([]*models.User, 0, total)
+	for _, part := range results {
+		users = append(users, part...)
+	}
+	return users, nil
+}
+
+// readDataFile decodes one persistence file
+func readDataFile(path string) (*PersistenceData, error) {
 	// Open file
-	file, err := os.Open(p.filePath)
+	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
 	// Read all content
 	jsonData, err := io.ReadAll(file)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	// Unmarshal JSON
 	var data PersistenceData
 	if err := json.Unmarshal(jsonData, &data); err != nil {
-		return fmt.Errorf("failed to unmarshal data: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal data: %w", err)
 	}
-
-	// Clear existing data
-	store.Clear()
-
-	// Load users
-	for _, user := range data.Users {
-		if err := store.AddUser(user); err != nil {
-			// Log but don't fail - continue loading other users
-			fmt.Printf("Warning: failed to load user %s: %v\n", user.ID, err)
-		}
-	}
-
-	return nil
+	return &data, nil
 }
 
 // Exists checks if persistence file exists
@@ -128,5 +397,6 @@ func (p *Persistence) Delete() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.removeShards(p.readShardNames())
 	return os.Remove(p.filePath)
 }
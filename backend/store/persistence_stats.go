@@ -0,0 +1,133 @@
+package store
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// persistenceOp tracks one save or load while it runs and after it ends
+type persistenceOp struct {
+	Operation   string    `json:"operation"`
+	InProgress  bool      `json:"in_progress"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMs  int64     `json:"duration_ms"`
+	Users       int       `json:"users"`
+	ShardsDone  int       `json:"shards_done"`
+	ShardsTotal int       `json:"shards_total"`
+	Workers     int       `json:"workers"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// persistenceProgress records the running and most recent saves and loads.
+// It has its own lock so health checks never wait on disk I/O.
+type persistenceProgress struct {
+	mu       sync.Mutex
+	current  *persistenceOp
+	lastSave *persistenceOp
+	lastLoad *persistenceOp
+}
+
+func (p *persistenceProgress) begin(operation string, shards, workers int) *persistenceOp {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.current = &persistenceOp{
+		Operation:   operation,
+		InProgress:  true,
+		StartedAt:   time.Now(),
+		ShardsTotal: shards,
+		Workers:     workers,
+	}
+	return p.current
+}
+
+func (p *persistenceProgress) shardDone(op *persistenceOp, users int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	op.ShardsDone++
+	op.Users += users
+}
+
+func (p *persistenceProgress) finish(op *persistenceOp, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	op.InProgress = false
+	op.DurationMs = time.Since(op.StartedAt).Milliseconds()
+	if err != nil {
+		op.Error = err.Error()
+	}
+	if op.Operation == "save" {
+		p.lastSave = op
+	} else {
+		p.lastLoad = op
+	}
+	if p.current == op {
+		p.current = nil
+	}
+
+	if op.ShardsTotal > 1 {
+		log.Printf("Persistence %s: %d users in %d/%d shards with %d workers in %dms\n",
+			op.Operation, op.Users, op.ShardsDone, op.ShardsTotal, op.Workers, op.DurationMs)
+	}
+}
+
+func (p *persistenceProgress) stats() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshot := func(op *persistenceOp) interface{} {
+		if op == nil {
+			return nil
+		}
+		copied := *op
+		if copied.InProgress {
+			copied.DurationMs = time.Since(copied.StartedAt).Milliseconds()
+		}
+		return copied
+	}
+
+	return map[string]interface{}{
+		"current":   snapshot(p.current),
+		"last_save": snapshot(p.lastSave),
+		"last_load": snapshot(p.lastLoad),
+	}
+}
+
+// runPool calls fn for every index in [0, n) using at most workers
+// goroutines and returns the error of the lowest failing index
+func runPool(workers, n int, fn func(i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
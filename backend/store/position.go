@@ -0,0 +1,19 @@
+package store
+
+import (
+	"fmt"
+)
+
+// GetPosition returns a user's 1-based place in leaderboard order, counted
+// with per-level skip list spans in O(log N). Users sharing a rating share
+// a rank but each has their own position, ordered by username.
+func (m *MemoryStore) GetPosition(id string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	position, exists := m.skipList.Position(id)
+	if !exists {
+		return 0, fmt.Errorf("user with ID %s not found", id)
+	}
+	return position, nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// PresenceTracker records when each user was last seen. A user counts as
+// online while their last heartbeat is within the configured window.
+type PresenceTracker struct {
+	mu       sync.RWMutex
+	lastSeen map[string]time.Time
+	window   time.Duration
+}
+
+// NewPresenceTracker creates a tracker with the given online window
+func NewPresenceTracker(window time.Duration) *PresenceTracker {
+	return &PresenceTracker{
+		lastSeen: make(map[string]time.Time),
+		window:   window,
+	}
+}
+
+// Heartbeat marks the user as seen now
+func (p *PresenceTracker) Heartbeat(userID string) time.Time {
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastSeen[userID] = now
+	return now
+}
+
+// IsOnline reports whether the user sent a heartbeat within the window
+func (p *PresenceTracker) IsOnline(userID string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	seen, exists := p.lastSeen[userID]
+	return exists && time.Since(seen) <= p.window
+}
+
+// LastSeen returns the last heartbeat time for a user, if any
+func (p *PresenceTracker) LastSeen(userID string) (time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	seen, exists := p.lastSeen[userID]
+	return seen, exists
+}
+
+// OnlineCount returns the number of users currently online
+func (p *PresenceTracker) OnlineCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	cutoff := time.Now().Add(-p.window)
+	count := 0
+	for _, seen := range p.lastSeen {
+		if seen.After(cutoff) {
+			count++
+		}
+	}
+	return count
+}
+
+// Forget drops presence data for a user
+func (p *PresenceTracker) Forget(userID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.lastSeen, userID)
+}
+
+// Clear removes all presence data
+func (p *PresenceTracker) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastSeen = make(map[string]time.Time)
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"leaderboard-backend/models"
+)
+
+// RankedList keeps users in leaderboard order. Implementations have no lock
+// of their own for writers: the owning store serializes Insert, Remove and
+// Clear under its write lock and guards the other queries with its read
+// lock, unless LockFreeReads says they are safe to run without it.
+type RankedList interface {
+	Insert(user *models.User)
+	Remove(userID string) bool
+	GetTopN(limit, offset int) []*models.User
+	// VisitTopN is GetTopN without the copies: visit sees up to limit users
+	// from offset in place, until it returns false. It must not keep or
+	// change them. The same locking rules apply as for GetTopN.
+	VisitTopN(limit, offset int, visit func(user *models.User) bool)
+	GetTopNFiltered(ctx context.Context, limit, offset int, keep func(user *models.User) bool) ([]*models.User, error)
+	After(ctx context.Context, after *models.User, limit int, keep func(user *models.User) bool) ([]*models.User, error)
+	Around(userID string, above, below int) ([]*models.User, int)
+	Position(userID string) (int, bool)
+	Length() int
+	Contains(userID string) bool
+	Clear()
+	GetAllUserIDs() []string
+	GetStats() map[string]interface{}
+
+	// LockFreeReads reports whether GetTopN, GetTopNFiltered, After and
+	// Length may be called without holding the owner's lock
+	LockFreeReads() bool
+}
+
+// SkipListImpl selects the RankedList behind a MemoryStore
+type SkipListImpl string
+
+const (
+	// SkipListLocked is the original skip list; readers share the store lock
+	// with writers
+	SkipListLocked SkipListImpl = "locked"
+	// SkipListConcurrent never blocks readers behind the writer
+	SkipListConcurrent SkipListImpl = "concurrent"
+	// SkipListBTree is a B+ tree; it locks like SkipListLocked but keeps
+	// users in wide leaves instead of one node each
+	SkipListBTree SkipListImpl = "btree"
+)
+
+// ParseSkipListImpl validates an implementation name
+func ParseSkipListImpl(name string) (SkipListImpl, error) {
+	switch impl := SkipListImpl(name); impl {
+	case SkipListLocked, SkipListConcurrent, SkipListBTree:
+		return impl, nil
+	}
+	return "", fmt.Errorf("unknown skip list implementation %q (want %q, %q or %q)", name, SkipListLocked, SkipListConcurrent, SkipListBTree)
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"leaderboard-backend/models"
 	"sync"
 	"sync/atomic"
 )
@@ -11,10 +12,15 @@ const (
 	RatingRange = MaxRating - MinRating + 1 // 4901 buckets
 )
 
+// RatingBucketIndex counts users per rating. Ranks come from a Fenwick tree
+// over the buckets, highest rating first, so adding, removing or moving a
+// user and looking up a rank are all O(log R) in the rating range. A second
+// tree counts the non-empty buckets for dense ranks.
 type RatingBucketIndex struct {
 	mu         sync.RWMutex
-	buckets    [RatingRange]int32 // Count of users at each rating
-	cumulative [RatingRange]int32 // Precomputed: users with rating > current
+	buckets    [RatingRange]int32     // Count of users at each rating
+	tree       [RatingRange + 1]int32 // Fenwick tree, 1-based, position 1 is MaxRating
+	distinct   [RatingRange + 1]int32 // Fenwick tree of non-empty buckets, same layout
 	totalUsers int32
 }
 
@@ -32,40 +38,199 @@ func ratingToIndex(rating int) int {
 	return rating - MinRating
 }
 
-// recalculateCumulative performs full O(4901) recalculation
-// Used only when adding/removing users (not for rating updates)
-func (r *RatingBucketIndex) recalculateCumulative() {
-	var cumSum int32 = 0
-	for i := RatingRange - 1; i >= 0; i-- {
-		r.cumulative[i] = cumSum
-		cumSum += r.buckets[i]
+// treePosition maps a bucket index to its Fenwick position; higher ratings
+// come first so a prefix sum counts the users above
+func treePosition(idx int) int {
+	return RatingRange - idx
+}
+
+// add changes the count at bucket idx by delta in the tree, after the
+// bucket itself has been changed
+// O(log 4901); the caller holds the write lock
+func (r *RatingBucketIndex) add(idx int, delta int32) {
+	for pos := treePosition(idx); pos <= RatingRange; pos += pos & -pos {
+		r.tree[pos] += delta
+	}
+
+	// The bucket just became non-empty or empty
+	var change int32
+	switch count := r.buckets[idx]; {
+	case delta > 0 && count == delta:
+		change = 1
+	case delta < 0 && count == 0:
+		change = -1
+	default:
+		return
+	}
+	for pos := treePosition(idx); pos <= RatingRange; pos += pos & -pos {
+		r.distinct[pos] += change
+	}
+}
+
+// usersAbove counts users with a rating strictly higher than bucket idx
+// O(log 4901); the caller holds the lock
+func (r *RatingBucketIndex) usersAbove(idx int) int {
+	var sum int32
+	for pos := treePosition(idx) - 1; pos > 0; pos -= pos & -pos {
+		sum += r.tree[pos]
+	}
+	return int(sum)
+}
+
+// ratingsAbove counts the distinct ratings held by someone that are strictly
+// higher than bucket idx
+// O(log 4901); the caller holds the lock
+func (r *RatingBucketIndex) ratingsAbove(idx int) int {
+	var sum int32
+	for pos := treePosition(idx) - 1; pos > 0; pos -= pos & -pos {
+		sum += r.distinct[pos]
 	}
+	return int(sum)
 }
 
 // GetRank returns the competition rank for a given rating
-// O(1) lookup using precomputed cumulative array
+// O(log 4901) prefix sum over the Fenwick tree
 func (r *RatingBucketIndex) GetRank(rating int) int {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	return r.usersAbove(ratingToIndex(rating)) + 1
+}
+
+// RatingStanding is where a rating stands on the board, from which either
+// kind of rank and a percentile follow
+type RatingStanding struct {
+	UsersAbove   int // users with a higher rating
+	RatingsAbove int // distinct higher ratings held by someone
+	TotalUsers   int
+}
+
+// GetRatingStanding reads the standing of rating under one read lock
+// O(log 4901)
+func (r *RatingBucketIndex) GetRatingStanding(rating int) RatingStanding {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.standing(rating)
+}
+
+// GetRatingStandings reads the standing of each rating under a single read
+// lock, so they are consistent with each other
+func (r *RatingBucketIndex) GetRatingStandings(ratings []int) []RatingStanding {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	standings := make([]RatingStanding, len(ratings))
+	for i, rating := range ratings {
+		standings[i] = r.standing(rating)
+	}
+	return standings
+}
+
+// standing reads one standing; the caller holds the lock
+func (r *RatingBucketIndex) standing(rating int) RatingStanding {
 	idx := ratingToIndex(rating)
-	return int(r.cumulative[idx]) + 1
+	return RatingStanding{
+		UsersAbove:   r.usersAbove(idx),
+		RatingsAbove: r.ratingsAbove(idx),
+		TotalUsers:   int(atomic.LoadInt32(&r.totalUsers)),
+	}
+}
+
+// GetDenseRank returns the dense rank for a given rating: users sharing a
+// rating share a rank and the next rating down is ranked one lower, with no
+// gap. O(log 4901) over the non-empty buckets.
+func (r *RatingBucketIndex) GetDenseRank(rating int) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.ratingsAbove(ratingToIndex(rating)) + 1
+}
+
+// GetDenseRanks returns the dense rank for each rating under a single read
+// lock
+func (r *RatingBucketIndex) GetDenseRanks(ratings []int) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ranks := make([]int, len(ratings))
+	for i, rating := range ratings {
+		ranks[i] = r.ratingsAbove(ratingToIndex(rating)) + 1
+	}
+	return ranks
+}
+
+// GetRanks returns the competition rank for each rating under a single read
+// lock, so all ranks in the result are consistent with each other
+func (r *RatingBucketIndex) GetRanks(ratings []int) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ranks := make([]int, len(ratings))
+	for i, rating := range ratings {
+		ranks[i] = r.usersAbove(ratingToIndex(rating)) + 1
+	}
+	return ranks
+}
+
+// GetRankAndTotal returns the competition rank for rating and the number of
+// users it is out of, read under one lock so the pair is consistent
+func (r *RatingBucketIndex) GetRankAndTotal(rating int) (int, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.usersAbove(ratingToIndex(rating)) + 1, int(atomic.LoadInt32(&r.totalUsers))
 }
 
 // IncrementBucket adds a user at the given rating
-// O(4901) - only called when adding new users
+// O(log 4901)
 func (r *RatingBucketIndex) IncrementBucket(rating int) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	idx := ratingToIndex(rating)
 	r.buckets[idx]++
+	r.add(idx, 1)
 	atomic.AddInt32(&r.totalUsers, 1)
-	r.recalculateCumulative()
+}
+
+// IncrementBuckets adds many users at once and rebuilds the tree in one
+// O(4901) pass, which beats a point update per user for large loads
+func (r *RatingBucketIndex) IncrementBuckets(ratings []int) {
+	if len(ratings) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rating := range ratings {
+		r.buckets[ratingToIndex(rating)]++
+	}
+	atomic.AddInt32(&r.totalUsers, int32(len(ratings)))
+	r.rebuild()
+}
+
+// rebuild recomputes the tree from the buckets in O(4901); the caller holds
+// the write lock
+func (r *RatingBucketIndex) rebuild() {
+	for pos := 1; pos <= RatingRange; pos++ {
+		r.tree[pos] = r.buckets[RatingRange-pos]
+		r.distinct[pos] = 0
+		if r.tree[pos] > 0 {
+			r.distinct[pos] = 1
+		}
+	}
+	for pos := 1; pos <= RatingRange; pos++ {
+		if parent := pos + pos&-pos; parent <= RatingRange {
+			r.tree[parent] += r.tree[pos]
+			r.distinct[parent] += r.distinct[pos]
+		}
+	}
 }
 
 // DecrementBucket removes a user at the given rating
-// O(4901) - only called when removing users
+// O(log 4901)
 func (r *RatingBucketIndex) DecrementBucket(rating int) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -73,13 +238,33 @@ func (r *RatingBucketIndex) DecrementBucket(rating int) {
 	idx := ratingToIndex(rating)
 	if r.buckets[idx] > 0 {
 		r.buckets[idx]--
+		r.add(idx, -1)
 		atomic.AddInt32(&r.totalUsers, -1)
 	}
-	r.recalculateCumulative()
+}
+
+// DecrementBuckets removes many users at once under a single write lock,
+// so readers never see a partly removed batch
+func (r *RatingBucketIndex) DecrementBuckets(ratings []int) {
+	if len(ratings) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rating := range ratings {
+		idx := ratingToIndex(rating)
+		if r.buckets[idx] > 0 {
+			r.buckets[idx]--
+			r.add(idx, -1)
+			atomic.AddInt32(&r.totalUsers, -1)
+		}
+	}
 }
 
 // UpdateRating moves a user from oldRating to newRating
-// O(|newRating - oldRating|) using incremental update instead of O(4901)
+// O(log 4901) - two point updates on the Fenwick tree
 func (r *RatingBucketIndex) UpdateRating(oldRating, newRating int) {
 	if oldRating == newRating {
 		return
@@ -90,27 +275,16 @@ func (r *RatingBucketIndex) UpdateRating(oldRating, newRating int) {
 
 	oldIdx := ratingToIndex(oldRating)
 	newIdx := ratingToIndex(newRating)
+	if oldIdx == newIdx {
+		return
+	}
 
 	if r.buckets[oldIdx] > 0 {
 		r.buckets[oldIdx]--
+		r.add(oldIdx, -1)
 	}
 	r.buckets[newIdx]++
-
-	// Incremental cumulative update - O(|newIdx - oldIdx|) instead of O(4901)
-	// cumulative[i] = count of users with rating > (i + MinRating)
-	if oldIdx < newIdx {
-		// User moved to higher rating
-		// Ratings in range [oldIdx, newIdx) now have one MORE user above them
-		for i := oldIdx; i < newIdx; i++ {
-			r.cumulative[i]++
-		}
-	} else {
-		// User moved to lower rating
-		// Ratings in range [newIdx, oldIdx) now have one LESS user above them
-		for i := newIdx; i < oldIdx; i++ {
-			r.cumulative[i]--
-		}
-	}
+	r.add(newIdx, 1)
 }
 
 // GetUsersAbove returns count of users with rating strictly higher than given
@@ -118,8 +292,7 @@ func (r *RatingBucketIndex) GetUsersAbove(rating int) int {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	idx := ratingToIndex(rating)
-	return int(r.cumulative[idx])
+	return r.usersAbove(ratingToIndex(rating))
 }
 
 // GetTotalUsers returns total number of users in the index
@@ -132,10 +305,9 @@ func (r *RatingBucketIndex) Clear() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	for i := range r.buckets {
-		r.buckets[i] = 0
-		r.cumulative[i] = 0
-	}
+	r.buckets = [RatingRange]int32{}
+	r.tree = [RatingRange + 1]int32{}
+	r.distinct = [RatingRange + 1]int32{}
 	atomic.StoreInt32(&r.totalUsers, 0)
 }
 
@@ -162,6 +334,38 @@ func (r *RatingBucketIndex) GetRatingsDescending() []int {
 	return ratings
 }
 
+// GetDistribution returns the non-empty buckets in ascending rating order.
+// With step > 1 adjacent ratings are merged into bands of that width, keyed
+// by the band's lowest rating, so charts can be down-sampled server-side.
+func (r *RatingBucketIndex) GetDistribution(step int) []models.RatingBucket {
+	if step < 1 {
+		step = 1
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	distribution := make([]models.RatingBucket, 0)
+	for start := 0; start < RatingRange; start += step {
+		end := start + step
+		if end > RatingRange {
+			end = RatingRange
+		}
+
+		var count int32
+		for i := start; i < end; i++ {
+			count += r.buckets[i]
+		}
+		if count > 0 {
+			distribution = append(distribution, models.RatingBucket{
+				Rating: start + MinRating,
+				Count:  int(count),
+			})
+		}
+	}
+	return distribution
+}
+
 // GetStats returns statistics about the rating index
 func (r *RatingBucketIndex) GetStats() map[string]interface{} {
 	r.mu.RLock()
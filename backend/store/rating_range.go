@@ -0,0 +1,88 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"leaderboard-backend/metrics"
+)
+
+// RatingRangeMode decides what happens to a rating outside [MinRating,
+// MaxRating], the range the rating buckets cover
+type RatingRangeMode string
+
+const (
+	// RatingClamp pulls the rating to the nearest bound. The user is stored
+	// with the clamped rating, so every index ranks them the same way.
+	RatingClamp RatingRangeMode = "clamp"
+	// RatingStrict rejects the change with ErrRatingOutOfRange
+	RatingStrict RatingRangeMode = "strict"
+)
+
+// ErrRatingOutOfRange is returned for a rating outside the indexed range in
+// strict mode
+var ErrRatingOutOfRange = errors.New("rating out of range")
+
+var (
+	ratingsClampedTotal  = metrics.NewCounter("store_ratings_clamped_total", "Out-of-range ratings pulled into the indexed range")
+	ratingsRejectedTotal = metrics.NewCounter("store_ratings_rejected_total", "Out-of-range ratings rejected in strict mode")
+)
+
+// ParseRatingRangeMode validates a mode name; empty means RatingClamp
+func ParseRatingRangeMode(name string) (RatingRangeMode, error) {
+	switch mode := RatingRangeMode(name); mode {
+	case "":
+		return RatingClamp, nil
+	case RatingClamp, RatingStrict:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown rating range mode %q (want %q or %q)", name, RatingClamp, RatingStrict)
+}
+
+// SetRatingRangeMode chooses how out-of-range ratings are handled
+func (m *MemoryStore) SetRatingRangeMode(mode RatingRangeMode) {
+	var strict uint32
+	if mode == RatingStrict {
+		strict = 1
+	}
+	atomic.StoreUint32(&m.strictRatings, strict)
+}
+
+// RatingRangeMode returns how out-of-range ratings are handled
+func (m *MemoryStore) RatingRangeMode() RatingRangeMode {
+	if atomic.LoadUint32(&m.strictRatings) == 1 {
+		return RatingStrict
+	}
+	return RatingClamp
+}
+
+// CheckRating returns the rating to use in place of rating: rating itself
+// when in range, otherwise the nearest bound in clamp mode or an error
+// wrapping ErrRatingOutOfRange in strict mode. Either way the event is
+// counted.
+func (m *MemoryStore) CheckRating(rating int) (int, error) {
+	if rating >= MinRating && rating <= MaxRating {
+		return rating, nil
+	}
+	if m.RatingRangeMode() == RatingStrict {
+		atomic.AddUint64(&m.ratingsRejected, 1)
+		ratingsRejectedTotal.Inc()
+		return 0, fmt.Errorf("%w: %d is not between %d and %d", ErrRatingOutOfRange, rating, MinRating, MaxRating)
+	}
+	atomic.AddUint64(&m.ratingsClamped, 1)
+	ratingsClampedTotal.Inc()
+	if rating < MinRating {
+		return MinRating, nil
+	}
+	return MaxRating, nil
+}
+
+// ratingRangeStats reports the mode and how many ratings it has acted on
+func (m *MemoryStore) ratingRangeStats() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":     m.RatingRangeMode(),
+		"clamped":  atomic.LoadUint64(&m.ratingsClamped),
+		"rejected": atomic.LoadUint64(&m.ratingsRejected),
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/tracing"
+)
+
+// RatingState is a user's rating with the deviation and volatility a
+// Glicko-2 rating carries alongside it
+type RatingState struct {
+	Rating     int
+	RD         float64
+	Volatility float64
+}
+
+// UpdateRatingState sets a user's rating, rating deviation and volatility
+// together. A change to the deviation or volatility alone is saved and
+// journaled but, like any change that leaves the rating alone, is not a
+// rating change.
+func (m *MemoryStore) UpdateRatingState(ctx context.Context, id string, state RatingState, source Source) error {
+	source = source.orDefault()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rating, err := m.CheckRating(state.Rating)
+	if err != nil {
+		return err
+	}
+
+	unlock := m.users.lock(id)
+	defer unlock()
+
+	user, exists := m.users.get(id)
+	if !exists {
+		return fmt.Errorf("user with ID %s not found", id)
+	}
+	if user.Rating == rating && user.RD == state.RD && user.Volatility == state.Volatility {
+		return nil
+	}
+
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	user.RD, user.Volatility = state.RD, state.Volatility
+	if user.Rating != rating {
+		m.applyRating(user, rating, time.Now(), source, tracing.TraceparentFrom(ctx))
+		return nil
+	}
+	atomic.AddUint64(&m.mutations, 1)
+	m.journalSet(user, source)
+	return nil
+}
+
+// UpdatePairRatingState rates two users against each other atomically: it
+// holds both users' stripe locks while it reads them, calls rate with copies
+// and applies the two states rate returns, so no other rating change can
+// land in between and be overwritten. If rate fails, or either new rating
+// is out of range, neither user changes. It returns copies of both users
+// before and after.
+func (m *MemoryStore) UpdatePairRatingState(ctx context.Context, idA, idB string, rate func(a, b models.User) (RatingState, RatingState, error), source Source) (before, after [2]models.User, err error) {
+	source = source.orDefault()
+	if err := ctx.Err(); err != nil {
+		return before, after, err
+	}
+
+	unlock := m.users.lock(idA, idB)
+	defer unlock()
+
+	a, exists := m.users.get(idA)
+	if !exists {
+		return before, after, fmt.Errorf("user with ID %s not found", idA)
+	}
+	b, exists := m.users.get(idB)
+	if !exists {
+		return before, after, fmt.Errorf("user with ID %s not found", idB)
+	}
+	before = [2]models.User{*a, *b}
+
+	stateA, stateB, err := rate(before[0], before[1])
+	if err != nil {
+		return before, after, err
+	}
+	if stateA.Rating, err = m.CheckRating(stateA.Rating); err != nil {
+		return before, after, err
+	}
+	if stateB.Rating, err = m.CheckRating(stateB.Rating); err != nil {
+		return before, after, err
+	}
+
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	now := time.Now()
+	trace := tracing.TraceparentFrom(ctx)
+	for _, update := range [2]struct {
+		user  *models.User
+		state RatingState
+	}{{a, stateA}, {b, stateB}} {
+		user, state := update.user, update.state
+		if user.Rating == state.Rating && user.RD == state.RD && user.Volatility == state.Volatility {
+			continue
+		}
+		user.RD, user.Volatility = state.RD, state.Volatility
+		if user.Rating != state.Rating {
+			m.applyRating(user, state.Rating, now, source, trace)
+			continue
+		}
+		atomic.AddUint64(&m.mutations, 1)
+		m.journalSet(user, source)
+	}
+	return before, [2]models.User{*a, *b}, nil
+}
+
+// UpdateDeviations sets the rating deviation of every user next returns one
+// for, under a single acquisition of the store lock, and returns how many
+// changed. Ratings stay where they are.
+func (m *MemoryStore) UpdateDeviations(next func(user models.User) (float64, bool)) int {
+	unlock := m.users.lockAll()
+	defer unlock()
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	changed := 0
+	m.users.each(func(user *models.User) bool {
+		rd, ok := next(*user)
+		if ok && rd != user.RD {
+			user.RD = rd
+			m.journalSet(user, SourceDecay)
+			changed++
+		}
+		return true
+	})
+	if changed > 0 {
+		atomic.AddUint64(&m.mutations, 1)
+	}
+	return changed
+}
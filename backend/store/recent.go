@@ -0,0 +1,54 @@
+package store
+
+import (
+	"leaderboard-backend/models"
+	"time"
+)
+
+// recentCapacity bounds how many changes are remembered for the activity feed
+const recentCapacity = 1024
+
+// RecentUpdate is a user together with the time and source of their latest
+// change
+type RecentUpdate struct {
+	User      *models.User
+	UpdatedAt time.Time
+	Source    Source
+}
+
+type recentEntry struct {
+	userID    string
+	updatedAt time.Time
+	source    Source
+}
+
+// recentRing is a fixed-size ring buffer of the latest user changes.
+// It is not synchronized; MemoryStore guards it with its own lock.
+type recentRing struct {
+	entries [recentCapacity]recentEntry
+	next    int
+	size    int
+}
+
+func (r *recentRing) record(userID string, at time.Time, source Source) {
+	r.entries[r.next] = recentEntry{userID: userID, updatedAt: at, source: source}
+	r.next = (r.next + 1) % recentCapacity
+	if r.size < recentCapacity {
+		r.size++
+	}
+}
+
+func (r *recentRing) clear() {
+	r.next = 0
+	r.size = 0
+}
+
+// newestFirst walks entries from newest to oldest until fn returns false
+func (r *recentRing) newestFirst(fn func(entry recentEntry) bool) {
+	for i := 0; i < r.size; i++ {
+		idx := (r.next - 1 - i + recentCapacity) % recentCapacity
+		if !fn(r.entries[idx]) {
+			return
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"leaderboard-backend/models"
+)
+
+// SampleWeight says how likely SampleUsers is to pick each user
+type SampleWeight string
+
+const (
+	SampleUniform SampleWeight = "uniform" // every user equally
+	SampleRating  SampleWeight = "rating"  // in proportion to rating
+	SampleRecency SampleWeight = "recency" // recently changed users, newest most
+)
+
+// ParseSampleWeight validates a weighting name; "" means uniform
+func ParseSampleWeight(name string) (SampleWeight, error) {
+	switch SampleWeight(name) {
+	case "", SampleUniform:
+		return SampleUniform, nil
+	case SampleRating, SampleRecency:
+		return SampleWeight(name), nil
+	}
+	return "", fmt.Errorf("unknown sample weight %q (want uniform, rating or recency)", name)
+}
+
+// sampleEntry is a candidate with its random key; the n largest keys win
+type sampleEntry struct {
+	user *models.User
+	key  float64
+}
+
+// sampleHeap is a min-heap on key, so the weakest pick is replaced first
+type sampleHeap []sampleEntry
+
+func (h sampleHeap) Len() int            { return len(h) }
+func (h sampleHeap) Less(i, j int) bool  { return h[i].key < h[j].key }
+func (h sampleHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sampleHeap) Push(x interface{}) { *h = append(*h, x.(sampleEntry)) }
+func (h *sampleHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// sampler picks n distinct candidates at random in proportion to their
+// weights in one pass (Efraimidis-Spirakis): each gets the key u^(1/w) for
+// uniform u, and the n largest keys are kept
+type sampler struct {
+	n    int
+	best sampleHeap
+}
+
+func (s *sampler) offer(user *models.User, weight float64) {
+	if weight <= 0 {
+		return
+	}
+	key := math.Pow(rand.Float64(), 1/weight)
+	if len(s.best) < s.n {
+		heap.Push(&s.best, sampleEntry{user: user, key: key})
+	} else if key > s.best[0].key {
+		s.best[0] = sampleEntry{user: user, key: key}
+		heap.Fix(&s.best, 0)
+	}
+}
+
+// users returns copies of the picks, in random order
+func (s *sampler) users() []models.User {
+	users := make([]models.User, len(s.best))
+	for i, entry := range s.best {
+		users[i] = *entry.user
+	}
+	rand.Shuffle(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] })
+	return users
+}
+
+// SampleUsersContext picks up to n distinct users at random. Uniform and
+// rating weighting consider every user; recency considers only the users
+// in the activity feed, weighting each by how recent its latest change is.
+func (m *MemoryStore) SampleUsersContext(ctx context.Context, n int, weight SampleWeight) ([]models.User, error) {
+	if err := m.rlockContext(ctx); err != nil {
+		return nil, err
+	}
+	defer m.mu.RUnlock()
+
+	s := &sampler{n: n, best: make(sampleHeap, 0, n)}
+	if n <= 0 {
+		return s.users(), nil
+	}
+
+	if weight == SampleRecency {
+		seen := make(map[string]bool)
+		position := 0
+		m.recent.newestFirst(func(entry recentEntry) bool {
+			position++
+			if seen[entry.userID] {
+				return true
+			}
+			seen[entry.userID] = true
+			if user, exists := m.users.get(entry.userID); exists {
+				s.offer(user, float64(recentCapacity-position+1))
+			}
+			return true
+		})
+		return s.users(), nil
+	}
+
+	var err error
+	i := 0
+	m.users.each(func(user *models.User) bool {
+		if i++; i%ctxCheckInterval == 0 && ctx.Err() != nil {
+			err = ctx.Err()
+			return false
+		}
+		if weight == SampleRating {
+			s.offer(user, float64(user.Rating))
+		} else {
+			s.offer(user, 1)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.users(), nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"time"
+
+	"leaderboard-backend/metrics"
+)
+
+// SearchLimits bounds the work one search or suggestion query may do while
+// holding the store's read lock. Short prefixes can match a large share of
+// all users; once a limit is hit the query answers from the candidates
+// scanned so far and reports itself truncated. Zero leaves a limit off.
+type SearchLimits struct {
+	MaxCandidates int           // candidate IDs examined per query
+	TimeBudget    time.Duration // time spent scanning candidates per query
+}
+
+// DefaultSearchLimits keeps even single-letter queries well under the time
+// a leaderboard read would notice
+var DefaultSearchLimits = SearchLimits{
+	MaxCandidates: 50000,
+	TimeBudget:    25 * time.Millisecond,
+}
+
+var searchTruncatedTotal = metrics.NewCounter("store_search_truncated_total", "Search and suggestion queries cut short by their work limits")
+
+// searchBudget tracks one query's use of its limits
+type searchBudget struct {
+	limits   SearchLimits
+	deadline time.Time
+}
+
+func newSearchBudget(limits SearchLimits) searchBudget {
+	b := searchBudget{limits: limits}
+	if limits.TimeBudget > 0 {
+		b.deadline = time.Now().Add(limits.TimeBudget)
+	}
+	return b
+}
+
+// exhausted reports whether the query must stop before examining candidate
+// i. The clock is only read every ctxCheckInterval candidates.
+func (b searchBudget) exhausted(i int) bool {
+	if b.limits.MaxCandidates > 0 && i >= b.limits.MaxCandidates {
+		return true
+	}
+	return !b.deadline.IsZero() && i%ctxCheckInterval == 0 && i > 0 && time.Now().After(b.deadline)
+}
+
+// SetSearchLimits replaces the work limits for search and suggestion queries
+func (m *MemoryStore) SetSearchLimits(limits SearchLimits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.searchLimits = limits
+}
@@ -1,38 +1,70 @@
 package store
 
 import (
+	"context"
 	"leaderboard-backend/models"
-	"math/rand"
 	"sync"
 )
 
 const (
-	MaxLevel    = 16   
-	Probability = 0.25 // Probability for level promotion
+	MaxLevel    = 32   // tallest a skip list can be configured; see SkipListParams
+	Probability = 0.25 // Default probability for level promotion
 )
 
 // SkipListNode represents a node in the skip list
 type SkipListNode struct {
-	User    *models.User
-	forward []*SkipListNode
+	User     *models.User
+	forward  []*SkipListNode
+	span     []int         // level-0 steps to forward[i], or to past the end when it is nil
+	backward *SkipListNode // previous node on level 0, nil for the first
 }
 
-// SkipList is a probabilistic data structure for O(log N) operations
+// SkipList is a probabilistic data structure for O(log N) operations.
+//
+// It has no lock of its own: it is owned by the structure embedding it,
+// whose lock must be held for reading around queries and for writing around
+// Insert, Remove and Clear. Taking a second lock inside every call doubled
+// the locking cost of each store operation without protecting anything the
+// store lock didn't already cover. A standalone skip list is not safe for
+// concurrent use.
 type SkipList struct {
-	mu      sync.RWMutex
+	guard   *sync.RWMutex // owner's lock, checked when lock checks are enabled
+	params  SkipListParams
 	head    *SkipListNode
 	level   int
 	length  int
 	nodeMap map[string]*SkipListNode // userID -> node for O(1) lookup
+	arena   nodeArena                // where nodes come from and go back to
+
+	// Diagnostics
+	levelCounts     [MaxLevel]int // nodes whose tower reaches each level
+	maxLevelReached int           // highest level ever used
+	searches        int64         // traversals performed by Insert/Remove
+	searchSteps     int64         // total pointer hops across those traversals
 }
 
-// NewSkipList creates a new skip list
+// NewSkipList creates a new skip list for single-goroutine use or for an
+// owner that serializes access itself
 func NewSkipList() *SkipList {
+	return NewGuardedSkipList(nil)
+}
+
+// NewGuardedSkipList creates a skip list protected by guard, its owner's
+// lock. With lock checks enabled, calls made without guard held panic.
+func NewGuardedSkipList(guard *sync.RWMutex) *SkipList {
+	return NewGuardedSkipListWithParams(guard, DefaultSkipListParams())
+}
+
+// NewGuardedSkipListWithParams is NewGuardedSkipList shaped by params
+func NewGuardedSkipListWithParams(guard *sync.RWMutex, params SkipListParams) *SkipList {
 	head := &SkipListNode{
 		User:    nil,
-		forward: make([]*SkipListNode, MaxLevel),
+		forward: make([]*SkipListNode, params.MaxLevel),
+		span:    make([]int, params.MaxLevel),
 	}
 	return &SkipList{
+		guard:   guard,
+		params:  params,
 		head:    head,
 		level:   0,
 		length:  0,
@@ -40,14 +72,6 @@ func NewSkipList() *SkipList {
 	}
 }
 
-// randomLevel generates a random level for a new node
-func (sl *SkipList) randomLevel() int {
-	level := 0
-	for level < MaxLevel-1 && rand.Float64() < Probability {
-		level++
-	}
-	return level
-}
 
 
 func compare(a, b *models.User) int {
@@ -69,46 +93,69 @@ func compare(a, b *models.User) int {
 
 // Insert adds a user to the skip list - O(log N)
 func (sl *SkipList) Insert(user *models.User) {
-	sl.mu.Lock()
-	defer sl.mu.Unlock()
+	sl.assertWriteHeld()
 
 	// Check if already exists
 	if _, exists := sl.nodeMap[user.ID]; exists {
 		return
 	}
 
-	update := make([]*SkipListNode, MaxLevel)
+	update := make([]*SkipListNode, sl.params.MaxLevel)
+	var rank [MaxLevel]int // position of update[i], the head being 0
 	current := sl.head
 
 	// Find position (descending by rating, ascending by username)
+	steps := 0
 	for i := sl.level; i >= 0; i-- {
+		if i < sl.level {
+			rank[i] = rank[i+1]
+		}
 		for current.forward[i] != nil && compare(current.forward[i].User, user) > 0 {
+			rank[i] += current.span[i]
 			current = current.forward[i]
+			steps++
 		}
 		update[i] = current
 	}
+	sl.recordSearch(steps)
 
 	// Generate random level for new node
-	newLevel := sl.randomLevel()
+	newLevel := sl.params.randomLevel()
 
 	// Update skip list level if needed
 	if newLevel > sl.level {
 		for i := sl.level + 1; i <= newLevel; i++ {
 			update[i] = sl.head
+			rank[i] = 0
+			sl.head.span[i] = sl.length
 		}
 		sl.level = newLevel
+		if newLevel > sl.maxLevelReached {
+			sl.maxLevelReached = newLevel
+		}
 	}
 
 	// Create new node
-	newNode := &SkipListNode{
-		User:    user,
-		forward: make([]*SkipListNode, newLevel+1),
-	}
+	newNode := sl.arena.alloc(newLevel)
+	newNode.User = user
 
-	// Insert node at each level
+	// Insert node at each level, splitting the span it lands in
 	for i := 0; i <= newLevel; i++ {
 		newNode.forward[i] = update[i].forward[i]
 		update[i].forward[i] = newNode
+		newNode.span[i] = update[i].span[i] - (rank[0] - rank[i])
+		update[i].span[i] = rank[0] - rank[i] + 1
+		sl.levelCounts[i]++
+	}
+	// Links above the new tower now step over one more node
+	for i := newLevel + 1; i <= sl.level; i++ {
+		update[i].span[i]++
+	}
+	if update[0] != sl.head {
+		newNode.backward = update[0]
+	}
+	if newNode.forward[0] != nil {
+		newNode.forward[0].backward = newNode
 	}
 
 	sl.nodeMap[user.ID] = newNode
@@ -117,8 +164,7 @@ func (sl *SkipList) Insert(user *models.User) {
 
 // Remove deletes a user from the skip list - O(log N)
 func (sl *SkipList) Remove(userID string) bool {
-	sl.mu.Lock()
-	defer sl.mu.Unlock()
+	sl.assertWriteHeld()
 
 	node, exists := sl.nodeMap[userID]
 	if !exists {
@@ -126,16 +172,19 @@ func (sl *SkipList) Remove(userID string) bool {
 	}
 
 	user := node.User
-	update := make([]*SkipListNode, MaxLevel)
+	update := make([]*SkipListNode, sl.params.MaxLevel)
 	current := sl.head
 
 	// Find the node
+	steps := 0
 	for i := sl.level; i >= 0; i-- {
 		for current.forward[i] != nil && compare(current.forward[i].User, user) > 0 {
 			current = current.forward[i]
+			steps++
 		}
 		update[i] = current
 	}
+	sl.recordSearch(steps)
 
 	current = current.forward[0]
 
@@ -163,9 +212,20 @@ func (sl *SkipList) Remove(userID string) bool {
 		}
 	}
 
-	// Remove node from each level
-	for i := 0; i <= sl.level && update[i].forward[i] == node; i++ {
-		update[i].forward[i] = node.forward[i]
+	// Remove node from each level; links passing over it get one shorter
+	for i := 0; i <= sl.level; i++ {
+		if update[i].forward[i] == node {
+			update[i].span[i] += node.span[i] - 1
+			update[i].forward[i] = node.forward[i]
+		} else {
+			update[i].span[i]--
+		}
+	}
+	if node.forward[0] != nil {
+		node.forward[0].backward = node.backward
+	}
+	for i := range node.forward {
+		sl.levelCounts[i]--
 	}
 
 	// Update level if needed
@@ -175,6 +235,7 @@ func (sl *SkipList) Remove(userID string) bool {
 
 	delete(sl.nodeMap, userID)
 	sl.length--
+	sl.arena.release(node)
 	return true
 }
 
@@ -186,18 +247,13 @@ func (sl *SkipList) Update(user *models.User) {
 
 // GetTopN returns top N users starting from offset - O(log N + limit)
 func (sl *SkipList) GetTopN(limit, offset int) []*models.User {
-	sl.mu.RLock()
-	defer sl.mu.RUnlock()
+	sl.assertReadHeld()
 
 	if offset >= sl.length {
 		return []*models.User{}
 	}
 
-	// Skip to offset
-	current := sl.head.forward[0]
-	for i := 0; i < offset && current != nil; i++ {
-		current = current.forward[0]
-	}
+	current := sl.seek(offset)
 
 	// Collect limit users
 	result := make([]*models.User, 0, limit)
@@ -210,39 +266,184 @@ func (sl *SkipList) GetTopN(limit, offset int) []*models.User {
 	return result
 }
 
+// VisitTopN passes up to limit users from offset to visit without copying
+// them - O(log N + limit)
+func (sl *SkipList) VisitTopN(limit, offset int, visit func(user *models.User) bool) {
+	sl.assertReadHeld()
+
+	if offset >= sl.length {
+		return
+	}
+	current := sl.seek(offset)
+	for i := 0; i < limit && current != nil && visit(current.User); i++ {
+		current = current.forward[0]
+	}
+}
+
+// seek returns the node at offset (0 for the first) by following spans down
+// the levels - O(log N). The caller checks offset is within the list.
+func (sl *SkipList) seek(offset int) *SkipListNode {
+	current := sl.head
+	traversed := 0
+	for i := sl.level; i >= 0; i-- {
+		for current.forward[i] != nil && traversed+current.span[i] <= offset+1 {
+			traversed += current.span[i]
+			current = current.forward[i]
+		}
+	}
+	return current
+}
+
+// Position returns the 1-based place of a user in leaderboard order. Unlike
+// a rank, it differs between users with the same rating. O(log N).
+func (sl *SkipList) Position(userID string) (int, bool) {
+	sl.assertReadHeld()
+
+	node, exists := sl.nodeMap[userID]
+	if !exists {
+		return 0, false
+	}
+
+	current := sl.head
+	position := 0
+	for i := sl.level; i >= 0; i-- {
+		for current.forward[i] != nil && compare(current.forward[i].User, node.User) > 0 {
+			position += current.span[i]
+			current = current.forward[i]
+		}
+	}
+	// Step over any nodes that compare equal to reach this exact one
+	for current != node {
+		current = current.forward[0]
+		position++
+	}
+	return position, true
+}
+
+// GetTopNFiltered returns up to limit users that satisfy keep, skipping the
+// first offset matches. It walks level 0, so cost is proportional to the number
+// of users examined rather than O(log N); the walk stops early once ctx ends.
+func (sl *SkipList) GetTopNFiltered(ctx context.Context, limit, offset int, keep func(user *models.User) bool) ([]*models.User, error) {
+	sl.assertReadHeld()
+
+	result := make([]*models.User, 0, limit)
+	skipped := 0
+	examined := 0
+	for current := sl.head.forward[0]; current != nil && len(result) < limit; current = current.forward[0] {
+		if examined++; examined%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !keep(current.User) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		userCopy := *current.User
+		result = append(result, &userCopy)
+	}
+
+	return result, nil
+}
+
+// After returns up to limit users ranked strictly after the position of
+// after (by rating, then username) that pass keep, or all users if keep is
+// nil. The start is found by a seek down the levels, so this is
+// O(log N + limit) however deep the position is.
+func (sl *SkipList) After(ctx context.Context, after *models.User, limit int, keep func(user *models.User) bool) ([]*models.User, error) {
+	sl.assertReadHeld()
+
+	current := sl.head
+	for i := sl.level; i >= 0; i-- {
+		for current.forward[i] != nil && compare(current.forward[i].User, after) >= 0 {
+			current = current.forward[i]
+		}
+	}
+
+	result := make([]*models.User, 0, limit)
+	examined := 0
+	for current = current.forward[0]; current != nil && len(result) < limit; current = current.forward[0] {
+		if examined++; examined%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if keep != nil && !keep(current.User) {
+			continue
+		}
+		userCopy := *current.User
+		result = append(result, &userCopy)
+	}
+	return result, nil
+}
+
+// Around returns the user with up to above users ranked just ahead of them
+// and up to below just behind, in leaderboard order, and the user's index
+// in that slice, or -1 if they aren't listed. The node is found through the
+// ID map and level 0 is linked both ways, so this is O(above + below).
+func (sl *SkipList) Around(userID string, above, below int) ([]*models.User, int) {
+	sl.assertReadHeld()
+
+	node, exists := sl.nodeMap[userID]
+	if !exists {
+		return nil, -1
+	}
+
+	first := node
+	for i := 0; i < above && first.backward != nil; i++ {
+		first = first.backward
+	}
+	result := make([]*models.User, 0, above+1+below)
+	index := -1
+	for current, after := first, -1; current != nil && after < below; current = current.forward[0] {
+		if current == node {
+			index = len(result)
+		}
+		if index >= 0 {
+			after++
+		}
+		userCopy := *current.User
+		result = append(result, &userCopy)
+	}
+	return result, index
+}
+
+// LockFreeReads is false: every query needs the owner's lock
+func (sl *SkipList) LockFreeReads() bool {
+	return false
+}
+
 // Length returns the number of elements in the skip list
 func (sl *SkipList) Length() int {
-	sl.mu.RLock()
-	defer sl.mu.RUnlock()
+	sl.assertReadHeld()
 	return sl.length
 }
 
 // Contains checks if a user exists in the skip list
 func (sl *SkipList) Contains(userID string) bool {
-	sl.mu.RLock()
-	defer sl.mu.RUnlock()
+	sl.assertReadHeld()
 	_, exists := sl.nodeMap[userID]
 	return exists
 }
 
 // Clear removes all elements from the skip list
 func (sl *SkipList) Clear() {
-	sl.mu.Lock()
-	defer sl.mu.Unlock()
+	sl.assertWriteHeld()
 
 	sl.head = &SkipListNode{
 		User:    nil,
-		forward: make([]*SkipListNode, MaxLevel),
+		forward: make([]*SkipListNode, sl.params.MaxLevel),
+		span:    make([]int, sl.params.MaxLevel),
 	}
 	sl.level = 0
 	sl.length = 0
 	sl.nodeMap = make(map[string]*SkipListNode)
+	sl.levelCounts = [MaxLevel]int{}
+	sl.arena.reset()
 }
 
 // GetAllUserIDs returns all user IDs (for simulator)
 func (sl *SkipList) GetAllUserIDs() []string {
-	sl.mu.RLock()
-	defer sl.mu.RUnlock()
+	sl.assertReadHeld()
 
 	ids := make([]string, 0, sl.length)
 	for id := range sl.nodeMap {
@@ -250,3 +451,39 @@ func (sl *SkipList) GetAllUserIDs() []string {
 	}
 	return ids
 }
+
+// recordSearch accumulates traversal cost; callers must hold the write lock
+func (sl *SkipList) recordSearch(steps int) {
+	sl.searches++
+	sl.searchSteps += int64(steps)
+}
+
+// GetStats returns structural diagnostics used to verify the skip list
+// stays balanced: node count per level, current and peak height, and the
+// average number of pointer hops per search.
+func (sl *SkipList) GetStats() map[string]interface{} {
+	sl.assertReadHeld()
+
+	nodesPerLevel := make([]int, 0, sl.level+1)
+	for i := 0; i <= sl.level; i++ {
+		nodesPerLevel = append(nodesPerLevel, sl.levelCounts[i])
+	}
+
+	avgSearchDepth := 0.0
+	if sl.searches > 0 {
+		avgSearchDepth = float64(sl.searchSteps) / float64(sl.searches)
+	}
+
+	return map[string]interface{}{
+		"implementation":    SkipListLocked,
+		"length":            sl.length,
+		"current_level":     sl.level,
+		"max_level_reached": sl.maxLevelReached,
+		"max_level":         sl.params.MaxLevel,
+		"probability":       sl.params.Probability,
+		"nodes_per_level":   nodesPerLevel,
+		"searches":          sl.searches,
+		"avg_search_depth":  avgSearchDepth,
+		"arena":             sl.arena.stats(),
+	}
+}
@@ -0,0 +1,90 @@
+package store
+
+// Slab sizes for nodeArena: nodes per slab, and forward pointers (and as
+// many spans) per link slab. A quarter of towers are taller than one level,
+// so a link slab serves roughly as many nodes as a node slab.
+const (
+	arenaSlabNodes = 1024
+	arenaSlabLinks = 1536
+)
+
+// nodeArena carves SkipList nodes and their forward and span slices out of
+// large slabs instead of allocating three objects per node. On a board of
+// millions the heap then holds thousands of slabs rather than millions of
+// small objects, which shortens GC marking, and nodes inserted together
+// sit next to each other in memory. Removed nodes are kept on free lists
+// by tower height and handed out again, since a slab is only freed when
+// none of its nodes is referenced.
+//
+// Like SkipList it relies on the owner's write lock.
+type nodeArena struct {
+	nodes []SkipListNode            // rest of the current node slab
+	links []*SkipListNode           // rest of the current link slab
+	spans []int                     // rest of the current span slab, as long as links
+	free  [MaxLevel][]*SkipListNode // released nodes by tower height - 1
+
+	slabs     int   // node and link slabs allocated
+	allocated int64 // nodes carved from slabs
+	reused    int64 // nodes handed out again from the free lists
+	freeNodes int
+}
+
+// alloc returns a node with a tower of level+1 links for user
+func (a *nodeArena) alloc(level int) *SkipListNode {
+	if n := len(a.free[level]); n > 0 {
+		node := a.free[level][n-1]
+		a.free[level][n-1] = nil
+		a.free[level] = a.free[level][:n-1]
+		a.freeNodes--
+		a.reused++
+		return node
+	}
+
+	if len(a.nodes) == 0 {
+		a.nodes = make([]SkipListNode, arenaSlabNodes)
+		a.slabs++
+	}
+	node := &a.nodes[0]
+	a.nodes = a.nodes[1:]
+
+	height := level + 1
+	if len(a.links) < height {
+		a.links = make([]*SkipListNode, max(arenaSlabLinks, height))
+		a.spans = make([]int, len(a.links))
+		a.slabs++
+	}
+	// Full slice expressions stop an append from running into a neighbour
+	node.forward = a.links[:height:height]
+	node.span = a.spans[:height:height]
+	a.links = a.links[height:]
+	a.spans = a.spans[height:]
+	a.allocated++
+	return node
+}
+
+// release takes back a node unlinked from the list, dropping its pointers
+// so the users and nodes it referred to can be collected
+func (a *nodeArena) release(node *SkipListNode) {
+	node.User = nil
+	node.backward = nil
+	clear(node.forward)
+	clear(node.span)
+	level := len(node.forward) - 1
+	a.free[level] = append(a.free[level], node)
+	a.freeNodes++
+}
+
+// reset forgets every slab, leaving them to the garbage collector
+func (a *nodeArena) reset() {
+	*a = nodeArena{}
+}
+
+// stats reports slab and reuse counters for GetStats
+func (a *nodeArena) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"slabs":      a.slabs,
+		"allocated":  a.allocated,
+		"reused":     a.reused,
+		"free_nodes": a.freeNodes,
+	}
+}
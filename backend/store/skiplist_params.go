@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// minAutoLevel is the lowest height AutoMaxLevel picks
+const minAutoLevel = 4
+
+// SkipListParams shapes a skip list: towers grow one level with chance
+// Probability, up to MaxLevel levels. The best height is log base
+// 1/Probability of the user count; fewer levels slow searches on a big
+// board, more only cost memory in every search's scratch space.
+type SkipListParams struct {
+	MaxLevel    int
+	Probability float64
+}
+
+// DefaultSkipListParams are the parameters skip lists had before they were
+// configurable: 16 levels, each reached by a quarter of the one below
+func DefaultSkipListParams() SkipListParams {
+	return SkipListParams{MaxLevel: 16, Probability: Probability}
+}
+
+// NewSkipListParams validates skip list parameters. A maxLevel of 0 is
+// picked from expectedUsers, the number of users the list should stay fast
+// up to.
+func NewSkipListParams(maxLevel int, probability float64, expectedUsers int) (SkipListParams, error) {
+	if probability <= 0 || probability >= 1 {
+		return SkipListParams{}, fmt.Errorf("promotion probability must be between 0 and 1, got %v", probability)
+	}
+	if maxLevel == 0 {
+		maxLevel = AutoMaxLevel(expectedUsers, probability)
+	}
+	if maxLevel < 1 || maxLevel > MaxLevel {
+		return SkipListParams{}, fmt.Errorf("max level must be between 1 and %d, got %d", MaxLevel, maxLevel)
+	}
+	return SkipListParams{MaxLevel: maxLevel, Probability: probability}, nil
+}
+
+// AutoMaxLevel is the height suited to a list of expectedUsers: one level
+// more than log base 1/probability of the count, between 4 and MaxLevel
+func AutoMaxLevel(expectedUsers int, probability float64) int {
+	if expectedUsers < 1 {
+		expectedUsers = 1
+	}
+	level := int(math.Ceil(math.Log(float64(expectedUsers))/math.Log(1/probability))) + 1
+	return min(max(level, minAutoLevel), MaxLevel)
+}
+
+// randomLevel generates a random level for a new node
+func (p SkipListParams) randomLevel() int {
+	level := 0
+	for level < p.MaxLevel-1 && rand.Float64() < p.Probability {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"leaderboard-backend/models"
+)
+
+var (
+	ErrInvalidSnapshotName = errors.New("snapshot names may only contain letters, digits, '-' and '_' (max 64)")
+	ErrSnapshotExists      = errors.New("a snapshot with this name already exists")
+	ErrSnapshotNotFound    = errors.New("snapshot not found")
+)
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// NamedSnapshots keeps immutable, named copies of the leaderboard on disk,
+// one JSON file per snapshot, for later comparison
+type NamedSnapshots struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// namedSnapshotData is the on-disk format of a named snapshot
+type namedSnapshotData struct {
+	Name      string        `json:"name"`
+	CreatedAt time.Time     `json:"created_at"`
+	Users     []models.User `json:"users"`
+	Version   int           `json:"version"`
+}
+
+// NewNamedSnapshots stores snapshots under dir, creating it on first write
+func NewNamedSnapshots(dir string) *NamedSnapshots {
+	return &NamedSnapshots{dir: dir}
+}
+
+func (n *NamedSnapshots) path(name string) string {
+	return filepath.Join(n.dir, name+".json")
+}
+
+// Create writes the current contents of the store as a new snapshot
+func (n *NamedSnapshots) Create(name string, store *MemoryStore) (*models.SnapshotInfo, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return nil, ErrInvalidSnapshotName
+	}
+
+	data := namedSnapshotData{
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+		Users:     store.Snapshot(),
+		Version:   1,
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, err := os.Stat(n.path(name)); err == nil {
+		return nil, ErrSnapshotExists
+	}
+	if err := os.MkdirAll(n.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tempPath := n.path(name) + ".tmp"
+	if err := writeJSONFile(tempPath, data, false); err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+	if err := os.Rename(tempPath, n.path(name)); err != nil {
+		os.Remove(tempPath)
+		return nil, fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	return &models.SnapshotInfo{
+		Name:      name,
+		CreatedAt: data.CreatedAt,
+		UserCount: len(data.Users),
+	}, nil
+}
+
+// Load reads every user in a snapshot
+func (n *NamedSnapshots) Load(name string) ([]models.User, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return nil, ErrSnapshotNotFound
+	}
+
+	file, err := os.Open(n.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	var data namedSnapshotData
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	return data.Users, nil
+}
+
+// List returns the stored snapshots, oldest first. Only file metadata is
+// read, so UserCount is not filled in.
+func (n *NamedSnapshots) List() ([]models.SnapshotInfo, error) {
+	entries, err := os.ReadDir(n.dir)
+	if os.IsNotExist(err) {
+		return []models.SnapshotInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	list := make([]models.SnapshotInfo, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || name == entry.Name() || !snapshotNamePattern.MatchString(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, models.SnapshotInfo{
+			Name:      name,
+			CreatedAt: info.ModTime().UTC(),
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list, nil
+}
@@ -0,0 +1,49 @@
+package store
+
+import "fmt"
+
+// Source says where a rating change came from, so organic play can be told
+// apart from simulation and maintenance in the activity feed and the
+// write-ahead log
+type Source string
+
+const (
+	SourceAPI       Source = "api"       // a client set the rating through the API
+	SourceSimulator Source = "simulator" // the score simulator or generated seed data
+	SourceMatch     Source = "match"     // the result of a played match
+	SourceDecay     Source = "decay"     // inactivity decay
+	SourceImport    Source = "import"    // loaded from a snapshot, log or fixture
+	SourceAdmin     Source = "admin"     // an operator correction
+)
+
+// sources lists every Source, in the order their counters are reported
+var sources = [...]Source{SourceAPI, SourceSimulator, SourceMatch, SourceDecay, SourceImport, SourceAdmin}
+
+// ParseSource validates a source name
+func ParseSource(name string) (Source, error) {
+	for _, source := range sources {
+		if string(source) == name {
+			return source, nil
+		}
+	}
+	return "", fmt.Errorf("unknown source %q (want api, simulator, match, decay, import or admin)", name)
+}
+
+// index returns the position of s in sources, treating unknown values as
+// SourceAPI
+func (s Source) index() int {
+	for i, source := range sources {
+		if source == s {
+			return i
+		}
+	}
+	return 0
+}
+
+// orDefault returns s, or SourceAPI when s is unset
+func (s Source) orDefault() Source {
+	if s == "" {
+		return SourceAPI
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"leaderboard-backend/models"
+)
+
+// userStripeCount is the number of independently locked parts of the user map
+const userStripeCount = 64
+
+// userStripe is one lock-protected part of the user map
+type userStripe struct {
+	mu    sync.RWMutex
+	users map[string]*models.User
+}
+
+// userStripes partitions the user map by ID hash so lookups and rating
+// changes for unrelated users don't serialize on the store lock.
+//
+// Locking rules:
+//   - Adding or removing users, and changing a user's rating, requires both
+//     the user's stripe lock and the MemoryStore write lock.
+//   - Reading therefore needs either the stripe lock or the store lock.
+//   - Stripe locks are always taken before the store lock, in ascending
+//     stripe order when several are needed.
+type userStripes struct {
+	stripes [userStripeCount]userStripe
+	count   int64 // atomic
+}
+
+func newUserStripes() *userStripes {
+	s := &userStripes{}
+	for i := range s.stripes {
+		s.stripes[i].users = make(map[string]*models.User)
+	}
+	return s
+}
+
+func stripeIndex(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % userStripeCount)
+}
+
+func (s *userStripes) stripe(id string) *userStripe {
+	return &s.stripes[stripeIndex(id)]
+}
+
+// get looks a user up; the caller holds the stripe lock or the store lock
+func (s *userStripes) get(id string) (*models.User, bool) {
+	user, ok := s.stripe(id).users[id]
+	return user, ok
+}
+
+// set and del change the map; the caller holds the stripe and store write locks
+func (s *userStripes) set(user *models.User) {
+	s.stripe(user.ID).users[user.ID] = user
+	atomic.AddInt64(&s.count, 1)
+}
+
+func (s *userStripes) del(id string) {
+	delete(s.stripe(id).users, id)
+	atomic.AddInt64(&s.count, -1)
+}
+
+func (s *userStripes) len() int {
+	return int(atomic.LoadInt64(&s.count))
+}
+
+// each visits every user until fn returns false; the caller holds the store lock
+func (s *userStripes) each(fn func(user *models.User) bool) {
+	for i := range s.stripes {
+		for _, user := range s.stripes[i].users {
+			if !fn(user) {
+				return
+			}
+		}
+	}
+}
+
+// reset empties every stripe; the caller holds all stripe locks and the store write lock
+func (s *userStripes) reset() {
+	for i := range s.stripes {
+		s.stripes[i].users = make(map[string]*models.User)
+	}
+	atomic.StoreInt64(&s.count, 0)
+}
+
+// lock write-locks the stripes of ids in ascending order and returns the unlock
+func (s *userStripes) lock(ids ...string) func() {
+	seen := make(map[int]bool, len(ids))
+	indexes := make([]int, 0, len(ids))
+	for _, id := range ids {
+		idx := stripeIndex(id)
+		if !seen[idx] {
+			seen[idx] = true
+			indexes = append(indexes, idx)
+		}
+	}
+	sort.Ints(indexes)
+
+	for _, idx := range indexes {
+		s.stripes[idx].mu.Lock()
+	}
+	return func() {
+		for i := len(indexes) - 1; i >= 0; i-- {
+			s.stripes[indexes[i]].mu.Unlock()
+		}
+	}
+}
+
+// lockAll write-locks every stripe and returns the unlock
+func (s *userStripes) lockAll() func() {
+	for i := range s.stripes {
+		s.stripes[i].mu.Lock()
+	}
+	return func() {
+		for i := len(s.stripes) - 1; i >= 0; i-- {
+			s.stripes[i].mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"leaderboard-backend/models"
+)
+
+// Write-ahead log operations
+const (
+	walSet    = "set"    // add a user or change their rating
+	walDelete = "delete" // remove a user
+	walClear  = "clear"  // remove every user
+	walMerge  = "merge"  // drop a user merged into another and tombstone their ID
+	walBot    = "bot"    // flag or unflag a user as a bot
+)
+
+// walEntry is one line of the write-ahead log. Entries describe the state
+// after the change rather than the change itself, so replaying an entry the
+// snapshot already contains is harmless.
+type walEntry struct {
+	Op       string `json:"op"`
+	ID       string `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Rating   int    `json:"rating,omitempty"`
+	Source   Source `json:"source,omitempty"` // what made a set; absent in older logs
+	Into     string `json:"into,omitempty"`   // the surviving user of a merge
+	Bot      bool   `json:"bot,omitempty"`    // the user's bot flag after a set or bot entry
+
+	RD         float64 `json:"rd,omitempty"` // Glicko-2 rating deviation and volatility after a set
+	Volatility float64 `json:"volatility,omitempty"`
+}
+
+// WAL is an append-only journal of store mutations since the last snapshot.
+// Entries are buffered and written in batches by Flush; under SyncWALBatch
+// every batch is fsynced. Each save rotates the log first and drops the
+// rotated part once the snapshot is safely on disk.
+type WAL struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	buf     *bufio.Writer
+	policy  SyncPolicy
+	pending int // entries buffered since the last flush
+
+	appended uint64
+	batches  uint64
+	lastErr  error
+}
+
+// OpenWAL opens (or creates) the log at path for appending
+func OpenWAL(path string, policy SyncPolicy) (*WAL, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	return &WAL{
+		path:   path,
+		file:   file,
+		buf:    bufio.NewWriterSize(file, 64*1024),
+		policy: policy,
+	}, nil
+}
+
+// Path returns the location of the active log file
+func (w *WAL) Path() string {
+	return w.path
+}
+
+func (w *WAL) rotatedPath() string {
+	return w.path + ".1"
+}
+
+// append buffers an entry; the store calls it while holding its write lock,
+// so log order matches the order mutations were applied
+func (w *WAL) append(entry walEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return
+	}
+	w.buf.Write(line)
+	w.buf.WriteByte('\n')
+	w.pending++
+	w.appended++
+}
+
+// Flush writes buffered entries to the log as one batch
+func (w *WAL) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.flushLocked()
+}
+
+func (w *WAL) flushLocked() error {
+	if w.file == nil || w.pending == 0 {
+		return nil
+	}
+
+	err := w.buf.Flush()
+	if err == nil && w.policy == SyncWALBatch {
+		err = w.file.Sync()
+	}
+	w.lastErr = err
+	if err != nil {
+		return fmt.Errorf("failed to flush write-ahead log: %w", err)
+	}
+	w.pending = 0
+	w.batches++
+	return nil
+}
+
+// Rotate moves the current log aside before a snapshot is taken. If an
+// earlier rotated log is still there (its save failed), the current log is
+// appended to it so no entries are lost.
+func (w *WAL) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.flushLocked(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close write-ahead log: %w", err)
+	}
+
+	var err error
+	if _, statErr := os.Stat(w.rotatedPath()); statErr == nil {
+		err = appendFile(w.rotatedPath(), w.path)
+		if err == nil {
+			err = os.Remove(w.path)
+		}
+	} else {
+		err = os.Rename(w.path, w.rotatedPath())
+	}
+
+	// Always reopen so mutations keep being journaled
+	file, openErr := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if openErr != nil {
+		w.file = nil
+		return fmt.Errorf("failed to reopen write-ahead log: %w", openErr)
+	}
+	w.file = file
+	w.buf.Reset(file)
+
+	if err != nil {
+		return fmt.Errorf("failed to rotate write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// DiscardRotated deletes the rotated log once a snapshot covers it
+func (w *WAL) DiscardRotated() error {
+	if err := os.Remove(w.rotatedPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Close flushes and closes the log
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	flushErr := w.flushLocked()
+	closeErr := w.file.Close()
+	w.file = nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// Pending returns how many entries are buffered awaiting the next flush.
+// It only grows between flushes, or while flushes keep failing.
+func (w *WAL) Pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.pending
+}
+
+// GetStats returns write-ahead log statistics
+func (w *WAL) GetStats() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lastErr := ""
+	if w.lastErr != nil {
+		lastErr = w.lastErr.Error()
+	}
+
+	return map[string]interface{}{
+		"path":        w.path,
+		"appended":    w.appended,
+		"batches":     w.batches,
+		"pending":     w.pending,
+		"sync_policy": w.policy,
+		"last_error":  lastErr,
+	}
+}
+
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// walReplay summarises one replay of the write-ahead log
+type walReplay struct {
+	Files          []string `json:"files"`
+	Entries        int      `json:"entries"`
+	TruncatedTail  bool     `json:"truncated_tail"`  // a torn final line was skipped
+	CorruptEntries int      `json:"corrupt_entries"` // unreadable lines skipped before the tail
+}
+
+// replayWAL applies the rotated log, then the active log, on top of store.
+// Only the last line of the newest segment can be a torn write; an
+// unreadable line anywhere else is logged and skipped so the records after
+// it still replay.
+func replayWAL(path string, store *MemoryStore) (walReplay, error) {
+	replay := walReplay{Files: make([]string, 0, 2)}
+
+	newest := path
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		newest = path + ".1"
+	}
+	for _, file := range []string{path + ".1", path} {
+		err := replayWALFile(file, file == newest, store, &replay)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return replay, err
+		}
+		replay.Files = append(replay.Files, file)
+	}
+	return replay, nil
+}
+
+func replayWALFile(path string, newest bool, store *MemoryStore, replay *walReplay) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read write-ahead log: %w", err)
+		}
+		if len(line) == 0 {
+			return nil
+		}
+
+		var entry walEntry
+		if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+			last := err == io.EOF
+			if !last {
+				_, peekErr := reader.Peek(1)
+				last = peekErr == io.EOF
+			}
+			if newest && last {
+				// The final write was cut off mid-line
+				replay.TruncatedTail = true
+				return nil
+			}
+			log.Printf("WAL replay: skipping corrupt record at %s:%d: %v\n", path, lineNo, jsonErr)
+			replay.CorruptEntries++
+		} else {
+			store.applyWALEntry(entry)
+			replay.Entries++
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// applyWALEntry replays one logged mutation without journaling it again,
+// keeping the source it was logged with
+func (m *MemoryStore) applyWALEntry(entry walEntry) {
+	source := entry.Source
+	if source == "" {
+		source = SourceImport
+	}
+	switch entry.Op {
+	case walSet:
+		if _, err := m.GetUser(entry.ID); err == nil {
+			m.UpdateRatingState(context.Background(), entry.ID, RatingState{Rating: entry.Rating, RD: entry.RD, Volatility: entry.Volatility}, source)
+			return
+		}
+		m.AddUserFrom(&models.User{ID: entry.ID, Username: entry.Username, Rating: entry.Rating, Bot: entry.Bot,
+			RD: entry.RD, Volatility: entry.Volatility}, source)
+	case walDelete:
+		m.DeleteUser(entry.ID)
+	case walClear:
+		m.Clear()
+	case walMerge:
+		m.replayMerge(entry.ID, entry.Into)
+	case walBot:
+		m.SetBot(entry.ID, entry.Bot)
+	}
+}
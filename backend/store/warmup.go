@@ -0,0 +1,99 @@
+package store
+
+import (
+	"sync/atomic"
+
+	"leaderboard-backend/models"
+)
+
+// deferredList is the store's RankedList while its indexes are deferred:
+// Insert and Remove do nothing until BuildIndexes fills the list from the
+// user map, after which it passes everything through. Reads always go to
+// the list underneath, empty or partly built as it may be.
+type deferredList struct {
+	RankedList
+	pending bool // guarded by the store's mu
+}
+
+func (d *deferredList) Insert(user *models.User) {
+	if !d.pending {
+		d.RankedList.Insert(user)
+	}
+}
+
+func (d *deferredList) Remove(userID string) bool {
+	if d.pending {
+		return false
+	}
+	return d.RankedList.Remove(userID)
+}
+
+// indexWarmup tracks BuildIndexes for IndexProgress
+type indexWarmup struct {
+	deferred *deferredList // nil unless DeferIndexes was called; guarded by mu
+	indexed  int64         // atomic
+	total    int64         // atomic
+	building uint32        // atomic; 1 from DeferIndexes until BuildIndexes is done
+}
+
+// IndexProgress is how far BuildIndexes has got
+type IndexProgress struct {
+	Indexed int
+	Total   int
+	Ready   bool
+}
+
+// DeferIndexes makes later loads and changes fill only the user map and
+// rating index, leaving the skip list and username index for BuildIndexes.
+// Until then the store finds users by ID and counts ranks by rating, but
+// lists and searches nobody. Call it on a new store, before loading.
+func (m *MemoryStore) DeferIndexes() {
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	if m.warmup.deferred != nil {
+		return
+	}
+	m.warmup.deferred = &deferredList{RankedList: m.skipList, pending: true}
+	m.skipList = m.warmup.deferred
+	atomic.StoreUint32(&m.warmup.building, 1)
+}
+
+// indexesDeferred reports whether the username index is waiting for
+// BuildIndexes; the caller holds mu
+func (m *MemoryStore) indexesDeferred() bool {
+	return m.warmup.deferred != nil && m.warmup.deferred.pending
+}
+
+// BuildIndexes builds the skip list and username index deferred by
+// DeferIndexes from every user now in the store, reporting progress through
+// IndexProgress. It holds the store write lock throughout, so reads wait
+// for it rather than see part of the ranking. It does nothing unless
+// indexes were deferred.
+func (m *MemoryStore) BuildIndexes() {
+	m.lockRanking()
+	defer m.unlockRanking()
+
+	if !m.indexesDeferred() {
+		return
+	}
+	m.warmup.deferred.pending = false
+	atomic.StoreInt64(&m.warmup.total, int64(m.users.len()))
+	m.users.each(func(user *models.User) bool {
+		m.skipList.Insert(user)
+		m.indexUsername(user.ID, user.Username)
+		atomic.AddInt64(&m.warmup.indexed, 1)
+		return true
+	})
+	atomic.StoreUint32(&m.warmup.building, 0)
+}
+
+// IndexProgress reports BuildIndexes' progress. A store whose indexes were
+// never deferred is always ready.
+func (m *MemoryStore) IndexProgress() IndexProgress {
+	return IndexProgress{
+		Indexed: int(atomic.LoadInt64(&m.warmup.indexed)),
+		Total:   int(atomic.LoadInt64(&m.warmup.total)),
+		Ready:   atomic.LoadUint32(&m.warmup.building) == 0,
+	}
+}
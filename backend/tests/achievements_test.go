@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+
+	"github.com/gorilla/mux"
+)
+
+func getAchievements(router *mux.Router, id string) (int, models.AchievementsResponse) {
+	req, _ := http.NewRequest("GET", "/api/users/"+id+"/achievements", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response models.AchievementsResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	return rr.Code, response
+}
+
+func achievementIDs(response models.AchievementsResponse) []string {
+	ids := make([]string, len(response.Achievements))
+	for i, achievement := range response.Achievements {
+		ids[i] = achievement.ID
+	}
+	return ids
+}
+
+func TestAchievements_UnlockedFromChanges(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "achievements.json")
+	memoryStore := store.NewMemoryStore(store.NewRatingBucketIndex())
+
+	// 150 users rated 2000 and up push the newcomer out of the top 100
+	for i := 0; i < 150; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("pro-%d", i), Username: fmt.Sprintf("pro%d", i), Rating: 2000 + i})
+	}
+
+	hub := services.NewLiveHub()
+	memoryStore.AddChangeFeed(hub.Feed())
+	hub.Start()
+	defer hub.Stop(ctx)
+	sub, _ := hub.Subscribe()
+
+	service := services.NewAchievementService(memoryStore, store.NewAchievementLedger(path))
+	service.SetLiveHub(hub)
+	memoryStore.AddChangeFeed(service.Feed())
+	service.Start()
+
+	memoryStore.AddUser(&models.User{ID: "rookie", Username: "rookie", Rating: 1000})
+	// Nine wins, a loss, then ten wins; admin corrections don't break a streak
+	rating := 1000
+	for i := 0; i < 20; i++ {
+		if i == 9 {
+			rating -= 10
+		} else {
+			rating += 10
+		}
+		memoryStore.UpdateRatingFrom(ctx, "rookie", rating, store.SourceMatch)
+		if i == 12 {
+			memoryStore.UpdateRatingFrom(ctx, "rookie", rating+1, store.SourceAdmin)
+			rating++
+		}
+	}
+	memoryStore.UpdateRatingFrom(ctx, "rookie", 4000, store.SourceAdmin)
+	memoryStore.UpdateRatingFrom(ctx, "rookie", 4001, store.SourceAdmin)
+
+	if err := service.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/users/{id}/achievements", handlers.NewAchievementsHandler(service).GetAchievements).Methods("GET")
+
+	code, response := getAchievements(router, "rookie")
+	ids := achievementIDs(response)
+	if code != http.StatusOK || len(ids) != 3 || ids[0] != services.AchievementWinStreak ||
+		ids[1] != services.AchievementTop100 || ids[2] != services.AchievementRating4000 {
+		t.Fatalf("Expected win streak, top 100 and 4000 rating once each in that order, got %d %v", code, ids)
+	}
+	if response.WinStreak != 10 || response.Achievements[0].Name == "" || response.Achievements[0].UnlockedAt.IsZero() {
+		t.Errorf("Unexpected achievements response: %+v", response)
+	}
+	if code, _ := getAchievements(router, "nobody"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown user, got %d", code)
+	}
+
+	// Each unlock is announced to live clients after the change behind it
+	unlocked := 0
+	timeout := time.After(2 * time.Second)
+	for unlocked < 3 {
+		select {
+		case event := <-sub.Events():
+			if event.Type == "achievement" {
+				if event.Achievement == nil || event.User == nil || event.User.ID != "rookie" {
+					t.Fatalf("Unexpected achievement event: %+v", event)
+				}
+				unlocked++
+			}
+		case <-timeout:
+			t.Fatalf("Expected 3 achievement events, got %d", unlocked)
+		}
+	}
+
+	// Unlocks survive a restart; removed users are forgotten
+	if err := service.Save(); err != nil {
+		t.Fatal(err)
+	}
+	ledger := store.NewAchievementLedger(path)
+	if err := ledger.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Unlocked("rookie")) != 3 || ledger.Streak("rookie") != 10 {
+		t.Errorf("Expected the saved ledger to hold 3 unlocks and the streak, got %v %d", ledger.Unlocked("rookie"), ledger.Streak("rookie"))
+	}
+
+	restarted := services.NewAchievementService(memoryStore, ledger)
+	memoryStore.AddChangeFeed(restarted.Feed())
+	restarted.Start()
+	memoryStore.DeleteUser("rookie")
+	restarted.Stop(ctx)
+	if len(ledger.Unlocked("rookie")) != 0 || ledger.Streak("rookie") != 0 {
+		t.Error("Expected a removed user's achievements to be dropped")
+	}
+}
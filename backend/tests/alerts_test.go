@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"testing"
+
+	"leaderboard-backend/alerts"
+	"leaderboard-backend/metrics"
+)
+
+func TestAlerts_ActivateAndResolve(t *testing.T) {
+	evaluator := alerts.NewEvaluator("")
+
+	value := 5.0
+	evaluator.AddRule(alerts.Rule{
+		Name:      "queue_depth",
+		Threshold: 10,
+		Probe:     func() float64 { return value },
+	})
+	// Zero threshold disables the rule
+	evaluator.AddRule(alerts.Rule{
+		Name:      "disabled",
+		Threshold: 0,
+		Probe:     func() float64 { return 100 },
+	})
+
+	evaluator.Evaluate()
+	list := evaluator.Alerts()
+	if len(list) != 1 {
+		t.Fatalf("Expected 1 configured alert, got %d", len(list))
+	}
+	if list[0].State != alerts.StateOK {
+		t.Errorf("Expected state ok below threshold, got %s", list[0].State)
+	}
+
+	value = 15
+	evaluator.Evaluate()
+	list = evaluator.Alerts()
+	if list[0].State != alerts.StateActive || list[0].ActiveSince == nil {
+		t.Errorf("Expected active alert with start time, got %+v", list[0])
+	}
+	if evaluator.ActiveCount() != 1 {
+		t.Errorf("Expected 1 active alert, got %d", evaluator.ActiveCount())
+	}
+
+	value = 3
+	evaluator.Evaluate()
+	list = evaluator.Alerts()
+	if list[0].State != alerts.StateResolved || list[0].ResolvedAt == nil {
+		t.Errorf("Expected resolved alert with resolve time, got %+v", list[0])
+	}
+}
+
+func TestAlerts_ErrorRateUsesDeltas(t *testing.T) {
+	total := metrics.NewCounter("test_alert_requests_total", "test")
+	failed := metrics.NewCounter("test_alert_errors_total", "test")
+	probe := alerts.ErrorRateProbe(total, failed)
+
+	total.Add(100)
+	failed.Add(10)
+	if rate := probe(); rate != 0.1 {
+		t.Errorf("Expected error rate 0.1, got %v", rate)
+	}
+
+	total.Add(100)
+	if rate := probe(); rate != 0 {
+		t.Errorf("Expected error rate 0 for a window with no errors, got %v", rate)
+	}
+	if rate := probe(); rate != 0 {
+		t.Errorf("Expected error rate 0 with no traffic, got %v", rate)
+	}
+}
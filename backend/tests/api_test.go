@@ -2,17 +2,31 @@ package tests
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"leaderboard-backend/alerts"
 	"leaderboard-backend/config"
+	"leaderboard-backend/fixtures"
 	"leaderboard-backend/handlers"
+	"leaderboard-backend/metrics"
 	"leaderboard-backend/models"
+	"leaderboard-backend/scheduler"
 	"leaderboard-backend/services"
 	"leaderboard-backend/store"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
@@ -23,26 +37,68 @@ func setupTestServer() (*mux.Router, *store.MemoryStore, *store.RatingBucketInde
 	ratingIndex := store.NewRatingBucketIndex()
 	memoryStore := store.NewMemoryStore(ratingIndex)
 
-	userService := services.NewUserService(memoryStore, ratingIndex, cfg.MinRating, cfg.MaxRating)
-	leaderboardService := services.NewLeaderboardService(memoryStore, ratingIndex)
+	presence := store.NewPresenceTracker(time.Duration(cfg.OnlineWindow) * time.Second)
+
+	userService := services.NewUserService(memoryStore, ratingIndex, presence, cfg.MinRating, cfg.MaxRating)
+	usernamePolicy, err := services.NewUsernamePolicy(services.UsernamePolicyConfig(cfg.Usernames))
+	if err != nil {
+		panic(err)
+	}
+	userService.SetUsernamePolicy(usernamePolicy)
+	leaderboardService := services.NewLeaderboardService(memoryStore, ratingIndex, presence)
 	simulator := services.NewScoreSimulator(memoryStore, ratingIndex, cfg.MinRating, cfg.MaxRating, cfg.UpdateInterval)
+	memoryStore.OnMembershipChange(simulator.InvalidateCache)
 
 	leaderboardHandler := handlers.NewLeaderboardHandler(leaderboardService)
-	userHandler := handlers.NewUserHandler(userService, leaderboardService, simulator, cfg.InitialUsers, ratingIndex, memoryStore)
+	tierHandler := handlers.NewTierHandler(services.NewTierService(ratingIndex))
+	seedGuard := services.NewSeedGuard(time.Duration(cfg.SeedCooldown)*time.Second, cfg.IsProduction())
+	userHandler := handlers.NewUserHandler(userService, leaderboardService, simulator, cfg.InitialUsers, ratingIndex, memoryStore, seedGuard)
+	adminHandler := handlers.NewAdminHandler(userService, scheduler.New())
+	alertsHandler := handlers.NewAlertsHandler(alerts.NewEvaluator(""))
 
 	router := mux.NewRouter()
+	router.HandleFunc("/metrics", handlers.NewMetricsHandler(metrics.Default).Prometheus).Methods("GET")
 	api := router.PathPrefix("/api").Subrouter()
 
 	api.HandleFunc("/leaderboard", leaderboardHandler.GetLeaderboard).Methods("GET")
+	api.HandleFunc("/leaderboard/final", leaderboardHandler.GetFinalStandings).Methods("GET")
 	api.HandleFunc("/search", leaderboardHandler.SearchUsers).Methods("GET")
+	api.HandleFunc("/search/suggest", leaderboardHandler.SuggestUsernames).Methods("GET")
+	api.HandleFunc("/stats/buckets", leaderboardHandler.GetRatingDistribution).Methods("GET")
+	api.HandleFunc("/tiers", tierHandler.ListTiers).Methods("GET")
+	api.HandleFunc("/ranks", leaderboardHandler.LookupRanks).Methods("POST")
+	api.HandleFunc("/leaderboard/subset", leaderboardHandler.GetSubsetLeaderboard).Methods("POST")
 	api.HandleFunc("/seed", userHandler.SeedUsers).Methods("POST")
+	api.HandleFunc("/users", userHandler.CreateUser).Methods("POST")
+	api.HandleFunc("/users", userHandler.GetUsers).Methods("GET")
+	api.HandleFunc("/users/recent", userHandler.GetRecentUsers).Methods("GET")
+	api.HandleFunc("/users/sample", userHandler.SampleUsers).Methods("GET")
+	api.HandleFunc("/usernames/check", userHandler.CheckUsername).Methods("GET")
+	api.HandleFunc("/users/by-username/{username}", userHandler.GetUserByUsername).Methods("GET")
 	api.HandleFunc("/users/{id}", userHandler.GetUser).Methods("GET")
+	api.HandleFunc("/users/{id}", userHandler.DeleteUser).Methods("DELETE")
+	api.HandleFunc("/matches", userHandler.RecordMatch).Methods("POST")
+	api.HandleFunc("/users/{id}/context", userHandler.GetAroundUser).Methods("GET")
+	api.HandleFunc("/users/{id}/history", userHandler.GetRatingHistory).Methods("GET")
 	api.HandleFunc("/users/{id}/rating", userHandler.UpdateRating).Methods("PATCH")
+	api.HandleFunc("/users/{id}/heartbeat", userHandler.Heartbeat).Methods("POST")
 	api.HandleFunc("/health", userHandler.Health).Methods("GET")
+	api.HandleFunc("/alerts", alertsHandler.ListAlerts).Methods("GET")
 	api.HandleFunc("/simulator/start", userHandler.StartSimulator).Methods("POST")
 	api.HandleFunc("/simulator/stop", userHandler.StopSimulator).Methods("POST")
+	api.HandleFunc("/simulator/pause", userHandler.PauseSimulator).Methods("POST")
+	api.HandleFunc("/simulator/resume", userHandler.ResumeSimulator).Methods("POST")
+	api.HandleFunc("/simulator/refresh", userHandler.RefreshSimulatorCache).Methods("POST")
 	api.HandleFunc("/simulator/status", userHandler.SimulatorStatus).Methods("GET")
 
+	api.HandleFunc("/admin/users/delete", adminHandler.BulkDeleteUsers).Methods("POST")
+	api.HandleFunc("/admin/users/{keep}/merge/{dupe}", adminHandler.MergeUsers).Methods("POST")
+	api.HandleFunc("/admin/users/{id}/bot", adminHandler.SetBot).Methods("PUT")
+	api.HandleFunc("/admin/export/users.csv.gz", leaderboardHandler.ExportUsers).Methods("GET")
+	api.HandleFunc("/admin/reset", adminHandler.Reset).Methods("POST")
+	api.HandleFunc("/admin/jobs", adminHandler.ListJobs).Methods("GET")
+	api.HandleFunc("/admin/jobs/{name}/run", adminHandler.RunJob).Methods("POST")
+
 	return router, memoryStore, ratingIndex, simulator
 }
 
@@ -103,8 +159,8 @@ func TestAPI_Leaderboard(t *testing.T) {
 	// Add test users
 	for i := 0; i < 50; i++ {
 		user := &models.User{
-			ID:       "user-" + string(rune('a'+i%26)) + string(rune('0'+i%10)),
-			Username: "testuser" + string(rune('0'+i%10)),
+			ID:       fixtures.ID("user", i),
+			Username: fixtures.Username(i),
 			Rating:   4000 - i*50,
 		}
 		memoryStore.AddUser(user)
@@ -329,14 +385,7 @@ func TestAPI_SimulatorStartStop(t *testing.T) {
 	defer simulator.Stop()
 
 	// Add some users first
-	for i := 0; i < 10; i++ {
-		user := &models.User{
-			ID:       "sim-user-" + string(rune('a'+i)),
-			Username: "simuser" + string(rune('0'+i)),
-			Rating:   2500,
-		}
-		memoryStore.AddUser(user)
-	}
+	fixtures.Load(memoryStore, fixtures.Set{Prefix: "sim-user", Count: 10, Distribution: fixtures.Flat, Rating: 2500})
 
 	// Start simulator
 	req, err := http.NewRequest("POST", "/api/simulator/start", nil)
@@ -373,14 +422,129 @@ func TestAPI_SimulatorStartStop(t *testing.T) {
 	}
 }
 
+func TestAPI_SimulatorPauseResume(t *testing.T) {
+	router, memoryStore, _, simulator := setupTestServer()
+	defer simulator.Stop()
+	fixtures.Load(memoryStore, fixtures.Set{Prefix: "pause-user", Count: 10, Distribution: fixtures.Flat, Rating: 2500})
+
+	post := func(path string) int {
+		req, _ := http.NewRequest("POST", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	waitForUpdates := func(after int64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for simulator.GetUpdateCount() <= after {
+			if time.Now().After(deadline) {
+				t.Fatalf("Simulator made no updates past %d", after)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if code := post("/api/simulator/pause"); code != http.StatusConflict {
+		t.Errorf("Pausing a stopped simulator should give 409, got %d", code)
+	}
+
+	post("/api/simulator/start")
+	waitForUpdates(0)
+	if code := post("/api/simulator/pause"); code != http.StatusOK {
+		t.Fatalf("Pause returned %d", code)
+	}
+	// A batch already under way may still land
+	time.Sleep(50 * time.Millisecond)
+	paused := simulator.GetUpdateCount()
+	// Five ticks at the default 100ms interval
+	time.Sleep(500 * time.Millisecond)
+	if count := simulator.GetUpdateCount(); count != paused {
+		t.Errorf("Paused simulator kept updating: %d -> %d", paused, count)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/simulator/status", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var status map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&status)
+	if status["running"] != true || status["paused"] != true {
+		t.Errorf("Expected a running, paused simulator, got %v", status)
+	}
+	if simulator.StalledFor() != 0 {
+		t.Error("A paused simulator should not count as stalled")
+	}
+
+	// Resuming carries on the same run and its count
+	if code := post("/api/simulator/resume"); code != http.StatusOK {
+		t.Fatalf("Resume returned %d", code)
+	}
+	waitForUpdates(paused)
+	if simulator.IsPaused() {
+		t.Error("Simulator should no longer be paused")
+	}
+
+	post("/api/simulator/stop")
+	if code := post("/api/simulator/resume"); code != http.StatusConflict {
+		t.Errorf("Resuming a stopped simulator should give 409, got %d", code)
+	}
+}
+
+func TestAPI_SimulatorCacheInvalidation(t *testing.T) {
+	router, memoryStore, _, simulator := setupTestServer()
+	defer simulator.Stop()
+	fixtures.Load(memoryStore, fixtures.Set{Prefix: "cache-user", Count: 10, Distribution: fixtures.Flat, Rating: 2500})
+
+	status := func() map[string]interface{} {
+		req, _ := http.NewRequest("GET", "/api/simulator/status", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var stats map[string]interface{}
+		json.NewDecoder(rr.Body).Decode(&stats)
+		return stats
+	}
+
+	simulator.Start()
+	if stats := status(); stats["cache_size"] != float64(10) || stats["cache_stale"] != false {
+		t.Fatalf("Expected a fresh cache of 10 IDs, got %v", stats)
+	}
+
+	// A new user marks the cache stale and is picked up within a second or so,
+	// well before the periodic rebuild
+	memoryStore.AddUser(&models.User{ID: "cache-new", Username: "cachenew", Rating: 2500})
+	if stats := status(); stats["cache_stale"] != true {
+		t.Errorf("Expected adding a user to mark the cache stale, got %v", stats)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for status()["cache_size"] != float64(11) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Simulator cache never picked up the new user: %v", status())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// The refresh endpoint drops deleted users at once
+	memoryStore.DeleteUser("cache-new")
+	req, _ := http.NewRequest("POST", "/api/simulator/refresh", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var refreshed map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&refreshed)
+	if rr.Code != http.StatusOK || refreshed["cache_size"] != float64(10) {
+		t.Errorf("Expected refresh to leave 10 cached IDs, got %d %v", rr.Code, refreshed)
+	}
+	if stats := status(); stats["cache_stale"] != false {
+		t.Errorf("Expected a refreshed cache not to be stale, got %v", stats)
+	}
+}
+
 func TestAPI_LeaderboardPagination(t *testing.T) {
 	router, memoryStore, _, _ := setupTestServer()
 
 	// Add 100 users
 	for i := 0; i < 100; i++ {
 		user := &models.User{
-			ID:       "page-user-" + string(rune('a'+i%26)) + string(rune('0'+i%10)) + string(rune('0'+(i/10)%10)),
-			Username: "pageuser" + string(rune('0'+i%10)),
+			ID:       fixtures.ID("page-user", i),
+			Username: fixtures.Username(i),
 			Rating:   5000 - i*10,
 		}
 		memoryStore.AddUser(user)
@@ -436,14 +600,7 @@ func TestAPI_RankTies(t *testing.T) {
 	router, memoryStore, _, _ := setupTestServer()
 
 	// Add users with tied ratings
-	for i := 0; i < 5; i++ {
-		user := &models.User{
-			ID:       "tie-user-" + string(rune('a'+i)),
-			Username: "tieuser" + string(rune('a'+i)),
-			Rating:   4000, // All same rating
-		}
-		memoryStore.AddUser(user)
-	}
+	fixtures.Load(memoryStore, fixtures.Set{Prefix: "tie-user", Count: 5, Distribution: fixtures.Flat, Rating: 4000})
 
 	req, _ := http.NewRequest("GET", "/api/leaderboard?limit=10&offset=0", nil)
 	rr := httptest.NewRecorder()
@@ -460,3 +617,1887 @@ func TestAPI_RankTies(t *testing.T) {
 		}
 	}
 }
+
+func TestAPI_Tiers(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	// Two Bronze, three Gold (one on each edge of the band), one Master
+	ratings := []int{100, 999, 2000, 2500, 2999, 4800}
+	for i, rating := range ratings {
+		memoryStore.AddUser(&models.User{
+			ID:       fixtures.ID("tier-user", i),
+			Username: fixtures.Username(i),
+			Rating:   rating,
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "/api/tiers", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var tiers models.TierListResponse
+	json.NewDecoder(rr.Body).Decode(&tiers)
+
+	if tiers.TotalUsers != 6 || len(tiers.Tiers) != 6 {
+		t.Fatalf("Expected 6 tiers and 6 users, got %+v", tiers)
+	}
+	counts := map[string]int{}
+	for _, tier := range tiers.Tiers {
+		counts[tier.Name] = tier.Users
+	}
+	if counts["Bronze"] != 2 || counts["Silver"] != 0 || counts["Gold"] != 3 || counts["Master"] != 1 {
+		t.Errorf("Unexpected tier counts: %v", counts)
+	}
+	if gold := tiers.Tiers[2]; gold.MinRating != 2000 || gold.MaxRating != 2999 {
+		t.Errorf("Expected Gold band 2000-2999, got %+v", gold)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/leaderboard?tier=gold&limit=2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var page models.LeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&page)
+
+	if page.TotalUsers != 3 || !page.HasMore || len(page.Users) != 2 {
+		t.Fatalf("Expected first 2 of 3 Gold users, got %+v", page)
+	}
+	if page.Users[0].Rating != 2999 || page.Users[0].Rank != 2 || page.Users[0].Tier != "Gold" {
+		t.Errorf("Expected top Gold user at global rank 2, got %+v", page.Users[0])
+	}
+
+	req, _ = http.NewRequest("GET", "/api/leaderboard?tier=Gold&limit=2&offset=2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	page = models.LeaderboardResponse{}
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Users) != 1 || page.Users[0].Rating != 2000 || page.HasMore {
+		t.Errorf("Expected the last Gold user alone, got %+v", page)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/leaderboard?tier=wood", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Unknown tier should return 400, got %d", rr.Code)
+	}
+}
+
+func TestAPI_RatingDistribution(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	ratings := []int{100, 150, 150, 199, 200, 4999}
+	for i, rating := range ratings {
+		memoryStore.AddUser(&models.User{
+			ID:       fixtures.ID("dist-user", i),
+			Username: fixtures.Username(i),
+			Rating:   rating,
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "/api/stats/buckets", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var exact models.RatingDistributionResponse
+	json.NewDecoder(rr.Body).Decode(&exact)
+
+	if len(exact.Buckets) != 5 {
+		t.Fatalf("Expected 5 non-empty buckets, got %d", len(exact.Buckets))
+	}
+	if exact.Buckets[1].Rating != 150 || exact.Buckets[1].Count != 2 {
+		t.Errorf("Expected bucket 150 with 2 users, got %+v", exact.Buckets[1])
+	}
+
+	// Down-sample into 100-point bands
+	req, _ = http.NewRequest("GET", "/api/stats/buckets?step=100", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var banded models.RatingDistributionResponse
+	json.NewDecoder(rr.Body).Decode(&banded)
+
+	if len(banded.Buckets) != 3 {
+		t.Fatalf("Expected 3 bands, got %d: %+v", len(banded.Buckets), banded.Buckets)
+	}
+	if banded.Buckets[0].Rating != 100 || banded.Buckets[0].Count != 4 {
+		t.Errorf("Expected band 100 with 4 users, got %+v", banded.Buckets[0])
+	}
+
+	req, _ = http.NewRequest("GET", "/api/stats/buckets?step=0", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Invalid step should return 400, got %d", rr.Code)
+	}
+}
+
+func TestAPI_BatchRankLookup(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "rank-a", Username: "ranka", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "rank-b", Username: "rankb", Rating: 2000})
+	memoryStore.AddUser(&models.User{ID: "rank-c", Username: "rankc", Rating: 2000})
+
+	body, _ := json.Marshal(models.RankLookupRequest{
+		UserIDs: []string{"rank-c", "missing", "rank-a"},
+		Ratings: []int{2500, 1000},
+	})
+	req, _ := http.NewRequest("POST", "/api/ranks", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Rank lookup returned wrong status: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response models.RankLookupResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+
+	if len(response.Users) != 2 || response.Users[0].ID != "rank-c" || response.Users[1].ID != "rank-a" {
+		t.Fatalf("Expected users in request order, got %+v", response.Users)
+	}
+	if response.Users[0].Rank != 2 || response.Users[1].Rank != 1 {
+		t.Errorf("Unexpected user ranks: %+v", response.Users)
+	}
+	if len(response.NotFound) != 1 || response.NotFound[0] != "missing" {
+		t.Errorf("Expected missing ID to be reported, got %v", response.NotFound)
+	}
+	if response.Ratings[0].Rank != 2 || response.Ratings[1].Rank != 4 {
+		t.Errorf("Unexpected rating ranks: %+v", response.Ratings)
+	}
+}
+
+func TestAPI_CompactLeaderboard(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	fixtures.Load(memoryStore, fixtures.Set{Prefix: "compact", Count: 30})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/leaderboard"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	var full models.LeaderboardResponse
+	json.NewDecoder(get("?limit=10&offset=5").Body).Decode(&full)
+
+	rr := get("?limit=10&offset=5&view=compact")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Compact page returned wrong status: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var compact models.CompactLeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&compact)
+
+	if compact.TotalUsers != full.TotalUsers || compact.Page != full.Page || compact.HasMore != full.HasMore || len(compact.Users) != len(full.Users) {
+		t.Fatalf("Compact page differs from the full one: %+v vs %+v", compact, full)
+	}
+	for i, row := range compact.Users {
+		want := full.Users[i]
+		if row.ID != want.ID || row.Username != want.Username || row.Rating != want.Rating || row.Rank != want.Rank {
+			t.Errorf("Row %d: got %+v, want %+v", i, row, want)
+		}
+	}
+
+	for _, query := range []string{"?view=full", "?view=compact&exclude_bots=true", "?view=compact&cursor=2000,a"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s should return 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestAPI_BatchGetUsers(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "batch-a", Username: "batcha", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "batch-b", Username: "batchb", Rating: 2000})
+	memoryStore.AddUser(&models.User{ID: "batch-c", Username: "batchc", Rating: 1000})
+	if _, err := memoryStore.MergeUsers("batch-b", "batch-c", func(keep, dupe models.User) int { return keep.Rating }); err != nil {
+		t.Fatalf("MergeUsers failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/users?ids=batch-b,missing,+batch-a,batch-b,batch-c", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Batch get returned wrong status: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response models.BatchUsersResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+
+	if len(response.Users) != 2 || response.Users[0].ID != "batch-b" || response.Users[1].ID != "batch-a" {
+		t.Fatalf("Expected each user once in request order, got %+v", response.Users)
+	}
+	if response.Users[0].Rank != 2 || response.Users[1].Rank != 1 {
+		t.Errorf("Unexpected ranks: %+v", response.Users)
+	}
+	if len(response.NotFound) != 1 || response.NotFound[0] != "missing" {
+		t.Errorf("Expected missing ID to be reported, got %v", response.NotFound)
+	}
+	if response.Merged["batch-c"] != "batch-b" {
+		t.Errorf("Expected merged ID to name its survivor, got %v", response.Merged)
+	}
+
+	for _, query := range []string{"", "?ids=", "?ids=" + strings.Repeat("x,", 101)} {
+		req, _ = http.NewRequest("GET", "/api/users"+query, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q should return 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestAPI_SubsetLeaderboard(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "friend-a", Username: "frienda", Rating: 1500})
+	memoryStore.AddUser(&models.User{ID: "stranger", Username: "stranger", Rating: 4000})
+	memoryStore.AddUser(&models.User{ID: "friend-b", Username: "friendb", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "friend-c", Username: "friendc", Rating: 3000, Bot: true})
+
+	subset := func(query string, ids ...string) (models.SubsetLeaderboardResponse, int) {
+		body, _ := json.Marshal(models.SubsetLeaderboardRequest{UserIDs: ids})
+		req, _ := http.NewRequest("POST", "/api/leaderboard/subset"+query, bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.SubsetLeaderboardResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response, rr.Code
+	}
+
+	response, code := subset("", "friend-a", "friend-c", "missing", "friend-b", "friend-a")
+	if code != http.StatusOK {
+		t.Fatalf("Subset returned wrong status: got %v want %v", code, http.StatusOK)
+	}
+	if len(response.Users) != 3 {
+		t.Fatalf("Expected 3 friends listed once each, got %+v", response.Users)
+	}
+	for i, want := range []struct {
+		id   string
+		rank int
+	}{{"friend-b", 2}, {"friend-c", 2}, {"friend-a", 4}} {
+		if response.Users[i].ID != want.id || response.Users[i].Rank != want.rank {
+			t.Errorf("Row %d: expected %s at global rank %d, got %s at %d", i, want.id, want.rank, response.Users[i].ID, response.Users[i].Rank)
+		}
+	}
+	if len(response.NotFound) != 1 || response.NotFound[0] != "missing" {
+		t.Errorf("Expected missing ID to be reported, got %v", response.NotFound)
+	}
+
+	response, _ = subset("?exclude_bots=true&ranking=dense", "friend-a", "friend-b", "friend-c")
+	if len(response.Users) != 2 || response.Users[1].ID != "friend-a" || response.Users[1].Rank != 3 {
+		t.Errorf("Expected bots left out and dense ranks, got %+v", response.Users)
+	}
+
+	if _, code := subset("?tier=wood", "friend-a"); code != http.StatusBadRequest {
+		t.Errorf("Unknown tier should return 400, got %d", code)
+	}
+}
+
+func TestAPI_TierInUserResponses(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "tier-gold", Username: "goldplayer", Rating: 2500})
+	memoryStore.AddUser(&models.User{ID: "tier-top", Username: "topplayer", Rating: 4900})
+
+	req, _ := http.NewRequest("GET", "/api/users/tier-gold", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var user models.UserWithRank
+	json.NewDecoder(rr.Body).Decode(&user)
+	if user.Tier != "Gold" || user.NextTierRating != 3000 {
+		t.Errorf("Expected Gold tier with next tier at 3000, got %s / %d", user.Tier, user.NextTierRating)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/leaderboard?limit=1", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var board models.LeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&board)
+	if board.Users[0].Tier != "Master" || board.Users[0].NextTierRating != 0 {
+		t.Errorf("Expected top tier with no next tier, got %s / %d", board.Users[0].Tier, board.Users[0].NextTierRating)
+	}
+}
+
+func TestAPI_GetUserByUsername(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "name-1", Username: "Rahul_Kumar", Rating: 2000})
+	memoryStore.AddUser(&models.User{ID: "name-2", Username: "rahul", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "name-3", Username: "raj", Rating: 1000})
+
+	tests := []struct {
+		username   string
+		expectedID string
+	}{
+		{"rahul_kumar", "name-1"},
+		{"RAHUL", "name-2"},
+		{"Raj", "name-3"},
+	}
+	for _, tc := range tests {
+		req, _ := http.NewRequest("GET", "/api/users/by-username/"+tc.username, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Lookup %q returned status %d", tc.username, rr.Code)
+			continue
+		}
+		var user models.UserWithRank
+		json.NewDecoder(rr.Body).Decode(&user)
+		if user.ID != tc.expectedID {
+			t.Errorf("Lookup %q: expected %s, got %s", tc.username, tc.expectedID, user.ID)
+		}
+	}
+
+	// Prefix of an existing name is not an exact match
+	req, _ := http.NewRequest("GET", "/api/users/by-username/rahul_k", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for partial username, got %d", rr.Code)
+	}
+}
+
+func TestAPI_CheckUsername(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "check-1", Username: "Rahul_Kumar", Rating: 2000})
+
+	cases := []struct {
+		name   string
+		valid  bool
+		reason string
+		taken  bool
+	}{
+		{"priya.sharma", true, "", false},
+		{"rahul_kumar", true, "", true},
+		{"ab", false, services.UsernameTooShort, false},
+		{"abcdefghijklmnopqrstuvwxyz", false, services.UsernameTooLong, false},
+		{"rahul kumar", false, services.UsernameInvalidChars, false},
+		{"Admin", false, services.UsernameReserved, false},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/api/usernames/check?name="+url.QueryEscape(c.name), nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var response models.UsernameCheckResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		if rr.Code != http.StatusOK || response.Valid != c.valid || response.Reason != c.reason || response.Taken != c.taken {
+			t.Errorf("Check %q: expected valid=%v reason=%q taken=%v, got %d %+v", c.name, c.valid, c.reason, c.taken, rr.Code, response)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/api/usernames/check", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a name, got %d", rr.Code)
+	}
+}
+
+func TestAPI_SuggestUsernames(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "sug-1", Username: "rahul", Rating: 1500})
+	memoryStore.AddUser(&models.User{ID: "sug-2", Username: "rajesh", Rating: 4000})
+	memoryStore.AddUser(&models.User{ID: "sug-3", Username: "Ravi_Kumar", Rating: 2500})
+	memoryStore.AddUser(&models.User{ID: "sug-4", Username: "ravinder", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "sug-5", Username: "arav", Rating: 4900}) // contains "ra" but not a prefix
+
+	req, _ := http.NewRequest("GET", "/api/search/suggest?q=ra&limit=3", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var response models.SuggestResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+
+	expected := []string{"rajesh", "ravinder", "Ravi_Kumar"}
+	if len(response.Suggestions) != len(expected) {
+		t.Fatalf("Expected %d suggestions, got %+v", len(expected), response.Suggestions)
+	}
+	for i, name := range expected {
+		if response.Suggestions[i].Username != name {
+			t.Errorf("Suggestion %d: expected %s, got %s", i, name, response.Suggestions[i].Username)
+		}
+	}
+
+	// Longer prefixes are filtered beyond the indexed prefix length
+	req, _ = http.NewRequest("GET", "/api/search/suggest?q=ravi_", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&response)
+	if len(response.Suggestions) != 1 || response.Suggestions[0].Username != "Ravi_Kumar" {
+		t.Errorf("Expected only Ravi_Kumar for prefix ravi_, got %+v", response.Suggestions)
+	}
+}
+
+func TestAPI_RecentUsers(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "recent-a", Username: "recenta", Rating: 1000})
+	memoryStore.AddUser(&models.User{ID: "recent-b", Username: "recentb", Rating: 1000})
+	memoryStore.AddUser(&models.User{ID: "recent-c", Username: "recentc", Rating: 1000})
+	memoryStore.UpdateRating("recent-a", 1200)
+
+	req, _ := http.NewRequest("GET", "/api/users/recent?limit=10", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var response models.RecentUsersResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+
+	expected := []string{"recent-a", "recent-c", "recent-b"}
+	if response.Count != len(expected) {
+		t.Fatalf("Expected %d distinct users, got %d", len(expected), response.Count)
+	}
+	for i, id := range expected {
+		if response.Users[i].ID != id {
+			t.Errorf("Position %d: expected %s, got %s", i, id, response.Users[i].ID)
+		}
+	}
+	if response.Users[0].Rating != 1200 || response.Users[0].Rank != 1 {
+		t.Errorf("Expected current rating and rank for recent-a, got %+v", response.Users[0])
+	}
+}
+
+func TestAPI_RecentUsersBySource(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	users, _ := fixtures.Load(memoryStore, fixtures.Set{Prefix: "src", Count: 4, Distribution: fixtures.Flat})
+	memoryStore.UpdateRatings([]store.RatingUpdate{{ID: users[0].ID, Rating: 3000, Source: store.SourceSimulator}})
+
+	patch := func(id, body string) int {
+		req, _ := http.NewRequest("PATCH", "/api/users/"+id+"/rating", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	// Match results come from POST /api/matches, not a client's tag
+	if code := patch(users[1].ID, `{"rating": 3100, "source": "match"}`); code != http.StatusForbidden {
+		t.Fatalf("Expected 403 tagging a change as match without an admin role, got %d", code)
+	}
+	memoryStore.UpdateRatings([]store.RatingUpdate{{ID: users[1].ID, Rating: 3100, Source: store.SourceMatch}})
+	if code := patch(users[2].ID, `{"rating": 3200}`); code != http.StatusOK {
+		t.Fatalf("Expected untagged update to succeed, got %d", code)
+	}
+	if code := patch(users[3].ID, `{"rating": 3300, "source": "simulator"}`); code != http.StatusBadRequest {
+		t.Errorf("Clients must not tag changes as simulator, got %d", code)
+	}
+	if code := patch(users[3].ID, `{"rating": 3300, "source": "bogus"}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown source, got %d", code)
+	}
+
+	recent := func(query string) models.RecentUsersResponse {
+		req, _ := http.NewRequest("GET", "/api/users/recent"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.RecentUsersResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response
+	}
+
+	all := recent("")
+	if all.Count != 4 || all.Users[0].Source != "api" || all.Users[1].Source != "match" || all.Users[2].Source != "simulator" {
+		t.Errorf("Expected newest changes tagged api, match, simulator; got %+v", all.Users)
+	}
+	for source, want := range map[string]string{"match": users[1].ID, "simulator": users[0].ID, "api": users[2].ID} {
+		if got := recent("?source=" + source); got.Count != 1 || got.Users[0].ID != want {
+			t.Errorf("source=%s: expected only %s, got %+v", source, want, got.Users)
+		}
+	}
+	// The fixture load itself is recorded as an import for every user
+	if got := recent("?source=import"); got.Count != 4 {
+		t.Errorf("Expected 4 imported users, got %d", got.Count)
+	}
+
+	counts := memoryStore.ChangesBySource()
+	if counts[store.SourceImport] != 4 || counts[store.SourceSimulator] != 1 || counts[store.SourceMatch] != 1 || counts[store.SourceAPI] != 1 {
+		t.Errorf("Unexpected per-source change counts: %v", counts)
+	}
+}
+
+func TestAPI_FinalStandings(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	fixtures.Load(memoryStore, fixtures.Set{Prefix: "final", Count: 500, Distribution: fixtures.Tied})
+
+	// Writers keep moving users while standings are certified
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			memoryStore.UpdateRating(fixtures.ID("final", i%500), 100+(i*37)%4901)
+		}
+	}()
+
+	for round := 0; round < 20; round++ {
+		req, _ := http.NewRequest("GET", "/api/leaderboard/final?top=50", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rr.Code)
+		}
+
+		var response models.FinalStandingsResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		if len(response.Users) != 50 || response.TotalUsers != 500 || response.Signature != "" {
+			t.Fatalf("Unexpected standings: %d users of %d, signature %q", len(response.Users), response.TotalUsers, response.Signature)
+		}
+		for i, user := range response.Users {
+			want := i + 1
+			if i > 0 && response.Users[i-1].Rating == user.Rating {
+				want = response.Users[i-1].Rank
+			} else if i > 0 && response.Users[i-1].Rating < user.Rating {
+				t.Fatalf("Standings out of order at place %d", i+1)
+			}
+			if user.Rank != want {
+				t.Fatalf("Place %d: rank %d, want %d for a consistent read", i+1, user.Rank, want)
+			}
+		}
+		if !services.VerifyFinalStandings(&response, nil) {
+			t.Fatal("Digest does not match the returned standings")
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	for _, top := range []string{"0", "1001", "abc"} {
+		req, _ := http.NewRequest("GET", "/api/leaderboard/final?top="+top, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("top=%s: expected 400, got %d", top, rr.Code)
+		}
+	}
+}
+
+func TestFinalStandings_Signed(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	fixtures.Load(ms, fixtures.Set{Count: 20, Distribution: fixtures.Skewed})
+
+	key := []byte("payout-secret")
+	service := services.NewLeaderboardService(ms, idx, store.NewPresenceTracker(time.Minute))
+	service.SetSigningKey(key)
+
+	response, err := service.GetFinalStandings(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Signature == "" || response.SignatureAlgorithm != "hmac-sha256" {
+		t.Fatalf("Expected a signed response, got %+v", response)
+	}
+	if !services.VerifyFinalStandings(response, key) {
+		t.Fatal("Signed standings failed verification")
+	}
+	if services.VerifyFinalStandings(response, []byte("wrong-key")) {
+		t.Error("Verification must fail with the wrong key")
+	}
+
+	response.Users[3].Rating++
+	if services.VerifyFinalStandings(response, key) {
+		t.Error("Verification must fail once a rating is altered")
+	}
+}
+
+func TestAPI_OnlinePresenceFilter(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "online-a", Username: "onlinea", Rating: 4000})
+	memoryStore.AddUser(&models.User{ID: "online-b", Username: "onlineb", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "online-c", Username: "onlinec", Rating: 2000})
+
+	req, _ := http.NewRequest("POST", "/api/users/online-b/heartbeat", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Heartbeat returned wrong status: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/users/missing/heartbeat", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Heartbeat for unknown user should 404, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/leaderboard?online=true", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var board models.LeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&board)
+	if len(board.Users) != 1 || board.Users[0].ID != "online-b" {
+		t.Fatalf("Expected only online-b, got %+v", board.Users)
+	}
+	if board.Users[0].Rank != 2 {
+		t.Errorf("Filtered users should keep their global rank, got %d", board.Users[0].Rank)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/search?q=online&online=true", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var search models.SearchResponse
+	json.NewDecoder(rr.Body).Decode(&search)
+	if search.Count != 1 {
+		t.Errorf("Expected 1 online search result, got %d", search.Count)
+	}
+}
+
+func TestAPI_CreateUser(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	memoryStore.AddUser(&models.User{ID: "create-top", Username: "createtop", Rating: 4000})
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/users", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := create(`{"username": "new_player", "rating": 2500}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created models.UserWithRank
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Username != "new_player" || created.Rating != 2500 || created.Rank != 2 || created.Tier != "Gold" {
+		t.Errorf("Unexpected created user: %+v", created)
+	}
+	if _, err := uuid.Parse(created.ID); err != nil || rr.Header().Get("Location") != "/api/users/"+created.ID {
+		t.Errorf("Expected a UUID and matching Location, got %q and %q", created.ID, rr.Header().Get("Location"))
+	}
+	if user, err := memoryStore.GetUser(created.ID); err != nil || user.Username != "new_player" {
+		t.Errorf("Created user not in the store: %+v %v", user, err)
+	}
+
+	// Rating is optional
+	rr = create(`{"username": "fresh_player"}`)
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created.Rating != 100 {
+		t.Errorf("Expected the minimum rating by default, got %d %+v", rr.Code, created)
+	}
+
+	for _, c := range []struct {
+		body string
+		code string
+	}{
+		{`{"username": "x"}`, "invalid_username"},
+		{`{"username": "admin"}`, "invalid_username"},
+		{`{"rating": 1000}`, "invalid_username"},
+		{`not json`, "invalid_request"},
+	} {
+		rr := create(c.body)
+		var response models.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		if rr.Code != http.StatusBadRequest || response.Error != c.code {
+			t.Errorf("%s: expected 400 %s, got %d %+v", c.body, c.code, rr.Code, response)
+		}
+	}
+	if memoryStore.GetUserCount() != 3 {
+		t.Errorf("Rejected requests must not add users, have %d", memoryStore.GetUserCount())
+	}
+}
+
+func TestAPI_RatingRangeMode(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	memoryStore.AddUser(&models.User{ID: "range-a", Username: "rangea", Rating: 100})
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	rangeStats := func() map[string]interface{} {
+		return memoryStore.GetStats()["rating_range"].(map[string]interface{})
+	}
+
+	// Clamp mode stores the nearest bound, so every index agrees on the rank
+	rr := send("POST", "/api/users", `{"username": "range_high", "rating": 9000}`)
+	var created models.UserWithRank
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created.Rating != store.MaxRating || created.Rank != 1 {
+		t.Fatalf("Expected the rating clamped to %d, got %d %+v", store.MaxRating, rr.Code, created)
+	}
+	if rr := send("PATCH", "/api/users/range-a/rating", `{"rating": 50}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected a clamped update to succeed, got %d", rr.Code)
+	}
+	if user, _ := memoryStore.GetUser("range-a"); user.Rating != store.MinRating {
+		t.Errorf("Expected range-a clamped to %d, got %d", store.MinRating, user.Rating)
+	}
+	if top := memoryStore.GetTopUsers(1, 0); top[0].ID != created.ID {
+		t.Errorf("Skip list disagrees with the clamped rating: %+v", top)
+	}
+	if stats := rangeStats(); stats["mode"] != store.RatingClamp || stats["clamped"] != uint64(2) {
+		t.Errorf("Expected two counted clamps, got %v", stats)
+	}
+
+	// Strict mode rejects with 422 wherever a rating comes in
+	memoryStore.SetRatingRangeMode(store.RatingStrict)
+	for _, c := range []struct{ method, path, body string }{
+		{"POST", "/api/users", `{"username": "range_strict", "rating": 5001}`},
+		{"PATCH", "/api/users/range-a/rating", `{"rating": 99}`},
+		{"POST", "/api/ranks", `{"ratings": [6000]}`},
+	} {
+		rr := send(c.method, c.path, c.body)
+		var response models.ErrorResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		if rr.Code != http.StatusUnprocessableEntity || response.Error != "rating_out_of_range" {
+			t.Errorf("%s %s %s: expected 422, got %d %+v", c.method, c.path, c.body, rr.Code, response)
+		}
+	}
+	failed := memoryStore.UpdateRatings([]store.RatingUpdate{{ID: "range-a", Rating: 5001}})
+	if !errors.Is(failed["range-a"], store.ErrRatingOutOfRange) {
+		t.Errorf("Expected the batch update to be rejected, got %v", failed)
+	}
+	if memoryStore.GetUserCount() != 2 {
+		t.Errorf("Rejected ratings must not add users, have %d", memoryStore.GetUserCount())
+	}
+	if stats := rangeStats(); stats["mode"] != store.RatingStrict || stats["rejected"] != uint64(4) {
+		t.Errorf("Expected four counted rejections, got %v", stats)
+	}
+}
+
+func TestAPI_DeleteUser(t *testing.T) {
+	router, memoryStore, ratingIndex, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "gone-a", Username: "gonea", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "gone-b", Username: "goneb", Rating: 2000})
+
+	req, _ := http.NewRequest("POST", "/api/users/gone-a/heartbeat", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("DELETE", "/api/users/gone-a", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var result models.DeleteResult
+	json.NewDecoder(rr.Body).Decode(&result)
+	if rr.Code != http.StatusOK || !result.Deleted || result.ID != "gone-a" {
+		t.Fatalf("Expected gone-a deleted, got %d %+v", rr.Code, result)
+	}
+
+	// Every index must forget the user
+	if _, err := memoryStore.GetUser("gone-a"); err == nil {
+		t.Error("User map still holds gone-a")
+	}
+	if results := memoryStore.SearchUsers("gonea"); len(results) != 0 {
+		t.Errorf("Username index still holds gone-a: %+v", results)
+	}
+	if top := memoryStore.GetTopUsers(10, 0); len(top) != 1 || top[0].ID != "gone-b" {
+		t.Errorf("Skip list still holds gone-a: %+v", top)
+	}
+	if ratingIndex.GetTotalUsers() != 1 || ratingIndex.GetRank(2000) != 1 {
+		t.Errorf("Rating buckets not decremented: total=%d rank(2000)=%d", ratingIndex.GetTotalUsers(), ratingIndex.GetRank(2000))
+	}
+
+	req, _ = http.NewRequest("GET", "/api/health", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var health struct {
+		Users struct {
+			Online int `json:"online"`
+		} `json:"users"`
+	}
+	json.NewDecoder(rr.Body).Decode(&health)
+	if health.Users.Online != 0 {
+		t.Errorf("Expected the deleted user to leave the online count, got %d", health.Users.Online)
+	}
+
+	// A second delete finds nothing
+	req, _ = http.NewRequest("DELETE", "/api/users/gone-a", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 deleting a missing user, got %d", rr.Code)
+	}
+}
+
+func TestAPI_BulkDelete(t *testing.T) {
+	router, memoryStore, ratingIndex, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "del-a", Username: "dela", Rating: 150})
+	memoryStore.AddUser(&models.User{ID: "del-b", Username: "delb", Rating: 180})
+	memoryStore.AddUser(&models.User{ID: "del-c", Username: "delc", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "del-d", Username: "deld", Rating: 2000})
+
+	body, _ := json.Marshal(models.BulkDeleteRequest{IDs: []string{"del-c", "missing"}})
+	req, _ := http.NewRequest("POST", "/api/admin/users/delete", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var response models.BulkDeleteResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Deleted != 1 || response.Failed != 1 {
+		t.Fatalf("Expected 1 deleted and 1 failed, got %+v", response)
+	}
+
+	// Heartbeat del-b so it is not inactive
+	req, _ = http.NewRequest("POST", "/api/users/del-b/heartbeat", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	body, _ = json.Marshal(models.BulkDeleteRequest{Filter: &models.BulkDeleteFilter{RatingBelow: 200, InactiveDays: 90}})
+	req, _ = http.NewRequest("POST", "/api/admin/users/delete", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Deleted != 1 || response.Results[0].ID != "del-a" {
+		t.Fatalf("Expected only del-a deleted by filter, got %+v", response)
+	}
+
+	// Every index must agree after removals
+	if memoryStore.GetUserCount() != 2 || ratingIndex.GetTotalUsers() != 2 {
+		t.Errorf("Expected 2 users left, store=%d index=%d", memoryStore.GetUserCount(), ratingIndex.GetTotalUsers())
+	}
+	if top := memoryStore.GetTopUsers(10, 0); len(top) != 2 || top[0].ID != "del-d" {
+		t.Errorf("Skip list not cleaned up: %+v", top)
+	}
+	if results := memoryStore.SearchUsers("del"); len(results) != 2 {
+		t.Errorf("Username index not cleaned up, got %d results", len(results))
+	}
+	if ratingIndex.GetRank(2000) != 1 {
+		t.Errorf("Expected rating 2000 to rank 1 after deletes, got %d", ratingIndex.GetRank(2000))
+	}
+}
+
+func TestAPI_MergeUsers(t *testing.T) {
+	router, memoryStore, ratingIndex, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "merge-keep", Username: "mergekeep", Rating: 1200})
+	memoryStore.AddUser(&models.User{ID: "merge-dupe", Username: "mergedupe", Rating: 2400})
+	memoryStore.AddUser(&models.User{ID: "merge-other", Username: "mergeother", Rating: 1800})
+
+	merge := func(keep, dupe, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/admin/users/"+keep+"/merge/"+dupe, bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The default strategy keeps the higher rating
+	rr := merge("merge-keep", "merge-dupe", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response models.MergeUsersResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.User.ID != "merge-keep" || response.User.Rating != 2400 || response.RatingStrategy != "max" ||
+		response.KeepRating != 1200 || response.DupeRating != 2400 {
+		t.Errorf("Unexpected merge result: %+v", response)
+	}
+
+	// The duplicate is gone from every index and the survivor re-ranked
+	if memoryStore.GetUserCount() != 2 || ratingIndex.GetTotalUsers() != 2 {
+		t.Errorf("Expected 2 users left, store=%d index=%d", memoryStore.GetUserCount(), ratingIndex.GetTotalUsers())
+	}
+	if top := memoryStore.GetTopUsers(10, 0); len(top) != 2 || top[0].ID != "merge-keep" {
+		t.Errorf("Expected merge-keep on top after the merge, got %+v", top)
+	}
+	if results := memoryStore.SearchUsers("mergedupe"); len(results) != 0 {
+		t.Errorf("Username index still holds the duplicate: %+v", results)
+	}
+
+	// The tombstone redirects lookups and blocks recreating the ID
+	req, _ := http.NewRequest("GET", "/api/users/merge-dupe", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/api/users/merge-keep" {
+		t.Errorf("Expected a redirect to the kept account, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if err := memoryStore.AddUser(&models.User{ID: "merge-dupe", Username: "again", Rating: 100}); !errors.Is(err, store.ErrMerged) {
+		t.Errorf("Expected ErrMerged re-adding a merged ID, got %v", err)
+	}
+
+	// Merging the survivor onward repoints the older tombstone
+	rr = merge("merge-other", "merge-keep", `{"rating_strategy": "average"}`)
+	json.NewDecoder(rr.Body).Decode(&response)
+	if rr.Code != http.StatusOK || response.User.Rating != 2100 {
+		t.Errorf("Expected the average of 1800 and 2400, got %d %+v", rr.Code, response)
+	}
+	if into, merged := memoryStore.MergedInto("merge-dupe"); !merged || into != "merge-other" {
+		t.Errorf("Expected merge-dupe to now point at merge-other, got %q %v", into, merged)
+	}
+
+	for _, c := range []struct {
+		keep, dupe, body string
+		status           int
+	}{
+		{"merge-other", "merge-other", "", http.StatusBadRequest},
+		{"merge-other", "missing", "", http.StatusNotFound},
+		{"merge-other", "merge-keep", `{"rating_strategy": "sum"}`, http.StatusBadRequest},
+		{"merge-other", "merge-keep", `{`, http.StatusBadRequest},
+	} {
+		if rr := merge(c.keep, c.dupe, c.body); rr.Code != c.status {
+			t.Errorf("merge %s <- %s %s: expected %d, got %d", c.keep, c.dupe, c.body, c.status, rr.Code)
+		}
+	}
+}
+
+func TestAPI_ExcludeBots(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "bot-a", Username: "simbot", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "human-a", Username: "humana", Rating: 2000})
+	memoryStore.AddUser(&models.User{ID: "human-b", Username: "humanb", Rating: 1000})
+
+	setBot := func(id, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/api/admin/users/"+id+"/bot", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	leaderboard := func(query string) models.LeaderboardResponse {
+		req, _ := http.NewRequest("GET", "/api/leaderboard"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.LeaderboardResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response
+	}
+
+	rr := setBot("bot-a", `{"bot": true}`)
+	var flagged models.User
+	json.NewDecoder(rr.Body).Decode(&flagged)
+	if rr.Code != http.StatusOK || !flagged.Bot {
+		t.Fatalf("Expected the flagged user back, got %d %+v", rr.Code, flagged)
+	}
+
+	// Bots are hidden but still hold their rank
+	page := leaderboard("?exclude_bots=true")
+	if len(page.Users) != 2 || page.Users[0].ID != "human-a" || page.Users[0].Rank != 2 || page.TotalUsers != 2 {
+		t.Errorf("Expected human-a first at rank 2 of 2 humans, got %+v", page)
+	}
+	page = leaderboard("")
+	if len(page.Users) != 3 || !page.Users[0].Bot || page.Users[1].Bot {
+		t.Errorf("Expected every user, with only the bot marked, got %+v", page.Users)
+	}
+
+	setBot("bot-a", `{"bot": false}`)
+	if page = leaderboard("?exclude_bots=true"); len(page.Users) != 3 || memoryStore.GetBotCount() != 0 {
+		t.Errorf("Expected the unflagged user back on the board, got %+v", page.Users)
+	}
+
+	if rr := setBot("missing", `{"bot": true}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown user, got %d", rr.Code)
+	}
+	if rr := setBot("bot-a", `{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a bot field, got %d", rr.Code)
+	}
+}
+
+func TestAPI_ExportUsers(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "exp-a", Username: "comma,name", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "exp-b", Username: "tied", Rating: 2000, Bot: true})
+	memoryStore.AddUser(&models.User{ID: "exp-c", Username: "tie2", Rating: 2000})
+
+	req, _ := http.NewRequest("GET", "/api/admin/export/users.csv.gz", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Export-Schema") != "1" || rr.Header().Get("X-Export-Count") != "3" {
+		t.Fatalf("Unexpected export response: %d %v", rr.Code, rr.Header())
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("Export is not gzip: %v", err)
+	}
+	rows, err := csv.NewReader(gz).ReadAll()
+	if err != nil {
+		t.Fatalf("Export is not CSV: %v", err)
+	}
+	want := [][]string{
+		{"rank", "id", "username", "rating", "tier", "bot"},
+		{"1", "exp-a", "comma,name", "3000"},
+		{"2", "exp-c", "tie2", "2000"},
+		{"2", "exp-b", "tied", "2000"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Expected %d rows, got %v", len(want), rows)
+	}
+	for i, row := range want {
+		for j, cell := range row {
+			if rows[i][j] != cell {
+				t.Errorf("Row %d column %d: expected %q, got %q", i, j, cell, rows[i][j])
+			}
+		}
+	}
+	if rows[3][5] != "true" || rows[1][5] != "false" {
+		t.Errorf("Expected only exp-b flagged as a bot, got %v", rows)
+	}
+}
+
+func TestAPI_ResetRequiresConfirmation(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "reset-a", Username: "reseta", Rating: 1000})
+	memoryStore.AddUser(&models.User{ID: "reset-b", Username: "resetb", Rating: 2000})
+
+	// Wrong token is rejected and nothing is cleared
+	body, _ := json.Marshal(models.ResetRequest{ConfirmToken: "bogus"})
+	req, _ := http.NewRequest("POST", "/api/admin/reset", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for bogus token, got %d", rr.Code)
+	}
+
+	// First call only issues a token
+	req, _ = http.NewRequest("POST", "/api/admin/reset", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202 challenge, got %d", rr.Code)
+	}
+	var challenge models.ResetChallengeResponse
+	json.NewDecoder(rr.Body).Decode(&challenge)
+	if challenge.ConfirmToken == "" || memoryStore.GetUserCount() != 2 {
+		t.Fatalf("Challenge must issue a token without clearing data")
+	}
+
+	body, _ = json.Marshal(models.ResetRequest{ConfirmToken: challenge.ConfirmToken})
+	req, _ = http.NewRequest("POST", "/api/admin/reset", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on confirmed reset, got %d", rr.Code)
+	}
+	if memoryStore.GetUserCount() != 0 {
+		t.Errorf("Expected store to be empty after reset, got %d users", memoryStore.GetUserCount())
+	}
+
+	// Tokens are single use
+	req, _ = http.NewRequest("POST", "/api/admin/reset", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected reused token to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestAPI_SeedKeepsExistingUsers(t *testing.T) {
+	router, memoryStore, _, simulator := setupTestServer()
+	defer simulator.Stop()
+
+	memoryStore.AddUser(&models.User{ID: "keep-me", Username: "keeper", Rating: 1000})
+
+	req, _ := http.NewRequest("POST", "/api/seed?count=10", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if _, err := memoryStore.GetUser("keep-me"); err != nil {
+		t.Error("Seeding must not wipe existing users")
+	}
+	if memoryStore.GetUserCount() != 11 {
+		t.Errorf("Expected 11 users after seeding 10, got %d", memoryStore.GetUserCount())
+	}
+}
+
+func TestAPI_SeedCooldown(t *testing.T) {
+	router, _, _, simulator := setupTestServer()
+	defer simulator.Stop()
+
+	req, _ := http.NewRequest("POST", "/api/seed?count=10", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("First seed should succeed, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/seed?count=10", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Second seed within cooldown should return 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on cooldown response")
+	}
+}
+
+func TestSeedGuard_ProductionRequiresForce(t *testing.T) {
+	guard := services.NewSeedGuard(0, true)
+
+	if err := guard.Acquire(false); err != services.ErrSeedForbidden {
+		t.Errorf("Expected production seed without force to be forbidden, got %v", err)
+	}
+	if err := guard.Acquire(true); err != nil {
+		t.Errorf("Forced seed in production should be allowed, got %v", err)
+	}
+}
+
+func TestAPI_LeaderboardCursor(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	for i := 0; i < 100; i++ {
+		memoryStore.AddUser(&models.User{ID: fixtures.ID("cursor-user", i), Username: fixtures.Username(i), Rating: 5000 - i*10})
+	}
+
+	get := func(query string) (*httptest.ResponseRecorder, models.LeaderboardResponse) {
+		req, _ := http.NewRequest("GET", "/api/leaderboard?"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.LeaderboardResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr, response
+	}
+
+	_, first := get("limit=30")
+	if first.NextCursor == "" {
+		t.Fatal("Expected a next_cursor on the first page")
+	}
+
+	// A user from the next page jumps above the boundary; an offset page
+	// would now repeat the old last user, the cursor page carries on
+	memoryStore.UpdateRating(fixtures.ID("cursor-user", 40), 5000)
+
+	seen := make(map[string]bool)
+	for _, user := range first.Users {
+		seen[user.ID] = true
+	}
+	cursor := first.NextCursor
+	pages := 1
+	for cursor != "" {
+		rr, page := get("limit=30&cursor=" + url.QueryEscape(cursor))
+		if rr.Code != http.StatusOK || page.Page != 0 {
+			t.Fatalf("Cursor page: got %d %+v", rr.Code, page)
+		}
+		for _, user := range page.Users {
+			if seen[user.ID] {
+				t.Errorf("User %s appears on two pages", user.ID)
+			}
+			seen[user.ID] = true
+		}
+		if page.HasMore != (page.NextCursor != "") {
+			t.Errorf("has_more %v disagrees with next_cursor %q", page.HasMore, page.NextCursor)
+		}
+		cursor = page.NextCursor
+		pages++
+	}
+	// Everyone but the user who moved onto the first page
+	if len(seen) != 99 || pages != 4 {
+		t.Errorf("Expected 99 users over 4 pages, got %d over %d", len(seen), pages)
+	}
+
+	if rr, _ := get("cursor=nope"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed cursor, got %d", rr.Code)
+	}
+}
+
+func TestAPI_LeaderboardDelta(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "delta-a", Username: "deltaa", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "delta-b", Username: "deltab", Rating: 2000})
+	memoryStore.AddUser(&models.User{ID: "delta-c", Username: "deltac", Rating: 1000})
+
+	req, _ := http.NewRequest("GET", "/api/leaderboard?limit=2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var page models.LeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&page)
+
+	// delta-c overtakes both, pushing delta-b off the first page
+	memoryStore.UpdateRating("delta-c", 4000)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/leaderboard?limit=2&since_version=%d", page.Version), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var delta models.LeaderboardDeltaResponse
+	json.NewDecoder(rr.Body).Decode(&delta)
+
+	if delta.Full {
+		t.Fatal("Expected a delta for a recently served version")
+	}
+	if delta.Version <= page.Version {
+		t.Errorf("Expected version to advance past %d, got %d", page.Version, delta.Version)
+	}
+	if len(delta.Changed) != 2 {
+		t.Fatalf("Expected 2 changed rows (new leader and demoted delta-a), got %+v", delta.Changed)
+	}
+	if delta.Changed[0].ID != "delta-c" || delta.Changed[1].ID != "delta-a" || delta.Changed[1].Rank != 2 {
+		t.Errorf("Unexpected changed rows: %+v", delta.Changed)
+	}
+	if len(delta.Removed) != 1 || delta.Removed[0] != "delta-b" {
+		t.Errorf("Expected delta-b removed from the page, got %v", delta.Removed)
+	}
+
+	// Unknown versions fall back to the full page
+	req, _ = http.NewRequest("GET", "/api/leaderboard?limit=2&since_version=999999", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&delta)
+	if !delta.Full || len(delta.Changed) != 2 {
+		t.Errorf("Expected full page for unknown version, got %+v", delta)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/leaderboard?since_version=abc", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid since_version, got %d", rr.Code)
+	}
+}
+
+func TestAPI_SnapshotDiff(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	service := services.NewSnapshotService(store.NewNamedSnapshots(t.TempDir()), memoryStore)
+	handler := handlers.NewSnapshotHandler(service)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/snapshots", handler.CreateSnapshot).Methods("POST")
+	router.HandleFunc("/api/snapshots", handler.ListSnapshots).Methods("GET")
+	router.HandleFunc("/api/snapshots/{a}/diff/{b}", handler.DiffSnapshots).Methods("GET")
+
+	create := func(name string) int {
+		body, _ := json.Marshal(models.CreateSnapshotRequest{Name: name})
+		req, _ := http.NewRequest("POST", "/api/snapshots", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	memoryStore.AddUser(&models.User{ID: "s1", Username: "snapone", Rating: 3000})
+	memoryStore.AddUser(&models.User{ID: "s2", Username: "snaptwo", Rating: 2000})
+	memoryStore.AddUser(&models.User{ID: "s3", Username: "snapthree", Rating: 1000})
+	if code := create("before"); code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating snapshot, got %d", code)
+	}
+	if code := create("before"); code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate snapshot name, got %d", code)
+	}
+	if code := create("../escape"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid snapshot name, got %d", code)
+	}
+
+	memoryStore.UpdateRating("s2", 3500)
+	memoryStore.AddUser(&models.User{ID: "s4", Username: "snapfour", Rating: 2500})
+	create("after")
+
+	req, _ := http.NewRequest("GET", "/api/snapshots/before/diff/after?top=2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for diff, got %d", rr.Code)
+	}
+
+	var diff models.SnapshotDiffResponse
+	json.NewDecoder(rr.Body).Decode(&diff)
+
+	// before top 2: s1, s2; after top 2: s2, s1
+	if len(diff.Movements) != 2 || diff.Movements[0].ID != "s2" || diff.Movements[0].Change != 1 {
+		t.Errorf("Expected s2 to climb one place, got %+v", diff.Movements)
+	}
+	if len(diff.NewEntrants) != 0 || len(diff.Dropouts) != 0 {
+		t.Errorf("Expected no entrants or dropouts in top 2, got %+v / %+v", diff.NewEntrants, diff.Dropouts)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/snapshots/before/diff/after?top=3", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&diff)
+	if len(diff.NewEntrants) != 1 || diff.NewEntrants[0].ID != "s4" || diff.NewEntrants[0].Rank != 3 {
+		t.Errorf("Expected s4 to enter at rank 3, got %+v", diff.NewEntrants)
+	}
+	if len(diff.Dropouts) != 1 || diff.Dropouts[0].ID != "s3" {
+		t.Errorf("Expected s3 to drop out of the top 3, got %+v", diff.Dropouts)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/snapshots/before/diff/missing", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown snapshot, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/snapshots", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var list models.SnapshotListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if list.Count != 2 {
+		t.Errorf("Expected 2 snapshots listed, got %d", list.Count)
+	}
+}
+
+func TestAPI_SeasonArchive(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	handler := handlers.NewArchiveHandler(services.NewArchiveService(store.NewSeasonArchive(t.TempDir(), 0), memoryStore))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/seasons/{season}/archive", handler.ArchiveSeason).Methods("POST")
+	router.HandleFunc("/api/seasons", handler.ListSeasons).Methods("GET")
+	router.HandleFunc("/api/seasons/{season}/leaderboard", handler.GetSeasonLeaderboard).Methods("GET")
+	router.HandleFunc("/api/seasons/{season}/users/{id}", handler.GetSeasonStanding).Methods("GET")
+
+	for i := 0; i < 10; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("arch-%d", i), Username: fmt.Sprintf("archuser%d", i), Rating: 1000 + i*100})
+	}
+	// Tie with arch-9 for first place
+	memoryStore.AddUser(&models.User{ID: "arch-tie", Username: "archtie", Rating: 1900})
+
+	req, _ := http.NewRequest("POST", "/api/admin/seasons/s1/archive", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 archiving season, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 archiving the same season twice, got %d", rr.Code)
+	}
+
+	// Live changes must not affect the archive
+	memoryStore.UpdateRating("arch-0", 5000)
+
+	req, _ = http.NewRequest("GET", "/api/seasons/s1/leaderboard?limit=3&offset=1", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var page models.SeasonLeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&page)
+	if page.TotalUsers != 11 || len(page.Users) != 3 || !page.HasMore {
+		t.Fatalf("Unexpected page: %+v", page)
+	}
+	if page.Users[0].Rank != 1 || page.Users[1].ID != "arch-8" || page.Users[1].Rank != 3 {
+		t.Errorf("Expected tie at rank 1 then arch-8 at rank 3, got %+v", page.Users)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/seasons/s1/users/arch-0", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var standing models.SeasonStandingResponse
+	json.NewDecoder(rr.Body).Decode(&standing)
+	if standing.Rank != 11 || standing.Rating != 1000 || standing.Percentile != 0 {
+		t.Errorf("Expected arch-0 last with its archived rating, got %+v", standing)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/seasons/s1/users/missing", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for user without a standing, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/seasons/nope/leaderboard", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown season, got %d", rr.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/seasons", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var list models.SeasonListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if list.Count != 1 || list.Seasons[0].UserCount != 11 {
+		t.Errorf("Expected one archived season with 11 users, got %+v", list)
+	}
+}
+
+func TestAPI_CloseSeason(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	handler := handlers.NewArchiveHandler(services.NewArchiveService(store.NewSeasonArchive(t.TempDir(), 0), memoryStore))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/seasons/{season}/close", handler.CloseSeason).Methods("POST")
+	router.HandleFunc("/api/seasons/{season}/users/{id}", handler.GetSeasonStanding).Methods("GET")
+	closeSeason := func(season, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/admin/seasons/"+season+"/close", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	memoryStore.AddUser(&models.User{ID: "high", Username: "high", Rating: 2500})
+	memoryStore.AddUser(&models.User{ID: "low", Username: "low", Rating: 1000})
+
+	if rr := closeSeason("s1", `{"reset": "sideways"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown reset, got %d", rr.Code)
+	}
+	if rr := closeSeason("s1", `{"reset": "soft", "carry": 1.5}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a carry above 1, got %d", rr.Code)
+	}
+
+	rr := closeSeason("s1", `{"reset": "soft", "base_rating": 1500}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 closing the season, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response models.CloseSeasonResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Season.UserCount != 2 || response.UsersReset != 2 {
+		t.Errorf("Expected 2 users archived and reset, got %+v", response)
+	}
+	high, _ := memoryStore.GetUser("high")
+	low, _ := memoryStore.GetUser("low")
+	if high.Rating != 2000 || low.Rating != 1250 {
+		t.Errorf("Expected soft reset to 2000 and 1250, got %d and %d", high.Rating, low.Rating)
+	}
+
+	// The archive keeps the ratings the season ended on
+	req, _ := http.NewRequest("GET", "/api/seasons/s1/users/high", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var standing models.SeasonStandingResponse
+	json.NewDecoder(rr.Body).Decode(&standing)
+	if standing.Rating != 2500 {
+		t.Errorf("Expected the archived rating 2500, got %d", standing.Rating)
+	}
+
+	if rr := closeSeason("s1", `{"reset": "hard"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 closing a season twice, got %d", rr.Code)
+	}
+	if rr := closeSeason("s2", `{"reset": "hard"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 closing the next season, got %d", rr.Code)
+	}
+	high, _ = memoryStore.GetUser("high")
+	if high.Rating != services.DefaultSeasonBaseRating || ratingIndex.GetRank(high.Rating) != 1 {
+		t.Errorf("Expected hard reset to the base rating, got %d", high.Rating)
+	}
+}
+
+func TestAPI_UserSummary(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	seasonArchive := store.NewSeasonArchive(t.TempDir(), 0)
+	archiveService := services.NewArchiveService(seasonArchive, memoryStore)
+	handler := handlers.NewSummaryHandler(services.NewSummaryService(memoryStore, ratingIndex, seasonArchive))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/users/{id}/summary", handler.GetUserSummary).Methods("GET")
+
+	getSummary := func(id string) (int, models.UserSummaryResponse) {
+		req, _ := http.NewRequest("GET", "/api/users/"+id+"/summary", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var summary models.UserSummaryResponse
+		json.NewDecoder(rr.Body).Decode(&summary)
+		return rr.Code, summary
+	}
+
+	for i := 0; i < 4; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("sum-%d", i), Username: fmt.Sprintf("sumuser%d", i), Rating: 1000 + i*1000})
+	}
+	if _, err := archiveService.ArchiveCurrent("s1"); err != nil {
+		t.Fatal(err)
+	}
+	// sum-0 climbs to the top, a newcomer joins, then season two closes
+	memoryStore.UpdateRating("sum-0", 4500)
+	memoryStore.AddUser(&models.User{ID: "sum-new", Username: "sumnew", Rating: 100})
+	if _, err := archiveService.ArchiveCurrent("s2"); err != nil {
+		t.Fatal(err)
+	}
+	memoryStore.UpdateRating("sum-0", 1500)
+
+	code, summary := getSummary("sum-0")
+	if code != http.StatusOK || summary.Username != "sumuser0" || len(summary.Boards) != 3 {
+		t.Fatalf("Expected live and two season boards, got %d %+v", code, summary)
+	}
+	want := []models.BoardStanding{
+		{Board: services.LiveBoard, Rating: 1500, Rank: 4, Percentile: 20, TotalUsers: 5, Tier: "Silver", NextTierRating: 2000},
+		{Board: "s2", Final: true, Rating: 4500, Rank: 1, Percentile: 80, TotalUsers: 5, Tier: "Master"},
+		{Board: "s1", Final: true, Rating: 1000, Rank: 4, Percentile: 0, TotalUsers: 4, Tier: "Silver", NextTierRating: 2000},
+	}
+	for i, board := range want {
+		if summary.Boards[i] != board {
+			t.Errorf("Board %d: expected %+v, got %+v", i, board, summary.Boards[i])
+		}
+	}
+
+	// Only boards the user appears in are listed
+	if _, summary := getSummary("sum-new"); len(summary.Boards) != 2 || summary.Boards[1].Board != "s2" {
+		t.Errorf("Expected the newcomer on the live board and s2 only, got %+v", summary.Boards)
+	}
+
+	// Archived seasons still count after the user leaves the live board
+	memoryStore.DeleteUser("sum-3")
+	if code, summary := getSummary("sum-3"); code != http.StatusOK || summary.Username != "sumuser3" || len(summary.Boards) != 2 || !summary.Boards[0].Final {
+		t.Errorf("Expected only archived boards for a deleted user, got %d %+v", code, summary)
+	}
+
+	if code, _ := getSummary("missing"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a user on no board, got %d", code)
+	}
+}
+
+func TestAPI_AbandonedRequests(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	for i := 0; i < 20; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("ctx%d", i), Username: fmt.Sprintf("ctxuser%d", i), Rating: 1000 + i})
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		ctx    context.Context
+		status int
+	}{
+		{"leaderboard past deadline", "GET", "/api/leaderboard?limit=10", "", expired, http.StatusGatewayTimeout},
+		{"online leaderboard past deadline", "GET", "/api/leaderboard?online=true", "", expired, http.StatusGatewayTimeout},
+		{"search canceled", "GET", "/api/search?q=ctx", "", canceled, 499},
+		{"user past deadline", "GET", "/api/users/ctx1", "", expired, http.StatusGatewayTimeout},
+		{"update canceled", "PATCH", "/api/users/ctx1/rating", `{"rating": 3000}`, canceled, 499},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body)).WithContext(tc.ctx)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.status, rr.Code, rr.Body.String())
+		}
+	}
+
+	// The canceled update must not have been applied
+	if user, _ := memoryStore.GetUser("ctx1"); user.Rating != 1001 {
+		t.Errorf("Canceled update changed the rating to %d", user.Rating)
+	}
+
+	// A live context still works
+	req := httptest.NewRequest("GET", "/api/leaderboard?limit=10", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 with a live context, got %d", rr.Code)
+	}
+}
+
+func TestAPI_SampleUsers(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	sample := func(query string) (int, models.SampleResponse) {
+		req, _ := http.NewRequest("GET", "/api/users/sample?"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.SampleResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr.Code, response
+	}
+
+	memoryStore.AddUser(&models.User{ID: "strong", Username: "strong", Rating: 5000})
+	for i := 0; i < 20; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("weak-%d", i), Username: fmt.Sprintf("weak%d", i), Rating: 100})
+	}
+
+	code, response := sample("n=5")
+	if code != http.StatusOK || response.Count != 5 || response.Weight != "uniform" {
+		t.Fatalf("Expected 5 uniform picks, got %d %+v", code, response)
+	}
+	seen := make(map[string]bool)
+	for _, user := range response.Users {
+		if seen[user.ID] || user.Rank == 0 {
+			t.Errorf("Expected distinct ranked users, got %+v", response.Users)
+		}
+		seen[user.ID] = true
+	}
+
+	// strong holds 5000 of 7000 rating points, so it should win most draws
+	strongPicks := 0
+	for i := 0; i < 200; i++ {
+		if _, response := sample("n=1&weight=rating"); len(response.Users) == 1 && response.Users[0].ID == "strong" {
+			strongPicks++
+		}
+	}
+	if strongPicks < 100 || strongPicks == 200 {
+		t.Errorf("Expected rating weighting to favour strong without excluding others, got %d/200", strongPicks)
+	}
+
+	// Recency only draws from the activity feed, here flooded by one user
+	for i := 0; i < 1100; i++ {
+		memoryStore.UpdateRating("weak-0", 200+i%2)
+	}
+	if _, response := sample("n=5&weight=recency"); response.Count != 1 || response.Users[0].ID != "weak-0" {
+		t.Errorf("Expected only weak-0 in a recency sample, got %+v", response.Users)
+	}
+
+	if code, _ := sample("weight=loudest"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown weight, got %d", code)
+	}
+	if _, response := sample("n=1000"); response.Count != 10 {
+		t.Errorf("Expected an out-of-range n to fall back to 10, got %d", response.Count)
+	}
+}
+
+func TestAPI_AroundUser(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	for i := 0; i < 20; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("near-%d", i), Username: fmt.Sprintf("near%d", i), Rating: 1000 + 10*i})
+	}
+
+	around := func(query string) (int, models.AroundUserResponse) {
+		req, _ := http.NewRequest("GET", "/api/users/"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.AroundUserResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr.Code, response
+	}
+
+	// near-10 is ranked 10th of 20
+	code, response := around("near-10/context?window=2")
+	if code != http.StatusOK || response.User.ID != "near-10" || response.User.Rank != 10 || response.TotalUsers != 20 {
+		t.Fatalf("Unexpected response: %d %+v", code, response)
+	}
+	if len(response.Above) != 2 || response.Above[0].ID != "near-12" || response.Above[1].Rank != 9 ||
+		len(response.Below) != 2 || response.Below[0].ID != "near-9" || response.Below[1].Rank != 12 {
+		t.Errorf("Unexpected neighbours: above %+v below %+v", response.Above, response.Below)
+	}
+
+	// The leader has nobody above; the default window is 5
+	if _, response := around("near-19/context"); len(response.Above) != 0 || len(response.Below) != 5 || response.Window != 5 {
+		t.Errorf("Expected 0 above and 5 below the leader, got %d and %d", len(response.Above), len(response.Below))
+	}
+	if code, _ := around("missing/context"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown user, got %d", code)
+	}
+}
+
+func TestAPI_DenseRanking(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	for i, rating := range []int{3000, 2000, 2000, 1000} {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("dense-%d", i), Username: fmt.Sprintf("dense%d", i), Rating: rating})
+	}
+
+	ranks := func(query string) ([]int, int) {
+		req, _ := http.NewRequest("GET", "/api/leaderboard"+query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response models.LeaderboardResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		ranks := make([]int, len(response.Users))
+		for i, user := range response.Users {
+			ranks[i] = user.Rank
+		}
+		return ranks, rr.Code
+	}
+
+	if got, _ := ranks(""); fmt.Sprint(got) != "[1 2 2 4]" {
+		t.Errorf("Expected competition ranks by default, got %v", got)
+	}
+	if got, _ := ranks("?ranking=dense"); fmt.Sprint(got) != "[1 2 2 3]" {
+		t.Errorf("Expected dense ranks, got %v", got)
+	}
+	if got, _ := ranks("?ranking=dense&cursor=2000,dense2"); fmt.Sprint(got) != "[3]" {
+		t.Errorf("Expected a dense rank on the cursor page, got %v", got)
+	}
+	if _, code := ranks("?ranking=olympic"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown ranking mode, got %d", code)
+	}
+}
+
+func TestAPI_UserPercentile(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	for i := 0; i < 8; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("pct-%d", i), Username: fmt.Sprintf("pct%d", i), Rating: 1000 + i*100})
+	}
+
+	req, _ := http.NewRequest("GET", "/api/users/pct-6", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var user models.UserWithRank
+	json.NewDecoder(rr.Body).Decode(&user)
+	// Second of eight: six ranked below, in the top 25%
+	if user.Rank != 2 || user.Percentile != 75 || user.TopPercent != 25 {
+		t.Errorf("Expected rank 2 at the 75th percentile (top 25%%), got %+v", user)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/leaderboard?limit=8", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var page models.LeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Users) != 8 || page.Users[0].TopPercent != 12.5 || page.Users[7].TopPercent != 100 || page.Users[7].Percentile != 0 {
+		t.Errorf("Unexpected percentiles on the leaderboard: %+v", page.Users)
+	}
+}
+
+func TestAPI_RatingHistory(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	memoryStore.SetRatingHistorySize(3)
+
+	memoryStore.AddUser(&models.User{ID: "hist", Username: "history", Rating: 1000})
+	for _, rating := range []int{1100, 1050, 1300, 1250} {
+		memoryStore.UpdateRating("hist", rating)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/users/hist/history?limit=2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response models.RatingHistoryResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if rr.Code != http.StatusOK || response.Rating != 1250 || response.Capacity != 3 || response.Count != 2 {
+		t.Fatalf("Unexpected history response %d: %+v", rr.Code, response)
+	}
+	if first := response.History[0]; first.Rating != 1250 || first.OldRating != 1300 || first.Change != -50 {
+		t.Errorf("Expected the newest change first, got %+v", first)
+	}
+
+	// Only the newest 3 of 4 changes are kept
+	req, _ = http.NewRequest("GET", "/api/users/hist/history", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Count != 3 || response.History[2].OldRating != 1100 {
+		t.Errorf("Expected the 3 newest changes, got %+v", response.History)
+	}
+
+	memoryStore.DeleteUser("hist")
+	req, _ = http.NewRequest("GET", "/api/users/hist/history", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted user, got %d", rr.Code)
+	}
+}
+
+func TestAPI_MergeKeepsRatingHistory(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	memoryStore.SetRatingHistorySize(4)
+
+	memoryStore.AddUser(&models.User{ID: "hkeep", Username: "historykeep", Rating: 1000})
+	memoryStore.AddUser(&models.User{ID: "hdupe", Username: "historydupe", Rating: 1000})
+	memoryStore.UpdateRating("hkeep", 1100)
+	memoryStore.UpdateRating("hdupe", 1200)
+	memoryStore.UpdateRating("hkeep", 1300)
+	memoryStore.UpdateRating("hdupe", 1400)
+	if _, err := memoryStore.MergeUsers("hkeep", "hdupe", func(keep, dupe models.User) int { return dupe.Rating }); err != nil {
+		t.Fatalf("MergeUsers failed: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/users/hkeep/history", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var response models.RatingHistoryResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+
+	// Interleaved by time, the merge's own change last, capped at 4
+	want := []int{1400, 1400, 1300, 1200}
+	if response.Count != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), response.History)
+	}
+	for i, rating := range want {
+		if response.History[i].Rating != rating {
+			t.Errorf("Change %d: expected rating %d, got %+v", i, rating, response.History[i])
+		}
+	}
+	if response.History[0].Source != "admin" || response.History[0].OldRating != 1300 {
+		t.Errorf("Expected the merge's admin change newest, got %+v", response.History[0])
+	}
+}
+
+func TestAPI_RecordMatch(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+	memoryStore.AddUser(&models.User{ID: "m1", Username: "matchone", Rating: 1500})
+	memoryStore.AddUser(&models.User{ID: "m2", Username: "matchtwo", Rating: 1500})
+	memoryStore.AddUser(&models.User{ID: "m3", Username: "matchthree", Rating: 1900})
+
+	record := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/matches", bytes.NewBufferString(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Evenly matched: the winner takes half the K-factor
+	rr := record(`{"winner_id": "m1", "loser_id": "m2"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response models.MatchResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Winner.Rating != 1516 || response.Winner.Change != 16 || response.Loser.Rating != 1484 || response.RatingSystem != "elo" {
+		t.Errorf("Expected 1516 and 1484, got %+v", response)
+	}
+	if response.Winner.Rank != 2 || response.Loser.Rank != 3 {
+		t.Errorf("Expected ranks 2 and 3 after the match, got %d and %d", response.Winner.Rank, response.Loser.Rank)
+	}
+	if user, _ := memoryStore.GetUser("m2"); user.Rating != 1484 {
+		t.Errorf("Expected the loser's rating stored, got %d", user.Rating)
+	}
+
+	// A draw against a stronger player still gains rating
+	rr = record(`{"winner_id": "m3", "loser_id": "m1", "draw": true}`)
+	json.NewDecoder(rr.Body).Decode(&response)
+	if !response.Draw || response.Winner.Change >= 0 || response.Loser.Change != -response.Winner.Change {
+		t.Errorf("Expected the higher-rated player to lose rating on a draw, got %+v", response)
+	}
+
+	if rr := record(`{"winner_id": "m1", "loser_id": "m1"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a self match, got %d", rr.Code)
+	}
+	if rr := record(`{"winner_id": "m1", "loser_id": "missing"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown player, got %d", rr.Code)
+	}
+}
+
+func TestLeaderboardService_RankChange(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	service := services.NewLeaderboardService(ms, idx, store.NewPresenceTracker(time.Minute))
+	ctx := context.Background()
+
+	ms.AddUser(&models.User{ID: "a", Username: "deltaa", Rating: 3000})
+	ms.AddUser(&models.User{ID: "b", Username: "deltab", Rating: 2000})
+	ms.AddUser(&models.User{ID: "c", Username: "deltac", Rating: 1000})
+
+	// No snapshot yet, so nobody has a rank change
+	if user, _ := service.GetUserWithRank(ctx, "c"); user.RankChange != nil {
+		t.Errorf("Expected no rank change before the first snapshot, got %d", *user.RankChange)
+	}
+
+	if err := service.SnapshotRanks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ms.UpdateRating("c", 3500)
+	ms.AddUser(&models.User{ID: "d", Username: "deltad", Rating: 500})
+
+	page, err := service.GetLeaderboard(ctx, 10, 0, services.LeaderboardFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"c": 2, "a": -1, "b": -1}
+	for _, user := range page.Users {
+		expected, known := want[user.ID]
+		switch {
+		case !known && user.RankChange != nil:
+			t.Errorf("Expected no rank change for %s, who joined after the snapshot", user.ID)
+		case known && (user.RankChange == nil || *user.RankChange != expected):
+			t.Errorf("Expected %s to move %d, got %v", user.ID, expected, user.RankChange)
+		}
+	}
+	if page.RankChangeSince == nil {
+		t.Error("Expected the snapshot time on the page")
+	}
+}
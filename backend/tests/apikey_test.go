@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"leaderboard-backend/middleware"
+)
+
+func TestAPIKeyAuth_Roles(t *testing.T) {
+	auth, err := middleware.NewAPIKeyAuth([]string{"admin:root-key", "writer:game-key", "Reader:dash-key"}, false)
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuth failed: %v", err)
+	}
+	var seenRole middleware.Role
+	handler := auth.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenRole, _ = middleware.RoleFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tc := range []struct {
+		method, path, key string
+		status            int
+	}{
+		// Reads stay public, including lookups sent as POST
+		{"GET", "/api/leaderboard", "", http.StatusNoContent},
+		{"POST", "/api/ranks", "", http.StatusNoContent},
+		{"POST", "/api/leaderboard/subset", "", http.StatusNoContent},
+		{"OPTIONS", "/api/seed", "", http.StatusNoContent},
+		// Writes need a writer key
+		{"PATCH", "/api/users/u1/rating", "", http.StatusUnauthorized},
+		{"PATCH", "/api/users/u1/rating", "wrong-key", http.StatusUnauthorized},
+		{"PATCH", "/api/users/u1/rating", "dash-key", http.StatusForbidden},
+		{"PATCH", "/api/users/u1/rating", "game-key", http.StatusNoContent},
+		{"POST", "/api/matches", "root-key", http.StatusNoContent},
+		// Seeding, the simulator, deletes and admin endpoints need admin
+		{"POST", "/api/seed", "game-key", http.StatusForbidden},
+		{"POST", "/api/seed", "root-key", http.StatusNoContent},
+		{"POST", "/api/simulator/start", "game-key", http.StatusForbidden},
+		{"GET", "/api/simulator/status", "", http.StatusNoContent},
+		{"DELETE", "/api/users/u1", "game-key", http.StatusForbidden},
+		{"GET", "/api/admin/jobs", "", http.StatusUnauthorized},
+		{"GET", "/api/admin/jobs", "root-key", http.StatusNoContent},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.key != "" {
+			req.Header.Set(middleware.APIKeyHeader, tc.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s %s with %q: expected %d, got %d", tc.method, tc.path, tc.key, tc.status, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/seed", nil)
+	req.Header.Set(middleware.APIKeyHeader, "root-key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if seenRole != middleware.RoleAdmin {
+		t.Errorf("Expected the key's role on the request context, got %q", seenRole)
+	}
+}
+
+func TestAPIKeyAuth_ProtectedReadsAndConfig(t *testing.T) {
+	for _, entries := range [][]string{{"secret"}, {"owner:secret"}, {"admin:"}, {"admin:k", "writer:k"}} {
+		if _, err := middleware.NewAPIKeyAuth(entries, false); err == nil {
+			t.Errorf("Expected %v to be rejected", entries)
+		}
+	}
+
+	open, _ := middleware.NewAPIKeyAuth(nil, true)
+	if open.Enabled() {
+		t.Error("Expected no keys to leave auth off")
+	}
+
+	auth, _ := middleware.NewAPIKeyAuth([]string{"reader:dash-key"}, true)
+	handler := auth.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, tc := range []struct {
+		path, key string
+		status    int
+	}{
+		{"/api/leaderboard", "", http.StatusUnauthorized},
+		{"/api/leaderboard", "dash-key", http.StatusNoContent},
+		{"/healthz", "", http.StatusNoContent},
+		{"/readyz", "", http.StatusNoContent},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		if tc.key != "" {
+			req.Header.Set(middleware.APIKeyHeader, tc.key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s with %q: expected %d, got %d", tc.path, tc.key, tc.status, rr.Code)
+		}
+	}
+}
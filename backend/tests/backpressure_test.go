@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"leaderboard-backend/middleware"
+)
+
+func TestBackpressure_RefusesWritesWhileQueuesBackUp(t *testing.T) {
+	depth := 0
+	bp := middleware.NewBackpressure(0.5, 0.9, middleware.Queue{
+		Name:    "test-queue",
+		Backlog: func() (int, int) { return depth, 100 },
+	})
+	handler := bp.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(method string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, "/api/users/a/rating", nil))
+		return rr
+	}
+
+	for _, tc := range []struct {
+		depth      int
+		level      string
+		status     int
+		retryAfter string
+	}{
+		{10, middleware.PressureNone, http.StatusNoContent, ""},
+		{50, middleware.PressureSoft, http.StatusTooManyRequests, "1"},
+		{95, middleware.PressureHard, http.StatusServiceUnavailable, "5"},
+	} {
+		depth = tc.depth
+		if level, queue := bp.Level(); level != tc.level || queue != "test-queue" {
+			t.Errorf("Depth %d: expected %s pressure from test-queue, got %s from %q", tc.depth, tc.level, level, queue)
+		}
+		rr := serve("PATCH")
+		if rr.Code != tc.status || rr.Header().Get("Retry-After") != tc.retryAfter {
+			t.Errorf("Depth %d: expected %d with Retry-After %q, got %d %q", tc.depth, tc.status, tc.retryAfter, rr.Code, rr.Header().Get("Retry-After"))
+		}
+		// Reads are never refused
+		if rr := serve("GET"); rr.Code != http.StatusNoContent {
+			t.Errorf("Depth %d: expected reads to pass, got %d", tc.depth, rr.Code)
+		}
+	}
+
+	stats := bp.GetStats()
+	if stats["level"] != middleware.PressureHard || stats["refused_hard"].(uint64) == 0 {
+		t.Errorf("Expected hard pressure with refusals in the stats, got %+v", stats)
+	}
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+
+	"github.com/gorilla/mux"
+)
+
+func setupBoards(t *testing.T, dir string, maxBoards int) (*mux.Router, *services.LeaderboardManager, *store.MemoryStore) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	presence := store.NewPresenceTracker(0)
+	manager := services.NewLeaderboardManager(dir, &services.Board{
+		Store:       memoryStore,
+		RatingIndex: ratingIndex,
+		Users:       services.NewUserService(memoryStore, ratingIndex, presence, 100, 5000),
+		Leaderboard: services.NewLeaderboardService(memoryStore, ratingIndex, presence),
+	}, services.BoardOptions{MinRating: 100, MaxRating: 5000, Presence: presence, MaxBoards: maxBoards})
+	if err := manager.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	boardsHandler := handlers.NewBoardsHandler(manager)
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/boards", boardsHandler.ListBoards).Methods("GET")
+	api.HandleFunc("/boards", boardsHandler.CreateBoard).Methods("POST")
+	api.HandleFunc("/boards/{board}", boardsHandler.DeleteBoard).Methods("DELETE")
+	api.HandleFunc("/boards/{board}/leaderboard", boardsHandler.GetLeaderboard).Methods("GET")
+	api.HandleFunc("/boards/{board}/users", boardsHandler.CreateUser).Methods("POST")
+	api.HandleFunc("/boards/{board}/users/{id}", boardsHandler.GetUser).Methods("GET")
+	api.HandleFunc("/boards/{board}/users/{id}/rating", boardsHandler.UpdateRating).Methods("PATCH")
+	return router, manager, memoryStore
+}
+
+func boardRequest(router *mux.Router, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAPI_Boards(t *testing.T) {
+	dir := t.TempDir()
+	router, manager, global := setupBoards(t, dir, 1)
+	global.AddUser(&models.User{ID: "g1", Username: "globaluser", Rating: 3000})
+
+	if rr := boardRequest(router, "POST", "/api/boards", `{"name": "Bad Name"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid name, got %d", rr.Code)
+	}
+	if rr := boardRequest(router, "POST", "/api/boards", `{"name": "tournament"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a board, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := boardRequest(router, "POST", "/api/boards", `{"name": "tournament"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an existing board, got %d", rr.Code)
+	}
+	if rr := boardRequest(router, "POST", "/api/boards", `{"name": "another"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 past MAX_BOARDS, got %d", rr.Code)
+	}
+
+	rr := boardRequest(router, "POST", "/api/boards/tournament/users", `{"username": "boarduser", "rating": 1500}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 creating a user on the board, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created models.UserWithRank
+	json.NewDecoder(rr.Body).Decode(&created)
+	if created.Rank != 1 {
+		t.Errorf("Expected rank 1 on the new board, got %d", created.Rank)
+	}
+	if rr := boardRequest(router, "PATCH", "/api/boards/tournament/users/"+created.ID+"/rating", `{"rating": 1700}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 updating the rating, got %d", rr.Code)
+	}
+
+	// Users stay on their own board
+	if global.GetUserCount() != 1 {
+		t.Errorf("Expected the global board untouched, got %d users", global.GetUserCount())
+	}
+	if rr := boardRequest(router, "GET", "/api/boards/global/users/"+created.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected the board's user to be missing from global, got %d", rr.Code)
+	}
+	rr = boardRequest(router, "GET", "/api/boards/tournament/leaderboard", "")
+	var page models.LeaderboardResponse
+	json.NewDecoder(rr.Body).Decode(&page)
+	if len(page.Users) != 1 || page.Users[0].Rating != 1700 {
+		t.Errorf("Expected only the board's user at 1700, got %+v", page.Users)
+	}
+	if rr := boardRequest(router, "GET", "/api/boards/missing/leaderboard", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown board, got %d", rr.Code)
+	}
+
+	// Saved boards come back after a restart
+	if err := manager.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	router, _, _ = setupBoards(t, dir, 1)
+	rr = boardRequest(router, "GET", "/api/boards", "")
+	var list models.BoardListResponse
+	json.NewDecoder(rr.Body).Decode(&list)
+	if list.Count != 2 || !list.Boards[0].Default || list.Boards[1].Name != "tournament" || list.Boards[1].TotalUsers != 1 {
+		t.Fatalf("Expected global then tournament with 1 user, got %+v", list.Boards)
+	}
+
+	if rr := boardRequest(router, "DELETE", "/api/boards/global", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 deleting the default board, got %d", rr.Code)
+	}
+	if rr := boardRequest(router, "DELETE", "/api/boards/tournament", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 deleting a board, got %d", rr.Code)
+	}
+	router, _, _ = setupBoards(t, dir, 1)
+	if rr := boardRequest(router, "GET", "/api/boards/tournament/leaderboard", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a deleted board to stay deleted, got %d", rr.Code)
+	}
+}
+
+func TestBoards_UseStorePersistenceSettings(t *testing.T) {
+	dir := t.TempDir()
+	newManager := func() *services.LeaderboardManager {
+		ratingIndex := store.NewRatingBucketIndex()
+		memoryStore := store.NewMemoryStore(ratingIndex)
+		presence := store.NewPresenceTracker(0)
+		manager := services.NewLeaderboardManager(dir, &services.Board{
+			Store:       memoryStore,
+			RatingIndex: ratingIndex,
+			Users:       services.NewUserService(memoryStore, ratingIndex, presence, 100, 5000),
+			Leaderboard: services.NewLeaderboardService(memoryStore, ratingIndex, presence),
+		}, services.BoardOptions{MinRating: 100, MaxRating: 5000, Presence: presence,
+			PersistenceShards: 3, PersistenceWorkers: 2, SyncPolicy: store.SyncNever})
+		if err := manager.Load(); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		return manager
+	}
+
+	manager := newManager()
+	board, err := manager.Create("tournament")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for i := 0; i < 30; i++ {
+		board.Store.AddUser(&models.User{ID: fmt.Sprintf("t%d", i), Username: fmt.Sprintf("player%d", i), Rating: 1000 + i})
+	}
+	if err := manager.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	shards, _ := filepath.Glob(filepath.Join(dir, "tournament.*.shard-*.json"))
+	if len(shards) != 3 {
+		t.Errorf("Expected the board saved in PERSISTENCE_SHARDS files, got %v", shards)
+	}
+	board, err = newManager().Get("tournament")
+	if err != nil || board.Store.GetUserCount() != 30 {
+		t.Fatalf("Expected the sharded board to load with 30 users, got %v", err)
+	}
+}
+
+func TestBoards_AutosaveOnWriteThreshold(t *testing.T) {
+	dir := t.TempDir()
+	newManager := func() *services.LeaderboardManager {
+		ratingIndex := store.NewRatingBucketIndex()
+		memoryStore := store.NewMemoryStore(ratingIndex)
+		presence := store.NewPresenceTracker(0)
+		manager := services.NewLeaderboardManager(dir, &services.Board{
+			Store:       memoryStore,
+			RatingIndex: ratingIndex,
+			Users:       services.NewUserService(memoryStore, ratingIndex, presence, 100, 5000),
+			Leaderboard: services.NewLeaderboardService(memoryStore, ratingIndex, presence),
+		}, services.BoardOptions{MinRating: 100, MaxRating: 5000, Presence: presence, AutosaveWrites: 5})
+		if err := manager.Load(); err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		return manager
+	}
+	savedUsers := func() int {
+		board, err := newManager().Get("tournament")
+		if err != nil {
+			t.Fatalf("Expected the board on disk: %v", err)
+		}
+		return board.Store.GetUserCount()
+	}
+
+	// AUTOSAVE_INTERVAL=0: only the write count triggers a save
+	manager := newManager()
+	board, err := manager.Create("tournament")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		board.Store.AddUser(&models.User{ID: fmt.Sprintf("t%d", i), Username: fmt.Sprintf("player%d", i), Rating: 1000})
+	}
+	if err := manager.Autosave(); err != nil {
+		t.Fatalf("Autosave failed: %v", err)
+	}
+	if n := savedUsers(); n != 0 {
+		t.Errorf("Expected no save below the write threshold, got %d users saved", n)
+	}
+	board.Store.AddUser(&models.User{ID: "t4", Username: "player4", Rating: 1000})
+	if err := manager.Autosave(); err != nil {
+		t.Fatalf("Autosave failed: %v", err)
+	}
+	if n := savedUsers(); n != 5 {
+		t.Errorf("Expected a save at the write threshold, got %d users saved", n)
+	}
+}
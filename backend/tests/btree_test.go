@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+func TestBTree_ChurnMatchesSkipList(t *testing.T) {
+	rng := rand.New(rand.NewSource(11))
+	btree := store.NewMemoryStoreWithSkipList(store.NewRatingBucketIndex(), store.SkipListBTree)
+	skipList := store.NewMemoryStoreWithSkipList(store.NewRatingBucketIndex(), store.SkipListLocked)
+
+	// Enough users for three levels of nodes; shared usernames make ties
+	const users = 6000
+	for i := 0; i < users; i++ {
+		user := models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i%500), Rating: 100 + rng.Intn(300)}
+		btree.AddUser(&user)
+		userCopy := user
+		skipList.AddUser(&userCopy)
+	}
+	if height := btree.GetStats()["skip_list"].(map[string]interface{})["height"].(int); height < 3 {
+		t.Fatalf("Expected at least 3 levels, got %d", height)
+	}
+
+	// Deleting most users forces leaves and inner nodes to merge
+	for i := 0; i < 20000; i++ {
+		id := fmt.Sprintf("u%d", rng.Intn(users))
+		switch op := rng.Intn(10); {
+		case op < 5 || i > 15000:
+			btree.DeleteUser(id)
+			skipList.DeleteUser(id)
+		case op < 7:
+			user := models.User{ID: id, Username: fmt.Sprintf("user%d", rng.Intn(500)), Rating: 100 + rng.Intn(300)}
+			btree.AddUser(&user)
+			userCopy := user
+			skipList.AddUser(&userCopy)
+		default:
+			rating := 100 + rng.Intn(300)
+			btree.UpdateRating(id, rating)
+			skipList.UpdateRating(id, rating)
+		}
+	}
+
+	got := btree.GetTopUsers(users, 0)
+	want := skipList.GetTopUsers(users, 0)
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d users, got %d", len(want), len(got))
+	}
+	for i := range want {
+		// Users tied on rating and username may sit in either order
+		if got[i].Rating != want[i].Rating || got[i].Username != want[i].Username {
+			t.Fatalf("Position %d: got %s (%d), want %s (%d)", i, got[i].Username, got[i].Rating, want[i].Username, want[i].Rating)
+		}
+		if position, err := btree.GetPosition(got[i].ID); err != nil || position != i+1 {
+			t.Fatalf("%s expected at position %d, got %d %v", got[i].ID, i+1, position, err)
+		}
+	}
+}
+
+// benchmarkRankedList measures one operation against a store of 100k users
+// ranked by impl, and reports the heap each user costs in that store
+func benchmarkRankedList(b *testing.B, impl store.SkipListImpl, op func(ms *store.MemoryStore, i int)) {
+	const users = 100000
+	rng := rand.New(rand.NewSource(1))
+	pending := make([]*models.User, users)
+	for i := range pending {
+		pending[i] = &models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + rng.Intn(4901)}
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	ms := store.NewMemoryStoreWithSkipList(store.NewRatingBucketIndex(), impl)
+	for _, user := range pending {
+		ms.AddUser(user)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		op(ms, i)
+	}
+	// After the loop, as ResetTimer drops reported metrics
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/users, "heap-bytes/user")
+}
+
+func benchmarkRankedListImpls(b *testing.B, op func(ms *store.MemoryStore, i int)) {
+	for _, impl := range []store.SkipListImpl{store.SkipListLocked, store.SkipListConcurrent, store.SkipListBTree} {
+		b.Run(string(impl), func(b *testing.B) {
+			benchmarkRankedList(b, impl, op)
+		})
+	}
+}
+
+func BenchmarkRankedList_UpdateRating(b *testing.B) {
+	benchmarkRankedListImpls(b, func(ms *store.MemoryStore, i int) {
+		ms.UpdateRating(fmt.Sprintf("u%d", (i*7919)%100000), 100+(i*31)%4901)
+	})
+}
+
+func BenchmarkRankedList_DeepPage(b *testing.B) {
+	benchmarkRankedListImpls(b, func(ms *store.MemoryStore, i int) {
+		ms.GetTopUsers(50, (i*997)%99950)
+	})
+}
+
+func BenchmarkRankedList_Page(b *testing.B) {
+	benchmarkRankedListImpls(b, func(ms *store.MemoryStore, i int) {
+		ms.GetTopUsers(100, (i*997)%99900)
+	})
+}
+
+// BenchmarkRankedList_VisitPage reads the same pages as
+// BenchmarkRankedList_Page, keeping three fields per row instead of copies
+func BenchmarkRankedList_VisitPage(b *testing.B) {
+	type row struct {
+		id, username string
+		rating       int
+	}
+	rows := make([]row, 0, 100)
+	benchmarkRankedListImpls(b, func(ms *store.MemoryStore, i int) {
+		rows = rows[:0]
+		ms.VisitTopUsersContext(context.Background(), 100, (i*997)%99900, func(user *models.User) bool {
+			rows = append(rows, row{user.ID, user.Username, user.Rating})
+			return true
+		})
+	})
+}
+
+func BenchmarkRankedList_Position(b *testing.B) {
+	benchmarkRankedListImpls(b, func(ms *store.MemoryStore, i int) {
+		ms.GetPosition(fmt.Sprintf("u%d", (i*7919)%100000))
+	})
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+	"leaderboard-backend/scheduler"
+
+	"github.com/gorilla/mux"
+)
+
+// chaosServer serves every path with 200 behind a fault injector and the
+// admin endpoints that control it
+func chaosServer(t *testing.T, production bool) (*httptest.Server, *middleware.Chaos) {
+	chaos, err := middleware.NewChaos(models.ChaosSettings{}, production)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminHandler := handlers.NewAdminHandler(nil, scheduler.New())
+	adminHandler.SetChaos(chaos)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/admin/chaos", adminHandler.GetChaos).Methods("GET")
+	router.HandleFunc("/api/admin/chaos", adminHandler.UpdateChaos).Methods("PUT")
+	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := httptest.NewServer(chaos.Inject(router))
+	t.Cleanup(server.Close)
+	return server, chaos
+}
+
+func putChaos(t *testing.T, server *httptest.Server, settings models.ChaosSettings) int {
+	body, _ := json.Marshal(settings)
+	req, _ := http.NewRequest("PUT", server.URL+"/api/admin/chaos", bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /api/admin/chaos failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestChaos_InjectsFaults(t *testing.T) {
+	server, _ := chaosServer(t, false)
+
+	resp, err := http.Get(server.URL + "/api/leaderboard")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Chaos should be off by default, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	// Errors on every request, but only under the targeted prefix
+	if status := putChaos(t, server, models.ChaosSettings{Enabled: true, ErrorRate: 1, ErrorStatus: 502, Paths: []string{"/api/users"}}); status != http.StatusOK {
+		t.Fatalf("Expected settings to be accepted, got %d", status)
+	}
+	resp, err = http.Get(server.URL + "/api/users/u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 502 || resp.Header.Get(middleware.ChaosHeader) != "error" {
+		t.Errorf("Expected an injected 502, got %d (%q)", resp.StatusCode, resp.Header.Get(middleware.ChaosHeader))
+	}
+	resp, err = http.Get(server.URL + "/api/leaderboard")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Untargeted path should be unaffected, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	// Latency then a dropped connection
+	putChaos(t, server, models.ChaosSettings{Enabled: true, LatencyMs: 50, LatencyRate: 1, DropRate: 1})
+	start := time.Now()
+	if resp, err := http.Get(server.URL + "/api/leaderboard"); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected a dropped connection, got status %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected at least 50ms of injected latency, took %v", elapsed)
+	}
+
+	// The control endpoint stays reachable so chaos can be switched off
+	resp, err = http.Get(server.URL + "/api/admin/chaos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status models.ChaosStatusResponse
+	json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if !status.Enabled || status.Injected["drop"] == 0 || status.Injected["latency"] == 0 {
+		t.Errorf("Unexpected chaos status: %+v", status)
+	}
+	putChaos(t, server, models.ChaosSettings{})
+	resp, err = http.Get(server.URL + "/api/leaderboard")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected normal service once disabled, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+}
+
+func TestChaos_RejectsInvalidSettings(t *testing.T) {
+	server, chaos := chaosServer(t, false)
+
+	for _, settings := range []models.ChaosSettings{
+		{Enabled: true, ErrorRate: 1.5},
+		{Enabled: true, DropRate: -0.1},
+		{Enabled: true, LatencyMs: 60000, LatencyRate: 1},
+		{Enabled: true, ErrorRate: 0.5, ErrorStatus: 404},
+	} {
+		if status := putChaos(t, server, settings); status != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %+v, got %d", settings, status)
+		}
+	}
+	if chaos.Settings().Enabled {
+		t.Error("Rejected settings must not be applied")
+	}
+}
+
+func TestChaos_ForbiddenInProduction(t *testing.T) {
+	if _, err := middleware.NewChaos(models.ChaosSettings{Enabled: true, ErrorRate: 1}, true); err == nil {
+		t.Error("Expected chaos enabled at startup to be refused in production")
+	}
+
+	server, _ := chaosServer(t, true)
+	if status := putChaos(t, server, models.ChaosSettings{Enabled: true, ErrorRate: 1}); status != http.StatusForbidden {
+		t.Errorf("Expected 403 enabling chaos in production, got %d", status)
+	}
+}
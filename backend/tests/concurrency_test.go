@@ -1,10 +1,16 @@
 package tests
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"leaderboard-backend/fixtures"
 	"leaderboard-backend/models"
+	"leaderboard-backend/services"
 	"leaderboard-backend/store"
 )
 
@@ -73,8 +79,8 @@ func TestConcurrentMemoryStore(t *testing.T) {
 		go func(i int) {
 			defer wg.Done()
 			user := &models.User{
-				ID:       "user-" + string(rune('a'+i%26)) + "-" + string(rune('0'+i%10)),
-				Username: "user" + string(rune('0'+i%10)),
+				ID:       fixtures.ID("user", i),
+				Username: fixtures.Username(i),
 				Rating:   100 + (i * 49),
 			}
 			_ = ms.AddUser(user)
@@ -83,3 +89,231 @@ func TestConcurrentMemoryStore(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestConcurrentStripedUpdates(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+
+	for i := 0; i < 500; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("s%d", i), Username: fmt.Sprintf("striped%d", i), Rating: 1000})
+	}
+
+	var wg sync.WaitGroup
+
+	// Single updates and batches on overlapping users, with readers alongside
+	for g := 0; g < 20; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ms.UpdateRating(fmt.Sprintf("s%d", (g*25+j)%500), 100+(g*j)%4901)
+			}
+		}(g)
+		go func(g int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				batch := make([]store.RatingUpdate, 0, 10)
+				for k := 0; k < 10; k++ {
+					batch = append(batch, store.RatingUpdate{ID: fmt.Sprintf("s%d", (g+j*10+k)%500), Rating: 100 + (g+j+k)%4901})
+				}
+				batch = append(batch, store.RatingUpdate{ID: "missing", Rating: 1})
+				if failed := ms.UpdateRatings(batch); len(failed) != 1 || failed["missing"] == nil {
+					t.Errorf("Expected only the missing user to fail, got %v", failed)
+				}
+				_, _ = ms.GetUser(fmt.Sprintf("s%d", j))
+				_ = ms.GetTopUsers(10, 0)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if ms.GetUserCount() != 500 || idx.GetTotalUsers() != 500 {
+		t.Fatalf("Expected 500 users in store and index, got %d and %d", ms.GetUserCount(), idx.GetTotalUsers())
+	}
+
+	// The skip list and rating index must agree with every user's final rating
+	top := ms.GetTopUsers(500, 0)
+	if len(top) != 500 {
+		t.Fatalf("Expected 500 ranked users, got %d", len(top))
+	}
+	for i, user := range top {
+		stored, err := ms.GetUser(user.ID)
+		if err != nil || stored.Rating != user.Rating {
+			t.Fatalf("Ranked rating for %s out of sync with the user map", user.ID)
+		}
+		if i > 0 && top[i-1].Rating < user.Rating {
+			t.Fatalf("Ranking out of order at position %d", i)
+		}
+		if idx.GetRank(user.Rating) > i+1 {
+			t.Fatalf("Rating index rank for %s is %d, beyond position %d", user.ID, idx.GetRank(user.Rating), i+1)
+		}
+	}
+}
+
+func TestConcurrentIdenticalPagesCoalesce(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	for i := 0; i < 1000; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("c%d", i), Username: fmt.Sprintf("coalesce%d", i), Rating: 100 + i%4900})
+	}
+	service := services.NewLeaderboardService(ms, idx, store.NewPresenceTracker(0))
+	filter := services.LeaderboardFilter{ExcludeBots: true}
+
+	// The first load is held until every other request is waiting on it
+	const requests = 32
+	var loads, waiters int32
+	release := make(chan struct{})
+	service.SetPageFlightHook(func(leader bool) {
+		if leader {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return
+		}
+		atomic.AddInt32(&waiters, 1)
+	})
+
+	pages := make([]*models.LeaderboardResponse, requests)
+	var wg sync.WaitGroup
+	for i := range pages {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			page, err := service.GetLeaderboard(context.Background(), 50, 500, filter)
+			if err != nil {
+				t.Errorf("GetLeaderboard failed: %v", err)
+				return
+			}
+			pages[i] = page
+		}(i)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&waiters) < requests-1 {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("Only %d of %d requests joined the load in progress (%d loads)", atomic.LoadInt32(&waiters), requests-1, atomic.LoadInt32(&loads))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("Expected identical concurrent requests to share one page load, got %d loads", n)
+	}
+	for _, page := range pages {
+		if page == nil || len(page.Users) != 50 || page.Users[0].ID != pages[0].Users[0].ID {
+			t.Fatalf("Expected every request to get the same page")
+		}
+	}
+}
+
+func TestPairRatingUpdate_ConcurrentChangeIsNotOverwritten(t *testing.T) {
+	ms := store.NewMemoryStore(store.NewRatingBucketIndex())
+	ms.AddUser(&models.User{ID: "w", Username: "winner", Rating: 1500})
+	ms.AddUser(&models.User{ID: "l", Username: "loser", Rating: 1500})
+
+	// A PATCH arriving while the match is being rated waits for it and
+	// lands afterwards, rather than being overwritten by the match
+	patched := make(chan error, 1)
+	_, after, err := ms.UpdatePairRatingState(context.Background(), "w", "l", func(w, l models.User) (store.RatingState, store.RatingState, error) {
+		go func() { patched <- ms.UpdateRating("w", 2000) }()
+		time.Sleep(20 * time.Millisecond)
+		return store.RatingState{Rating: w.Rating + 16}, store.RatingState{Rating: l.Rating - 16}, nil
+	}, store.SourceMatch)
+	if err != nil {
+		t.Fatalf("UpdatePairRatingState failed: %v", err)
+	}
+	if after[0].Rating != 1516 || after[1].Rating != 1484 {
+		t.Errorf("Unexpected ratings after the match: %d, %d", after[0].Rating, after[1].Rating)
+	}
+	if err := <-patched; err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := ms.GetUser("w"); user.Rating != 2000 {
+		t.Errorf("Expected the PATCH to land after the match, got %d", user.Rating)
+	}
+}
+
+func TestConcurrentMatchesConserveElo(t *testing.T) {
+	ms := store.NewMemoryStore(store.NewRatingBucketIndex())
+	users := services.NewUserService(ms, nil, store.NewPresenceTracker(0), 100, 5000)
+	const players = 8
+	for i := 0; i < players; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("p%d", i), Username: fmt.Sprintf("player%d", i), Rating: 2500})
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				winner, loser := (g+i)%players, (g+i*3+1)%players
+				if winner == loser {
+					continue
+				}
+				req := models.MatchRequest{WinnerID: fmt.Sprintf("p%d", winner), LoserID: fmt.Sprintf("p%d", loser)}
+				if _, err := users.RecordMatch(context.Background(), req); err != nil {
+					t.Errorf("RecordMatch failed: %v", err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Elo moves points from loser to winner, so no lost update may change the total
+	total := 0
+	for _, user := range ms.GetAllUsers() {
+		total += user.Rating
+	}
+	if total != players*2500 {
+		t.Errorf("Expected ratings to total %d, got %d", players*2500, total)
+	}
+}
+
+func TestConcurrentGlickoMatchesTrackPlayers(t *testing.T) {
+	ms := store.NewMemoryStore(store.NewRatingBucketIndex())
+	users := services.NewUserService(ms, nil, store.NewPresenceTracker(0), 100, 5000)
+	users.SetRatingEngine(services.NewGlickoEngine(0))
+	const players, idle = 16, 4
+	for i := 0; i < players+idle; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("p%d", i), Username: fmt.Sprintf("player%d", i), Rating: 1500})
+	}
+
+	play := func(rounds int, inflate bool) {
+		var wg sync.WaitGroup
+		for g := 0; g < players/2; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < rounds; i++ {
+					req := models.MatchRequest{WinnerID: fmt.Sprintf("p%d", 2*g), LoserID: fmt.Sprintf("p%d", (2*g+1+2*i)%players)}
+					if _, err := users.RecordMatch(context.Background(), req); err != nil {
+						t.Errorf("RecordMatch failed: %v", err)
+					}
+				}
+			}(g)
+		}
+		if inflate {
+			for i := 0; i < 10; i++ {
+				users.InflateDeviations()
+			}
+		}
+		wg.Wait()
+	}
+
+	// Rating periods may end while matches are being rated
+	play(20, true)
+	users.InflateDeviations()
+
+	// Every player matched in a period is spared, however matches overlapped;
+	// the idle users were never rated and are left alone
+	play(5, false)
+	if changed := users.InflateDeviations(); changed != 0 {
+		t.Errorf("Expected no player's RD widened after a period they all played in, got %d", changed)
+	}
+	if changed := users.InflateDeviations(); changed != players {
+		t.Errorf("Expected all %d players' RD widened after an idle period, got %d", players, changed)
+	}
+}
@@ -1,9 +1,13 @@
 package tests
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
+	"leaderboard-backend/fixtures"
 	"leaderboard-backend/models"
 	"leaderboard-backend/store"
 )
@@ -13,14 +17,7 @@ func TestThousandUsersWithSameRating(t *testing.T) {
 	ms := store.NewMemoryStore(idx)
 
 	// Add 1000 users with rating 3000
-	for i := 0; i < 1000; i++ {
-		user := &models.User{
-			ID:       "user-same-" + string(rune('a'+i%26)) + string(rune('0'+i%10)) + string(rune('0'+(i/10)%10)),
-			Username: "player" + string(rune('0'+i%10)),
-			Rating:   3000,
-		}
-		_ = ms.AddUser(user)
-	}
+	fixtures.Load(ms, fixtures.Set{Prefix: "user-same", Count: 1000, Distribution: fixtures.Flat, Rating: 3000})
 
 	// All users with same rating should have rank 1
 	rank := idx.GetRank(3000)
@@ -167,6 +164,45 @@ func TestSearchWithSpecialCharacters(t *testing.T) {
 	}
 }
 
+func TestSearchWorkLimits(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+
+	// Every user shares the one-letter prefix "a"
+	for i := 0; i < 2000; i++ {
+		ms.AddUser(&models.User{ID: fixtures.ID("a", i), Username: fmt.Sprintf("a_player%d", i), Rating: 100 + i})
+	}
+	ctx := context.Background()
+
+	ms.SetSearchLimits(store.SearchLimits{})
+	users, truncated, _ := ms.SearchUsersContext(ctx, "a")
+	if truncated || len(users) != 100 || users[0].Rating != 2099 {
+		t.Fatalf("Unlimited search should scan everything: truncated=%v, %d users, best %d", truncated, len(users), users[0].Rating)
+	}
+
+	ms.SetSearchLimits(store.SearchLimits{MaxCandidates: 500})
+	users, truncated, _ = ms.SearchUsersContext(ctx, "a")
+	if !truncated || len(users) != 100 {
+		t.Errorf("Expected a truncated search with a full page, got truncated=%v and %d users", truncated, len(users))
+	}
+	suggestions, truncated, _ := ms.SuggestUsernamesContext(ctx, "a", 5)
+	if !truncated || len(suggestions) != 5 {
+		t.Errorf("Expected truncated suggestions, got truncated=%v and %d", truncated, len(suggestions))
+	}
+
+	// A spent time budget stops the scan at its first clock check
+	ms.SetSearchLimits(store.SearchLimits{TimeBudget: time.Nanosecond})
+	if _, truncated, _ = ms.SearchUsersContext(ctx, "a"); !truncated {
+		t.Error("Expected the time budget to truncate the search")
+	}
+
+	// Narrow queries stay well inside the limits
+	ms.SetSearchLimits(store.DefaultSearchLimits)
+	if users, truncated, _ = ms.SearchUsersContext(ctx, "a_player1999"); truncated || len(users) != 1 {
+		t.Errorf("Expected one untruncated match, got truncated=%v and %d users", truncated, len(users))
+	}
+}
+
 func TestStressGetTopUsers(t *testing.T) {
 	idx := store.NewRatingBucketIndex()
 	ms := store.NewMemoryStore(idx)
@@ -175,8 +211,8 @@ func TestStressGetTopUsers(t *testing.T) {
 	for i := 0; i < 10000; i++ {
 		rating := 100 + (i % 4901)
 		user := &models.User{
-			ID:       "user-" + string(rune('a'+i%26)) + string(rune('0'+i%10)) + string(rune('0'+(i/10)%10)) + string(rune('0'+(i/100)%10)),
-			Username: "player" + string(rune('0'+i%10)),
+			ID:       fixtures.ID("user", i),
+			Username: fixtures.Username(i),
 			Rating:   rating,
 		}
 		_ = ms.AddUser(user)
@@ -216,8 +252,8 @@ func TestConcurrentRatingUpdates(t *testing.T) {
 	// Add initial users
 	for i := 0; i < 100; i++ {
 		user := &models.User{
-			ID:       "user-" + string(rune('a'+i%26)) + string(rune('0'+i%10)),
-			Username: "user" + string(rune('0'+i%10)),
+			ID:       fixtures.ID("user", i),
+			Username: fixtures.Username(i),
 			Rating:   2500, // All start at 2500
 		}
 		_ = ms.AddUser(user)
@@ -230,7 +266,7 @@ func TestConcurrentRatingUpdates(t *testing.T) {
 		go func(i int) {
 			defer wg.Done()
 			for j := 0; j < 20; j++ {
-				userID := "user-" + string(rune('a'+i%26)) + string(rune('0'+i%10))
+				userID := fixtures.ID("user", i)
 				newRating := 100 + (i*j)%4901
 				_ = ms.UpdateRating(userID, newRating)
 			}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"sort"
+	"testing"
+
+	"leaderboard-backend/fixtures"
+	"leaderboard-backend/store"
+)
+
+func TestFixtures_DeterministicAndUnique(t *testing.T) {
+	set := fixtures.Set{Count: 5000, Distribution: fixtures.Skewed, Seed: 7}
+	a := fixtures.Users(set)
+	b := fixtures.Users(set)
+
+	ids := make(map[string]bool)
+	names := make(map[string]bool)
+	for i := range a {
+		if *a[i] != *b[i] {
+			t.Fatalf("Same set generated different users at %d: %+v vs %+v", i, a[i], b[i])
+		}
+		if a[i].Rating < fixtures.DefaultMinRating || a[i].Rating > fixtures.DefaultMaxRating {
+			t.Fatalf("Rating %d outside the default range", a[i].Rating)
+		}
+		ids[a[i].ID] = true
+		names[a[i].Username] = true
+	}
+	if len(ids) != 5000 || len(names) != 5000 {
+		t.Errorf("Expected 5000 unique IDs and usernames, got %d and %d", len(ids), len(names))
+	}
+	if a[42].ID != "user-00042" {
+		t.Errorf("Unexpected fixture ID format: %s", a[42].ID)
+	}
+}
+
+func TestFixtures_DistributionShapes(t *testing.T) {
+	median := func(dist fixtures.Distribution) int {
+		users := fixtures.Users(fixtures.Set{Count: 2001, Distribution: dist, Seed: 1})
+		ratings := make([]int, len(users))
+		for i, user := range users {
+			ratings[i] = user.Rating
+		}
+		sort.Ints(ratings)
+		return ratings[len(ratings)/2]
+	}
+
+	if m := median(fixtures.Skewed); m > 1500 {
+		t.Errorf("Skewed ratings should bunch near the bottom, median %d", m)
+	}
+	if m := median(fixtures.Normal); m < 2200 || m > 2900 {
+		t.Errorf("Normal ratings should centre mid-range, median %d", m)
+	}
+
+	distinct := make(map[int]bool)
+	for _, user := range fixtures.Users(fixtures.Set{Count: 1000, Distribution: fixtures.Tied}) {
+		distinct[user.Rating] = true
+	}
+	if len(distinct) > 8 {
+		t.Errorf("Tied ratings should use a handful of values, got %d", len(distinct))
+	}
+}
+
+func TestFixtures_LoadIntoStore(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+
+	users, err := fixtures.Load(ms, fixtures.Set{Prefix: "fx", Count: 300, Distribution: fixtures.Normal})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if ms.GetUserCount() != 300 || idx.GetTotalUsers() != 300 {
+		t.Fatalf("Expected 300 users in store and index, got %d and %d", ms.GetUserCount(), idx.GetTotalUsers())
+	}
+
+	// The returned users are copies the store doesn't mutate
+	ms.UpdateRating(users[0].ID, 4999)
+	if users[0].Rating == 4999 {
+		t.Error("Store updates leaked into the returned fixture users")
+	}
+
+	if _, err := fixtures.Load(ms, fixtures.Set{Prefix: "fx", Count: 1}); err == nil {
+		t.Error("Expected loading a duplicate ID to fail")
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+)
+
+func TestGlicko_RatesAgainstUncertainty(t *testing.T) {
+	engine := services.NewGlickoEngine(0)
+
+	// Two new players: the winner of their first match moves a long way
+	winner, loser := engine.Rate(models.User{ID: "a", Rating: 1500}, models.User{ID: "b", Rating: 1500}, services.ScoreWin)
+	if winner.Rating != 1662 || loser.Rating != 1338 {
+		t.Errorf("Expected 1662 and 1338, got %d and %d", winner.Rating, loser.Rating)
+	}
+	if math.Abs(winner.RD-290.3) > 0.1 || winner.RD != loser.RD {
+		t.Errorf("Expected both RDs to shrink to about 290.3, got %.2f and %.2f", winner.RD, loser.RD)
+	}
+
+	// An established player moves far less than a new one for the same result
+	settled, _ := engine.Rate(models.User{Rating: 1500, RD: 50, Volatility: 0.06}, models.User{Rating: 1500}, services.ScoreWin)
+	if change := settled.Rating - 1500; change <= 0 || change >= 20 {
+		t.Errorf("Expected a settled rating to move a little, moved %d", change)
+	}
+
+	if rd := engine.Inflate(50, 0.06); rd <= 50 || rd >= 60 {
+		t.Errorf("Expected one idle period to widen RD 50 a little, got %.2f", rd)
+	}
+	if rd := engine.Inflate(services.DefaultGlickoRD, 0.06); rd != services.DefaultGlickoRD {
+		t.Errorf("Expected RD capped at %v, got %.2f", services.DefaultGlickoRD, rd)
+	}
+}
+
+func TestGlicko_MatchesAndInactivity(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	userService := services.NewUserService(memoryStore, ratingIndex, store.NewPresenceTracker(time.Minute), 100, 5000)
+	userService.SetRatingEngine(services.NewGlickoEngine(0))
+	for _, id := range []string{"g1", "g2", "g3"} {
+		memoryStore.AddUser(&models.User{ID: id, Username: id, Rating: 1500})
+	}
+	ctx := context.Background()
+
+	response, err := userService.RecordMatch(ctx, models.MatchRequest{WinnerID: "g1", LoserID: "g2"})
+	if err != nil {
+		t.Fatalf("RecordMatch failed: %v", err)
+	}
+	if response.RatingSystem != "glicko2" || response.Winner.Rating != 1662 || response.Winner.RD == 0 {
+		t.Errorf("Unexpected match result: %+v", response)
+	}
+	g1, _ := memoryStore.GetUser("g1")
+	if g1.Rating != 1662 || g1.RD != response.Winner.RD || g1.Volatility == 0 {
+		t.Errorf("Expected the Glicko-2 state stored, got %+v", g1)
+	}
+
+	// Both played this period, and g3 has never been rated
+	if changed := userService.InflateDeviations(); changed != 0 {
+		t.Errorf("Expected no RD widened in a period with matches, got %d", changed)
+	}
+	if changed := userService.InflateDeviations(); changed != 2 {
+		t.Errorf("Expected both idle players' RD widened, got %d", changed)
+	}
+	if idle, _ := memoryStore.GetUser("g1"); idle.RD <= g1.RD || idle.Rating != g1.Rating {
+		t.Errorf("Expected RD to widen and the rating to stay, got %+v after %+v", idle, g1)
+	}
+	if g3, _ := memoryStore.GetUser("g3"); g3.RD != 0 {
+		t.Errorf("Expected an unrated user left alone, got RD %.2f", g3.RD)
+	}
+}
+
+func TestGlicko_StateSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	memoryStore := store.NewMemoryStore(store.NewRatingBucketIndex())
+	wal, err := store.OpenWAL(filepath.Join(dir, "wal.log"), store.SyncNever)
+	if err != nil {
+		t.Fatalf("OpenWAL failed: %v", err)
+	}
+	memoryStore.SetJournal(wal)
+	memoryStore.AddUser(&models.User{ID: "w1", Username: "walter", Rating: 1500})
+	state := store.RatingState{Rating: 1500, RD: 120.5, Volatility: 0.059}
+	if err := memoryStore.UpdateRatingState(context.Background(), "w1", state, store.SourceMatch); err != nil {
+		t.Fatalf("UpdateRatingState failed: %v", err)
+	}
+	wal.Close()
+
+	ratingIndex := store.NewRatingBucketIndex()
+	recovered := store.NewMemoryStore(ratingIndex)
+	if _, err := store.NewPersistence(filepath.Join(dir, "data.json")).Recover(recovered, ratingIndex, filepath.Join(dir, "wal.log")); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if user, err := recovered.GetUser("w1"); err != nil || user.RD != 120.5 || user.Volatility != 0.059 {
+		t.Errorf("Expected RD and volatility replayed, got %+v (%v)", user, err)
+	}
+}
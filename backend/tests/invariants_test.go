@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"leaderboard-backend/fixtures"
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// invariantUsers bounds the IDs operations draw from, so sequences keep
+// hitting existing users, deleted users and never-seen IDs alike
+const invariantUsers = 48
+
+type opKind int
+
+const (
+	opAdd opKind = iota
+	opUpdate
+	opUpdateBatch
+	opDelete
+	opDeleteBatch
+	opClear
+)
+
+// op is one store operation; users and ratings are parallel for batches
+type op struct {
+	kind    opKind
+	users   []int
+	ratings []int
+}
+
+func (o op) String() string {
+	names := [...]string{"add", "update", "update-batch", "delete", "delete-batch", "clear"}
+	return fmt.Sprintf("%s%v%v", names[o.kind], o.users, o.ratings)
+}
+
+// opSequence is a random operation history followed by the page size used
+// to read the leaderboard back
+type opSequence struct {
+	ops      []op
+	pageSize int
+}
+
+func (s opSequence) String() string {
+	parts := make([]string, len(s.ops))
+	for i, o := range s.ops {
+		parts[i] = o.String()
+	}
+	return fmt.Sprintf("page=%d [%s]", s.pageSize, strings.Join(parts, " "))
+}
+
+// GoString keeps quick's failure report readable
+func (s opSequence) GoString() string {
+	return s.String()
+}
+
+// invariantRating mostly draws from a few values so ties are common, and
+// often hits the bucket boundaries
+func invariantRating(r *rand.Rand) int {
+	switch r.Intn(5) {
+	case 0:
+		return store.MinRating
+	case 1:
+		return store.MaxRating
+	default:
+		return store.MinRating + r.Intn(12)*400
+	}
+}
+
+// Generate implements quick.Generator
+func (opSequence) Generate(r *rand.Rand, size int) reflect.Value {
+	seq := opSequence{pageSize: 1 + r.Intn(17)}
+	n := 1 + r.Intn(4*size+1)
+	for i := 0; i < n; i++ {
+		var o op
+		switch k := r.Intn(100); {
+		case k < 45:
+			o.kind = opAdd
+		case k < 70:
+			o.kind = opUpdate
+		case k < 80:
+			o.kind = opUpdateBatch
+		case k < 92:
+			o.kind = opDelete
+		case k < 99:
+			o.kind = opDeleteBatch
+		default:
+			o.kind = opClear
+		}
+
+		count := 1
+		if o.kind == opUpdateBatch || o.kind == opDeleteBatch {
+			count = 1 + r.Intn(8)
+		} else if o.kind == opClear {
+			count = 0
+		}
+		for j := 0; j < count; j++ {
+			o.users = append(o.users, r.Intn(invariantUsers))
+			o.ratings = append(o.ratings, invariantRating(r))
+		}
+		seq.ops = append(seq.ops, o)
+	}
+	return reflect.ValueOf(seq)
+}
+
+// apply runs o against the store and mirrors it in model, a plain map of
+// user ID to rating
+func (o op) apply(ms *store.MemoryStore, model map[string]int) {
+	switch o.kind {
+	case opAdd:
+		id := fixtures.ID("p", o.users[0])
+		err := ms.AddUser(&models.User{ID: id, Username: fixtures.Username(o.users[0]), Rating: o.ratings[0]})
+		if err == nil {
+			model[id] = o.ratings[0]
+		}
+	case opUpdate:
+		id := fixtures.ID("p", o.users[0])
+		if ms.UpdateRating(id, o.ratings[0]) == nil {
+			model[id] = o.ratings[0]
+		}
+	case opUpdateBatch:
+		updates := make([]store.RatingUpdate, len(o.users))
+		for i, u := range o.users {
+			updates[i] = store.RatingUpdate{ID: fixtures.ID("p", u), Rating: o.ratings[i]}
+		}
+		failed := ms.UpdateRatings(updates)
+		for _, update := range updates {
+			if failed[update.ID] == nil {
+				model[update.ID] = update.Rating
+			}
+		}
+	case opDelete:
+		id := fixtures.ID("p", o.users[0])
+		if ms.DeleteUser(id) == nil {
+			delete(model, id)
+		}
+	case opDeleteBatch:
+		ids := make([]string, len(o.users))
+		for i, u := range o.users {
+			ids[i] = fixtures.ID("p", u)
+		}
+		ms.DeleteUsers(ids)
+		for _, id := range ids {
+			delete(model, id)
+		}
+	case opClear:
+		ms.Clear()
+		for id := range model {
+			delete(model, id)
+		}
+	}
+}
+
+// checkInvariants compares every ranking structure with each other and with
+// the model
+func checkInvariants(ms *store.MemoryStore, idx *store.RatingBucketIndex, model map[string]int, pageSize int) error {
+	// Sizes: user map == skip list == rating buckets == model
+	skipListSize := ms.GetStats()["skip_list_size"].(int)
+	bucketSum := 0
+	for _, rating := range idx.GetRatingsDescending() {
+		bucketSum += idx.GetBucketCount(rating)
+	}
+	if ms.GetUserCount() != len(model) || skipListSize != len(model) ||
+		idx.GetTotalUsers() != len(model) || bucketSum != len(model) {
+		return fmt.Errorf("sizes disagree: model %d, users %d, skip list %d, index total %d, bucket sum %d",
+			len(model), ms.GetUserCount(), skipListSize, idx.GetTotalUsers(), bucketSum)
+	}
+
+	// Ranks: the bucket index agrees with a naive sort of the model
+	ratings := make([]int, 0, len(model))
+	for _, rating := range model {
+		ratings = append(ratings, rating)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ratings)))
+	for i, rating := range ratings {
+		if i > 0 && ratings[i-1] == rating {
+			continue
+		}
+		if rank := idx.GetRank(rating); rank != i+1 {
+			return fmt.Errorf("rating %d: index rank %d, naive rank %d", rating, rank, i+1)
+		}
+	}
+
+	// Pagination: every user exactly once, in leaderboard order, with the
+	// model's rating
+	seen := make(map[string]bool, len(model))
+	var prev *models.User
+	for offset := 0; ; offset += pageSize {
+		page := ms.GetTopUsers(pageSize, offset)
+		for _, user := range page {
+			if seen[user.ID] {
+				return fmt.Errorf("user %s returned twice by pagination", user.ID)
+			}
+			seen[user.ID] = true
+			if rating, ok := model[user.ID]; !ok || rating != user.Rating {
+				return fmt.Errorf("user %s paged with rating %d, model has %d (present %v)", user.ID, user.Rating, rating, ok)
+			}
+			if prev != nil && (prev.Rating < user.Rating || prev.Rating == user.Rating && prev.Username > user.Username) {
+				return fmt.Errorf("pagination out of order: %s (%d) before %s (%d)", prev.Username, prev.Rating, user.Username, user.Rating)
+			}
+			prev = user
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+	if len(seen) != len(model) {
+		return fmt.Errorf("pagination returned %d users, model has %d", len(seen), len(model))
+	}
+	return nil
+}
+
+func testStoreInvariants(t *testing.T, impl store.SkipListImpl) {
+	property := func(seq opSequence) bool {
+		idx := store.NewRatingBucketIndex()
+		ms := store.NewMemoryStoreWithSkipList(idx, impl)
+		model := make(map[string]int)
+
+		for i, o := range seq.ops {
+			o.apply(ms, model)
+			// Checking after every step pins a failure to the operation
+			// that caused it; the full sweep is cheap at this size
+			if err := checkInvariants(ms, idx, model, seq.pageSize); err != nil {
+				t.Logf("after op %d (%s): %v", i, o, err)
+				return false
+			}
+		}
+		return true
+	}
+
+	config := &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(1))}
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStoreInvariants_Locked(t *testing.T) {
+	testStoreInvariants(t, store.SkipListLocked)
+}
+
+func TestStoreInvariants_Concurrent(t *testing.T) {
+	testStoreInvariants(t, store.SkipListConcurrent)
+}
+
+func TestStoreInvariants_BTree(t *testing.T) {
+	testStoreInvariants(t, store.SkipListBTree)
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/scheduler"
+
+	"github.com/gorilla/mux"
+)
+
+func TestIPFilter_AllowAndDenyLists(t *testing.T) {
+	if _, err := middleware.NewIPFilter([]string{"not-an-ip"}, nil); err == nil {
+		t.Error("Expected an invalid allowlist entry to be rejected")
+	}
+
+	filter, err := middleware.NewIPFilter([]string{"10.0.0.0/8", "2001:db8::/32"}, []string{"10.0.0.66"})
+	if err != nil {
+		t.Fatalf("NewIPFilter failed: %v", err)
+	}
+	handler := filter.Filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tc := range []struct {
+		addr   string
+		status int
+	}{
+		{"10.1.2.3:5000", http.StatusNoContent},
+		{"[2001:db8::1]:5000", http.StatusNoContent},
+		{"192.168.0.1:5000", http.StatusForbidden},
+		{"10.0.0.66:5000", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest("GET", "/api/search?q=a", nil)
+		req.RemoteAddr = tc.addr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s: expected %d, got %d", tc.addr, tc.status, rr.Code)
+		}
+	}
+}
+
+func TestAPI_TemporaryIPBlocks(t *testing.T) {
+	filter, _ := middleware.NewIPFilter(nil, nil)
+	adminHandler := handlers.NewAdminHandler(nil, scheduler.New())
+	adminHandler.SetIPFilter(filter)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("GET")
+	api.HandleFunc("/admin/ipfilter", adminHandler.GetIPFilter).Methods("GET")
+	api.HandleFunc("/admin/ipfilter/blocks", adminHandler.BlockIP).Methods("POST")
+	api.HandleFunc("/admin/ipfilter/blocks", adminHandler.UnblockIP).Methods("DELETE")
+	handler := filter.Filter(router)
+
+	serve := func(method, path, addr, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("POST", "/api/admin/ipfilter/blocks", "127.0.0.1:1", `{"cidr": "203.0.113.0/24", "duration_seconds": 9999999}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a block longer than the maximum, got %d", rr.Code)
+	}
+	if rr := serve("POST", "/api/admin/ipfilter/blocks", "127.0.0.1:1", `{"cidr": "203.0.113.0/24", "reason": "scraper"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 adding a block, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := serve("GET", "/api/search?q=a", "203.0.113.9:4000", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected a blocked client to get 403, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/api/search?q=a", "198.51.100.1:4000", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected other clients through, got %d", rr.Code)
+	}
+	if status := filter.Status(); len(status.Blocks) != 1 || status.Blocks[0].Reason != "scraper" {
+		t.Errorf("Expected the block listed, got %+v", status.Blocks)
+	}
+
+	if rr := serve("DELETE", "/api/admin/ipfilter/blocks?cidr=203.0.113.0/24", "127.0.0.1:1", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 lifting the block, got %d", rr.Code)
+	}
+	if rr := serve("GET", "/api/search?q=a", "203.0.113.9:4000", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected the client let in once unblocked, got %d", rr.Code)
+	}
+	if rr := serve("DELETE", "/api/admin/ipfilter/blocks?cidr=203.0.113.0/24", "127.0.0.1:1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 lifting a missing block, got %d", rr.Code)
+	}
+}
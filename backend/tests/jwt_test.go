@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+)
+
+// signJWT builds a token with alg in its header, signed by sign
+func signJWT(t *testing.T, alg string, claims map[string]interface{}, sign func(signed []byte) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func hs256(secret string) func([]byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+func TestJWTAuth_Verify(t *testing.T) {
+	now := time.Now()
+	auth, err := middleware.NewJWTAuth("", "shared-secret")
+	if err != nil {
+		t.Fatalf("NewJWTAuth failed: %v", err)
+	}
+
+	token := signJWT(t, "HS256", map[string]interface{}{"sub": "u1", "role": "admin", "exp": now.Add(time.Hour).Unix()}, hs256("shared-secret"))
+	claims, err := auth.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Expected a valid token, got %v", err)
+	}
+	if claims.Subject != "u1" || claims.Role != middleware.RoleAdmin {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	for name, bad := range map[string]string{
+		"wrong secret": signJWT(t, "HS256", map[string]interface{}{"sub": "u1"}, hs256("other")),
+		"alg none":     signJWT(t, "none", map[string]interface{}{"sub": "u1"}, func([]byte) []byte { return nil }),
+		"expired":      signJWT(t, "HS256", map[string]interface{}{"sub": "u1", "exp": now.Add(-time.Hour).Unix()}, hs256("shared-secret")),
+		"not yet":      signJWT(t, "HS256", map[string]interface{}{"sub": "u1", "nbf": now.Add(time.Hour).Unix()}, hs256("shared-secret")),
+		"malformed":    "not.a-token",
+		"no expiry":    signJWT(t, "HS256", map[string]interface{}{"sub": "u1"}, hs256("shared-secret")),
+	} {
+		if _, err := auth.Verify(bad, now); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+
+	// Tokens without an expiry are accepted only when allowed
+	lenient, _ := middleware.NewJWTAuth("", "shared-secret")
+	lenient.AllowNoExpiry()
+	if _, err := lenient.Verify(signJWT(t, "HS256", map[string]interface{}{"sub": "u1"}, hs256("shared-secret")), now); err != nil {
+		t.Errorf("Expected a token without exp to be allowed, got %v", err)
+	}
+
+	// An unknown role grants nothing but keeps the token usable
+	claims, err = auth.Verify(signJWT(t, "HS256", map[string]interface{}{"sub": "u1", "role": "player", "exp": now.Add(time.Hour).Unix()}, hs256("shared-secret")), now)
+	if err != nil || claims.Role != "" {
+		t.Errorf("Expected no role for an unknown one, got %+v %v", claims, err)
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	rsaAuth, err := middleware.NewJWTAuth("RS256", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	if err != nil {
+		t.Fatalf("NewJWTAuth RS256 failed: %v", err)
+	}
+	rs256 := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+		return signature
+	}
+	if claims, err := rsaAuth.Verify(signJWT(t, "RS256", map[string]interface{}{"sub": "u2", "exp": now.Add(time.Hour).Unix()}, rs256), now); err != nil || claims.Subject != "u2" {
+		t.Errorf("Expected a valid RS256 token, got %+v %v", claims, err)
+	}
+	if _, err := rsaAuth.Verify(signJWT(t, "HS256", map[string]interface{}{"sub": "u2"}, hs256(string(der))), now); err == nil {
+		t.Error("Expected an HS256 token to be refused by an RS256 key")
+	}
+}
+
+func TestJWTAuth_SelfServiceRating(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	presence := store.NewPresenceTracker(time.Minute)
+	userService := services.NewUserService(memoryStore, ratingIndex, presence, 100, 5000)
+	leaderboardService := services.NewLeaderboardService(memoryStore, ratingIndex, presence)
+	userHandler := handlers.NewUserHandler(userService, leaderboardService, nil, 0, ratingIndex, memoryStore, nil)
+	userHandler.RequireRatingTokens()
+	memoryStore.AddUser(&models.User{ID: "alice", Username: "alice", Rating: 1000})
+	memoryStore.AddUser(&models.User{ID: "bob", Username: "bob", Rating: 1000})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/users/{id}/rating", userHandler.UpdateRating).Methods("PATCH")
+	jwtAuth, _ := middleware.NewJWTAuth("HS256", "shared-secret")
+	apiKeys, _ := middleware.NewAPIKeyAuth([]string{"writer:game-key", "admin:root-key"}, false)
+	server := middleware.Authenticate(jwtAuth, apiKeys, router)
+
+	aliceToken := signJWT(t, "HS256", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}, hs256("shared-secret"))
+	adminToken := signJWT(t, "HS256", map[string]interface{}{"sub": "ops", "role": "admin", "exp": time.Now().Add(time.Hour).Unix()}, hs256("shared-secret"))
+	for _, tc := range []struct {
+		name, user, token, key string
+		status                 int
+	}{
+		{"own rating", "alice", aliceToken, "", http.StatusOK},
+		{"someone else's rating", "bob", aliceToken, "", http.StatusUnauthorized},
+		{"someone else's rating with a writer key", "bob", aliceToken, "game-key", http.StatusForbidden},
+		{"writer key alone", "bob", "", "game-key", http.StatusUnauthorized},
+		{"admin key", "bob", "", "root-key", http.StatusOK},
+		{"admin token", "bob", adminToken, "", http.StatusOK},
+		{"forged token", "alice", aliceToken + "x", "", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("PATCH", "/api/users/"+tc.user+"/rating", bytes.NewBufferString(`{"rating": 1500}`))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		if tc.key != "" {
+			req.Header.Set(middleware.APIKeyHeader, tc.key)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.status, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Only admins may tag a change as anything but api
+	for _, tc := range []struct {
+		name, token, key string
+		status           int
+	}{
+		{"own token", aliceToken, "", http.StatusForbidden},
+		{"admin token", adminToken, "", http.StatusOK},
+		{"admin key", "", "root-key", http.StatusOK},
+	} {
+		req := httptest.NewRequest("PATCH", "/api/users/alice/rating", bytes.NewBufferString(`{"rating": 1600, "source": "admin"}`))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		if tc.key != "" {
+			req.Header.Set(middleware.APIKeyHeader, tc.key)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("admin source with %s: expected %d, got %d: %s", tc.name, tc.status, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// TestJWTAuth_TokensWithoutAPIKeys goes through middleware.Authenticate, the
+// stage main chains, with JWT_KEY set and API_KEYS empty
+func TestJWTAuth_TokensWithoutAPIKeys(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	presence := store.NewPresenceTracker(time.Minute)
+	userService := services.NewUserService(memoryStore, ratingIndex, presence, 100, 5000)
+	leaderboardService := services.NewLeaderboardService(memoryStore, ratingIndex, presence)
+	userHandler := handlers.NewUserHandler(userService, leaderboardService, nil, 0, ratingIndex, memoryStore, nil)
+	userHandler.RequireRatingTokens()
+	memoryStore.AddUser(&models.User{ID: "carol", Username: "carol", Rating: 1000})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/users/{id}/rating", userHandler.UpdateRating).Methods("PATCH")
+	jwtAuth, _ := middleware.NewJWTAuth("", "shared-secret")
+	apiKeys, _ := middleware.NewAPIKeyAuth(nil, false)
+	server := middleware.Authenticate(jwtAuth, apiKeys, router)
+
+	patch := func(token string) int {
+		req := httptest.NewRequest("PATCH", "/api/users/carol/rating", bytes.NewBufferString(`{"rating": 1200}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := patch(signJWT(t, "HS256", map[string]interface{}{"sub": "carol", "exp": time.Now().Add(time.Hour).Unix()}, hs256("shared-secret"))); code != http.StatusOK {
+		t.Errorf("Expected carol's own token to set her rating, got %d", code)
+	}
+	if code := patch(""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", code)
+	}
+}
+
+// TestJWTAuth_BoardRatings checks the rating route of a named board is
+// guarded like the unprefixed one, with and without API keys
+func TestJWTAuth_BoardRatings(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	presence := store.NewPresenceTracker(time.Minute)
+	manager := services.NewLeaderboardManager(t.TempDir(), &services.Board{
+		Store:       memoryStore,
+		RatingIndex: ratingIndex,
+		Users:       services.NewUserService(memoryStore, ratingIndex, presence, 100, 5000),
+		Leaderboard: services.NewLeaderboardService(memoryStore, ratingIndex, presence),
+	}, services.BoardOptions{MinRating: 100, MaxRating: 5000, Presence: presence})
+	board, err := manager.Create("tournament")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	board.Store.AddUser(&models.User{ID: "alice", Username: "alice", Rating: 1000})
+	board.Store.AddUser(&models.User{ID: "bob", Username: "bob", Rating: 1000})
+
+	boardsHandler := handlers.NewBoardsHandler(manager)
+	boardsHandler.RequireRatingTokens()
+	router := mux.NewRouter()
+	router.HandleFunc("/api/boards/{board}/users/{id}/rating", boardsHandler.UpdateRating).Methods("PATCH")
+	jwtAuth, _ := middleware.NewJWTAuth("HS256", "shared-secret")
+	aliceToken := signJWT(t, "HS256", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}, hs256("shared-secret"))
+
+	for _, tc := range []struct {
+		name, user, token     string
+		withoutKeys, withKeys int
+	}{
+		{"own rating", "alice", aliceToken, http.StatusOK, http.StatusOK},
+		{"someone else's rating", "bob", aliceToken, http.StatusForbidden, http.StatusUnauthorized},
+		{"no token", "bob", "", http.StatusUnauthorized, http.StatusUnauthorized},
+	} {
+		for keys, status := range map[string]int{"": tc.withoutKeys, "writer:game-key": tc.withKeys} {
+			apiKeys, _ := middleware.NewAPIKeyAuth(strings.Fields(keys), false)
+			req := httptest.NewRequest("PATCH", "/api/boards/tournament/users/"+tc.user+"/rating", bytes.NewBufferString(`{"rating": 1500}`))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rr := httptest.NewRecorder()
+			middleware.Authenticate(jwtAuth, apiKeys, router).ServeHTTP(rr, req)
+			if rr.Code != status {
+				t.Errorf("%s with keys %q: expected %d, got %d: %s", tc.name, keys, status, rr.Code, rr.Body.String())
+			}
+		}
+	}
+	if user, _ := board.Store.GetUser("bob"); user.Rating != 1000 {
+		t.Errorf("Expected bob's board rating untouched, got %d", user.Rating)
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"leaderboard-backend/lifecycle"
+)
+
+func TestLifecycle_HooksRunInOrder(t *testing.T) {
+	lc := lifecycle.NewManager()
+
+	var mu sync.Mutex
+	order := make([]string, 0)
+	record := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	// Register out of order on purpose
+	lc.Register("persistence", lifecycle.OrderPersistence, time.Second, record("persistence"))
+	lc.Register("simulator", lifecycle.OrderProducers, time.Second, record("simulator"))
+	lc.Register("server", lifecycle.OrderServer, time.Second, record("server"))
+
+	lc.Shutdown()
+
+	expected := []string{"simulator", "server", "persistence"}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %d hooks to run, got %d", len(expected), len(order))
+	}
+	for i, name := range expected {
+		if order[i] != name {
+			t.Errorf("Hook %d: expected %s, got %s", i, name, order[i])
+		}
+	}
+
+	select {
+	case <-lc.Done():
+	default:
+		t.Error("Done channel should be closed after Shutdown")
+	}
+}
+
+func TestLifecycle_TimeoutAndErrorsDoNotBlockLaterHooks(t *testing.T) {
+	lc := lifecycle.NewManager()
+
+	ran := false
+	lc.Register("slow", lifecycle.OrderProducers, 50*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	lc.Register("failing", lifecycle.OrderServer, time.Second, func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	lc.Register("final", lifecycle.OrderPersistence, time.Second, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+
+	start := time.Now()
+	lc.Shutdown()
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Shutdown should not wait past hook timeout, took %v", elapsed)
+	}
+	if !ran {
+		t.Error("Final hook should run even after earlier hooks time out or fail")
+	}
+
+	// A second Shutdown call must be a no-op
+	lc.Shutdown()
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// liveServer serves /api/ws behind the request logger, as in main.go
+func liveServer(t *testing.T) (*httptest.Server, *store.MemoryStore, *services.LiveHub) {
+	memoryStore := store.NewMemoryStore(store.NewRatingBucketIndex())
+	hub := services.NewLiveHub()
+	memoryStore.AddChangeFeed(hub.Feed())
+	hub.Start()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/ws", handlers.NewLiveHandler(hub).Stream).Methods("GET")
+	server := httptest.NewServer(middleware.NewLogger().LogRequest(router))
+	t.Cleanup(func() {
+		hub.Stop(context.Background())
+		server.Close()
+	})
+	return server, memoryStore, hub
+}
+
+func dialLive(t *testing.T, server *httptest.Server, hub *services.LiveHub) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	// The handler subscribes before upgrading, so this is already true
+	if hub.Subscribers() != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d", hub.Subscribers())
+	}
+	return conn
+}
+
+func readLive(t *testing.T, conn *websocket.Conn) models.LiveEvent {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var event models.LiveEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("Expected an event: %v", err)
+	}
+	return event
+}
+
+func TestLive_PushesChanges(t *testing.T) {
+	server, memoryStore, hub := liveServer(t)
+	conn := dialLive(t, server, hub)
+
+	memoryStore.AddUser(&models.User{ID: "a", Username: "alice", Rating: 1500})
+	memoryStore.AddUser(&models.User{ID: "b", Username: "bob", Rating: 1400})
+	memoryStore.UpdateRatingFrom(context.Background(), "b", 1600, store.SourceMatch)
+	memoryStore.DeleteUser("a")
+	memoryStore.Clear()
+
+	added := readLive(t, conn)
+	if added.Type != "added" || added.User == nil || added.User.ID != "a" || added.User.Rank != 1 || added.Source != "api" {
+		t.Errorf("Unexpected add event: %+v", added)
+	}
+	if second := readLive(t, conn); second.User.ID != "b" || second.User.Rank != 2 {
+		t.Errorf("Expected bob added at rank 2, got %+v", second.User)
+	}
+
+	moved := readLive(t, conn)
+	if moved.Type != "rating" || moved.Source != "match" || moved.OldRating != 1400 || moved.OldRank != 2 ||
+		moved.User.Rating != 1600 || moved.User.Rank != 1 {
+		t.Errorf("Unexpected rating event: %+v %+v", moved, moved.User)
+	}
+
+	if removed := readLive(t, conn); removed.Type != "removed" || removed.User.ID != "a" {
+		t.Errorf("Unexpected remove event: %+v", removed)
+	}
+	if cleared := readLive(t, conn); cleared.Type != "cleared" || cleared.User != nil {
+		t.Errorf("Unexpected clear event: %+v", cleared)
+	}
+}
+
+func TestLive_ShutdownClosesClients(t *testing.T) {
+	server, _, hub := liveServer(t)
+	conn := dialLive(t, server, hub)
+
+	if err := hub.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected a going-away close, got %v", err)
+	}
+	if _, err := hub.Subscribe(); err != services.ErrHubStopped {
+		t.Errorf("Expected ErrHubStopped after Stop, got %v", err)
+	}
+}
+
+func TestLive_DropsSlowSubscribers(t *testing.T) {
+	memoryStore := store.NewMemoryStore(store.NewRatingBucketIndex())
+	hub := services.NewLiveHub()
+	memoryStore.AddChangeFeed(hub.Feed())
+	hub.Start()
+	defer hub.Stop(context.Background())
+
+	slow, _ := hub.Subscribe()
+
+	memoryStore.AddUser(&models.User{ID: "u", Username: "user", Rating: 1000})
+	for i := 1; i < 1000; i++ {
+		memoryStore.UpdateRating("u", 1000+i)
+	}
+
+	// The subscriber that never read is cut off once its buffer fills
+	for range slow.Events() {
+	}
+	if !slow.Slow() {
+		t.Error("Expected the idle subscriber to be marked slow")
+	}
+	if hub.Subscribers() != 0 {
+		t.Errorf("Expected the slow subscriber to be removed, got %d", hub.Subscribers())
+	}
+}
+
+func TestLive_BroadcastsTopPage(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	for i := 0; i < 60; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 1000 + i})
+	}
+	hub := services.NewLiveHub()
+	memoryStore.AddChangeFeed(hub.Feed())
+	hub.Start()
+	service := services.NewLeaderboardService(memoryStore, ratingIndex, store.NewPresenceTracker(time.Minute))
+	// Ticked by hand below
+	topPage := services.NewTopPageBroadcaster(hub, service, memoryStore, time.Hour)
+
+	liveHandler := handlers.NewLiveHandler(hub)
+	liveHandler.SetTopPage(topPage)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/ws", liveHandler.Stream).Methods("GET")
+	server := httptest.NewServer(router)
+	t.Cleanup(func() {
+		hub.Stop(context.Background())
+		server.Close()
+	})
+
+	// Nobody is listening yet, so there is nothing to compute
+	topPage.Tick(context.Background())
+	if _, ok := topPage.Latest(); ok {
+		t.Error("Expected no page computed without subscribers")
+	}
+
+	conn := dialLive(t, server, hub)
+	if err := topPage.Tick(context.Background()); err != nil {
+		t.Fatalf("Tick failed: %v", err)
+	}
+	event := readLive(t, conn)
+	if event.Type != "top_page" || event.Page == nil {
+		t.Fatalf("Expected a top_page event, got %+v", event)
+	}
+	if len(event.Page.Users) != services.TopPageSize || event.Page.Users[0].ID != "u59" || event.Page.TotalUsers != 60 {
+		t.Errorf("Expected the top %d of 60 led by u59, got %d users led by %+v", services.TopPageSize, len(event.Page.Users), event.Page.Users[0])
+	}
+
+	// An unchanged board isn't sent again; the next event is the change
+	topPage.Tick(context.Background())
+	memoryStore.UpdateRatingFrom(context.Background(), "u0", 5000, store.SourceMatch)
+	if change := readLive(t, conn); change.Type != "rating" {
+		t.Fatalf("Expected the rating change, got %+v", change)
+	}
+	topPage.Tick(context.Background())
+	if next := readLive(t, conn); next.Type != "top_page" || next.Page.Users[0].ID != "u0" {
+		t.Errorf("Expected the changed page led by u0, got %+v", next)
+	}
+
+	// A client connecting now starts from the last page
+	late, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer late.Close()
+	if first := readLive(t, late); first.Type != "top_page" || first.Page.Users[0].ID != "u0" {
+		t.Errorf("Expected a late client to get the last page first, got %+v", first)
+	}
+}
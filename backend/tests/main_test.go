@@ -0,0 +1,16 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"leaderboard-backend/store"
+)
+
+// TestMain runs the whole suite with lock ownership assertions enabled, so
+// any store path that touches the skip list without holding the store lock
+// panics instead of racing silently
+func TestMain(m *testing.M) {
+	store.SetLockChecks(true)
+	os.Exit(m.Run())
+}
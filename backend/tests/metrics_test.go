@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"leaderboard-backend/metrics"
+	"leaderboard-backend/models"
+)
+
+func TestHistogram_Quantiles(t *testing.T) {
+	h := metrics.NewHistogram("test_quantile_seconds", "test histogram")
+
+	for i := 0; i < 99; i++ {
+		h.Observe(100 * time.Microsecond)
+	}
+	h.Observe(200 * time.Millisecond)
+
+	if h.Count() != 100 {
+		t.Fatalf("Expected 100 observations, got %d", h.Count())
+	}
+	if p50 := h.Quantile(0.5); p50 > 0.0001 || p50 < 0.00005 {
+		t.Errorf("p50 should fall in the 100µs bucket, got %g", p50)
+	}
+	if p100 := h.Quantile(1.0); p100 < 0.1 || p100 > 0.25 {
+		t.Errorf("max should fall in the 250ms bucket, got %g", p100)
+	}
+}
+
+func TestAPI_MetricsEndpoint(t *testing.T) {
+	router, memoryStore, _, _ := setupTestServer()
+
+	memoryStore.AddUser(&models.User{ID: "metrics-a", Username: "metricsa", Rating: 1000})
+	memoryStore.UpdateRating("metrics-a", 1100)
+	memoryStore.GetTopUsers(10, 0)
+	memoryStore.SearchUsers("met")
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	for _, name := range []string{
+		"store_add_user_seconds",
+		"store_update_rating_seconds",
+		"store_get_top_users_seconds",
+		"store_search_users_seconds",
+	} {
+		if !strings.Contains(body, "# TYPE "+name+" histogram") {
+			t.Errorf("Missing histogram %s", name)
+		}
+		if !strings.Contains(body, name+`_bucket{le="+Inf"}`) {
+			t.Errorf("Missing +Inf bucket for %s", name)
+		}
+	}
+}
+
+func TestStatsdEmitter_PushesDeltas(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	counter := metrics.NewCounter("test_statsd_pushes_total", "test counter")
+	h := metrics.NewHistogram("test_statsd_seconds", "test histogram")
+	counter.Add(3)
+	h.Observe(time.Millisecond)
+
+	emitter, err := metrics.NewStatsdEmitter(metrics.Default, conn.LocalAddr().String(), "lb.", []string{"env:test"})
+	if err != nil {
+		t.Fatalf("NewStatsdEmitter failed: %v", err)
+	}
+	defer emitter.Close()
+
+	read := func() string {
+		var lines []string
+		buf := make([]byte, 64*1024)
+		for {
+			conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return strings.Join(lines, "\n")
+			}
+			lines = append(lines, string(buf[:n]))
+		}
+	}
+
+	if err := emitter.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	first := read()
+	for _, want := range []string{
+		"lb.test_statsd_pushes_total:3|c|#env:test",
+		"lb.test_statsd_seconds.count:1|c|#env:test",
+		"lb.test_statsd_seconds.p99:",
+	} {
+		if !strings.Contains(first, want) {
+			t.Errorf("Expected %q in the first push, got:\n%s", want, first)
+		}
+	}
+
+	counter.Inc()
+	if err := emitter.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	second := read()
+	if !strings.Contains(second, "lb.test_statsd_pushes_total:1|c") {
+		t.Errorf("Expected only the increase to be pushed, got:\n%s", second)
+	}
+	if strings.Contains(second, "test_statsd_seconds") {
+		t.Errorf("Expected an unchanged histogram to be skipped, got:\n%s", second)
+	}
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"leaderboard-backend/alerts"
+	"leaderboard-backend/notify"
+)
+
+func TestDispatcher_AggregatesAndDrops(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	// Not started yet, so everything stays queued
+	d := notify.NewDispatcher(notify.Options{Workers: 2, QueueSize: 2, Policy: notify.PolicyAggregate})
+	if !d.Send(notify.Message{URL: server.URL, Key: "u1", Body: []byte(`1`)}) ||
+		!d.Send(notify.Message{URL: server.URL, Key: "u1", Body: []byte(`2`)}) ||
+		!d.Send(notify.Message{URL: server.URL, Key: "u2", Body: []byte(`3`)}) {
+		t.Fatal("Expected the first messages to be queued")
+	}
+	if d.Send(notify.Message{URL: server.URL, Key: "u3", Body: []byte(`4`)}) {
+		t.Error("Expected a new key to be dropped while the queue is full")
+	}
+	if !d.Send(notify.Message{URL: server.URL, Key: "u2", Body: []byte(`5`)}) {
+		t.Error("Expected a queued key to be replaced even while the queue is full")
+	}
+	if depth, capacity := d.Backlog(); depth != 2 || capacity != 2 {
+		t.Errorf("Expected backlog 2/2, got %d/%d", depth, capacity)
+	}
+
+	d.Start()
+	if err := d.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 || bodies[0]+bodies[1] != "25" && bodies[0]+bodies[1] != "52" {
+		t.Errorf("Expected the latest message per key, got %v", bodies)
+	}
+	if d.Send(notify.Message{URL: server.URL, Key: "late", Body: []byte(`6`)}) {
+		t.Error("Expected messages sent after Stop to be dropped")
+	}
+}
+
+func TestDispatcher_DeadLettersFailingEndpoint(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var dead []notify.DeadLetter
+	d := notify.NewDispatcher(notify.Options{Workers: 1, Policy: notify.PolicyDrop, MaxAttempts: 3, Backoff: time.Millisecond})
+	d.SetDeadLetterHandler(func(letter notify.DeadLetter) {
+		mu.Lock()
+		dead = append(dead, letter)
+		mu.Unlock()
+	})
+	d.Start()
+	d.Send(notify.Message{URL: server.URL, Key: "a", Body: []byte(`{}`)})
+	d.Send(notify.Message{URL: server.URL, Key: "b", Body: []byte(`{}`)})
+	if err := d.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dead) != 2 || dead[0].Key != "a" || dead[0].Attempts != 3 {
+		t.Fatalf("Expected both messages dead-lettered, the first after 3 attempts, got %+v", dead)
+	}
+	// Once failing, the endpoint gets a single attempt per message
+	if dead[1].Attempts != 1 || attempts.Load() != 4 {
+		t.Errorf("Expected 1 attempt for the second message and 4 in all, got %d and %d", dead[1].Attempts, attempts.Load())
+	}
+	endpoint := d.GetStats()["endpoints"].(map[string]interface{})[server.URL].(map[string]interface{})
+	if endpoint["failing"] != true {
+		t.Errorf("Expected the endpoint to be marked failing, got %v", endpoint)
+	}
+}
+
+func TestDispatcher_DoesNotRetryRejections(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	d := notify.NewDispatcher(notify.Options{MaxAttempts: 5, Backoff: time.Millisecond})
+	d.Start()
+	d.Send(notify.Message{URL: server.URL, Key: "a", Body: []byte(`{}`)})
+	d.Stop(context.Background())
+
+	if attempts.Load() != 1 {
+		t.Errorf("Expected a 400 to be dead-lettered without retries, got %d attempts", attempts.Load())
+	}
+}
+
+func TestEvaluator_PostsThroughDispatcher(t *testing.T) {
+	received := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	d := notify.NewDispatcher(notify.Options{Workers: 1})
+	d.Start()
+	defer d.Stop(context.Background())
+
+	evaluator := alerts.NewEvaluator(server.URL)
+	evaluator.SetDispatcher(d)
+	evaluator.AddRule(alerts.Rule{Name: "always", Threshold: 1, Probe: func() float64 { return 2 }})
+	evaluator.Evaluate()
+
+	select {
+	case body := <-received:
+		if body == "" {
+			t.Error("Expected the alert as the webhook body")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the alert transition to be posted")
+	}
+}
+
+func TestDeadLetterQueue_PersistsAndReplays(t *testing.T) {
+	var healthy atomic.Bool
+	received := make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+	queue, err := notify.OpenDeadLetterQueue(path, 10)
+	if err != nil {
+		t.Fatalf("OpenDeadLetterQueue failed: %v", err)
+	}
+	d := notify.NewDispatcher(notify.Options{Workers: 1, MaxAttempts: 2, Backoff: time.Millisecond})
+	d.SetDeadLetterHandler(func(letter notify.DeadLetter) { queue.Add(letter) })
+	d.Start()
+	d.Send(notify.Message{URL: server.URL, Key: "user:a", Body: []byte(`{"n":1}`)})
+	d.Send(notify.Message{URL: server.URL, Key: "user:b", Body: []byte(`{"n":2}`)})
+	d.Stop(context.Background())
+	queue.Close()
+
+	// The letters survive a restart
+	queue, err = notify.OpenDeadLetterQueue(path, 10)
+	if err != nil {
+		t.Fatalf("Reopening failed: %v", err)
+	}
+	defer queue.Close()
+	letters, total := queue.List(0, 10)
+	if total != 2 || letters[0].Key != "user:a" || string(letters[0].Body) != `{"n":1}` || letters[0].Attempts != 2 {
+		t.Fatalf("Expected both letters back after reopening, got %d: %+v", total, letters)
+	}
+
+	healthy.Store(true)
+	d = notify.NewDispatcher(notify.Options{Workers: 1})
+	d.SetDeadLetterHandler(func(letter notify.DeadLetter) { queue.Add(letter) })
+	d.Start()
+	replayed, err := queue.Replay(d, []uint64{letters[1].ID})
+	if err != nil || replayed != 1 || queue.Len() != 1 {
+		t.Fatalf("Expected one letter replayed and one left, got %d %v, %d left", replayed, err, queue.Len())
+	}
+	d.Stop(context.Background())
+
+	select {
+	case body := <-received:
+		if body != `{"n":2}` {
+			t.Errorf("Expected the replayed body, got %s", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the replayed letter to be delivered")
+	}
+}
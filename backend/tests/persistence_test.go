@@ -0,0 +1,326 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+)
+
+func TestAutoSaver_WriteThresholdTriggersSave(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	path := filepath.Join(t.TempDir(), "leaderboard.json")
+	p := store.NewPersistence(path)
+
+	// Interval disabled so only the write count can trigger a save
+	saver := store.NewAutoSaver(p, ms, 0, 50)
+
+	for i := 0; i < 49; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 1000})
+	}
+	if err := saver.Check(); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if p.Exists() {
+		t.Fatal("Should not save before reaching write threshold")
+	}
+
+	ms.AddUser(&models.User{ID: "u49", Username: "user49", Rating: 1000})
+	if err := saver.Check(); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !p.Exists() {
+		t.Fatal("Expected a save after reaching write threshold")
+	}
+
+	loadedIdx := store.NewRatingBucketIndex()
+	loaded := store.NewMemoryStore(loadedIdx)
+	if err := p.Load(loaded, loadedIdx); err != nil {
+		t.Fatalf("Failed to load saved data: %v", err)
+	}
+	if loaded.GetUserCount() != 50 {
+		t.Errorf("Expected 50 users saved, got %d", loaded.GetUserCount())
+	}
+}
+
+func TestAutoSaver_StatsInHealth(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	saver := store.NewAutoSaver(store.NewPersistence(filepath.Join(t.TempDir(), "leaderboard.json")), ms, time.Minute, 2)
+
+	presence := store.NewPresenceTracker(time.Minute)
+	userHandler := handlers.NewUserHandler(services.NewUserService(ms, idx, presence, 100, 5000),
+		services.NewLeaderboardService(ms, idx, presence), services.NewScoreSimulator(ms, idx, 100, 5000, 100),
+		0, idx, ms, nil)
+	userHandler.SetAutoSaver(saver)
+
+	health := func() map[string]interface{} {
+		rr := httptest.NewRecorder()
+		userHandler.Health(rr, httptest.NewRequest("GET", "/api/health", nil))
+		var response struct {
+			Autosave map[string]interface{} `json:"autosave"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode health: %v", err)
+		}
+		return response.Autosave
+	}
+
+	ms.AddUser(&models.User{ID: "u1", Username: "user1", Rating: 1000})
+	if stats := health(); stats["pending_mutations"] != float64(1) || stats["save_count"] != float64(0) {
+		t.Errorf("Expected one pending write and no saves, got %v", stats)
+	}
+	ms.AddUser(&models.User{ID: "u2", Username: "user2", Rating: 1000})
+	if err := saver.Check(); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if stats := health(); stats["pending_mutations"] != float64(0) || stats["save_count"] != float64(1) || stats["write_threshold"] != float64(2) {
+		t.Errorf("Expected the save in the health report, got %v", stats)
+	}
+}
+
+func TestPersistence_SaveDuringConcurrentWrites(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	for i := 0; i < 2000; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%4901})
+	}
+
+	p := store.NewPersistence(filepath.Join(t.TempDir(), "leaderboard.json"))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				ms.UpdateRating(fmt.Sprintf("u%d", i%2000), 100+(i*7)%4901)
+			}
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		if err := p.Save(ms); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	close(stop)
+	<-done
+
+	loadedIdx := store.NewRatingBucketIndex()
+	loaded := store.NewMemoryStore(loadedIdx)
+	if err := p.Load(loaded, loadedIdx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.GetUserCount() != 2000 {
+		t.Errorf("Expected 2000 users after reload, got %d", loaded.GetUserCount())
+	}
+}
+
+func TestSeasonArchive_LoadsSeasonsOnDemandWithLRU(t *testing.T) {
+	archive := store.NewSeasonArchive(t.TempDir(), 1)
+
+	for _, season := range []string{"s1", "s2"} {
+		users := []models.User{
+			{ID: "a", Username: "alpha", Rating: 2000},
+			{ID: "b", Username: "bravo", Rating: 3000},
+		}
+		if _, err := archive.Archive(season, users); err != nil {
+			t.Fatalf("Archive %s failed: %v", season, err)
+		}
+	}
+
+	standing, _, err := archive.FindUser("s1", "a")
+	if err != nil || standing.Rank != 2 {
+		t.Fatalf("Expected alpha at rank 2 in s1, got %+v (%v)", standing, err)
+	}
+	if _, _, err := archive.FindUser("s1", "missing"); err != store.ErrNoStanding {
+		t.Errorf("Expected ErrNoStanding, got %v", err)
+	}
+
+	// Loading s2 evicts s1; querying s1 again reloads it from disk
+	page, info, err := archive.Page("s2", 10, 0)
+	if err != nil || len(page) != 2 || info.UserCount != 2 || page[0].ID != "b" {
+		t.Fatalf("Unexpected s2 page: %+v %+v (%v)", page, info, err)
+	}
+	archive.Page("s1", 1, 1)
+
+	stats := archive.GetCacheStats()
+	if stats["hits"].(int64) != 1 || stats["misses"].(int64) != 3 || stats["evictions"].(int64) != 2 {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+	if loaded := stats["loaded"].([]string); len(loaded) != 1 || loaded[0] != "s1" {
+		t.Errorf("Expected only s1 loaded, got %v", loaded)
+	}
+}
+
+func TestPersistence_ShardedSaveAndLoad(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	for i := 0; i < 1000; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%4901})
+	}
+
+	dir := t.TempDir()
+	p := store.NewPersistence(filepath.Join(dir, "leaderboard.json"))
+	p.SetShards(4)
+
+	// Two saves: the second must replace the first generation of shards
+	for i := 0; i < 2; i++ {
+		if err := p.Save(ms); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if shards, _ := filepath.Glob(filepath.Join(dir, "leaderboard.*.shard-*.json")); len(shards) != 4 {
+		t.Errorf("Expected 4 shard files after resave, found %d", len(shards))
+	}
+
+	// A single-file reader loads whatever shard count was saved
+	loadedIdx := store.NewRatingBucketIndex()
+	loaded := store.NewMemoryStore(loadedIdx)
+	if err := store.NewPersistence(filepath.Join(dir, "leaderboard.json")).Load(loaded, loadedIdx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.GetUserCount() != 1000 || loadedIdx.GetTotalUsers() != 1000 {
+		t.Errorf("Expected 1000 users after sharded load, got %d", loaded.GetUserCount())
+	}
+
+	// Switching back to one file cleans up the shards
+	p.SetShards(1)
+	if err := p.Save(ms); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if shards, _ := filepath.Glob(filepath.Join(dir, "leaderboard.*.shard-*.json")); len(shards) != 0 {
+		t.Errorf("Expected shard files removed, found %d", len(shards))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the data file to remain, found %d entries", len(entries))
+	}
+}
+
+func TestPersistence_WorkerPoolReportsProgress(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	for i := 0; i < 500; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i})
+	}
+
+	p := store.NewPersistence(filepath.Join(t.TempDir(), "leaderboard.json"))
+	p.SetShards(8)
+	p.SetWorkers(3)
+	if err := p.Save(ms); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loadedIdx := store.NewRatingBucketIndex()
+	if err := p.Load(store.NewMemoryStore(loadedIdx), loadedIdx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	var stats struct {
+		Current  interface{} `json:"current"`
+		LastSave struct {
+			InProgress  bool `json:"in_progress"`
+			Users       int  `json:"users"`
+			ShardsDone  int  `json:"shards_done"`
+			ShardsTotal int  `json:"shards_total"`
+			Workers     int  `json:"workers"`
+		} `json:"last_save"`
+		LastLoad struct {
+			Users      int `json:"users"`
+			ShardsDone int `json:"shards_done"`
+		} `json:"last_load"`
+	}
+	raw, _ := json.Marshal(p.GetStats())
+	json.Unmarshal(raw, &stats)
+
+	if stats.Current != nil {
+		t.Errorf("Expected no operation in progress, got %v", stats.Current)
+	}
+	if stats.LastSave.InProgress || stats.LastSave.Users != 500 || stats.LastSave.ShardsDone != 8 ||
+		stats.LastSave.ShardsTotal != 8 || stats.LastSave.Workers != 3 {
+		t.Errorf("Unexpected save stats: %+v", stats.LastSave)
+	}
+	if stats.LastLoad.Users != 500 || stats.LastLoad.ShardsDone != 8 {
+		t.Errorf("Unexpected load stats: %+v", stats.LastLoad)
+	}
+}
+
+func TestPersistence_SyncPolicy(t *testing.T) {
+	if _, err := store.ParseSyncPolicy("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown sync policy")
+	}
+
+	for _, name := range []string{"never", "on-save"} {
+		policy, err := store.ParseSyncPolicy(name)
+		if err != nil {
+			t.Fatalf("ParseSyncPolicy(%q) failed: %v", name, err)
+		}
+
+		idx := store.NewRatingBucketIndex()
+		ms := store.NewMemoryStore(idx)
+		ms.AddUser(&models.User{ID: "u1", Username: "user1", Rating: 1000})
+
+		p := store.NewPersistence(filepath.Join(t.TempDir(), "leaderboard.json"))
+		p.SetSyncPolicy(policy)
+		if err := p.Save(ms); err != nil {
+			t.Fatalf("Save with policy %s failed: %v", name, err)
+		}
+		if got := p.GetStats()["sync_policy"]; got != policy {
+			t.Errorf("Expected sync_policy %s in stats, got %v", policy, got)
+		}
+	}
+}
+
+func TestMemoryStore_LoadUsersMatchesAddUser(t *testing.T) {
+	users := make([]*models.User, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		users = append(users, &models.User{ID: fmt.Sprintf("bulk%d", i), Username: fmt.Sprintf("bulk%d", i), Rating: 100 + (i*37)%4901})
+	}
+
+	oneByOneIdx := store.NewRatingBucketIndex()
+	oneByOne := store.NewMemoryStore(oneByOneIdx)
+	for _, user := range users {
+		userCopy := *user
+		oneByOne.AddUser(&userCopy)
+	}
+
+	bulkIdx := store.NewRatingBucketIndex()
+	bulk := store.NewMemoryStore(bulkIdx)
+	// The tree is rebuilt on top of users already in the index
+	bulk.AddUser(&models.User{ID: "early", Username: "early", Rating: 2500})
+	oneByOne.AddUser(&models.User{ID: "early", Username: "early", Rating: 2500})
+
+	if failed := bulk.LoadUsers(users, store.SourceImport); len(failed) != 0 {
+		t.Fatalf("Expected every user to load, got %v", failed)
+	}
+	if failed := bulk.LoadUsers([]*models.User{{ID: "bulk1", Username: "again", Rating: 500}}, store.SourceImport); failed["bulk1"] == nil {
+		t.Error("Expected a duplicate ID to be rejected")
+	}
+
+	if bulk.GetUserCount() != 5001 || bulkIdx.GetTotalUsers() != 5001 {
+		t.Fatalf("Expected 5001 users, store=%d index=%d", bulk.GetUserCount(), bulkIdx.GetTotalUsers())
+	}
+	for rating := store.MinRating; rating <= store.MaxRating; rating += 13 {
+		if got, want := bulkIdx.GetRank(rating), oneByOneIdx.GetRank(rating); got != want {
+			t.Fatalf("Rating %d: bulk rank %d, one-by-one rank %d", rating, got, want)
+		}
+	}
+	bulkTop, oneTop := bulk.GetTopUsers(100, 0), oneByOne.GetTopUsers(100, 0)
+	for i := range oneTop {
+		if bulkTop[i].ID != oneTop[i].ID {
+			t.Fatalf("Position %d: bulk has %s, one-by-one has %s", i, bulkTop[i].ID, oneTop[i].ID)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// postgresTestEnv names a scratch database for the PostgreSQL tests; they
+// are skipped when it is unset. Its tables are dropped and recreated.
+const postgresTestEnv = "TEST_DATABASE_URL"
+
+var _ store.Store = (*store.PostgresStore)(nil)
+
+func TestMigrations_AreOrdered(t *testing.T) {
+	migrations, err := store.Migrations()
+	if err != nil {
+		t.Fatalf("Migrations failed: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("Migration %s: expected version %d, got %d", migration.Name, i+1, migration.Version)
+		}
+		if migration.SQL == "" {
+			t.Errorf("Migration %s is empty", migration.Name)
+		}
+	}
+}
+
+func openTestPostgres(t *testing.T) *store.PostgresStore {
+	dsn := os.Getenv(postgresTestEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", postgresTestEnv)
+	}
+	pg, err := store.OpenPostgres(dsn)
+	if err != nil {
+		t.Fatalf("OpenPostgres failed: %v", err)
+	}
+	t.Cleanup(func() { pg.Close() })
+
+	if _, err := pg.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	// Running them again is a no-op
+	if applied, err := pg.Migrate(context.Background()); err != nil || applied != 0 {
+		t.Fatalf("Expected no migrations on the second run, got %d %v", applied, err)
+	}
+	pg.Clear()
+	return pg
+}
+
+func TestPostgresStore_SaveAndLoad(t *testing.T) {
+	pg := openTestPostgres(t)
+
+	ms := store.NewMemoryStore(store.NewRatingBucketIndex())
+	for i, rating := range []int{1200, 3400, 2100} {
+		ms.AddUser(&models.User{ID: string(rune('a' + i)), Username: "pg" + string(rune('a'+i)), Rating: rating})
+	}
+	ms.AddUser(&models.User{ID: "dupe", Username: "pgdupe", Rating: 900})
+	if _, err := ms.MergeUsers("a", "dupe", func(keep, dupe models.User) int { return keep.Rating }); err != nil {
+		t.Fatal(err)
+	}
+	ms.SetBot("c", true)
+	if err := pg.Save(ms); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	idx := store.NewRatingBucketIndex()
+	loaded := store.NewMemoryStore(idx)
+	if err := pg.Load(loaded); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.GetUserCount() != 3 || idx.GetRank(3400) != 1 || loaded.GetBotCount() != 1 {
+		t.Errorf("Expected 3 users with one bot and b on top, got %d users, %d bots", loaded.GetUserCount(), loaded.GetBotCount())
+	}
+	if into, merged := loaded.MergedInto("dupe"); !merged || into != "a" {
+		t.Errorf("Expected the merge tombstone to survive, got %q %v", into, merged)
+	}
+}
+
+func TestPostgresStore_InstancesShareDatabase(t *testing.T) {
+	pgA := openTestPostgres(t)
+	pgB, err := store.OpenPostgres(os.Getenv(postgresTestEnv))
+	if err != nil {
+		t.Fatalf("OpenPostgres failed: %v", err)
+	}
+	t.Cleanup(func() { pgB.Close() })
+
+	// Each instance saves only its own users, and deletes only rows it held
+	msA := store.NewMemoryStore(store.NewRatingBucketIndex())
+	msA.AddUser(&models.User{ID: "a1", Username: "pga1", Rating: 1500})
+	msA.AddUser(&models.User{ID: "a2", Username: "pga2", Rating: 1600})
+	msB := store.NewMemoryStore(store.NewRatingBucketIndex())
+	msB.AddUser(&models.User{ID: "b1", Username: "pgb1", Rating: 1700})
+	if err := pgA.Save(msA); err != nil {
+		t.Fatalf("Save A failed: %v", err)
+	}
+	if err := pgB.Save(msB); err != nil {
+		t.Fatalf("Save B failed: %v", err)
+	}
+	msA.DeleteUser("a2")
+	msA.UpdateRating("a1", 1550)
+	if err := pgA.Save(msA); err != nil {
+		t.Fatalf("Second save A failed: %v", err)
+	}
+
+	if got := pgA.GetUserCount(); got != 2 {
+		t.Errorf("Expected a1 and b1 to remain, got %d users", got)
+	}
+	if user, err := pgA.GetUser("b1"); err != nil || user.Rating != 1700 {
+		t.Errorf("Instance B's user was overwritten: %+v %v", user, err)
+	}
+	if user, err := pgA.GetUser("a1"); err != nil || user.Rating != 1550 {
+		t.Errorf("Expected a1's new rating to be saved, got %+v %v", user, err)
+	}
+
+	// An emptied database still counts as saved, so AUTO_SEED leaves it be
+	pgA.Clear()
+	if saved, err := pgA.Exists(); err != nil || !saved {
+		t.Errorf("Expected a cleared database to still count as saved, got %v %v", saved, err)
+	}
+}
+
+func TestPostgresStore_Store(t *testing.T) {
+	pg := openTestPostgres(t)
+
+	if err := pg.AddUser(&models.User{ID: "p1", Username: "Alpha", Rating: 2000}); err != nil {
+		t.Fatalf("AddUser failed: %v", err)
+	}
+	pg.AddUser(&models.User{ID: "p2", Username: "alpine", Rating: 2500})
+	pg.AddUser(&models.User{ID: "p3", Username: "beta", Rating: 2000})
+	if err := pg.AddUser(&models.User{ID: "p1", Username: "again", Rating: 100}); err == nil {
+		t.Error("Expected a duplicate ID to be rejected")
+	}
+
+	if err := pg.UpdateRating("p3", 3000); err != nil {
+		t.Fatalf("UpdateRating failed: %v", err)
+	}
+	if err := pg.UpdateRating("missing", 3000); err == nil {
+		t.Error("Expected an error updating a missing user")
+	}
+	if user, err := pg.GetUser("p3"); err != nil || user.Rating != 3000 {
+		t.Errorf("Expected p3 at 3000, got %+v %v", user, err)
+	}
+
+	top := pg.GetTopUsers(2, 0)
+	if len(top) != 2 || top[0].ID != "p3" || top[1].ID != "p2" {
+		t.Errorf("Unexpected top users: %+v", top)
+	}
+	if found := pg.SearchUsers("AL"); len(found) != 2 {
+		t.Errorf("Expected 2 case-insensitive prefix matches, got %+v", found)
+	}
+	if pg.GetUserCount() != 3 || len(pg.GetUsersByRating(2000)) != 1 {
+		t.Errorf("Unexpected counts: %d users, %d at 2000", pg.GetUserCount(), len(pg.GetUsersByRating(2000)))
+	}
+
+	pg.Clear()
+	if pg.GetUserCount() != 0 {
+		t.Errorf("Expected no users after Clear, got %d", pg.GetUserCount())
+	}
+}
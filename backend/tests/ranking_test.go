@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"math/rand"
 	"testing"
 
 	"leaderboard-backend/store"
@@ -139,3 +140,61 @@ func TestRatingBucketIndex_CompetitionRankingExample(t *testing.T) {
 		}
 	}
 }
+
+func TestRatingBucketIndex_MatchesBruteForce(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	rng := rand.New(rand.NewSource(7))
+	ratings := make([]int, 0, 2000)
+
+	for step := 0; step < 5000; step++ {
+		switch op := rng.Intn(4); {
+		case op == 0 && len(ratings) > 0:
+			i := rng.Intn(len(ratings))
+			idx.DecrementBucket(ratings[i])
+			ratings[i] = ratings[len(ratings)-1]
+			ratings = ratings[:len(ratings)-1]
+		case op == 1 && len(ratings) > 0:
+			i := rng.Intn(len(ratings))
+			newRating := store.MinRating + rng.Intn(store.RatingRange)
+			idx.UpdateRating(ratings[i], newRating)
+			ratings[i] = newRating
+		default:
+			rating := store.MinRating + rng.Intn(store.RatingRange)
+			idx.IncrementBucket(rating)
+			ratings = append(ratings, rating)
+		}
+	}
+
+	if total := idx.GetTotalUsers(); total != len(ratings) {
+		t.Fatalf("Expected %d total users, got %d", len(ratings), total)
+	}
+	for probe := store.MinRating; probe <= store.MaxRating; probe += 7 {
+		above := 0
+		for _, rating := range ratings {
+			if rating > probe {
+				above++
+			}
+		}
+		if got := idx.GetRank(probe); got != above+1 {
+			t.Fatalf("Rating %d: expected rank %d, got %d", probe, above+1, got)
+		}
+		distinct := make(map[int]bool)
+		for _, rating := range ratings {
+			if rating > probe {
+				distinct[rating] = true
+			}
+		}
+		if got := idx.GetDenseRank(probe); got != len(distinct)+1 {
+			t.Fatalf("Rating %d: expected dense rank %d, got %d", probe, len(distinct)+1, got)
+		}
+	}
+
+	// A bulk load rebuilds both trees
+	bulk := store.NewRatingBucketIndex()
+	bulk.IncrementBuckets(ratings)
+	for probe := store.MinRating; probe <= store.MaxRating; probe += 7 {
+		if bulk.GetDenseRank(probe) != idx.GetDenseRank(probe) {
+			t.Fatalf("Rating %d: bulk load dense rank %d, want %d", probe, bulk.GetDenseRank(probe), idx.GetDenseRank(probe))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"leaderboard-backend/middleware"
+)
+
+func TestRateLimiter_EvictsOnlyIdleVisitors(t *testing.T) {
+	rl := middleware.NewRateLimiter(1000, 1)
+	rl.SetIdleTimeout(20 * time.Millisecond)
+	handler := rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(addr string) int {
+		req := httptest.NewRequest("GET", "/api/leaderboard", nil)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	serve("10.0.0.1:1")
+	serve("10.0.0.2:1")
+	time.Sleep(40 * time.Millisecond)
+	serve("10.0.0.1:1")
+
+	if evicted := rl.CleanupVisitors(); evicted != 1 {
+		t.Errorf("Expected only the idle visitor evicted, got %d", evicted)
+	}
+	if visitors := rl.GetStats()["visitors"]; visitors != 1 {
+		t.Errorf("Expected 1 visitor left, got %v", visitors)
+	}
+}
+
+func TestRateLimiter_CleanupKeepsBurstSpent(t *testing.T) {
+	rl := middleware.NewRateLimiter(1, 2)
+	rl.SetIdleTimeout(0)
+	handler := rl.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func() int {
+		req := httptest.NewRequest("GET", "/api/leaderboard", nil)
+		req.RemoteAddr = "10.0.0.1:1"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	serve()
+	serve()
+	if code := serve(); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the burst to be spent, got %d", code)
+	}
+	// The bucket hasn't refilled, so the visitor is kept and stays limited
+	if evicted := rl.CleanupVisitors(); evicted != 0 {
+		t.Errorf("Expected a visitor with a spent burst to be kept, got %d evicted", evicted)
+	}
+	if code := serve(); code != http.StatusTooManyRequests {
+		t.Errorf("Expected cleanup not to reset the burst, got %d", code)
+	}
+}
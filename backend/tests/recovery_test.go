@@ -0,0 +1,336 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+// crashChildEnv makes TestRecovery_KillMidWrite run as the writer process
+const crashChildEnv = "LEADERBOARD_CRASH_CHILD_DIR"
+
+// runCrashChild writes users forever with the WAL attached, saving once
+// part-way through, and reports each flushed batch on stdout until killed
+func runCrashChild(dir string) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	p := store.NewPersistence(filepath.Join(dir, "leaderboard.json"))
+	walPath := filepath.Join(dir, "leaderboard.json.wal")
+
+	if _, err := p.Recover(ms, idx, walPath); err != nil {
+		fmt.Println("error", err)
+		os.Exit(1)
+	}
+	wal, err := store.OpenWAL(walPath, store.SyncWALBatch)
+	if err != nil {
+		fmt.Println("error", err)
+		os.Exit(1)
+	}
+	p.SetJournal(wal)
+	ms.SetJournal(wal)
+
+	for i := 0; ; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("c%d", i), Username: fmt.Sprintf("crash%d", i), Rating: 100 + i%4901})
+		if i%3 == 0 && i > 0 {
+			ms.UpdateRating(fmt.Sprintf("c%d", i-1), 5000)
+		}
+		if (i+1)%50 == 0 {
+			if err := wal.Flush(); err != nil {
+				fmt.Println("error", err)
+				os.Exit(1)
+			}
+			if i+1 == 500 {
+				p.Save(ms)
+			}
+			fmt.Printf("flushed %d\n", i+1)
+		}
+	}
+}
+
+func TestRecovery_KillMidWrite(t *testing.T) {
+	if dir := os.Getenv(crashChildEnv); dir != "" {
+		runCrashChild(dir)
+		return
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRecovery_KillMidWrite$")
+	cmd.Env = append(os.Environ(), crashChildEnv+"="+dir)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Kill the writer without warning once a few thousand users are durable
+	flushed := 0
+	scanner := bufio.NewScanner(stdout)
+	deadline := time.After(30 * time.Second)
+	for flushed < 3000 {
+		select {
+		case <-deadline:
+			cmd.Process.Kill()
+			t.Fatalf("Writer only flushed %d users before the deadline", flushed)
+		default:
+		}
+		if !scanner.Scan() {
+			t.Fatalf("Writer exited early after %d users", flushed)
+		}
+		line := scanner.Text()
+		if strings.HasPrefix(line, "flushed ") {
+			flushed, _ = strconv.Atoi(strings.TrimPrefix(line, "flushed "))
+		} else if strings.HasPrefix(line, "error") {
+			t.Fatalf("Writer failed: %s", line)
+		}
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	p := store.NewPersistence(filepath.Join(dir, "leaderboard.json"))
+	report, err := p.Recover(ms, idx, filepath.Join(dir, "leaderboard.json.wal"))
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	if !report.Unclean {
+		t.Error("Expected the killed run to be detected as an unclean shutdown")
+	}
+	if report.SnapshotUsers < 500 {
+		t.Errorf("Expected the mid-run snapshot to hold at least 500 users, got %d", report.SnapshotUsers)
+	}
+	if report.EntriesReplayed == 0 {
+		t.Error("Expected WAL entries to be replayed over the snapshot")
+	}
+	if ms.GetUserCount() < flushed {
+		t.Errorf("Lost flushed writes: %d users flushed, %d recovered", flushed, ms.GetUserCount())
+	}
+	if idx.GetTotalUsers() != ms.GetUserCount() {
+		t.Errorf("Rating index out of sync: %d vs %d", idx.GetTotalUsers(), ms.GetUserCount())
+	}
+	// The writer's last flushed rating update
+	updated := fmt.Sprintf("c%d", (flushed-1)/3*3-1)
+	if user, err := ms.GetUser(updated); err != nil || user.Rating != 5000 {
+		t.Errorf("Expected a replayed rating update on %s, got %+v (%v)", updated, user, err)
+	}
+	if _, err := os.Stat(p.ReportPath()); err != nil {
+		t.Errorf("Expected a recovery report on disk: %v", err)
+	}
+}
+
+func TestRecovery_CleanShutdown(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "leaderboard.json.wal")
+
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	p := store.NewPersistence(filepath.Join(dir, "leaderboard.json"))
+	if _, err := p.Recover(ms, idx, walPath); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	wal, err := store.OpenWAL(walPath, store.SyncOnSave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetJournal(wal)
+	ms.SetJournal(wal)
+
+	ms.AddUser(&models.User{ID: "u1", Username: "user1", Rating: 1000})
+	ms.AddUser(&models.User{ID: "u2", Username: "user2", Rating: 2000})
+	if err := p.Save(ms); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	// Logged after the save: only in the WAL
+	ms.DeleteUser("u1")
+	wal.Close()
+	p.MarkClean()
+
+	idx = store.NewRatingBucketIndex()
+	ms = store.NewMemoryStore(idx)
+	p = store.NewPersistence(filepath.Join(dir, "leaderboard.json"))
+	report, err := p.Recover(ms, idx, walPath)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	if report.Unclean {
+		t.Error("Clean shutdown should not be reported as unclean")
+	}
+	if report.SnapshotUsers != 2 || report.EntriesReplayed != 1 {
+		t.Errorf("Expected 2 snapshot users and 1 replayed delete, got %+v", report)
+	}
+	if ms.GetUserCount() != 1 {
+		t.Errorf("Expected 1 user after replaying the delete, got %d", ms.GetUserCount())
+	}
+}
+
+func TestRecovery_ReplayKeepsSource(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "leaderboard.json.wal")
+
+	ms := store.NewMemoryStore(store.NewRatingBucketIndex())
+	wal, err := store.OpenWAL(walPath, store.SyncOnSave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.SetJournal(wal)
+	ms.AddUser(&models.User{ID: "u1", Username: "user1", Rating: 1000})
+	ms.UpdateRatings([]store.RatingUpdate{{ID: "u1", Rating: 1500, Source: store.SourceSimulator}})
+	wal.Close()
+
+	idx := store.NewRatingBucketIndex()
+	ms = store.NewMemoryStore(idx)
+	p := store.NewPersistence(filepath.Join(dir, "leaderboard.json"))
+	if _, err := p.Recover(ms, idx, walPath); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	recent := ms.GetRecentlyUpdated(1)
+	if len(recent) != 1 || recent[0].User.Rating != 1500 || recent[0].Source != store.SourceSimulator {
+		t.Errorf("Expected the replayed change to keep its simulator source, got %+v", recent)
+	}
+}
+
+func TestRecovery_CorruptRecordMidSegment(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "leaderboard.json.wal")
+	rotated := `{"op":"set","id":"u1","username":"user1","rating":1000}
+{"op":"set","id":"u2","usern
+{"op":"set","id":"u3","username":"user3","rating":1200}
+`
+	active := `{"op":"set","id":"u4","username":"user4","rating":1300}
+not json
+{"op":"set","id":"u5","username":"user5","rating":1400}
+{"op":"set","id":"u6","user`
+	if err := os.WriteFile(walPath+".1", []byte(rotated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(walPath, []byte(active), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	p := store.NewPersistence(filepath.Join(dir, "leaderboard.json"))
+	report, err := p.Recover(ms, idx, walPath)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	for _, id := range []string{"u1", "u3", "u4", "u5"} {
+		if _, err := ms.GetUser(id); err != nil {
+			t.Errorf("Expected %s to survive a corrupt record earlier in its segment: %v", id, err)
+		}
+	}
+	if report.EntriesReplayed != 4 || report.CorruptEntries != 2 || !report.TruncatedTail {
+		t.Errorf("Expected 4 replayed, 2 corrupt and a torn tail, got %+v", report)
+	}
+
+	// A bad last line in the rotated segment is corruption, not a torn write
+	os.WriteFile(walPath+".1", []byte(rotated+"{\"op\":\"set\"\n"), 0644)
+	os.WriteFile(walPath, []byte(active+"\n"), 0644)
+	idx = store.NewRatingBucketIndex()
+	ms = store.NewMemoryStore(idx)
+	report, err = p.Recover(ms, idx, walPath)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if report.CorruptEntries != 3 || !report.TruncatedTail {
+		t.Errorf("Expected 3 corrupt records and a torn tail, got %+v", report)
+	}
+}
+
+func TestRecovery_ReplayKeepsBotFlags(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "leaderboard.json.wal")
+
+	ms := store.NewMemoryStore(store.NewRatingBucketIndex())
+	wal, err := store.OpenWAL(walPath, store.SyncOnSave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.SetJournal(wal)
+	ms.AddUser(&models.User{ID: "born-bot", Username: "bornbot", Rating: 1000, Bot: true})
+	ms.AddUser(&models.User{ID: "flagged", Username: "flagged", Rating: 1200})
+	ms.AddUser(&models.User{ID: "human", Username: "human", Rating: 1400})
+	if _, err := ms.SetBot("flagged", true); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	idx := store.NewRatingBucketIndex()
+	ms = store.NewMemoryStore(idx)
+	if _, err := store.NewPersistence(filepath.Join(dir, "leaderboard.json")).Recover(ms, idx, walPath); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	for id, want := range map[string]bool{"born-bot": true, "flagged": true, "human": false} {
+		if user, err := ms.GetUser(id); err != nil || user.Bot != want {
+			t.Errorf("%s: expected bot=%v after replay, got %+v %v", id, want, user, err)
+		}
+	}
+	if ms.GetBotCount() != 2 {
+		t.Errorf("Expected 2 bots after replay, got %d", ms.GetBotCount())
+	}
+}
+
+func TestRecovery_MergeTombstones(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "leaderboard.json")
+	walPath := dataPath + ".wal"
+
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	wal, err := store.OpenWAL(walPath, store.SyncOnSave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.SetJournal(wal)
+	p := store.NewPersistence(dataPath)
+	p.SetJournal(wal)
+
+	for _, id := range []string{"a", "b", "c"} {
+		ms.AddUser(&models.User{ID: id, Username: "user" + id, Rating: 1000})
+	}
+	sumRatings := func(keep, dupe models.User) int { return keep.Rating + dupe.Rating }
+	// One merge lands in the snapshot, the other only in the log
+	if _, err := ms.MergeUsers("a", "b", sumRatings); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save(ms); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.MergeUsers("a", "c", sumRatings); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	idx = store.NewRatingBucketIndex()
+	ms = store.NewMemoryStore(idx)
+	if _, err := store.NewPersistence(dataPath).Recover(ms, idx, walPath); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	if ms.GetUserCount() != 1 || idx.GetTotalUsers() != 1 {
+		t.Fatalf("Expected only the kept user, store=%d index=%d", ms.GetUserCount(), idx.GetTotalUsers())
+	}
+	if user, err := ms.GetUser("a"); err != nil || user.Rating != 3000 {
+		t.Errorf("Expected the kept user's merged rating to survive, got %+v %v", user, err)
+	}
+	merged := ms.MergedIDs()
+	if len(merged) != 2 || merged["b"] != "a" || merged["c"] != "a" {
+		t.Errorf("Expected both tombstones restored, got %v", merged)
+	}
+}
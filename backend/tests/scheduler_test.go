@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"leaderboard-backend/scheduler"
+)
+
+func TestScheduler_RunsJobsAndTracksStatus(t *testing.T) {
+	s := scheduler.New()
+
+	var runs int32
+	s.Register("counter", 20*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	s.Register("failing", time.Hour, func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+
+	if err := s.Register("counter", time.Second, nil); err == nil {
+		t.Error("Registering a duplicate job name should fail")
+	}
+
+	s.Start()
+	if err := s.RunNow("failing"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if atomic.LoadInt32(&runs) < 2 {
+		t.Errorf("Expected counter job to run several times, ran %d", runs)
+	}
+
+	statuses := s.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "counter" || statuses[1].Name != "failing" {
+		t.Fatalf("Unexpected statuses: %+v", statuses)
+	}
+	if statuses[1].FailCount != 1 || statuses[1].LastError != "boom" {
+		t.Errorf("Expected failing job to record its error, got %+v", statuses[1])
+	}
+	if statuses[0].LastRun.IsZero() || !statuses[0].NextRun.After(statuses[0].LastRun) {
+		t.Errorf("Expected last/next run to be tracked, got %+v", statuses[0])
+	}
+
+	if err := s.RunNow("missing"); err == nil {
+		t.Error("RunNow for an unknown job should fail")
+	}
+}
+
+func TestFileLocker_OneOwnerPerLease(t *testing.T) {
+	dir := t.TempDir()
+	replicaA, _ := scheduler.NewFileLocker(dir, "replica-a")
+	replicaB, _ := scheduler.NewFileLocker(dir, "replica-b")
+
+	ok, err := replicaA.TryLock("decay", 100*time.Millisecond)
+	if err != nil || !ok {
+		t.Fatalf("First replica should acquire the lease, got ok=%v err=%v", ok, err)
+	}
+	if ok, _ := replicaB.TryLock("decay", 100*time.Millisecond); ok {
+		t.Error("Second replica must not acquire a live lease")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if ok, _ := replicaB.TryLock("decay", 100*time.Millisecond); !ok {
+		t.Error("Expired lease should be taken over")
+	}
+}
+
+func TestFileLocker_ConcurrentTakeoverHasOneWinner(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := scheduler.NewFileLocker(dir, "replica-0")
+	if ok, _ := first.TryLock("decay", 20*time.Millisecond); !ok {
+		t.Fatal("First replica should acquire the lease")
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	// Every round races replicas for an expired lease; only one may take it
+	for round := 0; round < 5; round++ {
+		var winners int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				locker, _ := scheduler.NewFileLocker(dir, fmt.Sprintf("replica-%d", i))
+				ok, err := locker.TryLock("decay", 200*time.Millisecond)
+				if err != nil {
+					t.Errorf("TryLock failed: %v", err)
+				}
+				if ok {
+					atomic.AddInt32(&winners, 1)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if winners != 1 {
+			t.Fatalf("Round %d: expected exactly one replica to take the expired lease, got %d", round, winners)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected superseded leases and temporary files to be cleaned up, found %d files", len(entries))
+	}
+}
+
+func TestScheduler_ExclusiveJobsRunOncePerPeriod(t *testing.T) {
+	dir := t.TempDir()
+	var runs int32
+
+	schedulers := make([]*scheduler.Scheduler, 0, 3)
+	for i := 0; i < 3; i++ {
+		locker, _ := scheduler.NewFileLocker(dir, "replica-"+string(rune('a'+i)))
+		s := scheduler.New()
+		s.SetLocker(locker)
+		s.RegisterExclusive("snapshot", 200*time.Millisecond, func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		})
+		schedulers = append(schedulers, s)
+	}
+
+	for _, s := range schedulers {
+		s.Start()
+	}
+	time.Sleep(500 * time.Millisecond)
+	for _, s := range schedulers {
+		s.Stop(context.Background())
+	}
+
+	// Two periods elapsed; without locking every replica would have run twice
+	if got := atomic.LoadInt32(&runs); got < 1 || got > 3 {
+		t.Errorf("Expected roughly one run per period across replicas, got %d", got)
+	}
+}
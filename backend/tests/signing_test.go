@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// signedRouter serves a fixed JSON body behind signer, plus the key endpoint
+func signedRouter(signer *middleware.Signer) *mux.Router {
+	router := mux.NewRouter()
+	router.Handle("/api/leaderboard", signer.SignFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"users":[{"id":"u1","rank":1}]}`))
+	})).Methods("GET")
+	router.HandleFunc("/api/signing-key", handlers.NewSigningHandler(signer).GetSigningKey).Methods("GET")
+	return router
+}
+
+// verifyRecorded checks the signature on a recorded response for a GET of uri
+func verifyRecorded(rr *httptest.ResponseRecorder, uri string, key []byte) bool {
+	timestamp, _ := strconv.ParseInt(rr.Header().Get(middleware.SignatureTimestampHeader), 10, 64)
+	return middleware.VerifyResponse(rr.Header().Get(middleware.SignatureAlgorithmHeader), key,
+		"GET", uri, rr.Code, timestamp, rr.Body.Bytes(), rr.Header().Get(middleware.SignatureHeader))
+}
+
+func TestSigning_Ed25519(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	signer, err := middleware.NewSigner(middleware.SigningEd25519, base64.StdEncoding.EncodeToString(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := signedRouter(signer)
+
+	// Consumers fetch the public key once
+	req, _ := http.NewRequest("GET", "/api/signing-key", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var keyInfo models.SigningKeyResponse
+	json.NewDecoder(rr.Body).Decode(&keyInfo)
+	publicKey, err := base64.StdEncoding.DecodeString(keyInfo.PublicKey)
+	if err != nil || keyInfo.Algorithm != middleware.SigningEd25519 || keyInfo.Ephemeral {
+		t.Fatalf("Unexpected key description: %+v (%v)", keyInfo, err)
+	}
+
+	uri := "/api/leaderboard?limit=10"
+	req, _ = http.NewRequest("GET", uri, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Header().Get(middleware.SignatureKeyIDHeader) != keyInfo.KeyID || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected key ID and original headers on the response, got %v", rr.Header())
+	}
+	if !verifyRecorded(rr, uri, publicKey) {
+		t.Fatal("Signature did not verify with the published key")
+	}
+
+	// Any change to the body or the request it answers breaks the signature
+	if verifyRecorded(rr, "/api/leaderboard?limit=50", publicKey) {
+		t.Error("Signature must not verify for a different request URI")
+	}
+	rr.Body.WriteString(" ")
+	if verifyRecorded(rr, uri, publicKey) {
+		t.Error("Signature must not verify for an altered body")
+	}
+}
+
+func TestSigning_HMACAndDisabled(t *testing.T) {
+	signer, err := middleware.NewSigner(middleware.SigningHMAC, "shared-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "/api/leaderboard", nil)
+	rr := httptest.NewRecorder()
+	signedRouter(signer).ServeHTTP(rr, req)
+	if !verifyRecorded(rr, "/api/leaderboard", []byte("shared-secret")) {
+		t.Error("HMAC signature did not verify with the shared secret")
+	}
+	if verifyRecorded(rr, "/api/leaderboard", []byte("other-secret")) {
+		t.Error("HMAC signature must not verify with another secret")
+	}
+
+	disabled, _ := middleware.NewSigner("", "")
+	router := signedRouter(disabled)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Header().Get(middleware.SignatureHeader) != "" {
+		t.Error("Responses must be unsigned when signing is disabled")
+	}
+	req, _ = http.NewRequest("GET", "/api/signing-key", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for the key endpoint when disabled, got %d", rr.Code)
+	}
+
+	for _, bad := range [][2]string{{middleware.SigningHMAC, ""}, {middleware.SigningEd25519, "not base64!"}, {middleware.SigningEd25519, "AAAA"}, {"rsa", "x"}} {
+		if _, err := middleware.NewSigner(bad[0], bad[1]); err == nil {
+			t.Errorf("Expected NewSigner(%q, %q) to fail", bad[0], bad[1])
+		}
+	}
+}
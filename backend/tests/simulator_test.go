@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+)
+
+func TestSimulator_StopWaitsForInFlightBatch(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	for i := 0; i < 100; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("sim-%d", i), Username: fmt.Sprintf("sim%d", i), Rating: 2500})
+	}
+
+	sim := services.NewScoreSimulator(ms, idx, 100, 5000, 1)
+	sim.Start()
+	time.Sleep(50 * time.Millisecond)
+	sim.Stop()
+
+	select {
+	case <-sim.Done():
+	default:
+		t.Fatal("Done channel should be closed once Stop returns")
+	}
+
+	// No further mutations may happen after Stop returns
+	before := ms.GetMutationCount()
+	time.Sleep(50 * time.Millisecond)
+	if after := ms.GetMutationCount(); after != before {
+		t.Errorf("Store mutated after Stop returned: %d -> %d", before, after)
+	}
+
+	// Stopping twice is a no-op
+	sim.Stop()
+}
+
+func TestSimulator_ReportsUpdateRate(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+	for i := 0; i < 100; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("rate-%d", i), Username: fmt.Sprintf("rate%d", i), Rating: 2500})
+	}
+
+	sim := services.NewScoreSimulator(ms, idx, 100, 5000, 10)
+	sim.Start()
+	time.Sleep(2100 * time.Millisecond)
+	sim.Stop()
+
+	rates := sim.GetStats()["updates_per_second"].(map[string]float64)
+	for _, window := range []string{"1s", "10s", "60s"} {
+		if _, ok := rates[window]; !ok {
+			t.Errorf("Missing %s rate window", window)
+		}
+	}
+	// 10 updates per 10ms tick is ~1000/s; allow generous slack for slow CI
+	if rates["1s"] < 100 {
+		t.Errorf("Expected a live 1s rate, got %.1f", rates["1s"])
+	}
+	if rates["10s"] <= 0 || rates["10s"] > rates["1s"] {
+		t.Errorf("10s rate should be positive and diluted by the idle window, got %.1f (1s=%.1f)", rates["10s"], rates["1s"])
+	}
+}
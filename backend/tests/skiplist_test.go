@@ -0,0 +1,474 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+)
+
+func TestSkipList_StatsTrackLevels(t *testing.T) {
+	sl := store.NewSkipList()
+
+	for i := 0; i < 5000; i++ {
+		sl.Insert(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%4901})
+	}
+	for i := 0; i < 1000; i++ {
+		sl.Remove(fmt.Sprintf("u%d", i))
+	}
+
+	stats := sl.GetStats()
+	perLevel := stats["nodes_per_level"].([]int)
+
+	if perLevel[0] != 4000 {
+		t.Errorf("Level 0 should contain every node: expected 4000, got %d", perLevel[0])
+	}
+	for i := 1; i < len(perLevel); i++ {
+		if perLevel[i] > perLevel[i-1] {
+			t.Errorf("Level %d has more nodes (%d) than level %d (%d)", i, perLevel[i], i-1, perLevel[i-1])
+		}
+	}
+	if stats["max_level_reached"].(int) < stats["current_level"].(int) {
+		t.Error("max_level_reached should never be below current_level")
+	}
+	if depth := stats["avg_search_depth"].(float64); depth <= 0 || depth > 100 {
+		t.Errorf("Average search depth looks unbalanced: %.2f", depth)
+	}
+}
+
+func TestSkipList_ArenaReusesNodes(t *testing.T) {
+	sl := store.NewSkipList()
+
+	for i := 0; i < 3000; i++ {
+		sl.Insert(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%4901})
+	}
+	for i := 0; i < 1000; i++ {
+		sl.Remove(fmt.Sprintf("u%d", i))
+	}
+	for i := 3000; i < 3500; i++ {
+		sl.Insert(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%4901})
+	}
+
+	arena := sl.GetStats()["arena"].(map[string]interface{})
+	allocated, reused, free := arena["allocated"].(int64), arena["reused"].(int64), arena["free_nodes"].(int)
+	if allocated+reused != 3500 {
+		t.Errorf("Expected every insert to take a node from the arena, got %d new and %d reused", allocated, reused)
+	}
+	if reused == 0 || int64(free) != 1000-reused {
+		t.Errorf("Expected removed nodes to be reused or kept free, got %d reused and %d free", reused, free)
+	}
+	if slabs := arena["slabs"].(int); slabs == 0 || slabs > 10 {
+		t.Errorf("Expected a few slabs for 3000 nodes, got %d", slabs)
+	}
+
+	top := sl.GetTopN(2500, 0)
+	if len(top) != 2500 {
+		t.Fatalf("Expected 2500 users, got %d", len(top))
+	}
+	for i := 1; i < len(top); i++ {
+		if top[i-1].Rating < top[i].Rating {
+			t.Fatalf("Reused nodes broke the order at position %d", i)
+		}
+	}
+}
+
+func TestSkipListParams_PickHeight(t *testing.T) {
+	for _, tc := range []struct {
+		users       int
+		probability float64
+		want        int
+	}{
+		{100, 0.25, 5},
+		{1_000_000, 0.25, 11},
+		{100_000_000, 0.25, 15},
+		{100_000_000, 0.5, 28},
+		{1, 0.25, 4},
+	} {
+		if got := store.AutoMaxLevel(tc.users, tc.probability); got != tc.want {
+			t.Errorf("AutoMaxLevel(%d, %v): expected %d, got %d", tc.users, tc.probability, tc.want, got)
+		}
+	}
+
+	if _, err := store.NewSkipListParams(0, 1, 1000); err == nil {
+		t.Error("Expected a probability of 1 to be rejected")
+	}
+	if _, err := store.NewSkipListParams(store.MaxLevel+1, 0.25, 1000); err == nil {
+		t.Error("Expected a height over MaxLevel to be rejected")
+	}
+	if params, err := store.NewSkipListParams(6, 0.5, 1000); err != nil || params.MaxLevel != 6 {
+		t.Errorf("Expected an explicit height kept, got %+v (%v)", params, err)
+	}
+}
+
+func TestSkipList_CustomParams(t *testing.T) {
+	params := store.SkipListParams{MaxLevel: 3, Probability: 0.5}
+	for _, impl := range []store.SkipListImpl{store.SkipListLocked, store.SkipListConcurrent} {
+		t.Run(string(impl), func(t *testing.T) {
+			memoryStore := store.NewMemoryStoreWithSkipListParams(store.NewRatingBucketIndex(), impl, params)
+			for i := 0; i < 2000; i++ {
+				memoryStore.AddUser(&models.User{ID: fmt.Sprintf("p%d", i), Username: fmt.Sprintf("param%d", i), Rating: 100 + rand.Intn(4900)})
+			}
+			top := memoryStore.GetTopUsers(2000, 0)
+			for i := 1; i < len(top); i++ {
+				if top[i].Rating > top[i-1].Rating {
+					t.Fatalf("Out of order at %d: %d after %d", i, top[i].Rating, top[i-1].Rating)
+				}
+			}
+
+			stats := memoryStore.GetStats()["skip_list"].(map[string]interface{})
+			if stats["max_level"] != 3 || stats["probability"] != 0.5 {
+				t.Errorf("Expected the params reported, got %v and %v", stats["max_level"], stats["probability"])
+			}
+			if reached := stats["max_level_reached"].(int); reached > 2 {
+				t.Errorf("Expected towers of at most 3 levels, reached level %d", reached)
+			}
+		})
+	}
+}
+
+func TestSkipList_GuardedRequiresOwnerLock(t *testing.T) {
+	var guard sync.RWMutex
+	sl := store.NewGuardedSkipList(&guard)
+	user := &models.User{ID: "u1", Username: "user1", Rating: 1000}
+
+	expectPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: expected a panic without the owner's lock", name)
+			}
+		}()
+		fn()
+	}
+
+	expectPanic("Insert unlocked", func() { sl.Insert(user) })
+	expectPanic("GetTopN unlocked", func() { sl.GetTopN(10, 0) })
+
+	// A read lock is enough for queries but not for mutations
+	guard.RLock()
+	expectPanic("Insert under read lock", func() { sl.Insert(user) })
+	if n := sl.Length(); n != 0 {
+		t.Errorf("Expected an empty skip list, got %d", n)
+	}
+	guard.RUnlock()
+
+	guard.Lock()
+	sl.Insert(user)
+	sl.Remove("u1")
+	guard.Unlock()
+}
+
+func TestSkipList_StoreHoldsOwnerLock(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStore(idx)
+
+	// Every store path that reaches the skip list must do so under the
+	// store lock; TestMain enables the checks that would panic otherwise
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				id := fmt.Sprintf("g%d-%d", g, i)
+				ms.AddUser(&models.User{ID: id, Username: "user" + id, Rating: 100 + i})
+				ms.UpdateRating(id, 200+i)
+				ms.UpdateRatings([]store.RatingUpdate{{ID: id, Rating: 300 + i}})
+				ms.GetTopUsers(10, 0)
+				ms.GetTopUsersFiltered(10, 0, func(*models.User) bool { return true })
+				ms.GetStats()
+				if i%2 == 0 {
+					ms.DeleteUser(id)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	ms.Clear()
+
+	if ms.GetUserCount() != 0 || ms.GetStats()["skip_list_size"].(int) != 0 {
+		t.Error("Expected an empty store after Clear")
+	}
+}
+
+func TestConcurrentSkipList_ReadersDuringWrites(t *testing.T) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStoreWithSkipList(idx, store.SkipListConcurrent)
+	for i := 0; i < 2000; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%4901})
+	}
+
+	var stop int32
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; atomic.LoadInt32(&stop) == 0; i++ {
+				ms.UpdateRating(fmt.Sprintf("u%d", (w*500+i)%2000), 100+(w*7919+i*31)%4901)
+			}
+		}(w)
+	}
+
+	// Pages read without the lock must stay in rating order
+	var readers sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			for i := 0; i < 300; i++ {
+				page := ms.GetTopUsers(100, (r*100)%1500)
+				for j := 1; j < len(page); j++ {
+					if page[j-1].Rating < page[j].Rating {
+						t.Errorf("Page out of order at %d: %d before %d", j, page[j-1].Rating, page[j].Rating)
+						return
+					}
+				}
+			}
+		}(r)
+	}
+	readers.Wait()
+	atomic.StoreInt32(&stop, 1)
+	writers.Wait()
+
+	all := ms.GetTopUsers(3000, 0)
+	if len(all) != 2000 {
+		t.Fatalf("Expected 2000 ranked users once writes stop, got %d", len(all))
+	}
+	for _, user := range all {
+		stored, err := ms.GetUser(user.ID)
+		if err != nil || stored.Rating != user.Rating {
+			t.Fatalf("Ranked rating for %s out of sync with the user map", user.ID)
+		}
+	}
+
+	reclamation := ms.GetStats()["skip_list"].(map[string]interface{})["reclamation"].(map[string]interface{})
+	if reclamation["reused"].(uint64) == 0 {
+		t.Errorf("Expected removed nodes to be reclaimed and reused, got %v", reclamation)
+	}
+	if reclamation["active_readers"].(int) != 0 {
+		t.Errorf("Expected no pinned readers after reads finished, got %v", reclamation["active_readers"])
+	}
+}
+
+// benchmarkReadsDuringWrites measures page reads while a writer keeps
+// changing ratings, for comparing the skip list implementations
+func benchmarkReadsDuringWrites(b *testing.B, impl store.SkipListImpl) {
+	idx := store.NewRatingBucketIndex()
+	ms := store.NewMemoryStoreWithSkipList(idx, impl)
+	for i := 0; i < 100000; i++ {
+		ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%4901})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			ms.UpdateRatings([]store.RatingUpdate{
+				{ID: fmt.Sprintf("u%d", i%100000), Rating: 100 + (i*31)%4901},
+				{ID: fmt.Sprintf("u%d", (i*7)%100000), Rating: 100 + (i*17)%4901},
+			})
+		}
+	}()
+	defer close(done)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ms.GetTopUsers(50, 0)
+		}
+	})
+}
+
+func BenchmarkSkipList_ReadsDuringWrites_Locked(b *testing.B) {
+	benchmarkReadsDuringWrites(b, store.SkipListLocked)
+}
+
+func BenchmarkSkipList_ReadsDuringWrites_Concurrent(b *testing.B) {
+	benchmarkReadsDuringWrites(b, store.SkipListConcurrent)
+}
+
+func TestSkipList_AroundMatchesFullOrder(t *testing.T) {
+	ctx := context.Background()
+	for _, impl := range []store.SkipListImpl{store.SkipListLocked, store.SkipListConcurrent, store.SkipListBTree} {
+		ms := store.NewMemoryStoreWithSkipList(store.NewRatingBucketIndex(), impl)
+		for i := 0; i < 300; i++ {
+			ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%50})
+		}
+		// Moves and removals must keep the backward links in step
+		for i := 0; i < 600; i++ {
+			ms.UpdateRating(fmt.Sprintf("u%d", (i*7)%300), 100+(i*13)%60)
+		}
+		for i := 0; i < 300; i += 11 {
+			ms.DeleteUser(fmt.Sprintf("u%d", i))
+		}
+
+		all := ms.GetTopUsers(1000, 0)
+		for pos, user := range all {
+			around, err := ms.GetAroundContext(ctx, user.ID, 3)
+			if err != nil {
+				t.Fatalf("%s: %v", impl, err)
+			}
+			start := pos - 3
+			if start < 0 {
+				start = 0
+			}
+			end := pos + 4
+			if end > len(all) {
+				end = len(all)
+			}
+			if around.Index != pos-start || len(around.Users) != end-start {
+				t.Fatalf("%s: around %s got index %d of %d, want %d of %d", impl, user.ID, around.Index, len(around.Users), pos-start, end-start)
+			}
+			for i, neighbour := range around.Users {
+				if neighbour.ID != all[start+i].ID {
+					t.Fatalf("%s: around %s position %d is %s, want %s", impl, user.ID, i, neighbour.ID, all[start+i].ID)
+				}
+			}
+		}
+		if _, err := ms.GetAroundContext(ctx, "u0", 3); err == nil {
+			t.Errorf("%s: expected an error for a removed user", impl)
+		}
+	}
+}
+
+func TestSkipList_VisitMatchesPages(t *testing.T) {
+	ctx := context.Background()
+	for _, impl := range []store.SkipListImpl{store.SkipListLocked, store.SkipListConcurrent, store.SkipListBTree} {
+		ms := store.NewMemoryStoreWithSkipList(store.NewRatingBucketIndex(), impl)
+		for i := 0; i < 500; i++ {
+			ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%40})
+		}
+
+		for _, offset := range []int{0, 1, 63, 64, 250, 490, 500} {
+			want := ms.GetTopUsers(20, offset)
+			var got []string
+			err := ms.VisitTopUsersContext(ctx, 20, offset, func(user *models.User) bool {
+				got = append(got, user.ID)
+				return true
+			})
+			if err != nil || len(got) != len(want) {
+				t.Fatalf("%s: offset %d visited %d users, want %d (%v)", impl, offset, len(got), len(want), err)
+			}
+			for i := range want {
+				if got[i] != want[i].ID {
+					t.Fatalf("%s: offset %d row %d is %s, want %s", impl, offset, i, got[i], want[i].ID)
+				}
+			}
+		}
+
+		// Returning false stops the walk
+		visited := 0
+		ms.VisitTopUsersContext(ctx, 20, 0, func(user *models.User) bool {
+			visited++
+			return visited < 5
+		})
+		if visited != 5 {
+			t.Errorf("%s: expected the walk to stop after 5 users, got %d", impl, visited)
+		}
+	}
+}
+
+func TestSkipList_AfterMatchesOffsetPages(t *testing.T) {
+	ctx := context.Background()
+	for _, impl := range []store.SkipListImpl{store.SkipListLocked, store.SkipListConcurrent, store.SkipListBTree} {
+		ms := store.NewMemoryStoreWithSkipList(store.NewRatingBucketIndex(), impl)
+		for i := 0; i < 500; i++ {
+			ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + i%40})
+		}
+
+		all := ms.GetTopUsers(1000, 0)
+		// Walking pages from each position covers the rest in order
+		for pos := 0; pos < len(all); pos += 17 {
+			after := all[pos]
+			users, err := ms.GetUsersAfterContext(ctx, after.Rating, after.Username, 10, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", impl, err)
+			}
+			want := all[pos+1:]
+			if len(want) > 10 {
+				want = want[:10]
+			}
+			if len(users) != len(want) {
+				t.Fatalf("%s: after %s got %d users, want %d", impl, after.ID, len(users), len(want))
+			}
+			for i := range want {
+				if users[i].ID != want[i].ID {
+					t.Fatalf("%s: after %s position %d is %s, want %s", impl, after.ID, i, users[i].ID, want[i].ID)
+				}
+			}
+		}
+
+		// A position between users starts at the next one down; usernames
+		// ascend within a rating
+		if users, _ := ms.GetUsersAfterContext(ctx, 120, "", 1, nil); len(users) != 1 || users[0].Rating != 120 {
+			t.Errorf("%s: expected the first user at 120, got %+v", impl, users)
+		}
+		users, _ := ms.GetUsersAfterContext(ctx, 120, "~", 1, nil)
+		if len(users) != 1 || users[0].Rating != 119 {
+			t.Errorf("%s: expected the first user below 120, got %+v", impl, users)
+		}
+		users, _ = ms.GetUsersAfterContext(ctx, 130, "zzz", 5, func(user *models.User) bool { return user.Rating%2 == 0 })
+		for _, user := range users {
+			if user.Rating >= 130 || user.Rating%2 != 0 {
+				t.Errorf("%s: filter or position ignored: %+v", impl, user)
+			}
+		}
+	}
+}
+
+func TestSkipList_SpansMatchFullOrder(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for _, impl := range []store.SkipListImpl{store.SkipListLocked, store.SkipListConcurrent, store.SkipListBTree} {
+		ms := store.NewMemoryStoreWithSkipList(store.NewRatingBucketIndex(), impl)
+		for i := 0; i < 800; i++ {
+			ms.AddUser(&models.User{ID: fmt.Sprintf("u%d", i), Username: fmt.Sprintf("user%d", i), Rating: 100 + rng.Intn(60)})
+		}
+		// Churn the towers so spans are split and merged many times
+		for i := 0; i < 2000; i++ {
+			id := fmt.Sprintf("u%d", rng.Intn(800))
+			switch rng.Intn(3) {
+			case 0:
+				ms.DeleteUser(id)
+			case 1:
+				ms.AddUser(&models.User{ID: id, Username: "user" + id[1:], Rating: 100 + rng.Intn(60)})
+			default:
+				ms.UpdateRating(id, 100+rng.Intn(60))
+			}
+		}
+
+		all := ms.GetTopUsers(1000, 0)
+		for offset := 0; offset <= len(all); offset++ {
+			page := ms.GetTopUsers(3, offset)
+			want := all[offset:]
+			if len(want) > 3 {
+				want = want[:3]
+			}
+			if len(page) != len(want) {
+				t.Fatalf("%s: offset %d got %d users, want %d", impl, offset, len(page), len(want))
+			}
+			for i := range want {
+				if page[i].ID != want[i].ID {
+					t.Fatalf("%s: offset %d row %d is %s, want %s", impl, offset, i, page[i].ID, want[i].ID)
+				}
+			}
+		}
+		for i, user := range all {
+			if position, err := ms.GetPosition(user.ID); err != nil || position != i+1 {
+				t.Fatalf("%s: %s expected at position %d, got %d %v", impl, user.ID, i+1, position, err)
+			}
+		}
+		if _, err := ms.GetPosition("missing"); err == nil {
+			t.Errorf("%s: expected an error for a missing user", impl)
+		}
+	}
+}
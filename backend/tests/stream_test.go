@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+
+	"github.com/gorilla/mux"
+)
+
+// readStream collects the data of each "leaderboard" event from an SSE body
+func readStream(t *testing.T, resp *http.Response) <-chan models.LeaderboardResponse {
+	pages := make(chan models.LeaderboardResponse, 16)
+	go func() {
+		defer close(pages)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 1<<20), 1<<20)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var page models.LeaderboardResponse
+				if err := json.Unmarshal([]byte(data), &page); err != nil {
+					t.Errorf("Bad event data %q: %v", data, err)
+					return
+				}
+				pages <- page
+			}
+		}
+	}()
+	return pages
+}
+
+func nextPage(t *testing.T, pages <-chan models.LeaderboardResponse) models.LeaderboardResponse {
+	select {
+	case page, ok := <-pages:
+		if !ok {
+			t.Fatal("Stream ended")
+		}
+		return page
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a leaderboard event")
+	}
+	return models.LeaderboardResponse{}
+}
+
+func expectNoPage(t *testing.T, pages <-chan models.LeaderboardResponse, why string) {
+	select {
+	case page := <-pages:
+		t.Errorf("Expected no event %s, got %+v", why, page.Users)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestStream_SendsPageOnChange(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	for i := 0; i < 10; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("s-%d", i), Username: fmt.Sprintf("s%d", i), Rating: 1000 + 100*i})
+	}
+
+	hub := services.NewLiveHub()
+	memoryStore.AddChangeFeed(hub.Feed())
+	hub.Start()
+	service := services.NewLeaderboardService(memoryStore, ratingIndex, store.NewPresenceTracker(time.Minute))
+	streamHandler := handlers.NewStreamHandler(service, hub, 50*time.Millisecond)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/leaderboard/stream", streamHandler.StreamLeaderboard).Methods("GET")
+	// A request timeout shorter than the test must not end the stream
+	timeout := middleware.RequestTimeout(100*time.Millisecond, "/api/leaderboard/stream")
+	server := httptest.NewServer(middleware.NewLogger().LogRequest(timeout(router)))
+	defer server.Close()
+	defer hub.Stop(context.Background())
+
+	resp, err := http.Get(server.URL + "/api/leaderboard/stream?limit=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+	pages := readStream(t, resp)
+
+	if page := nextPage(t, pages); len(page.Users) != 3 || page.Users[0].ID != "s-9" {
+		t.Fatalf("Expected the top 3 on connect, got %+v", page.Users)
+	}
+
+	// Changes below the page are ignored
+	memoryStore.UpdateRating("s-0", 1050)
+	expectNoPage(t, pages, "for a change below the page")
+
+	// A burst of changes to the page is sent once
+	for i := 0; i < 20; i++ {
+		memoryStore.UpdateRating("s-1", 3000+i)
+	}
+	page := nextPage(t, pages)
+	if page.Users[0].ID != "s-1" || page.Users[0].Rating != 3019 || page.Users[0].Rank != 1 {
+		t.Errorf("Expected s-1 on top at 3019, got %+v", page.Users[0])
+	}
+	expectNoPage(t, pages, "after a debounced burst")
+
+	// Changes that cancel out within the interval send nothing
+	memoryStore.UpdateRating("s-8", 1850)
+	memoryStore.UpdateRating("s-8", 1800)
+	expectNoPage(t, pages, "when the page ends up unchanged")
+
+	// Closing the handler ends the stream so the server can shut down
+	streamHandler.Close()
+	select {
+	case _, ok := <-pages:
+		if ok {
+			t.Error("Expected the stream to end after Close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Stream stayed open after Close")
+	}
+}
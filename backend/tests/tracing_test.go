@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"leaderboard-backend/middleware"
+	"leaderboard-backend/models"
+	"leaderboard-backend/store"
+	"leaderboard-backend/tracing"
+)
+
+func TestTrace_HeadersReachChanges(t *testing.T) {
+	memoryStore := store.NewMemoryStore(store.NewRatingBucketIndex())
+	memoryStore.AddUser(&models.User{ID: "traced", Username: "traced", Rating: 1000})
+	feed := make(chan store.Change, 4)
+	memoryStore.AddChangeFeed(feed)
+
+	handler := middleware.Trace(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		memoryStore.UpdateRatingFrom(r.Context(), "traced", 1100, store.SourceMatch)
+	}))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	for _, tc := range []struct {
+		header, value string
+	}{
+		{tracing.TraceparentHeader, "00-" + traceID + "-00f067aa0ba902b7-01"},
+		{tracing.CloudTraceHeader, strings.ToUpper(traceID) + "/12345;o=1"},
+	} {
+		req := httptest.NewRequest("PATCH", "/api/users/traced/rating", nil)
+		req.Header.Set(tc.header, tc.value)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		change := <-feed
+		if !strings.HasPrefix(change.Trace, "00-"+traceID+"-") || !strings.HasSuffix(change.Trace, "-01") {
+			t.Errorf("%s: expected the change to continue trace %s, got %q", tc.header, traceID, change.Trace)
+		}
+		if strings.Contains(change.Trace, "00f067aa0ba902b7") {
+			t.Errorf("%s: expected a new span for this server, got %q", tc.header, change.Trace)
+		}
+		if got := rr.Header().Get(tracing.TraceparentHeader); got != change.Trace {
+			t.Errorf("%s: expected the response to echo %q, got %q", tc.header, change.Trace, got)
+		}
+		memoryStore.UpdateRating("traced", 1000)
+		<-feed
+	}
+
+	// Without a usable header a trace is started, so logs still correlate
+	req := httptest.NewRequest("PATCH", "/api/users/traced/rating", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if change := <-feed; len(change.Trace) != 55 || strings.Contains(change.Trace, "-00000000000000000000000000000000-") {
+		t.Errorf("Expected a fresh trace for an invalid header, got %q", change.Trace)
+	}
+}
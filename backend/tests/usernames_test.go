@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"leaderboard-backend/services"
+)
+
+func defaultUsernameConfig() services.UsernamePolicyConfig {
+	return services.UsernamePolicyConfig{MinLength: 3, MaxLength: 24, Pattern: `^[A-Za-z0-9_.-]+$`}
+}
+
+func TestUsernamePolicy_Blocklist(t *testing.T) {
+	blocklistPath := filepath.Join(t.TempDir(), "blocklist.txt")
+	os.WriteFile(blocklistPath, []byte("# one word per line\nrudeword\n\n"), 0644)
+
+	cfg := defaultUsernameConfig()
+	cfg.Blocklist = []string{"Badword"}
+	cfg.BlocklistFile = blocklistPath
+	cfg.Reserved = []string{"Referee"}
+	policy, err := services.NewUsernamePolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"xx_badword_xx": services.UsernameBlocked,
+		"B.a-d_W0rd":    services.UsernameBlocked, // separators and digit swaps don't hide it
+		"RUDEW0RD99":    services.UsernameBlocked, // from the file
+		"referee":       services.UsernameReserved,
+		"admin":         "", // a configured list replaces the built-in one
+		"good_player":   "",
+	}
+	for name, want := range cases {
+		err := policy.Check(name)
+		var usernameErr *services.UsernameError
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("Expected %q to be allowed, got %v", name, err)
+		case want != "" && (!errors.As(err, &usernameErr) || usernameErr.Code != want):
+			t.Errorf("Expected %q to be refused as %s, got %v", name, want, err)
+		}
+	}
+}
+
+func TestUsernamePolicy_InvalidConfig(t *testing.T) {
+	bad := []func(*services.UsernamePolicyConfig){
+		func(c *services.UsernamePolicyConfig) { c.MinLength = 0 },
+		func(c *services.UsernamePolicyConfig) { c.MaxLength = 2 },
+		func(c *services.UsernamePolicyConfig) { c.Pattern = "[" },
+		func(c *services.UsernamePolicyConfig) { c.BlocklistFile = filepath.Join(t.TempDir(), "missing.txt") },
+	}
+	for i, mutate := range bad {
+		cfg := defaultUsernameConfig()
+		mutate(&cfg)
+		if _, err := services.NewUsernamePolicy(cfg); err == nil {
+			t.Errorf("Case %d: expected %+v to be rejected", i, cfg)
+		}
+	}
+}
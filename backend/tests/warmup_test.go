@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"leaderboard-backend/handlers"
+	"leaderboard-backend/models"
+	"leaderboard-backend/services"
+	"leaderboard-backend/store"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStore_DeferredIndexes(t *testing.T) {
+	for _, impl := range []store.SkipListImpl{store.SkipListLocked, store.SkipListConcurrent, store.SkipListBTree} {
+		t.Run(string(impl), func(t *testing.T) {
+			ratingIndex := store.NewRatingBucketIndex()
+			memoryStore := store.NewMemoryStoreWithSkipList(ratingIndex, impl)
+			memoryStore.DeferIndexes()
+			if memoryStore.IndexProgress().Ready {
+				t.Fatal("Expected a deferred store not to be ready")
+			}
+
+			users := make([]*models.User, 100)
+			for i := range users {
+				users[i] = &models.User{ID: fmt.Sprintf("d%d", i), Username: fmt.Sprintf("deferred%d", i), Rating: 1000 + i}
+			}
+			memoryStore.LoadUsers(users, store.SourceImport)
+			// Changes made before the build, as a replayed log would
+			memoryStore.UpdateRating("d0", 4000)
+			memoryStore.DeleteUser("d99")
+			memoryStore.AddUser(&models.User{ID: "late", Username: "latecomer", Rating: 1050})
+
+			if top := memoryStore.GetTopUsers(10, 0); len(top) != 0 {
+				t.Errorf("Expected nobody listed before the build, got %d", len(top))
+			}
+			if user, err := memoryStore.GetUser("d0"); err != nil || user.Rating != 4000 {
+				t.Errorf("Expected lookups by ID to work before the build, got %+v (%v)", user, err)
+			}
+
+			memoryStore.BuildIndexes()
+			progress := memoryStore.IndexProgress()
+			if !progress.Ready || progress.Indexed != 100 || progress.Total != 100 {
+				t.Errorf("Expected 100 of 100 users indexed, got %+v", progress)
+			}
+			top := memoryStore.GetTopUsers(200, 0)
+			if len(top) != 100 || top[0].ID != "d0" || top[1].ID != "d98" {
+				t.Fatalf("Expected the full ranking led by d0 then d98, got %d users", len(top))
+			}
+			if found := memoryStore.SearchUsers("latecom"); len(found) != 1 {
+				t.Errorf("Expected the username index built, found %d", len(found))
+			}
+			if found := memoryStore.SearchUsers("deferred99"); len(found) != 0 {
+				t.Errorf("Expected the deleted user left out of the index, found %d", len(found))
+			}
+
+			// Changes after the build maintain the indexes as usual
+			memoryStore.UpdateRating("late", 4500)
+			if top := memoryStore.GetTopUsers(1, 0); top[0].ID != "late" {
+				t.Errorf("Expected late to lead after the build, got %s", top[0].ID)
+			}
+		})
+	}
+}
+
+func TestAPI_WarmupProbes(t *testing.T) {
+	ratingIndex := store.NewRatingBucketIndex()
+	memoryStore := store.NewMemoryStore(ratingIndex)
+	memoryStore.DeferIndexes()
+	for i := 0; i < 10; i++ {
+		memoryStore.AddUser(&models.User{ID: fmt.Sprintf("w%d", i), Username: fmt.Sprintf("warm%d", i), Rating: 1500})
+	}
+	warmup := services.NewWarmup(memoryStore)
+	ready := make(chan struct{})
+	warmup.OnReady(func() { close(ready) })
+
+	probes := handlers.NewProbeHandler(warmup)
+	router := mux.NewRouter()
+	router.HandleFunc("/healthz", probes.Healthz).Methods("GET")
+	router.HandleFunc("/readyz", probes.Readyz).Methods("GET")
+	router.HandleFunc("/api/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("GET")
+	handler := probes.Gate(router)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	if rr := get("/healthz"); rr.Code != http.StatusOK {
+		t.Errorf("Expected /healthz up before the build, got %d", rr.Code)
+	}
+	if rr := get("/readyz"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected /readyz 503 before the build, got %d", rr.Code)
+	}
+	rr := get("/api/leaderboard")
+	var errResp models.ErrorResponse
+	json.NewDecoder(rr.Body).Decode(&errResp)
+	if rr.Code != http.StatusServiceUnavailable || errResp.Error != "warming_up" || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 warming_up with Retry-After, got %d %+v", rr.Code, errResp)
+	}
+
+	warmup.Start()
+	select {
+	case <-ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Indexes were not built")
+	}
+
+	rr = get("/readyz")
+	var status models.ReadinessResponse
+	json.NewDecoder(rr.Body).Decode(&status)
+	if rr.Code != http.StatusOK || status.Status != "ready" || status.Indexed != 10 || status.Percent != 100 {
+		t.Errorf("Expected ready with 10 users indexed, got %d %+v", rr.Code, status)
+	}
+	if rr := get("/api/leaderboard"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected requests through once ready, got %d", rr.Code)
+	}
+	if top, _ := memoryStore.GetTopUsersContext(context.Background(), 5, 0); len(top) != 5 {
+		t.Errorf("Expected the ranking built, got %d users", len(top))
+	}
+}
@@ -0,0 +1,147 @@
+// Package tracing carries a request's trace identity from its headers into
+// logs and published events. It understands W3C traceparent and Google
+// Cloud's X-Cloud-Trace-Context without a full OpenTelemetry setup, so
+// traffic through tunnels and load balancers stays traceable.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TraceparentHeader is the W3C Trace Context header
+	TraceparentHeader = "traceparent"
+	// CloudTraceHeader is Google Cloud's "TRACE_ID/SPAN_ID;o=1" header
+	CloudTraceHeader = "X-Cloud-Trace-Context"
+)
+
+// Trace identifies one request within a distributed trace
+type Trace struct {
+	TraceID  string // 32 lowercase hex digits
+	ParentID string // the caller's span, "" when the trace starts here
+	SpanID   string // this request's span, 16 lowercase hex digits
+	Sampled  bool
+}
+
+// FromHeaders continues the trace named by traceparent or, failing that,
+// X-Cloud-Trace-Context, with a new span for this request. Without a
+// usable header a new sampled trace is started.
+func FromHeaders(h http.Header) Trace {
+	t, ok := parseTraceparent(h.Get(TraceparentHeader))
+	if !ok {
+		t, ok = parseCloudTrace(h.Get(CloudTraceHeader))
+	}
+	if !ok {
+		t = Trace{TraceID: randomHex(16), Sampled: true}
+	}
+	t.SpanID = randomHex(8)
+	return t
+}
+
+// Traceparent formats the trace for the W3C traceparent header, with this
+// request's span as the parent of whatever receives it
+func (t Trace) Traceparent() string {
+	flags := "00"
+	if t.Sampled {
+		flags = "01"
+	}
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + flags
+}
+
+// CloudTrace formats the trace for the X-Cloud-Trace-Context header
+func (t Trace) CloudTrace() string {
+	span, _ := strconv.ParseUint(t.SpanID, 16, 64)
+	sampled := 0
+	if t.Sampled {
+		sampled = 1
+	}
+	return fmt.Sprintf("%s/%d;o=%d", t.TraceID, span, sampled)
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying t
+func NewContext(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the trace ctx carries, if any
+func FromContext(ctx context.Context) (Trace, bool) {
+	t, ok := ctx.Value(contextKey{}).(Trace)
+	return t, ok
+}
+
+// TraceparentFrom returns the traceparent for ctx's trace, or "" if it has
+// none
+func TraceparentFrom(ctx context.Context) string {
+	if t, ok := FromContext(ctx); ok {
+		return t.Traceparent()
+	}
+	return ""
+}
+
+// parseTraceparent reads "00-<trace id>-<parent id>-<flags>". Later
+// versions may append fields, which are ignored.
+func parseTraceparent(value string) (Trace, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return Trace{}, false
+	}
+	if !isHexID(parts[0], 2) || !isHexID(parts[1], 32) || !isHexID(parts[2], 16) || !isHexID(parts[3], 2) {
+		return Trace{}, false
+	}
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	return Trace{TraceID: parts[1], ParentID: parts[2], Sampled: flags&1 == 1}, true
+}
+
+// parseCloudTrace reads "<trace id>/<decimal span id>;o=<0|1>"; the span
+// and options are optional
+func parseCloudTrace(value string) (Trace, bool) {
+	value = strings.TrimSpace(value)
+	options := ""
+	if i := strings.IndexByte(value, ';'); i >= 0 {
+		value, options = value[:i], value[i+1:]
+	}
+	traceID, spanID, _ := strings.Cut(value, "/")
+	traceID = strings.ToLower(traceID)
+	if !isHexID(traceID, 32) {
+		return Trace{}, false
+	}
+
+	t := Trace{TraceID: traceID, Sampled: options != "o=0"}
+	if span, err := strconv.ParseUint(spanID, 10, 64); err == nil && span != 0 {
+		t.ParentID = fmt.Sprintf("%016x", span)
+	}
+	return t, true
+}
+
+// isHexID reports whether s is n lowercase hex digits and, for trace and
+// span IDs, not all zero as the spec forbids
+func isHexID(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	nonZero := false
+	for _, c := range s {
+		switch {
+		case c >= '1' && c <= '9', c >= 'a' && c <= 'f':
+			nonZero = true
+		case c == '0':
+		default:
+			return false
+		}
+	}
+	return nonZero || n <= 2
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}